@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// connectAlias is a memorable name for a cluster, connected to with
+// `gke connect NAME`.
+type connectAlias struct {
+	Project  string `json:"project"`
+	Location string `json:"location,omitempty"`
+	Cluster  string `json:"cluster"`
+
+	// Profile is the network profile to connect with unless --profile is
+	// given.
+	Profile string `json:"profile,omitempty"`
+
+	// Endpoint is an alternate address for the control plane, such as an
+	// internal load balancer or a tunnel, used in the written kubeconfig
+	// like a profile's endpointOverrides.
+	Endpoint string `json:"endpoint,omitempty"`
+}
+
+// endpointOverride is the endpoint of the alias being connected to, if it
+// sets one.
+var endpointOverride string
+
+// aliasNames returns the aliases defined in the config file, sorted.
+func aliasNames() []string {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for name := range cfg.Aliases {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// connectToAlias connects to the cluster the alias name stands for,
+// without the picker, and returns it.
+func connectToAlias(ctx context.Context, name string) (GKEConfig, error) {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return GKEConfig{}, err
+	}
+	alias, ok := cfg.Aliases[name]
+	if !ok {
+		return GKEConfig{}, usageError{fmt.Errorf("unknown alias %q; define it under \"aliases\" in the config file", name)}
+	}
+	if activeProfile == "" {
+		activeProfile = alias.Profile
+	}
+	endpointOverride = alias.Endpoint
+
+	start := time.Now()
+	config, err := connectAliasCluster(ctx, alias)
+	ref := &clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	if config.Cluster == "" {
+		ref = nil
+	}
+	recordHistory(os.Args[1:], ref, start, err)
+	return config, err
+}
+
+func connectAliasCluster(ctx context.Context, alias connectAlias) (GKEConfig, error) {
+	config, cluster, err := resolveCluster(ctx, alias.Project, alias.Location, alias.Cluster)
+	if err != nil {
+		return GKEConfig{}, err
+	}
+	if config.Username, err = getUsername(ctx); err != nil {
+		return config, err
+	}
+	if printCommands {
+		script, err := connectScript(config, cluster)
+		fmt.Print(script)
+		return config, err
+	}
+	if warning, _ := busyWarning(ctx, config, cluster); warning != "" {
+		fmt.Printf("⚠️  %s.\n", warning)
+	}
+	if err := setClusterCredentials(ctx, config, cluster, nil); err != nil {
+		return config, err
+	}
+	printConnected(config)
+	return config, nil
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// anonymize is --anonymize: exports replace project, cluster and user names
+// with salted hashes.
+var anonymize bool
+
+// anonymizer hashes identifiers with a salt kept on this machine, so the
+// same name maps to the same hash in every document shared, but the names
+// can't be recovered or guessed by whoever reads them.
+type anonymizer struct {
+	salt []byte
+}
+
+func saltPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "anonymize-salt"), nil
+}
+
+// loadAnonymizer returns the anonymizer, creating the salt on first use, or
+// nil when --anonymize isn't set.
+func loadAnonymizer() (*anonymizer, error) {
+	if !anonymize {
+		return nil, nil
+	}
+	path, err := saltPath()
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err == nil {
+		salt, err := hex.DecodeString(strings.TrimSpace(string(data)))
+		if err != nil || len(salt) == 0 {
+			return nil, fmt.Errorf("invalid salt in %s", path)
+		}
+		return &anonymizer{salt: salt}, nil
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read salt: %v", err)
+	}
+
+	salt := make([]byte, 32)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return nil, err
+	}
+	if err := os.WriteFile(path, []byte(hex.EncodeToString(salt)+"\n"), 0o600); err != nil {
+		return nil, fmt.Errorf("failed to save salt: %v", err)
+	}
+	return &anonymizer{salt: salt}, nil
+}
+
+// hash returns a short stable hash of value, prefixed by kind so projects,
+// clusters and users stay distinguishable, e.g. "project-3f9a1c02d4".
+func (a *anonymizer) hash(kind, value string) string {
+	if a == nil || value == "" {
+		return value
+	}
+	mac := hmac.New(sha256.New, a.salt)
+	mac.Write([]byte(kind + "\x00" + value))
+	return kind + "-" + hex.EncodeToString(mac.Sum(nil))[:10]
+}
+
+func (a *anonymizer) project(id string) string   { return a.hash("project", id) }
+func (a *anonymizer) cluster(name string) string { return a.hash("cluster", name) }
+func (a *anonymizer) user(name string) string    { return a.hash("user", name) }
+
+// approval anonymizes how an audit record was approved. The approver a
+// webhook names is hashed as a user; approve file paths and reasons and
+// denial messages are free text that may name anything, so only what kind
+// of decision it was is kept.
+func (a *anonymizer) approval(via string) string {
+	if a == nil {
+		return via
+	}
+	switch {
+	case strings.HasPrefix(via, "denied: "):
+		return "denied"
+	case strings.HasPrefix(via, "file "):
+		return "file"
+	case strings.HasPrefix(via, "webhook (") && strings.HasSuffix(via, ")"):
+		return "webhook (" + a.user(via[len("webhook ("):len(via)-1]) + ")"
+	}
+	return via
+}
+
+// refs anonymizes a comma-separated list of project/location/cluster or
+// project/cluster references, or of projects/P/locations/L/clusters/C
+// resource names. Locations are kept.
+func (a *anonymizer) refs(s string) string {
+	if a == nil || s == "" {
+		return s
+	}
+	refs := strings.Split(s, ",")
+	for i, ref := range refs {
+		parts := strings.Split(ref, "/")
+		if len(parts) == 6 && parts[0] == "projects" {
+			parts[1], parts[5] = a.project(parts[1]), a.cluster(parts[5])
+			refs[i] = strings.Join(parts, "/")
+			continue
+		}
+		if len(parts) < 2 {
+			refs[i] = a.cluster(ref)
+			continue
+		}
+		parts[0] = a.project(parts[0])
+		parts[len(parts)-1] = a.cluster(parts[len(parts)-1])
+		refs[i] = strings.Join(parts, "/")
+	}
+	return strings.Join(refs, ",")
+}
@@ -0,0 +1,42 @@
+package main
+
+import (
+	"net/http"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// traceAPI logs every Google API request at debug level. It is set by
+// --debug, which also turns on debug logging.
+var traceAPI bool
+
+// tracingTransport logs the method, path, status and latency of each request
+// passing through it. Bodies and headers are never logged, as they carry
+// access tokens and cluster credentials; only their sizes are.
+type tracingTransport struct {
+	base http.RoundTripper
+}
+
+func (t tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	start := time.Now()
+	resp, err := t.base.RoundTrip(req)
+	attrs := []interface{}{
+		"method", req.Method,
+		"host", req.URL.Host,
+		"path", req.URL.Path,
+		"latency", time.Since(start).Round(time.Millisecond),
+		"requestBytes", req.ContentLength,
+	}
+	if err != nil {
+		slog.Debug("api request failed", append(attrs, "err", err)...)
+		return resp, err
+	}
+	attrs = append(attrs, "status", resp.StatusCode, "responseBytes", resp.ContentLength)
+	if resp.StatusCode >= 400 {
+		slog.Debug("api request returned an error", attrs...)
+	} else {
+		slog.Debug("api request", attrs...)
+	}
+	return resp, nil
+}
@@ -0,0 +1,222 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// approveFile and approveWebhook are --approve-file and --approve-webhook,
+// the non-interactive ways to consent to dangerous operations.
+var (
+	approveFile    string
+	approveWebhook string
+)
+
+// approvalTimeout bounds the wait for the approval webhook, which may wait
+// for a human.
+const approvalTimeout = 5 * time.Minute
+
+// approvalRequest describes an operation that needs consent. It is what the
+// webhook receives.
+type approvalRequest struct {
+	Action   string   `json:"action"`
+	Question string   `json:"question"`
+	Clusters []string `json:"clusters,omitempty"`
+	Changes  []string `json:"changes,omitempty"`
+	// Digest identifies exactly these changes to these clusters, so an
+	// approval can be limited to what was reviewed.
+	Digest string `json:"digest"`
+	User   string `json:"user,omitempty"`
+	Host   string `json:"host,omitempty"`
+}
+
+func newApproval(action, question string, clusters, changes []string) approvalRequest {
+	h := sha256.New()
+	fmt.Fprintf(h, "%s\n", action)
+	for _, cluster := range clusters {
+		fmt.Fprintf(h, "cluster %s\n", cluster)
+	}
+	for _, change := range changes {
+		fmt.Fprintf(h, "change %s\n", change)
+	}
+	return approvalRequest{
+		Action:   action,
+		Question: question,
+		Clusters: clusters,
+		Changes:  changes,
+		Digest:   hex.EncodeToString(h.Sum(nil))[:16],
+	}
+}
+
+// preapproval is an entry of the --approve-file. It approves operations of
+// its action, optionally only the one with its digest or only on its
+// clusters, until it expires.
+type preapproval struct {
+	Action   string    `json:"action"`
+	Digest   string    `json:"digest,omitempty"`
+	Clusters []string  `json:"clusters,omitempty"`
+	Expires  time.Time `json:"expires,omitempty"`
+	Reason   string    `json:"reason,omitempty"`
+}
+
+func (p preapproval) covers(req approvalRequest) bool {
+	if p.Action != req.Action && p.Action != "*" {
+		return false
+	}
+	if p.Digest != "" && p.Digest != req.Digest {
+		return false
+	}
+	if !p.Expires.IsZero() && time.Now().After(p.Expires) {
+		return false
+	}
+	if len(p.Clusters) == 0 {
+		return true
+	}
+	allowed := make(map[string]bool)
+	for _, cluster := range p.Clusters {
+		allowed[cluster] = true
+	}
+	for _, cluster := range req.Clusters {
+		if !allowed[cluster] {
+			return false
+		}
+	}
+	return true
+}
+
+// approvalResponse is the webhook's answer.
+type approvalResponse struct {
+	Approved bool   `json:"approved"`
+	Approver string `json:"approver,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+// approve gets consent for req: from --yes, the approve file, the approval
+// webhook or, when neither is given, a prompt. The decision is recorded in
+// the audit log. A refusal is errAborted.
+func approve(ctx context.Context, yes bool, req approvalRequest) error {
+	req.User, _ = getUsername(ctx)
+	req.Host, _ = os.Hostname()
+
+	via, err := decideApproval(ctx, yes, req)
+	record := auditRecord{
+		Time:     time.Now(),
+		User:     req.User,
+		Action:   req.Action,
+		Cluster:  strings.Join(req.Clusters, ","),
+		Digest:   req.Digest,
+		Approval: via,
+	}
+	if err != nil {
+		record.Approval = "denied: " + err.Error()
+	}
+	if aerr := appendAudit(record); aerr != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  %v\n", aerr)
+	}
+	return err
+}
+
+func decideApproval(ctx context.Context, yes bool, req approvalRequest) (string, error) {
+	if yes {
+		return "--yes", nil
+	}
+	if approveFile != "" {
+		entry, ok, err := findPreapproval(approveFile, req)
+		if err != nil {
+			return "", err
+		}
+		if ok {
+			via := "file " + approveFile
+			if entry.Reason != "" {
+				via += ": " + entry.Reason
+			}
+			fmt.Printf("✅ Approved by %s\n", via)
+			return via, nil
+		}
+		if approveWebhook == "" {
+			return "", fmt.Errorf("%s doesn't approve %s (digest %s): %w", approveFile, req.Action, req.Digest, errAborted)
+		}
+	}
+	if approveWebhook != "" {
+		return askWebhook(ctx, approveWebhook, req)
+	}
+	if !confirm(req.Question) {
+		return "", errAborted
+	}
+	return "prompt", nil
+}
+
+func findPreapproval(path string, req approvalRequest) (preapproval, bool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return preapproval{}, false, fmt.Errorf("failed to read approve file: %v", err)
+	}
+	var file struct {
+		Approvals []preapproval `json:"approvals"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return preapproval{}, false, fmt.Errorf("failed to parse %s: %v", filepath.Base(path), err)
+	}
+	for _, entry := range file.Approvals {
+		if entry.covers(req) {
+			return entry, true, nil
+		}
+	}
+	return preapproval{}, false, nil
+}
+
+// askWebhook posts req to url and waits for its decision. Set
+// GKE_APPROVAL_TOKEN to send it as a bearer token.
+func askWebhook(ctx context.Context, url string, req approvalRequest) (string, error) {
+	ctx, cancel := context.WithTimeout(ctx, approvalTimeout)
+	defer cancel()
+
+	body, err := json.Marshal(req)
+	if err != nil {
+		return "", err
+	}
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return "", fmt.Errorf("invalid approval webhook: %v", err)
+	}
+	httpReq.Header.Set("Content-Type", "application/json")
+	if token := os.Getenv("GKE_APPROVAL_TOKEN"); token != "" {
+		httpReq.Header.Set("Authorization", "Bearer "+token)
+	}
+
+	fmt.Printf("⏳ Waiting for approval of %s (digest %s)...\n", req.Action, req.Digest)
+	resp, err := http.DefaultClient.Do(httpReq)
+	if err != nil {
+		return "", fmt.Errorf("approval webhook failed: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return "", fmt.Errorf("approval webhook failed: %s", resp.Status)
+	}
+	var answer approvalResponse
+	if err := json.NewDecoder(resp.Body).Decode(&answer); err != nil {
+		return "", fmt.Errorf("approval webhook returned an invalid answer: %v", err)
+	}
+	if !answer.Approved {
+		if answer.Reason != "" {
+			return "", fmt.Errorf("approval denied: %s: %w", answer.Reason, errAborted)
+		}
+		return "", fmt.Errorf("approval denied: %w", errAborted)
+	}
+
+	via := "webhook"
+	if answer.Approver != "" {
+		via += " (" + answer.Approver + ")"
+	}
+	fmt.Printf("✅ Approved by %s\n", via)
+	return via, nil
+}
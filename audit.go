@@ -0,0 +1,124 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// auditRecord is one line of the audit log, which keeps a permanent trail of
+// changes that discard information, such as merged display names, and of
+// approvals of dangerous operations.
+type auditRecord struct {
+	Time    time.Time `json:"time"`
+	User    string    `json:"user,omitempty"`
+	Action  string    `json:"action"`
+	Cluster string    `json:"cluster,omitempty"`
+	CIDR    string    `json:"cidrBlock,omitempty"`
+	Kept    string    `json:"kept,omitempty"`
+	Aliases []string  `json:"aliases,omitempty"`
+
+	// Digest and Approval record consent to dangerous operations: which
+	// changes were approved and how.
+	Digest   string `json:"digest,omitempty"`
+	Approval string `json:"approval,omitempty"`
+}
+
+func auditPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "audit.log"), nil
+}
+
+// appendAudit adds records to the audit log, one JSON object per line. The
+// log is only ever appended to.
+func appendAudit(records ...auditRecord) error {
+	path, err := auditPath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create audit log directory: %v", err)
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	enc := json.NewEncoder(f)
+	for _, record := range records {
+		if err := enc.Encode(record); err != nil {
+			return fmt.Errorf("failed to write audit log: %v", err)
+		}
+	}
+	return nil
+}
+
+func newAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:         "audit",
+		Short:       "Work with the audit log",
+		Annotations: map[string]string{skipAuth: "true"},
+	}
+	cmd.AddCommand(&cobra.Command{
+		Use:         "export",
+		Short:       "Print the audit log as JSON lines, e.g. with --anonymize for sharing",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true", honorsAnonymize: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return exportAudit(os.Stdout)
+		},
+	})
+	return cmd
+}
+
+// exportAudit writes the audit log to w, anonymized with --anonymize.
+func exportAudit(w io.Writer) error {
+	an, err := loadAnonymizer()
+	if err != nil {
+		return err
+	}
+	path, err := auditPath()
+	if err != nil {
+		return err
+	}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open audit log: %v", err)
+	}
+	defer f.Close()
+
+	dec, enc := json.NewDecoder(f), json.NewEncoder(w)
+	for {
+		var record auditRecord
+		if err := dec.Decode(&record); err == io.EOF {
+			return nil
+		} else if err != nil {
+			return fmt.Errorf("failed to read audit log: %v", err)
+		}
+		if an != nil {
+			record.User = an.user(record.User)
+			record.Cluster = an.refs(record.Cluster)
+			// Display names usually contain user names.
+			record.Kept = an.user(record.Kept)
+			record.Approval = an.approval(record.Approval)
+			for i, alias := range record.Aliases {
+				record.Aliases[i] = an.user(alias)
+			}
+		}
+		if err := enc.Encode(record); err != nil {
+			return err
+		}
+	}
+}
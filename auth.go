@@ -0,0 +1,325 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/oauth2"
+	"golang.org/x/oauth2/google"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+const cloudPlatformScope = "https://www.googleapis.com/auth/cloud-platform"
+
+// hasGcloud reports whether the gcloud CLI is available on PATH. Without it
+// the tool falls back to Application Default Credentials for everything.
+func hasGcloud() bool {
+	_, err := exec.LookPath("gcloud")
+	return err == nil
+}
+
+// usesGcloudIdentity reports whether this run acts as gcloud's account:
+// gcloud is installed and either no credentials were given explicitly,
+// with --credentials or GOOGLE_APPLICATION_CREDENTIALS, or --account picked
+// a gcloud account anyway. Otherwise the display name, the API calls and
+// the kubeconfig all use Application Default Credentials, so a run never
+// mixes two identities.
+func usesGcloudIdentity() bool {
+	if !hasGcloud() {
+		return false
+	}
+	return sessionAccount != "" || os.Getenv("GOOGLE_APPLICATION_CREDENTIALS") == ""
+}
+
+// getUsername returns the display name used for this user's authorized
+// network entry, preferring the active gcloud account and falling back to
+// the identity behind Application Default Credentials.
+func getUsername(ctx context.Context) (string, error) {
+	if usesGcloudIdentity() {
+		if username, err := getGcloudUsername(); err == nil {
+			return username, nil
+		}
+	}
+
+	email, err := getADCEmail(ctx)
+	if err != nil {
+		return "", fmt.Errorf("failed to determine username: %v", err)
+	}
+	return usernameFromEmail(email), nil
+}
+
+func usernameFromEmail(email string) string {
+	username := strings.Split(email, "@")[0]
+	return strings.ReplaceAll(username, ".", "-")
+}
+
+// getADCEmail resolves the email of the identity behind Application Default
+// Credentials: the client_email of a service account key, the impersonated
+// account of an external account, the metadata server account on GCE, or
+// the tokeninfo email for user credentials.
+func getADCEmail(ctx context.Context) (string, error) {
+	creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+	if err != nil {
+		return "", fmt.Errorf("failed to find default credentials: %w", err)
+	}
+
+	if len(creds.JSON) == 0 {
+		if metadata.OnGCE() {
+			return metadata.Email("default")
+		}
+		return "", fmt.Errorf("default credentials carry no identity")
+	}
+
+	var file struct {
+		Type                           string `json:"type"`
+		ClientEmail                    string `json:"client_email"`
+		ServiceAccountImpersonationURL string `json:"service_account_impersonation_url"`
+	}
+	if err := json.Unmarshal(creds.JSON, &file); err != nil {
+		return "", fmt.Errorf("failed to parse credentials file: %v", err)
+	}
+
+	switch {
+	case file.ClientEmail != "":
+		return file.ClientEmail, nil
+	case file.ServiceAccountImpersonationURL != "":
+		// .../serviceAccounts/NAME@PROJECT.iam.gserviceaccount.com:generateAccessToken
+		target := file.ServiceAccountImpersonationURL
+		target = target[strings.LastIndex(target, "/")+1:]
+		return strings.TrimSuffix(target, ":generateAccessToken"), nil
+	}
+
+	token, err := creds.TokenSource.Token()
+	if err != nil {
+		return "", fmt.Errorf("failed to get access token: %w", err)
+	}
+	return tokenInfoEmail(ctx, token.AccessToken)
+}
+
+func tokenInfoEmail(ctx context.Context, accessToken string) (string, error) {
+	endpoint := "https://oauth2.googleapis.com/tokeninfo?access_token=" + url.QueryEscape(accessToken)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to query tokeninfo: %v", err)
+	}
+	defer resp.Body.Close()
+
+	var info struct {
+		Email string `json:"email"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&info); err != nil {
+		return "", fmt.Errorf("failed to read tokeninfo: %v", err)
+	}
+	if info.Email == "" {
+		return "", fmt.Errorf("credentials do not expose an email address")
+	}
+	return info.Email, nil
+}
+
+// sessionAccount is the gcloud account picked for this session, if any.
+// When set, API calls authenticate with that account's gcloud token
+// instead of Application Default Credentials.
+var sessionAccount string
+
+// gcloudAccount is one entry of `gcloud auth list`.
+type gcloudAccount struct {
+	Account string `json:"account"`
+	Status  string `json:"status"`
+}
+
+func listGcloudAccounts() ([]gcloudAccount, error) {
+	output, err := exec.Command("gcloud", "auth", "list", "--format=json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gcloud accounts: %v", err)
+	}
+
+	var accounts []gcloudAccount
+	if err := json.Unmarshal(output, &accounts); err != nil {
+		return nil, fmt.Errorf("failed to parse gcloud accounts: %v", err)
+	}
+	return accounts, nil
+}
+
+// useGcloudAccount makes account the identity for the rest of the session,
+// both for gcloud invocations and Google API clients.
+func useGcloudAccount(account string) {
+	sessionAccount = account
+	os.Setenv("CLOUDSDK_CORE_ACCOUNT", account)
+}
+
+// billingProject is the project API usage is attributed to, from
+// --billing-project or CLOUDSDK_BILLING_QUOTA_PROJECT.
+var billingProject string
+
+// clientOptions returns the options the client for the named Google API
+// ("container", "cloudresourcemanager", ...) is created with.
+func clientOptions(ctx context.Context, api string) ([]option.ClientOption, error) {
+	if offlineFor(ctx) {
+		return nil, errOffline
+	}
+	var opts []option.ClientOption
+	if billingProject != "" {
+		opts = append(opts, option.WithQuotaProject(billingProject))
+	}
+	if sessionAccount != "" {
+		ts := oauth2.ReuseTokenSource(nil, gcloudTokenSource{account: sessionAccount})
+		opts = append(opts, option.WithTokenSource(ts))
+	}
+	if endpoint := apiEndpoint(api); endpoint != "" {
+		opts = append(opts, option.WithEndpoint(endpoint))
+	}
+
+	base := apiBaseTransport()
+	if traceAPI {
+		if base == nil {
+			base = http.DefaultTransport
+		}
+		base = tracingTransport{base: base}
+	}
+	if base == nil {
+		return opts, nil
+	}
+	// A custom base transport means building the authenticated client
+	// ourselves; the endpoint option still applies on top of it.
+	transport, err := htransport.NewTransport(ctx, base, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create %s transport: %v", api, err)
+	}
+	return append(opts, option.WithHTTPClient(&http.Client{Transport: transport})), nil
+}
+
+// gcloudTokenSource mints access tokens for a specific gcloud account.
+type gcloudTokenSource struct {
+	account string
+}
+
+func (ts gcloudTokenSource) Token() (*oauth2.Token, error) {
+	output, err := exec.Command("gcloud", "auth", "print-access-token", ts.account).Output()
+	if err != nil {
+		// gcloud's message, such as a reauthentication prompt, is what
+		// tells an expired login apart.
+		var exitErr *exec.ExitError
+		if errors.As(err, &exitErr) && len(bytes.TrimSpace(exitErr.Stderr)) > 0 {
+			return nil, fmt.Errorf("failed to get access token for %s: %s: %w", ts.account, bytes.TrimSpace(exitErr.Stderr), err)
+		}
+		return nil, fmt.Errorf("failed to get access token for %s: %w", ts.account, err)
+	}
+	// gcloud caches tokens and doesn't report their expiry, so ask again
+	// well before the usual one hour lifetime runs out.
+	return &oauth2.Token{
+		AccessToken: strings.TrimSpace(string(output)),
+		TokenType:   "Bearer",
+		Expiry:      time.Now().Add(10 * time.Minute),
+	}, nil
+}
+
+// checkCredentials makes sure an access token can be obtained for the
+// session identity before any API call is attempted.
+func checkCredentials(ctx context.Context) error {
+	if sessionAccount != "" {
+		_, err := gcloudTokenSource{account: sessionAccount}.Token()
+		return err
+	}
+
+	creds, err := google.FindDefaultCredentials(ctx, cloudPlatformScope)
+	if err != nil {
+		return err
+	}
+	_, err = creds.TokenSource.Token()
+	return err
+}
+
+// isAuthError reports whether err means the credentials are missing,
+// expired or revoked, as opposed to a permission or network problem.
+func isAuthError(err error) bool {
+	if err == nil {
+		return false
+	}
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Code == http.StatusUnauthorized {
+		return true
+	}
+
+	msg := err.Error()
+	for _, marker := range []string{
+		"invalid_grant",
+		"invalid_rapt",
+		"Reauthentication",
+		"could not find default credentials",
+		"oauth2: cannot fetch token",
+		"token has been expired or revoked",
+	} {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// offerReauthentication explains an authentication failure and, when gcloud
+// is available, offers to run `gcloud auth login --update-adc` inline. It
+// reports whether the credentials work afterwards.
+func offerReauthentication(ctx context.Context, cause error) bool {
+	fmt.Printf("🔐 Your Google credentials are missing or have expired.\n   (%v)\n\n", cause)
+
+	if !usesGcloudIdentity() {
+		fmt.Printf("Refresh the key or ADC file referenced by GOOGLE_APPLICATION_CREDENTIALS and try again.\n")
+		return false
+	}
+
+	args := []string{"auth", "login", "--update-adc"}
+	if sessionAccount != "" {
+		args = append(args, sessionAccount)
+	}
+	if !canLaunchBrowser() {
+		args = append(args, "--no-launch-browser")
+	}
+	if !confirm(fmt.Sprintf("Run `gcloud %s` now?", strings.Join(args, " "))) {
+		return false
+	}
+
+	cmd := exec.Command("gcloud", args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	if err := cmd.Run(); err != nil {
+		fmt.Printf("❌ Re-authentication failed: %v\n", err)
+		return false
+	}
+
+	if err := checkCredentials(ctx); err != nil {
+		fmt.Printf("❌ Credentials still don't work: %v\n", err)
+		return false
+	}
+	fmt.Printf("✅ Re-authenticated\n\n")
+	return true
+}
+
+// canLaunchBrowser guesses whether gcloud can open a browser, falling back
+// to the device-code style flow over SSH and on headless machines.
+func canLaunchBrowser() bool {
+	if os.Getenv("SSH_CONNECTION") != "" || os.Getenv("CLOUD_SHELL") == "true" {
+		return false
+	}
+	if runtime.GOOS == "linux" {
+		return os.Getenv("DISPLAY") != "" || os.Getenv("WAYLAND_DISPLAY") != ""
+	}
+	return true
+}
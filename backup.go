@@ -0,0 +1,229 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// maxKubeconfigBackups is how many kubeconfig backups are kept; older ones
+// are deleted as new ones are made.
+const maxKubeconfigBackups = 10
+
+// kubeconfigBackup is a copy of a kubeconfig file taken before changing it.
+type kubeconfigBackup struct {
+	ID     string    `json:"id"`
+	Time   time.Time `json:"time"`
+	Source string    `json:"source"`
+}
+
+func backupDir() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "kubeconfig-backups"), nil
+}
+
+// kubeconfigFile returns the file that credentials for config are written
+// to: the per-cluster file, else the first existing KUBECONFIG entry, else
+// ~/.kube/config.
+func kubeconfigFile(config GKEConfig) string {
+	if path := kubeconfigPath(config); path != "" {
+		return path
+	}
+	return sharedKubeconfigFile()
+}
+
+// sharedKubeconfigFile returns the first existing KUBECONFIG entry, else
+// ~/.kube/config.
+func sharedKubeconfigFile() string {
+	if env := os.Getenv("KUBECONFIG"); env != "" {
+		paths := filepath.SplitList(env)
+		for _, path := range paths {
+			if _, err := os.Stat(path); err == nil {
+				return path
+			}
+		}
+		return paths[0]
+	}
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(home, ".kube", "config")
+}
+
+// backedUp remembers the files already backed up by this process, so a
+// batch of writes to one file makes a single backup.
+var backedUp sync.Map
+
+// backupKubeconfig copies path into the backup directory once per process.
+// A file that doesn't exist yet needs no backup.
+func backupKubeconfig(path string) error {
+	if path == "" {
+		return nil
+	}
+	if _, done := backedUp.LoadOrStore(path, true); done {
+		return nil
+	}
+	_, err := saveKubeconfigBackup(path)
+	return err
+}
+
+func saveKubeconfigBackup(path string) (*kubeconfigBackup, error) {
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %v", path, err)
+	}
+	dir, err := backupDir()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o700); err != nil {
+		return nil, fmt.Errorf("failed to create backup directory: %v", err)
+	}
+
+	now := time.Now()
+	backup := kubeconfigBackup{
+		ID:     now.Format("20060102-150405.000"),
+		Time:   now,
+		Source: path,
+	}
+	if err := os.WriteFile(filepath.Join(dir, backup.ID), data, 0o600); err != nil {
+		return nil, fmt.Errorf("failed to back up %s: %v", path, err)
+	}
+
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, _ := loadState()
+	st.KubeconfigBackups = append(st.KubeconfigBackups, backup)
+	for len(st.KubeconfigBackups) > maxKubeconfigBackups {
+		os.Remove(filepath.Join(dir, st.KubeconfigBackups[0].ID))
+		st.KubeconfigBackups = st.KubeconfigBackups[1:]
+	}
+	if err := st.save(); err != nil {
+		return nil, err
+	}
+	return &backup, nil
+}
+
+func newKubeconfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "kubeconfig",
+		Short: "Prune stale contexts and restore kubeconfig backups",
+		Long: fmt.Sprintf(`Before credentials are written or contexts pruned, the kubeconfig file
+about to change is copied to my-gke/kubeconfig-backups under your user
+config directory. The last %d backups are kept.`, maxKubeconfigBackups),
+		Annotations: map[string]string{skipAuth: "true"},
+	}
+
+	backups := &cobra.Command{
+		Use:         "backups",
+		Short:       "List the kubeconfig backups, newest first",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runKubeconfigBackups()
+		},
+	}
+
+	var yes bool
+	restore := &cobra.Command{
+		Use:         "restore [ID]",
+		Short:       "Put a kubeconfig backup back in place (the newest by default)",
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			id := ""
+			if len(args) == 1 {
+				id = args[0]
+			}
+			return runKubeconfigRestore(cmd.Context(), id, yes)
+		}),
+	}
+	restore.Flags().BoolVar(&yes, "yes", false, "restore without asking for confirmation")
+
+	cmd.AddCommand(backups, restore, newKubeconfigPruneCmd())
+	return cmd
+}
+
+func runKubeconfigBackups() error {
+	st, _ := loadState()
+	if len(st.KubeconfigBackups) == 0 {
+		fmt.Println("No kubeconfig backups yet")
+		return nil
+	}
+	w := tabwriter.NewWriter(os.Stdout, 0, 4, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tTAKEN\tFILE")
+	for i := len(st.KubeconfigBackups) - 1; i >= 0; i-- {
+		backup := st.KubeconfigBackups[i]
+		fmt.Fprintf(w, "%s\t%s\t%s\n", backup.ID, formatRelative(backup.Time), backup.Source)
+	}
+	return w.Flush()
+}
+
+func runKubeconfigRestore(ctx context.Context, id string, yes bool) error {
+	st, _ := loadState()
+	var backup *kubeconfigBackup
+	for i := range st.KubeconfigBackups {
+		if id == "" || st.KubeconfigBackups[i].ID == id {
+			backup = &st.KubeconfigBackups[i]
+		}
+	}
+	if backup == nil {
+		if id == "" {
+			return fmt.Errorf("no kubeconfig backups yet")
+		}
+		return fmt.Errorf("no kubeconfig backup %s; see gke kubeconfig backups", id)
+	}
+
+	dir, err := backupDir()
+	if err != nil {
+		return err
+	}
+	src, err := os.Open(filepath.Join(dir, backup.ID))
+	if err != nil {
+		return fmt.Errorf("failed to open backup %s: %v", backup.ID, err)
+	}
+	defer src.Close()
+
+	question := fmt.Sprintf("Replace %s with the backup from %s?", backup.Source, formatTime(backup.Time))
+	if err := approve(ctx, yes, newApproval("kubeconfig restore", question, nil, []string{backup.Source + " <- " + backup.ID})); err != nil {
+		return err
+	}
+
+	// The current file is backed up as well, so a restore can be undone.
+	if current, err := saveKubeconfigBackup(backup.Source); err != nil {
+		return err
+	} else if current != nil {
+		fmt.Printf("💾 Saved the current %s as backup %s\n", backup.Source, current.ID)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(backup.Source), 0o700); err != nil {
+		return err
+	}
+	dst, err := os.OpenFile(backup.Source, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0o600)
+	if err != nil {
+		return fmt.Errorf("failed to write %s: %v", backup.Source, err)
+	}
+	if _, err := io.Copy(dst, src); err != nil {
+		dst.Close()
+		return fmt.Errorf("failed to write %s: %v", backup.Source, err)
+	}
+	if err := dst.Close(); err != nil {
+		return fmt.Errorf("failed to write %s: %v", backup.Source, err)
+	}
+	fmt.Printf("✨ Restored %s from backup %s\n", backup.Source, backup.ID)
+	return nil
+}
@@ -0,0 +1,243 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// gcloudPool bounds how many clusters are worked on at once and serializes
+// kubeconfig writes. Parallel get-credentials runs race on the kubeconfig
+// file and fail with lock errors, so those go through one at a time while
+// the slower authorized-network updates run concurrently.
+type gcloudPool struct {
+	slots      chan struct{}
+	kubeconfig sync.Mutex
+}
+
+func newGcloudPool(concurrency int) *gcloudPool {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+	return &gcloudPool{slots: make(chan struct{}, concurrency)}
+}
+
+// do runs fn once a slot is free.
+func (p *gcloudPool) do(fn func() error) error {
+	p.slots <- struct{}{}
+	defer func() { <-p.slots }()
+	return fn()
+}
+
+// writeCredentials writes kubeconfig for one cluster, retrying when another
+// process holds the kubeconfig lock.
+func (p *gcloudPool) writeCredentials(config GKEConfig, cluster *container.Cluster) error {
+	p.kubeconfig.Lock()
+	defer p.kubeconfig.Unlock()
+
+	var err error
+	for attempt := 0; attempt < 3; attempt++ {
+		if err = writeCredentials(config, cluster); err == nil || !isLockError(err) {
+			return err
+		}
+		time.Sleep(time.Duration(attempt+1) * time.Second)
+	}
+	return err
+}
+
+// isLockError reports whether err is kubeconfig lock contention. kubectl's
+// client-go takes the lock by creating KUBECONFIG.lock exclusively, failing
+// with "open /home/me/.kube/config.lock: file exists" ("The file exists."
+// on Windows) while another process holds it.
+func isLockError(err error) bool {
+	msg := strings.ToLower(err.Error())
+	return strings.Contains(msg, ".lock: ") && strings.Contains(msg, "file exists")
+}
+
+type batchOptions struct {
+	project     string
+	location    string
+	clusters    string
+	concurrency int
+}
+
+func newBatchCmd() *cobra.Command {
+	var opts batchOptions
+	cmd := &cobra.Command{
+		Use:   "batch",
+		Short: "Connect to many clusters of a project at once",
+		Long: `Adds your IP to the authorized networks of every matching cluster in a
+project and writes kubectl credentials for each, several at a time.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runBatch(cmd.Context(), opts)
+		}),
+	}
+	cmd.Flags().StringVar(&opts.project, "project", "", "project ID or glob (defaults to the gcloud project)")
+	cmd.Flags().StringVar(&opts.location, "location", "", "only clusters in this location")
+	cmd.Flags().StringVar(&opts.clusters, "clusters", "", "comma-separated cluster names (default: all clusters)")
+	cmd.Flags().IntVar(&opts.concurrency, "concurrency", 4, "clusters to work on at once")
+	addPrintCommandsFlag(cmd)
+	return cmd
+}
+
+// runBatch connects to many clusters of a project in one go.
+func runBatch(ctx context.Context, opts batchOptions) error {
+	projectID := opts.project
+	if projectID == "" {
+		projectID = defaultProject()
+	}
+	if projectID == "" {
+		return fmt.Errorf("no project given; pass --project")
+	}
+	projectID, err := resolveProject(ctx, projectID)
+	if err != nil {
+		return err
+	}
+
+	clusters, err := getClusters(ctx, projectID)
+	if err != nil {
+		return err
+	}
+	clusters = filterClusters(clusters, opts.location, opts.clusters)
+	if len(clusters) == 0 {
+		return fmt.Errorf("no matching clusters in project %s", projectID)
+	}
+
+	username, err := getUsername(ctx)
+	if err != nil {
+		return err
+	}
+
+	if printCommands {
+		for i, cluster := range clusters {
+			config := GKEConfig{ProjectID: projectID, Region: cluster.Location, Cluster: cluster.Name, Username: username}
+			script, err := connectScript(config, cluster)
+			if err != nil {
+				return fmt.Errorf("%s: %w", cluster.Name, err)
+			}
+			if i > 0 {
+				fmt.Println()
+			}
+			fmt.Print(script)
+		}
+		return nil
+	}
+
+	if careful && !usesGcloudIdentity() {
+		for _, cluster := range clusters {
+			config := GKEConfig{ProjectID: projectID, Region: cluster.Location, Cluster: cluster.Name}
+			fmt.Println(nativeKubeconfigPreview(config, cluster))
+		}
+		if !confirm(fmt.Sprintf("Write %s to your kubeconfig?", pluralize(len(clusters), "entry"))) {
+			return errAborted
+		}
+		fmt.Println()
+	}
+
+	fmt.Printf("🔑 Connecting %s in %s (%d at a time)...\n\n", pluralize(len(clusters), "cluster"), projectID, opts.concurrency)
+
+	pool := newGcloudPool(opts.concurrency)
+	var (
+		wg     sync.WaitGroup
+		mu     sync.Mutex
+		done   int
+		failed []string
+		errs   []error
+		files  []string
+	)
+	for _, cluster := range clusters {
+		cluster := cluster
+		config := GKEConfig{
+			ProjectID: projectID,
+			Region:    cluster.Location,
+			Cluster:   cluster.Name,
+			Username:  username,
+		}
+
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			err := pool.do(func() error {
+				if gke.HasAuthorizedNetworks(cluster) {
+					if err := updateAuthorizedNetworks(ctx, config, cluster, nil); err != nil {
+						return fmt.Errorf("failed to update authorized networks: %w", err)
+					}
+				}
+				return pool.writeCredentials(config, cluster)
+			})
+
+			mu.Lock()
+			defer mu.Unlock()
+			done++
+			if err != nil {
+				failed = append(failed, cluster.Name)
+				errs = append(errs, err)
+				fmt.Printf("[%d/%d] ❌ %s (%s): %v\n", done, len(clusters), cluster.Name, cluster.Location, err)
+				return
+			}
+			fmt.Printf("[%d/%d] ✅ %s (%s)\n", done, len(clusters), cluster.Name, cluster.Location)
+			if path := kubeconfigPath(config); path != "" {
+				files = append(files, path)
+			}
+		}()
+	}
+	wg.Wait()
+
+	fmt.Println()
+	if len(failed) > 0 {
+		summary := fmt.Sprintf("%d of %s failed: %s", len(failed), pluralize(len(clusters), "cluster"), strings.Join(failed, ", "))
+		// When every cluster failed alike, e.g. for lack of permission, the
+		// exit code tells why.
+		if len(failed) == len(clusters) && sameExitCode(errs) {
+			return fmt.Errorf("%s: %w", summary, errs[0])
+		}
+		return errors.New(summary)
+	}
+	fmt.Printf("✨ Configured credentials for %s\n", pluralize(len(clusters), "cluster"))
+	if len(files) > 0 {
+		sort.Strings(files)
+		fmt.Printf("📄 To use them:\n   export KUBECONFIG=%s\n", strings.Join(files, string(os.PathListSeparator)))
+	}
+	return nil
+}
+
+// sameExitCode reports whether errs all map to the same exit code.
+func sameExitCode(errs []error) bool {
+	for _, err := range errs {
+		if exitCode(err) != exitCode(errs[0]) {
+			return false
+		}
+	}
+	return true
+}
+
+func filterClusters(clusters []*container.Cluster, location, names string) []*container.Cluster {
+	wanted := make(map[string]bool)
+	for _, name := range strings.Split(names, ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			wanted[name] = true
+		}
+	}
+
+	var filtered []*container.Cluster
+	for _, cluster := range clusters {
+		if location != "" && cluster.Location != location {
+			continue
+		}
+		if len(wanted) > 0 && !wanted[cluster.Name] {
+			continue
+		}
+		filtered = append(filtered, cluster)
+	}
+	return filtered
+}
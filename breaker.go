@@ -0,0 +1,146 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// A project whose cluster listing fails projectFailureThreshold times in a
+// row, with an error that retrying won't fix, is skipped by bulk scans for
+// projectCooldown. Every further failure doubles the cooldown, up to
+// maxProjectCooldown. A successful listing resets it.
+const (
+	projectFailureThreshold = 2
+	projectCooldown         = time.Hour
+	maxProjectCooldown      = 24 * time.Hour
+)
+
+// scanConcurrency is how many projects a bulk scan lists at once.
+const scanConcurrency = 8
+
+// projectFailure is the remembered failure streak of a project.
+type projectFailure struct {
+	Error    string    `json:"error"`
+	Failures int       `json:"failures"`
+	Until    time.Time `json:"until,omitempty"`
+}
+
+// persistentFailure reports whether err is one that retrying soon won't
+// fix, such as a revoked permission or a disabled API.
+func persistentFailure(err error) bool {
+	var apiErr *googleapi.Error
+	if !errors.As(err, &apiErr) {
+		return false
+	}
+	switch apiErr.Code {
+	case http.StatusBadRequest, http.StatusForbidden, http.StatusNotFound:
+		return true
+	}
+	return false
+}
+
+// recordProjectResult updates the failure streak of projectID after a
+// listing. Transient errors leave it as it is.
+func recordProjectResult(projectID string, err error) {
+	if err != nil && !persistentFailure(err) {
+		return
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, _ := loadState()
+
+	if err == nil {
+		if _, ok := st.ProjectFailures[projectID]; !ok {
+			return
+		}
+		delete(st.ProjectFailures, projectID)
+		st.save()
+		return
+	}
+
+	f := st.ProjectFailures[projectID]
+	f.Failures++
+	f.Error = err.Error()
+	var apiErr *googleapi.Error
+	if errors.As(err, &apiErr) && apiErr.Message != "" {
+		f.Error = apiErr.Message
+	}
+	if f.Failures >= projectFailureThreshold {
+		cooldown := maxProjectCooldown
+		if shift := f.Failures - projectFailureThreshold; shift < 5 {
+			if d := projectCooldown << shift; d < cooldown {
+				cooldown = d
+			}
+		}
+		f.Until = time.Now().Add(cooldown)
+	}
+	if st.ProjectFailures == nil {
+		st.ProjectFailures = make(map[string]projectFailure)
+	}
+	st.ProjectFailures[projectID] = f
+	st.save()
+}
+
+// projectSkipped reports whether bulk scans should skip projectID for now.
+func (st *state) projectSkipped(projectID string) (projectFailure, bool) {
+	f, ok := st.ProjectFailures[projectID]
+	return f, ok && time.Now().Before(f.Until)
+}
+
+// projectClusters are the clusters found in one project.
+type projectClusters struct {
+	projectID string
+	clusters  []*container.Cluster
+}
+
+// scanProjects lists the clusters of every accessible project, a few at a
+// time. Projects in their cooldown are skipped unless retry is set. Failing
+// projects are reported on stderr and don't fail the scan.
+func scanProjects(ctx context.Context, retry bool) ([]projectClusters, error) {
+	projects, err := getProjects(ctx)
+	if err != nil {
+		return nil, err
+	}
+	st, _ := loadState()
+
+	results := make([]projectClusters, len(projects))
+	slots := make(chan struct{}, scanConcurrency)
+	var wg sync.WaitGroup
+	for i, projectID := range projects {
+		if f, skipped := st.projectSkipped(projectID); skipped && !retry {
+			fmt.Fprintf(os.Stderr, "⏭️  Skipping %s until %s after %s: %s\n",
+				projectID, formatTime(f.Until), pluralize(f.Failures, "failure"), f.Error)
+			continue
+		}
+		wg.Add(1)
+		go func(i int, projectID string) {
+			defer wg.Done()
+			slots <- struct{}{}
+			defer func() { <-slots }()
+
+			clusters, err := getClusters(ctx, projectID)
+			if err != nil {
+				fmt.Fprintf(os.Stderr, "⚠️  %s: %v\n", projectID, err)
+				return
+			}
+			results[i] = projectClusters{projectID: projectID, clusters: clusters}
+		}(i, projectID)
+	}
+	wg.Wait()
+
+	var scanned []projectClusters
+	for _, result := range results {
+		if result.projectID != "" {
+			scanned = append(scanned, result)
+		}
+	}
+	return scanned, nil
+}
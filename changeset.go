@@ -0,0 +1,230 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+// changeSet is a MAN change applied to several clusters as one unit.
+type changeSet struct {
+	Clusters []clusterRef `json:"clusters"`
+	Entries  []man.Entry  `json:"entries"`
+
+	// Prune makes Entries the complete allow-list of every cluster instead
+	// of upserting them.
+	Prune bool `json:"prune,omitempty"`
+}
+
+// plannedChange is one cluster's part of a change set.
+type plannedChange struct {
+	ref     clusterRef
+	config  GKEConfig
+	cluster *container.Cluster
+	plan    *man.Plan
+
+	// running is the update left unfinished when applying failed.
+	running *container.Operation
+}
+
+func newManChangeSetCmd() *cobra.Command {
+	var apply applyOptions
+	cmd := &cobra.Command{
+		Use:   "changeset",
+		Short: "Apply a MAN change to several clusters, all or nothing",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runManChangeSet(cmd.Context(), apply)
+		}),
+	}
+	apply.addFlags(cmd, "JSON change set to apply")
+	return cmd
+}
+
+// runManChangeSet plans a change set on every cluster first and only then
+// applies it. If any cluster fails, the change is undone on the clusters
+// already changed, and on the failed one if its update got started, so the
+// workspace is never left half-updated.
+func runManChangeSet(ctx context.Context, apply applyOptions) error {
+	if apply.file == "" {
+		return fmt.Errorf("no change set given; pass --file")
+	}
+	data, err := os.ReadFile(apply.file)
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", apply.file, err)
+	}
+	var set changeSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return fmt.Errorf("failed to parse %s: %v", apply.file, err)
+	}
+	if len(set.Clusters) == 0 {
+		return fmt.Errorf("%s lists no clusters", apply.file)
+	}
+
+	changes, err := planChangeSet(ctx, set)
+	if err != nil {
+		return err
+	}
+
+	var pending []*plannedChange
+	for _, change := range changes {
+		if change.plan.Empty() {
+			fmt.Printf("✅ %s already up to date\n\n", change.ref)
+			continue
+		}
+		fmt.Printf("Changes to %s:\n\n", change.ref)
+		printPlan(change.plan)
+		if change.plan.Limit.Exceeded {
+			return fmt.Errorf("%s would have %d entries, more than the limit of %d",
+				change.ref, change.plan.Limit.After, change.plan.Limit.Max)
+		}
+		pending = append(pending, change)
+	}
+	if len(pending) == 0 || apply.dryRun {
+		return nil
+	}
+	for _, change := range pending {
+		if err := checkOwnership(ctx, change.config, change.cluster); err != nil {
+			return err
+		}
+	}
+	var refs, lines []string
+	for _, change := range pending {
+		refs = append(refs, change.ref.String())
+		for _, line := range planChanges(change.plan) {
+			lines = append(lines, change.ref.String()+": "+line)
+		}
+	}
+	req := newApproval("man changeset", fmt.Sprintf("Apply these changes to %s?", pluralize(len(pending), "cluster")), refs, lines)
+	if err := approve(ctx, apply.yes, req); err != nil {
+		return err
+	}
+
+	// Every hook gets its say before any cluster changes, so a veto can't
+	// leave the change set half applied.
+	for _, change := range pending {
+		if err := runPreChangeHooks(ctx, change.config, "apply"); err != nil {
+			return err
+		}
+	}
+	for i, change := range pending {
+		fmt.Printf("[%d/%d] 📡 Updating %s...\n", i+1, len(pending), change.ref)
+		err := setAuthorizedNetworks(ctx, change.config, change.cluster, change.plan.Result, nil)
+		if err != nil {
+			fmt.Printf("[%d/%d] ❌ %s: %v\n\n", i+1, len(pending), change.ref, err)
+			changed := pending[:i]
+			var unfinished *gke.UnfinishedError
+			if errors.As(err, &unfinished) {
+				change.running = unfinished.Operation
+				changed = pending[:i+1]
+			}
+			return rollbackChangeSet(ctx, changed, change.ref, err)
+		}
+	}
+
+	fmt.Printf("\n✨ Applied the change set to %s\n", pluralize(len(pending), "cluster"))
+	return nil
+}
+
+// planChangeSet resolves and plans every cluster. Nothing is applied if any
+// of them cannot be planned.
+func planChangeSet(ctx context.Context, set changeSet) ([]*plannedChange, error) {
+	planner := man.NewPlanner(man.Policy{Prune: set.Prune})
+
+	var changes []*plannedChange
+	for _, ref := range set.Clusters {
+		config, cluster, err := resolveCluster(ctx, ref.Project, ref.Location, ref.Cluster)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", ref, err)
+		}
+		if !gke.HasAuthorizedNetworks(cluster) {
+			return nil, fmt.Errorf("%s: authorized networks are not enabled", ref)
+		}
+		changes = append(changes, &plannedChange{
+			ref:     clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster},
+			config:  config,
+			cluster: cluster,
+			plan:    planner.Plan(gke.AuthorizedEntries(cluster), set.Entries),
+		})
+	}
+	return changes, nil
+}
+
+// rollbackChangeSet undoes the change on every cluster it may have been
+// applied to, most recent first, and reports the final state of the
+// workspace.
+func rollbackChangeSet(ctx context.Context, applied []*plannedChange, failedRef clusterRef, cause error) error {
+	if len(applied) == 0 {
+		return fmt.Errorf("%s failed, no clusters were changed: %v", failedRef, cause)
+	}
+
+	fmt.Printf("↩️  Rolling back %s...\n", pluralize(len(applied), "cluster"))
+	var stuck []string
+	for i := len(applied) - 1; i >= 0; i-- {
+		change := applied[i]
+		reverted, err := revertChange(ctx, change)
+		switch {
+		case err != nil:
+			stuck = append(stuck, change.ref.String())
+			fmt.Printf("  ❌ %s: %v\n", change.ref, err)
+		case reverted:
+			fmt.Printf("  ✅ %s restored\n", change.ref)
+		default:
+			fmt.Printf("  ✅ %s unchanged\n", change.ref)
+		}
+	}
+	fmt.Println()
+
+	if len(stuck) > 0 {
+		return fmt.Errorf("%s failed and %d clusters could not be rolled back, they still have the new allow-list: %v",
+			failedRef, len(stuck), stuck)
+	}
+	return fmt.Errorf("%s failed, all clusters were rolled back: %v", failedRef, cause)
+}
+
+// revertChange undoes change's own adds, updates and removals on the
+// cluster's live allow-list, keeping anything else changed meanwhile. An
+// update left running is waited for first. The pre-change hooks don't run:
+// they agreed to the change set, and undoing it mustn't be vetoed. It
+// reports whether the cluster had to be changed back.
+func revertChange(ctx context.Context, change *plannedChange) (bool, error) {
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return false, err
+	}
+	if change.running != nil {
+		err := gke.WaitForOperation(ctx, api, change.config.target(), change.running, nil)
+		if err != nil && !errors.Is(err, gke.ErrOperationFailed) {
+			return false, fmt.Errorf("its update is still running: %w", err)
+		}
+	}
+	live, err := getCluster(ctx, change.config)
+	if err != nil {
+		return false, fmt.Errorf("failed to re-read cluster: %w", err)
+	}
+	current := gke.AuthorizedEntries(live)
+	reverted := change.plan.Revert(current)
+	if sameEntries(reverted, current) {
+		return false, nil
+	}
+	return true, setAuthorizedNetworks(ctx, change.config, live, reverted, nil)
+}
+
+func sameEntries(a, b []man.Entry) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+type cleanupOptions struct {
+	project string
+	pinned  bool
+	yes     bool
+	dryRun  bool
+}
+
+func newCleanupCmd() *cobra.Command {
+	var opts cleanupOptions
+	cmd := &cobra.Command{
+		Use:   "cleanup",
+		Short: "Remove your authorized network entries from clusters",
+		Long: `Removes every authorized network entry named after you, including the
+profile-tagged ones, from the clusters of a project or from the pinned
+clusters. Entries of other users are left alone.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runCleanup(cmd.Context(), opts)
+		}),
+	}
+	cmd.Flags().StringVar(&opts.project, "project", "", "project ID or glob (defaults to the gcloud project)")
+	cmd.Flags().BoolVar(&opts.pinned, "pinned", false, "clean up the pinned clusters instead of a project")
+	cmd.Flags().BoolVar(&opts.yes, "yes", false, "remove without asking for confirmation")
+	cmd.Flags().BoolVar(&opts.dryRun, "dry-run", false, "only show what would be removed")
+	return cmd
+}
+
+// cleanupTarget is a cluster with entries to remove.
+type cleanupTarget struct {
+	config    GKEConfig
+	cluster   *container.Cluster
+	remaining []man.Entry
+}
+
+func runCleanup(ctx context.Context, opts cleanupOptions) error {
+	username, err := getUsername(ctx)
+	if err != nil {
+		return err
+	}
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	refs, err := cleanupRefs(ctx, opts)
+	if err != nil {
+		return err
+	}
+
+	var targets []cleanupTarget
+	for _, ref := range refs {
+		config, cluster, err := resolveCluster(ctx, ref.Project, ref.Location, ref.Cluster)
+		if err != nil {
+			return fmt.Errorf("%s: %w", ref, err)
+		}
+		if !gke.HasAuthorizedNetworks(cluster) {
+			continue
+		}
+		target := cleanupTarget{config: config, cluster: cluster}
+		var removed []man.Entry
+		for _, entry := range gke.AuthorizedEntries(cluster) {
+			if isMyEntry(entry, username, cfg.Profiles) {
+				removed = append(removed, entry)
+			} else {
+				target.remaining = append(target.remaining, entry)
+			}
+		}
+		if len(removed) == 0 {
+			continue
+		}
+		fmt.Printf("%s:\n", config.Cluster)
+		for _, entry := range removed {
+			fmt.Printf("  - %-30s %s\n", entry.DisplayName, entry.CIDR)
+		}
+		fmt.Println()
+		targets = append(targets, target)
+	}
+
+	if len(targets) == 0 {
+		fmt.Printf("✅ No entries of %s found\n", username)
+		return nil
+	}
+	if opts.dryRun {
+		return nil
+	}
+	for _, target := range targets {
+		if err := checkOwnership(ctx, target.config, target.cluster); err != nil {
+			return err
+		}
+	}
+	var names []string
+	for _, target := range targets {
+		names = append(names, clusterRef{Project: target.config.ProjectID, Location: target.config.Region, Cluster: target.config.Cluster}.String())
+	}
+	req := newApproval("cleanup", fmt.Sprintf("Remove these entries from %s?", pluralize(len(targets), "cluster")), names, []string{"- " + username})
+	if err := approve(ctx, opts.yes, req); err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, target := range targets {
+		err := applyAuthorizedNetworks(ctx, target.config, target.cluster, target.remaining, nil)
+		if err == nil {
+			err = forgetGrants(target.config)
+		}
+		if err != nil {
+			failed++
+			fmt.Printf("❌ %s: %v\n", target.config.Cluster, err)
+			continue
+		}
+		fmt.Printf("🧹 %s cleaned up\n", target.config.Cluster)
+	}
+	if failed > 0 {
+		return fmt.Errorf("%d of %s failed", failed, pluralize(len(targets), "cluster"))
+	}
+	return nil
+}
+
+// cleanupRefs lists the clusters to clean up.
+func cleanupRefs(ctx context.Context, opts cleanupOptions) ([]clusterRef, error) {
+	if opts.pinned {
+		cfg, err := loadUserConfig()
+		if err != nil {
+			return nil, err
+		}
+		if len(cfg.Pinned) == 0 {
+			return nil, fmt.Errorf("no pinned clusters in the config file")
+		}
+		return cfg.Pinned, nil
+	}
+
+	project := opts.project
+	if project == "" {
+		project = defaultProject()
+	}
+	if project == "" {
+		return nil, fmt.Errorf("no project given; pass --project or --pinned")
+	}
+	projectID, err := resolveProject(ctx, project)
+	if err != nil {
+		return nil, err
+	}
+	clusters, err := getClusters(ctx, projectID)
+	if err != nil {
+		return nil, err
+	}
+	var refs []clusterRef
+	for _, cluster := range clusters {
+		refs = append(refs, clusterRef{Project: projectID, Location: cluster.Location, Cluster: cluster.Name})
+	}
+	return refs, nil
+}
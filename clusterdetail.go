@@ -0,0 +1,203 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+var (
+	detailStyle      = lipgloss.NewStyle().Border(lipgloss.RoundedBorder()).Padding(0, 1)
+	detailTitleStyle = lipgloss.NewStyle().Bold(true)
+)
+
+// detailedEntries is how many authorized network entries the detail pane
+// names.
+const detailedEntries = 3
+
+// clusterDetails renders what is worth knowing about cluster before
+// connecting to it. upgrades are its control-plane upgrades, if known,
+// posture its Security Posture findings, if known, and cost its estimated
+// monthly cost, if known.
+func clusterDetails(cluster *container.Cluster, upgrades clusterUpgrades, posture *postureCounts, cost string) string {
+	var b strings.Builder
+	b.WriteString(detailTitleStyle.Render(cluster.Name) + "\n\n")
+	row := func(label, value string) {
+		fmt.Fprintf(&b, "%-17s %s\n", label, value)
+	}
+
+	row("Version", cluster.CurrentMasterVersion)
+	if upgrades.Available != "" {
+		row("Upgrade", upgradeStyle.Render(upgrades.Available+" available"))
+	}
+	row("Nodes", fmt.Sprint(cluster.CurrentNodeCount))
+	if cost != "" {
+		row("Cost", cost)
+	}
+	locationType := "regional"
+	if strings.Count(cluster.Location, "-") == 2 {
+		locationType = "zonal"
+	}
+	row("Location", cluster.Location+" ("+locationType+")")
+	if cluster.Endpoint != "" {
+		row("Endpoint", cluster.Endpoint)
+	}
+	if pcc := cluster.PrivateClusterConfig; pcc != nil && pcc.PrivateEndpoint != "" && pcc.PrivateEndpoint != cluster.Endpoint {
+		row("Private endpoint", pcc.PrivateEndpoint)
+	}
+	networkDetails(cluster, row)
+	autopilot := "no"
+	if cluster.Autopilot != nil && cluster.Autopilot.Enabled {
+		autopilot = "yes"
+	}
+	row("Autopilot", autopilot)
+	securityDetails(cluster, row)
+	if posture != nil {
+		row("Findings", posture.String())
+	}
+	row("Release channel", channelName(gke.ReleaseChannel(cluster)))
+	row("Maintenance", maintenanceWindowLabel(cluster))
+	for _, e := range gke.MaintenanceExclusions(cluster) {
+		if e.End.After(time.Now()) {
+			fmt.Fprintf(&b, "  %s until %s\n", e.Name, e.End.Local().Format("Mon 2 Jan 15:04"))
+		}
+	}
+	if upgrades.Auto != "" {
+		row("Auto-upgrade", upgradeStyle.Render("control plane to "+upgrades.Auto+" in a coming window"))
+	}
+	for _, u := range gke.ScheduledUpgrades(cluster) {
+		row("Auto-upgrade", upgradeStyle.Render(u.NodePool+" from "+u.Start.Local().Format("Mon 2 Jan 15:04")))
+		if u.Description != "" {
+			fmt.Fprintf(&b, "  %s\n", u.Description)
+		}
+	}
+	row("Notifications", notificationsLabel(cluster))
+
+	if !gke.HasAuthorizedNetworks(cluster) {
+		row("Authorized nets", "disabled")
+		return strings.TrimSuffix(b.String(), "\n")
+	}
+	entries := gke.AuthorizedEntries(cluster)
+	row("Authorized nets", pluralize(len(entries), "entry"))
+	for i, entry := range entries {
+		if i == detailedEntries {
+			fmt.Fprintf(&b, "  ... and %d more\n", len(entries)-detailedEntries)
+			break
+		}
+		fmt.Fprintf(&b, "  %s %s\n", entry.CIDR, entry.DisplayName)
+	}
+	return strings.TrimSuffix(b.String(), "\n")
+}
+
+// notificationsLabel describes where cluster sends its upgrade and security
+// bulletin notifications.
+func notificationsLabel(cluster *container.Cluster) string {
+	topic, events := gke.Notifications(cluster)
+	if topic == "" {
+		return "off"
+	}
+	label := lastSegment(topic, topic)
+	if len(events) == 0 {
+		return label + " (all events)"
+	}
+	var names []string
+	for _, event := range events {
+		names = append(names, strings.ToLower(strings.ReplaceAll(strings.TrimSuffix(event, "_EVENT"), "_", " ")))
+	}
+	return label + " (" + strings.Join(names, ", ") + ")"
+}
+
+// networkDetails adds cluster's VPC network, subnetwork, pod and service
+// ranges, and dataplane to the detail pane, naming the host project when
+// the network is a Shared VPC one.
+func networkDetails(cluster *container.Cluster, row func(label, value string)) {
+	network, subnetwork := cluster.Network, cluster.Subnetwork
+	if nc := cluster.NetworkConfig; nc != nil {
+		network, subnetwork = lastSegment(nc.Network, network), lastSegment(nc.Subnetwork, subnetwork)
+	}
+	if network != "" {
+		row("Network", network)
+	}
+	if subnetwork != "" {
+		row("Subnetwork", subnetwork)
+	}
+	pods, services := cluster.ClusterIpv4Cidr, cluster.ServicesIpv4Cidr
+	if ip := cluster.IpAllocationPolicy; ip != nil {
+		if ip.ClusterIpv4CidrBlock != "" {
+			pods = ip.ClusterIpv4CidrBlock
+		}
+		if ip.ServicesIpv4CidrBlock != "" {
+			services = ip.ServicesIpv4CidrBlock
+		}
+	}
+	if pods != "" {
+		row("Pod range", pods)
+	}
+	if services != "" {
+		row("Service range", services)
+	}
+	dataplane := "no"
+	if nc := cluster.NetworkConfig; nc != nil && nc.DatapathProvider == "ADVANCED_DATAPATH" {
+		dataplane = "yes"
+	}
+	row("Dataplane V2", dataplane)
+	if host := sharedVPCHost(cluster); host != "" {
+		row("Shared VPC", "service project of "+host)
+	}
+}
+
+// sharedVPCHost returns the project whose VPC network cluster uses when
+// that isn't the cluster's own project, as for Shared VPC service
+// projects.
+func sharedVPCHost(cluster *container.Cluster) string {
+	if cluster.NetworkConfig == nil {
+		return ""
+	}
+	host := linkProject(cluster.NetworkConfig.Network)
+	if own := linkProject(cluster.SelfLink); host == "" || own == "" || host == own {
+		return ""
+	}
+	return host
+}
+
+// linkProject returns the project of a resource name or link such as
+// "projects/p/global/networks/n".
+func linkProject(link string) string {
+	_, rest, ok := strings.Cut(link, "projects/")
+	if !ok {
+		return ""
+	}
+	project, _, _ := strings.Cut(rest, "/")
+	return project
+}
+
+// lastSegment returns the last path segment of a resource name, or def
+// when name is empty.
+func lastSegment(name, def string) string {
+	if name == "" {
+		return def
+	}
+	return name[strings.LastIndex(name, "/")+1:]
+}
+
+// withDetails puts the detail pane of the highlighted cluster next to
+// list, or below it when the terminal is too narrow.
+func (m *model) withDetails(list string) string {
+	if m.step != "cluster" || m.cursor >= len(m.clusters) || m.clusterRemoved(m.cursor) {
+		return list
+	}
+	cluster := m.clusters[m.cursor]
+	var posture *postureCounts
+	if counts, ok := m.posture[clusterKey(cluster)]; ok {
+		posture = &counts
+	}
+	pane := detailStyle.Render(clusterDetails(cluster, m.upgrades[clusterKey(cluster)], posture, m.costs[clusterKey(cluster)]))
+	if m.width > 0 && lipgloss.Width(list)+2+lipgloss.Width(pane) <= m.width {
+		return lipgloss.JoinHorizontal(lipgloss.Top, list, "  ", pane)
+	}
+	return list + "\n" + pane
+}
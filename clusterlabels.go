@@ -0,0 +1,66 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"google.golang.org/api/container/v1"
+)
+
+// clusterLabelFlag is --label.
+var clusterLabelFlag string
+
+// clusterSelector returns the resource labels --label requires of clusters.
+func clusterSelector() (map[string]string, error) {
+	return parseLabelSelector(clusterLabelFlag)
+}
+
+// matchesLabels reports whether cluster has the labels of selector; an
+// empty value only requires the label to be set.
+func matchesLabels(cluster *container.Cluster, selector map[string]string) bool {
+	for key, value := range selector {
+		actual, ok := cluster.ResourceLabels[key]
+		if !ok || (value != "" && actual != value) {
+			return false
+		}
+	}
+	return true
+}
+
+// formatLabelSelector renders selector the way --label takes it.
+func formatLabelSelector(selector map[string]string) string {
+	var terms []string
+	for key, value := range selector {
+		if value != "" {
+			key += "=" + value
+		}
+		terms = append(terms, key)
+	}
+	sort.Strings(terms)
+	return strings.Join(terms, ",")
+}
+
+// editLabels handles a key typed into the picker's label filter.
+func (m *model) editLabels(msg tea.KeyMsg) {
+	switch msg.Type {
+	case tea.KeyEnter:
+		selector, err := parseLabelSelector(m.labelInput)
+		if err != nil {
+			m.labelErr = err
+			return
+		}
+		m.editingLabels, m.labelErr = false, nil
+		m.labelFilter = selector
+		m.showClusters(m.allClusters)
+	case tea.KeyEsc, tea.KeyCtrlC:
+		m.editingLabels, m.labelErr = false, nil
+		m.labelInput = formatLabelSelector(m.labelFilter)
+	case tea.KeyBackspace:
+		if len(m.labelInput) > 0 {
+			m.labelInput = m.labelInput[:len(m.labelInput)-1]
+		}
+	case tea.KeyRunes:
+		m.labelInput += string(msg.Runes)
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// sortFlag is --sort.
+var sortFlag string
+
+// clusterSorts are the orders cluster lists can be sorted in, in the order
+// the picker's s key cycles through them. An empty key keeps the API's
+// order.
+var clusterSorts = []string{"", "name", "location", "version", "nodes", "created"}
+
+// sortNames lists the sort keys for help texts.
+const sortNames = "name, location, version, nodes or created"
+
+// clusterSort returns the sort key from --sort, or else the config file.
+func clusterSort() (string, error) {
+	key := sortFlag
+	if key == "" {
+		cfg, _ := loadUserConfig()
+		key = cfg.Sort
+	}
+	key = strings.ToLower(strings.TrimSpace(key))
+	for _, known := range clusterSorts {
+		if key == known {
+			return key, nil
+		}
+	}
+	return "", usageError{fmt.Errorf("unknown sort order %q; choose from %s", key, sortNames)}
+}
+
+// nextSort is the sort key after key in the picker's cycle.
+func nextSort(key string) string {
+	for i, known := range clusterSorts {
+		if known == key {
+			return clusterSorts[(i+1)%len(clusterSorts)]
+		}
+	}
+	return clusterSorts[0]
+}
+
+// sortClusters sorts clusters by key: names and locations alphabetically,
+// versions and node counts highest first, creation times newest first.
+// Ties keep their order.
+func sortClusters(clusters []*container.Cluster, key string) {
+	var less func(a, b *container.Cluster) bool
+	switch key {
+	case "name":
+		less = func(a, b *container.Cluster) bool { return a.Name < b.Name }
+	case "location":
+		less = func(a, b *container.Cluster) bool { return a.Location < b.Location }
+	case "version":
+		less = func(a, b *container.Cluster) bool {
+			return gke.CompareVersions(a.CurrentMasterVersion, b.CurrentMasterVersion) > 0
+		}
+	case "nodes":
+		less = func(a, b *container.Cluster) bool { return a.CurrentNodeCount > b.CurrentNodeCount }
+	case "created":
+		// RFC 3339 times in UTC sort alphabetically.
+		less = func(a, b *container.Cluster) bool { return a.CreateTime > b.CreateTime }
+	default:
+		return
+	}
+	sort.SliceStable(clusters, func(i, j int) bool { return less(clusters[i], clusters[j]) })
+}
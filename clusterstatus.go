@@ -0,0 +1,76 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+var (
+	// inactiveStyle de-emphasizes clusters that aren't running.
+	inactiveStyle = lipgloss.NewStyle().Faint(true)
+	// badgeStyle renders the cluster type badges of the picker.
+	badgeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+)
+
+// clusterRunning reports whether cluster is RUNNING, the only status in
+// which connecting is expected to go smoothly.
+func clusterRunning(cluster *container.Cluster) bool {
+	return cluster.Status == "RUNNING" || cluster.Status == ""
+}
+
+// statusWarning explains what cluster's status means for connecting to it,
+// or returns "" when it is running.
+func statusWarning(cluster *container.Cluster) string {
+	var what string
+	switch cluster.Status {
+	case "RUNNING", "":
+		return ""
+	case "RECONCILING":
+		what = "GKE is repairing, upgrading or resizing it, and its control plane may be unreachable for a while"
+	case "PROVISIONING":
+		what = "it is still being created"
+	case "STOPPING":
+		what = "it is being deleted"
+	case "ERROR", "DEGRADED":
+		what = "its control plane may not respond"
+	default:
+		what = "connecting may fail"
+	}
+	return fmt.Sprintf("%s is %s: %s", cluster.Name, cluster.Status, what)
+}
+
+// busyWarning is statusWarning plus the operations running on the cluster,
+// predicting that updating its authorized networks will wait for them. It
+// also reports whether the update is expected to queue.
+func busyWarning(ctx context.Context, config GKEConfig, cluster *container.Cluster) (string, bool) {
+	warning := statusWarning(cluster)
+	var ops []*container.Operation
+	if api, err := clusterAPI(ctx); err == nil {
+		if ops, err = gke.RunningOperations(ctx, api, config.target()); err != nil {
+			slog.Debug("failed to list operations", "cluster", config.target().Name(), "err", err)
+		}
+	}
+	if len(ops) == 0 && cluster.Status != "RECONCILING" {
+		return warning, false
+	}
+
+	if len(ops) > 0 {
+		op := ops[0]
+		running := fmt.Sprintf("a %s operation", op.OperationType)
+		if started, err := time.Parse(time.RFC3339, op.StartTime); err == nil {
+			running += " started " + formatRelative(started)
+		}
+		if warning == "" {
+			warning = fmt.Sprintf("%s is busy: %s is running", cluster.Name, running)
+		} else {
+			warning += fmt.Sprintf(" (%s is running)", running)
+		}
+	}
+	return warning + ". GKE runs one operation at a time, so the authorized networks update will queue behind it and connecting may take until it finishes", true
+}
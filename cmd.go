@@ -0,0 +1,203 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// globalOptions are the persistent flags that only matter during setup.
+type globalOptions struct {
+	credentials             string
+	configuration           string
+	account                 string
+	containerEndpoint       string
+	resourceManagerEndpoint string
+	logging                 logOptions
+}
+
+// globalFlags are the persistent flags of the root command, kept so that a
+// run can be repeated with the same flags.
+var globalFlags *pflag.FlagSet
+
+// skipAuth marks commands that must work without valid credentials.
+const skipAuth = "skip-auth"
+
+// honorsAnonymize marks the commands that anonymize their output with
+// --anonymize. The others refuse the flag rather than print real names.
+const honorsAnonymize = "honors-anonymize"
+
+func newRootCmd() *cobra.Command {
+	var opts globalOptions
+	var connect connectOptions
+
+	root := &cobra.Command{
+		Use:   "gke",
+		Short: "Connect to GKE clusters, keeping your IP in their authorized networks",
+		Long: `gke picks a project and cluster interactively, adds your public IP to the
+cluster's authorized networks and configures kubectl for it. Running it
+without a command is the same as "gke connect".`,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			// Completion runs on every tab press and must stay silent.
+			if cmd.Name() == cobra.ShellCompRequestCmd || cmd.Name() == cobra.ShellCompNoDescRequestCmd {
+				return nil
+			}
+			if anonymize && cmd.Annotations[honorsAnonymize] == "" {
+				return usageError{fmt.Errorf("%s doesn't support --anonymize", cmd.CommandPath())}
+			}
+			return setup(cmd.Context(), opts, cmd.Annotations[skipAuth] == "")
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runConnect(cmd.Context(), connect)
+		},
+	}
+	root.CompletionOptions.DisableDefaultCmd = true
+	root.SetFlagErrorFunc(func(cmd *cobra.Command, err error) error {
+		return usageError{err}
+	})
+	connect.addFlags(root)
+
+	pf := root.PersistentFlags()
+	pf.StringVar(&opts.credentials, "credentials", "", "path to a service account key or ADC file (defaults to GOOGLE_APPLICATION_CREDENTIALS)")
+	pf.StringVar(&opts.configuration, "configuration", "", "gcloud named configuration to use for this session")
+	pf.StringVar(&opts.account, "account", "", "gcloud account to use for this session")
+	pf.StringVar(&billingProject, "billing-project", os.Getenv("CLOUDSDK_BILLING_QUOTA_PROJECT"), "project to bill API usage and quota to")
+	pf.StringVar(&apiVIP, "api-vip", "", "reach Google APIs through the private or restricted VIP (VPC Service Controls)")
+	pf.StringVar(&caBundle, "ca-bundle", os.Getenv("CLOUDSDK_CORE_CUSTOM_CA_CERTS_FILE"), "PEM file of extra root CAs for the public IP lookup")
+	pf.StringVar(&projectLabels, "project-labels", "", "only list projects with these labels, e.g. team=payments,env")
+	pf.StringVar(&projectFolder, "folder", "", "only list projects directly in this folder ID")
+	pf.StringVar(&projectOrganization, "organization", "", "only list projects directly in this organization ID")
+	pf.StringVar(&regionFlag, "region", "", "only list clusters in these regions or zones, e.g. europe-west1,europe-west4-a")
+	pf.StringVar(&sortFlag, "sort", "", "sort cluster lists by "+sortNames)
+	pf.StringVar(&clusterLabelFlag, "label", "", "only show clusters with these resource labels, e.g. env=prod,team")
+	pf.BoolVar(&careful, "careful", false, "preview kubeconfig changes and ask before writing them")
+	pf.StringVarP(&namespaceFlag, "namespace", "n", "", "namespace to set on the written kubeconfig context")
+	pf.StringVar(&kubeconfigFlag, "kubeconfig", "", "kubeconfig file to write credentials to (overrides KUBECONFIG and kubeconfigDir)")
+	pf.StringVar(&ipSource, "ip-source", "", "public IP detection: auto, metadata, http or stun (overrides the config)")
+	pf.DurationVar(&accessFor, "for", 0, "remove your authorized network entry again after this long, e.g. 4h")
+	pf.StringVar(&approveFile, "approve-file", "", "JSON file of pre-approved dangerous operations, for scripts")
+	pf.StringVar(&approveWebhook, "approve-webhook", "", "URL to ask for approval of dangerous operations, for scripts")
+	pf.BoolVar(&allowManaged, "allow-managed", false, "change clusters managed by Terraform, Config Connector or a fleet without asking")
+	pf.StringVar(&activeProfile, "profile", "", "network profile from the config to use for the authorized network entry")
+	pf.StringVar(&opts.containerEndpoint, "container-endpoint", "", "override the GKE API endpoint")
+	pf.StringVar(&opts.resourceManagerEndpoint, "resourcemanager-endpoint", "", "override the Resource Manager API endpoint")
+	pf.BoolVar(&offlineFlag, "offline", false, "don't call Google Cloud: browse cached projects and clusters and switch between existing contexts")
+	pf.BoolVar(&anonymize, "anonymize", false, "replace project, cluster and user names in list and audit export output with salted hashes, for sharing")
+	opts.logging.addFlags(pf)
+	pf.BoolVar(&traceAPI, "debug", false, "log each Google API request with its path, status and latency (implies -v)")
+	globalFlags = pf
+
+	root.AddCommand(
+		newConnectCmd(),
+		newListCmd(),
+		newStatusCmd(),
+		newCleanupCmd(),
+		newDoctorCmd(),
+		newBatchCmd(),
+		newManCmd(),
+		newWatchCmd(),
+		newDaemonCmd(),
+		newServeCmd(),
+		newDebugCmd(),
+		newEphemeralCmd(),
+		newSwitchCmd(),
+		newLastCmd(),
+		newAuditCmd(),
+		newKubeconfigCmd(),
+		newNodePoolCmd(),
+		newHibernateCmd(),
+		newResumeCmd(),
+		newUpgradeCmd(),
+		newMaintenanceCmd(),
+		newFleetCmd(),
+		newFindingsCmd(),
+		newExportCmd(),
+		newMigrateHintsCmd(),
+		newCompletionCmd(),
+		newVersionCmd(),
+		newUpdateCmd(),
+	)
+	var register func(cmd *cobra.Command)
+	register = func(cmd *cobra.Command) {
+		registerCompletions(cmd)
+		for _, sub := range cmd.Commands() {
+			register(sub)
+		}
+	}
+	register(root)
+	return root
+}
+
+// setup configures logging, applies the global flags, checks the data
+// files and, unless checkAuth is false, makes sure the credentials work and
+// expires grants.
+func setup(ctx context.Context, opts globalOptions, checkAuth bool) error {
+	if traceAPI && opts.logging.verbose < 1 {
+		opts.logging.verbose = 1
+	}
+	if err := setupLogging(opts.logging); err != nil {
+		return err
+	}
+	if err := validateAPIVIP(apiVIP); err != nil {
+		return err
+	}
+	if _, err := clusterSelector(); err != nil {
+		return err
+	}
+	if _, err := clusterSort(); err != nil {
+		return err
+	}
+	setAPIEndpoint("container", opts.containerEndpoint)
+	setAPIEndpoint("cloudresourcemanager", opts.resourceManagerEndpoint)
+
+	if opts.credentials != "" {
+		os.Setenv("GOOGLE_APPLICATION_CREDENTIALS", opts.credentials)
+	}
+	if kubeconfigFlag != "" {
+		os.Setenv("KUBECONFIG", kubeconfigFlag)
+	}
+	if opts.configuration != "" {
+		configuration, err := findGcloudConfiguration(opts.configuration)
+		if err != nil {
+			return err
+		}
+		useGcloudConfiguration(configuration)
+	}
+	if opts.account != "" {
+		useGcloudAccount(opts.account)
+	}
+
+	checkDataFiles()
+	offline = offlineFlag
+	if !checkAuth || offline {
+		return nil
+	}
+
+	err := checkCredentials(ctx)
+	if goOffline(err) {
+		return nil
+	}
+	if isAuthError(err) && !offerReauthentication(ctx, err) {
+		return fmt.Errorf("not authenticated: %w", err)
+	}
+	if err := expireGrants(ctx, func(format string, args ...interface{}) { fmt.Printf(format, args...) }); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	return nil
+}
+
+// recorded wraps a command so every run ends up in the history.
+func recorded(run func(cmd *cobra.Command, args []string) error) func(cmd *cobra.Command, args []string) error {
+	return func(cmd *cobra.Command, args []string) error {
+		start := time.Now()
+		err := run(cmd, args)
+		recordHistory(os.Args[1:], nil, start, err)
+		return err
+	}
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// clusterColumn is a column of the cluster listing.
+type clusterColumn struct {
+	header string
+	value  func(info clusterInfo) string
+}
+
+var clusterColumns = map[string]clusterColumn{
+	"name":     {"NAME", func(info clusterInfo) string { return info.Name }},
+	"project":  {"PROJECT", func(info clusterInfo) string { return info.Project }},
+	"location": {"LOCATION", func(info clusterInfo) string { return info.Location }},
+	"region":   {"REGION", func(info clusterInfo) string { return clusterRegion(info.Location) }},
+	"version":  {"VERSION", func(info clusterInfo) string { return info.Version }},
+	"status":   {"STATUS", func(info clusterInfo) string { return info.Status }},
+	"type":     {"TYPE", func(info clusterInfo) string { return info.badge() }},
+	"channel":  {"CHANNEL", func(info clusterInfo) string { return channelName(info.ReleaseChannel) }},
+	"security": {"SECURITY", func(info clusterInfo) string { return info.Security.summary() }},
+	"upgrade": {"UPGRADE", func(info clusterInfo) string {
+		if info.AvailableUpgrade == "" {
+			return "-"
+		}
+		return info.AvailableUpgrade
+	}},
+	"insights": {"INSIGHTS", func(info clusterInfo) string {
+		if len(info.Insights) == 0 {
+			return "-"
+		}
+		return strings.Join(info.Insights, ", ")
+	}},
+	"man": {"AUTHORIZED NETWORKS", func(info clusterInfo) string {
+		if !info.AuthorizedNetworks {
+			return "disabled"
+		}
+		return pluralize(info.Entries, "entry")
+	}},
+	"authorized": {"YOUR IP", func(info clusterInfo) string {
+		switch {
+		case !info.AuthorizedNetworks:
+			return "-"
+		case info.Authorized == nil:
+			return "?"
+		case *info.Authorized:
+			return "yes"
+		}
+		return "no"
+	}},
+	"rtt": {"RTT", func(info clusterInfo) string {
+		if info.RTTMillis == 0 {
+			return "-"
+		}
+		rtt := formatRTT(time.Duration(info.RTTMillis) * time.Millisecond)
+		if info.Nearest {
+			rtt += " (nearest)"
+		}
+		return rtt
+	}},
+	"labels": {"LABELS", func(info clusterInfo) string {
+		var labels []string
+		for key, value := range info.Labels {
+			labels = append(labels, key+"="+value)
+		}
+		sort.Strings(labels)
+		return strings.Join(labels, ",")
+	}},
+}
+
+// columnNames lists the column names for help texts.
+const columnNames = "name, project, location, region, version, channel, upgrade, insights, status, type, security, man, authorized, rtt, labels"
+
+// defaultClusterColumns are shown when neither --columns nor the config
+// picks any.
+var defaultClusterColumns = []string{"name", "location", "status", "man", "authorized", "rtt"}
+
+// resolveColumns returns the columns named in flag, or else in the config,
+// or else the defaults.
+func resolveColumns(flag string) ([]string, error) {
+	var columns []string
+	if flag != "" {
+		columns = strings.Split(flag, ",")
+	} else if cfg, err := loadUserConfig(); err == nil && len(cfg.Columns) > 0 {
+		columns = cfg.Columns
+	} else {
+		return defaultClusterColumns, nil
+	}
+
+	for i, column := range columns {
+		columns[i] = strings.ToLower(strings.TrimSpace(column))
+		if _, ok := clusterColumns[columns[i]]; !ok {
+			return nil, fmt.Errorf("unknown column %q; choose from %s", column, columnNames)
+		}
+	}
+	return columns, nil
+}
+
+// badge sums up the kind of cluster in a few letters: AP for Autopilot or
+// STD for Standard, then pub or prv for a public or private-only control
+// plane endpoint.
+func (info clusterInfo) badge() string {
+	kind := "STD"
+	if info.Autopilot {
+		kind = "AP"
+	}
+	endpoint := "pub"
+	if info.PrivateEndpoint {
+		endpoint = "prv"
+	}
+	return kind + "·" + endpoint
+}
+
+// channelName renders a release channel such as "REGULAR" for people.
+func channelName(channel string) string {
+	if channel == "" {
+		return "none"
+	}
+	return strings.ToLower(channel)
+}
+
+// newClusterInfo describes cluster. mine is the user's CIDR, empty when
+// unknown, and latencies the measured region round-trip times.
+func newClusterInfo(projectID string, cluster *container.Cluster, mine string, latencies map[string]time.Duration) clusterInfo {
+	info := clusterInfo{
+		Name:            cluster.Name,
+		Project:         projectID,
+		Location:        cluster.Location,
+		Version:         cluster.CurrentMasterVersion,
+		Status:          cluster.Status,
+		Labels:          cluster.ResourceLabels,
+		ReleaseChannel:  gke.ReleaseChannel(cluster),
+		Autopilot:       cluster.Autopilot != nil && cluster.Autopilot.Enabled,
+		PrivateEndpoint: cluster.PrivateClusterConfig != nil && cluster.PrivateClusterConfig.EnablePrivateEndpoint,
+		Security:        securityOf(cluster),
+	}
+	if d, ok := latencies[clusterRegion(cluster.Location)]; ok {
+		info.RTTMillis = d.Milliseconds()
+		info.Nearest = clusterRegion(cluster.Location) == nearestRegion(latencies)
+	}
+	if gke.HasAuthorizedNetworks(cluster) {
+		info.AuthorizedNetworks = true
+		info.Entries = len(gke.AuthorizedBlocks(cluster))
+		if mine != "" {
+			authorized := false
+			for _, block := range gke.AuthorizedBlocks(cluster) {
+				authorized = authorized || block.CidrBlock == mine
+			}
+			info.Authorized = &authorized
+		}
+	}
+	return info
+}
+
+// writeClusterRow writes the columns of info, or their headers when info is
+// nil, as one tab-separated line.
+func writeClusterRow(w io.Writer, columns []string, info *clusterInfo) {
+	values := make([]string, len(columns))
+	for i, name := range columns {
+		if info == nil {
+			values[i] = clusterColumns[name].header
+		} else {
+			values[i] = clusterColumns[name].value(*info)
+		}
+	}
+	fmt.Fprintln(w, strings.Join(values, "\t"))
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"google.golang.org/api/container/v1"
+)
+
+func newCompletionCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "completion bash|zsh|fish|powershell",
+		Short: "Generate a shell completion script",
+		Long: `Prints a completion script for the given shell. Project IDs, locations and
+cluster names are completed from the ones seen by earlier runs, so
+completing never waits for the network.
+
+  bash:       source <(gke completion bash)
+  zsh:        gke completion zsh > "${fpath[1]}/_gke"
+  fish:       gke completion fish > ~/.config/fish/completions/gke.fish
+  powershell: gke completion powershell | Out-String | Invoke-Expression`,
+		Args:                  cobra.MatchAll(cobra.ExactArgs(1), cobra.OnlyValidArgs),
+		ValidArgs:             []string{"bash", "zsh", "fish", "powershell"},
+		DisableFlagsInUseLine: true,
+		Annotations:           map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			root := cmd.Root()
+			switch args[0] {
+			case "bash":
+				return root.GenBashCompletionV2(os.Stdout, true)
+			case "zsh":
+				return root.GenZshCompletion(os.Stdout)
+			case "fish":
+				return root.GenFishCompletion(os.Stdout, true)
+			case "powershell":
+				return root.GenPowerShellCompletionWithDesc(os.Stdout)
+			}
+			return fmt.Errorf("unsupported shell %q", args[0])
+		},
+	}
+}
+
+// cacheProjects remembers the projects of a listing for completion.
+func cacheProjects(projects []string) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, _ := loadState()
+	st.Projects = projects
+	st.save()
+}
+
+// cacheClusters replaces the remembered clusters of projectID.
+func cacheClusters(projectID string, clusters []*container.Cluster) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, _ := loadState()
+	kept := st.Clusters[:0]
+	for _, ref := range st.Clusters {
+		if ref.Project != projectID {
+			kept = append(kept, ref)
+		}
+	}
+	for _, cluster := range clusters {
+		kept = append(kept, clusterRef{Project: projectID, Location: cluster.Location, Cluster: cluster.Name})
+	}
+	st.Clusters = kept
+	st.save()
+}
+
+// registerCompletions completes the cmd's --project, --location, --cluster
+// and --clusters flags, whichever it has, from the cache.
+func registerCompletions(cmd *cobra.Command) {
+	complete := map[string]func(cmd *cobra.Command) []string{
+		"project":  func(*cobra.Command) []string { st, _ := loadState(); return st.Projects },
+		"location": cachedLocations,
+		"cluster":  cachedClusters,
+		"clusters": cachedClusters,
+	}
+	for name, candidates := range complete {
+		if cmd.Flags().Lookup(name) == nil {
+			continue
+		}
+		candidates, list := candidates, name == "clusters"
+		cmd.RegisterFlagCompletionFunc(name, func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			values := candidates(cmd)
+			if list {
+				values = completeList(values, toComplete)
+			}
+			return values, cobra.ShellCompDirectiveNoFileComp
+		})
+	}
+}
+
+// cachedMatches returns the cached clusters matching the --project and
+// --location flags given so far. An empty flag matches everything.
+func cachedMatches(cmd *cobra.Command) []clusterRef {
+	project, _ := cmd.Flags().GetString("project")
+	location, _ := cmd.Flags().GetString("location")
+	st, _ := loadState()
+	var matches []clusterRef
+	for _, ref := range st.Clusters {
+		if (project == "" || ref.Project == project) && (location == "" || ref.Location == location) {
+			matches = append(matches, ref)
+		}
+	}
+	return matches
+}
+
+func cachedClusters(cmd *cobra.Command) []string {
+	var names []string
+	for _, ref := range cachedMatches(cmd) {
+		names = append(names, ref.Cluster)
+	}
+	return unique(names)
+}
+
+func cachedLocations(cmd *cobra.Command) []string {
+	project, _ := cmd.Flags().GetString("project")
+	st, _ := loadState()
+	var locations []string
+	for _, ref := range st.Clusters {
+		if project == "" || ref.Project == project {
+			locations = append(locations, ref.Location)
+		}
+	}
+	return unique(locations)
+}
+
+// completeList completes the last element of a comma-separated list,
+// leaving out values already listed.
+func completeList(values []string, toComplete string) []string {
+	prefix := ""
+	if i := strings.LastIndex(toComplete, ","); i >= 0 {
+		prefix = toComplete[:i+1]
+	}
+	listed := make(map[string]bool)
+	for _, value := range strings.Split(prefix, ",") {
+		listed[value] = true
+	}
+	var completions []string
+	for _, value := range values {
+		if !listed[value] {
+			completions = append(completions, prefix+value)
+		}
+	}
+	return completions
+}
+
+func unique(values []string) []string {
+	sort.Strings(values)
+	var out []string
+	for i, value := range values {
+		if i == 0 || value != values[i-1] {
+			out = append(out, value)
+		}
+	}
+	return out
+}
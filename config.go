@@ -0,0 +1,153 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"gke-tool/pkg/gke"
+)
+
+// userConfig is the hand-edited configuration file.
+type userConfig struct {
+	// Pinned are the clusters background modes keep in sync.
+	Pinned []clusterRef `json:"pinned,omitempty"`
+
+	// Schedule lists the times at which the daemon pre-syncs pinned
+	// clusters, e.g. {"days": "Mon-Fri", "at": "08:30"}.
+	Schedule []scheduleEntry `json:"schedule,omitempty"`
+
+	Serve serveConfig `json:"serve,omitempty"`
+
+	// IPSource selects public IP detection: auto, metadata, http or stun.
+	IPSource string `json:"ipSource,omitempty"`
+
+	// STUNServer is the host:port used when IPSource is "stun".
+	STUNServer string `json:"stunServer,omitempty"`
+
+	// ProjectFilter narrows the project list, e.g. to a team's label or
+	// folder.
+	ProjectFilter gke.ProjectFilter `json:"projectFilter,omitempty"`
+
+	// ShowProjectLabels adds the projects' labels to the project picker.
+	ShowProjectLabels bool `json:"showProjectLabels,omitempty"`
+
+	// Profiles are named network locations, picked with --profile.
+	Profiles map[string]networkProfile `json:"profiles,omitempty"`
+
+	// DefaultProfile is used when --profile isn't given.
+	DefaultProfile string `json:"defaultProfile,omitempty"`
+
+	// DebugListen is the loopback address long-running modes serve pprof
+	// and expvar on. Empty disables them.
+	DebugListen string `json:"debugListen,omitempty"`
+
+	// KubeconfigDir, when set, makes every cluster's credentials go to a
+	// file of their own in this directory instead of the shared kubeconfig.
+	KubeconfigDir string `json:"kubeconfigDir,omitempty"`
+
+	// Namespaces are the namespaces written contexts start in, keyed by
+	// "project/location/cluster" or cluster name.
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+
+	// DuplicateEntries is what connecting does with further entries
+	// carrying your display name: keep (the default), consolidate or
+	// update-all.
+	DuplicateEntries string `json:"duplicateEntries,omitempty"`
+
+	// PickNamespace makes the picker offer the cluster's namespaces after
+	// connecting, like --pick-namespace.
+	PickNamespace bool `json:"pickNamespace,omitempty"`
+
+	// ContextTemplate, when set, names an alias of every context written,
+	// e.g. "{cluster}-{env}". See expandContextTemplate.
+	ContextTemplate string `json:"contextTemplate,omitempty"`
+
+	// PreChange are shell commands run before authorized networks are
+	// changed, except to remove your own entry again; any failing vetoes
+	// the change.
+	PreChange []string `json:"preChange,omitempty"`
+
+	// PostConnect are shell commands run after connecting interactively,
+	// with the cluster in MY_GKE_* environment variables.
+	PostConnect []string `json:"postConnect,omitempty"`
+
+	// Aliases are memorable names for clusters, connected to with
+	// `gke connect NAME`.
+	Aliases map[string]connectAlias `json:"aliases,omitempty"`
+
+	// Then is a command run against the new context after connecting
+	// interactively, like --then.
+	Then string `json:"then,omitempty"`
+
+	// Locations restricts cluster listings to these regions or zones, like
+	// --region.
+	Locations []string `json:"locations,omitempty"`
+
+	// Sort is the default order of cluster lists, like --sort.
+	Sort string `json:"sort,omitempty"`
+
+	// HideNotRunning leaves clusters that aren't RUNNING out of the picker
+	// instead of showing them dimmed.
+	HideNotRunning bool `json:"hideNotRunning,omitempty"`
+
+	// Production are label selectors, like --label, marking production
+	// clusters; matching any of them makes upgrades ask for the cluster name.
+	Production []string `json:"production,omitempty"`
+
+	// InsightTypes are the Recommender insight types whose insights flag
+	// clusters in listings; defaultInsightTypes when empty.
+	InsightTypes []string `json:"insightTypes,omitempty"`
+
+	// CacheTTL is how long the picker reuses project and cluster listings,
+	// e.g. "10m"; "0" turns the cache off.
+	CacheTTL string `json:"cacheTTL,omitempty"`
+
+	// Columns are the cluster listing columns shown by default, in order.
+	Columns []string `json:"columns,omitempty"`
+}
+
+// clusterRef identifies a cluster without fetching it.
+type clusterRef struct {
+	Project  string `json:"project"`
+	Location string `json:"location,omitempty"`
+	Cluster  string `json:"cluster"`
+}
+
+func (r clusterRef) String() string {
+	if r.Location == "" {
+		return r.Project + "/" + r.Cluster
+	}
+	return r.Project + "/" + r.Location + "/" + r.Cluster
+}
+
+func configPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "config.json"), nil
+}
+
+// loadUserConfig reads the config file. A missing file, or one ignored in
+// safe mode, yields the defaults.
+func loadUserConfig() (*userConfig, error) {
+	cfg := &userConfig{}
+	path, err := configPath()
+	if err != nil || safeMode["config"] {
+		return cfg, err
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return cfg, nil
+	}
+	if err != nil {
+		return cfg, fmt.Errorf("failed to read config: %v", err)
+	}
+	if err := json.Unmarshal(data, cfg); err != nil {
+		return &userConfig{}, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return cfg, nil
+}
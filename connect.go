@@ -0,0 +1,152 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+)
+
+// connectOptions are the flags of connect, which the root command shares.
+type connectOptions struct {
+	session bool
+	shell   bool
+	// then is a command to run once connected, e.g. "k9s".
+	then string
+	// alias names the cluster to connect to without the picker.
+	alias string
+}
+
+func (o *connectOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&o.session, "session", false, "open a subshell after connecting and revoke your authorized network entry when it exits")
+	cmd.Flags().BoolVar(&o.shell, "shell", false, "write the credentials to a private kubeconfig and open a subshell using it")
+	cmd.Flags().StringVar(&o.then, "then", "", "command to run against the new context once connected, e.g. k9s (instead of the subshell with --session or --shell)")
+	cmd.Flags().BoolVar(&pickNamespaceFlag, "pick-namespace", false, "choose one of the cluster's namespaces for the context after connecting")
+	addPrintCommandsFlag(cmd)
+}
+
+func newConnectCmd() *cobra.Command {
+	var opts connectOptions
+	cmd := &cobra.Command{
+		Use:   "connect [ALIAS]",
+		Short: "Pick a project and cluster interactively and connect to it",
+		Long: `Walks through the gcloud configuration, account, project and cluster,
+adds your public IP to the cluster's authorized networks and writes
+kubectl credentials for it. This is the default command. With ALIAS, an
+alias from the config file, its cluster is connected to without asking.`,
+		Args: cobra.MaximumNArgs(1),
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			if len(args) > 0 {
+				return nil, cobra.ShellCompDirectiveNoFileComp
+			}
+			return aliasNames(), cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 1 {
+				opts.alias = args[0]
+			}
+			return runConnect(cmd.Context(), opts)
+		},
+	}
+	opts.addFlags(cmd)
+	return cmd
+}
+
+// runConnect runs the interactive picker, or connects to the alias, then
+// re-runs a history entry or starts a session shell or the then command if
+// one was asked for. With shell, the credentials go to a private
+// kubeconfig that only the shell uses and that is shredded when it exits.
+func runConnect(ctx context.Context, opts connectOptions) error {
+	if opts.then == "" {
+		if cfg, err := loadUserConfig(); err == nil {
+			opts.then = cfg.Then
+		}
+	}
+	if opts.shell {
+		dir, err := os.MkdirTemp("", "gke-shell-")
+		if err != nil {
+			return fmt.Errorf("failed to create temporary directory: %v", err)
+		}
+		defer shredDir(dir)
+		kubeconfigFlag = filepath.Join(dir, "kubeconfig")
+		os.Setenv("KUBECONFIG", kubeconfigFlag)
+	}
+
+	var connected *GKEConfig
+	if opts.alias != "" {
+		config, err := connectToAlias(ctx, opts.alias)
+		if err != nil {
+			return err
+		}
+		connected = &config
+	} else {
+		m, err := runPicker()
+		if err != nil {
+			return err
+		}
+		if m.err != nil {
+			return m.err
+		}
+		if m.rerun != nil {
+			if !confirm(fmt.Sprintf("Run %s again?", m.rerun.command())) {
+				return errAborted
+			}
+			return rerun(*m.rerun)
+		}
+		if m.switchTo != nil {
+			return switchTo(*m.switchTo)
+		}
+		if m.script != "" {
+			fmt.Print(m.script)
+			return nil
+		}
+		connected = m.connected
+	}
+	if connected == nil || printCommands {
+		return nil
+	}
+
+	runPostConnectHooks(ctx, *connected)
+	if opts.session || opts.shell || opts.then != "" {
+		return runSession(ctx, *connected, opts.session, opts.then)
+	}
+	return nil
+}
+
+// runPicker runs the interactive picker to its end.
+func runPicker() (*model, error) {
+	m := &model{
+		bar:     progress.New(progress.WithDefaultGradient(), progress.WithWidth(40)),
+		spinner: spinner.New(spinner.WithSpinner(spinner.Dot)),
+	}
+	m.labelFilter, _ = clusterSelector()
+	m.labelInput = formatLabelSelector(m.labelFilter)
+	m.sortKey, _ = clusterSort()
+
+	// Offer a choice of gcloud configuration and account first unless they
+	// were picked via flags or the CLOUDSDK_* environment.
+	var configurations []gcloudConfiguration
+	if os.Getenv("CLOUDSDK_ACTIVE_CONFIG_NAME") == "" && hasGcloud() {
+		configurations, _ = listGcloudConfigurations()
+	}
+	if len(configurations) > 1 {
+		m.showConfigurations(configurations)
+	} else if accounts := pickableAccounts(); accounts != nil {
+		m.showAccounts(accounts, "", "")
+	} else {
+		m.showProjects("")
+	}
+
+	p := tea.NewProgram(m)
+	m.program = p
+
+	if _, err := p.Run(); err != nil {
+		return nil, fmt.Errorf("running program: %v", err)
+	}
+	return m, nil
+}
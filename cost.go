@@ -0,0 +1,80 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// pricesTTL is how long the Compute Engine price list is reused. Prices
+// rarely change and the catalog takes a while to download.
+const pricesTTL = 24 * time.Hour
+
+// cachedPrices returns the Compute Engine prices, from the cache when they
+// were fetched less than pricesTTL ago.
+func cachedPrices(ctx context.Context) (gke.Prices, error) {
+	key := listingKey("prices", gke.ComputeEngineService)
+	var prices gke.Prices
+	if fetched, ok := readStaleListing(key, &prices); ok && time.Since(fetched) <= pricesTTL {
+		return prices, nil
+	}
+	api, err := pricingAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	prices, err = gke.ListPrices(ctx, api)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list Compute Engine prices: %v", err)
+	}
+	writeListing(key, prices)
+	return prices, nil
+}
+
+// costLabel renders a cluster's estimated monthly cost for the detail
+// pane.
+func costLabel(cluster *container.Cluster, prices gke.Prices) string {
+	estimate, err := gke.EstimateCost(cluster, prices)
+	if err != nil {
+		return "billed per pod (Autopilot)"
+	}
+	label := fmt.Sprintf("≈ $%.0f/month", estimate.Monthly)
+	if len(estimate.Unpriced) > 0 {
+		label += " excluding " + strings.Join(estimate.Unpriced, ", ")
+	}
+	return label
+}
+
+// costEstimates estimates the monthly cost of each cluster, keyed by
+// clusterKey, or returns nil when the prices can't be fetched.
+func costEstimates(ctx context.Context, clusters []*container.Cluster) map[string]string {
+	prices, err := cachedPrices(ctx)
+	if err != nil {
+		slog.Debug("not estimating cluster costs", "err", err)
+		return nil
+	}
+	costs := make(map[string]string)
+	for _, cluster := range clusters {
+		costs[clusterKey(cluster)] = costLabel(cluster, prices)
+	}
+	return costs
+}
+
+// costsMsg delivers the cost estimates to the cluster list.
+type costsMsg struct {
+	projectID string
+	costs     map[string]string
+}
+
+// checkCosts estimates the clusters' costs in the background.
+func checkCosts(projectID string, clusters []*container.Cluster) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		return costsMsg{projectID: projectID, costs: costEstimates(ctx, clusters)}
+	}
+}
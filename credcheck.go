@@ -0,0 +1,113 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gke-tool/pkg/kubeconfig"
+	"golang.org/x/exp/slog"
+)
+
+// credentialMarkers are fragments of kubectl errors caused by a rejected or
+// unobtainable token rather than by the network or the cluster.
+var credentialMarkers = []string{
+	"Unauthorized",
+	"status code 401",
+	"getting credentials",
+	"exec plugin",
+	kubeconfig.AuthPlugin,
+	"invalid_grant",
+	"Reauthentication",
+}
+
+// credentialError reports whether err is a kubectl failure caused by
+// credentials.
+func credentialError(err error) bool {
+	if err == nil {
+		return false
+	}
+	msg := err.Error()
+	for _, marker := range credentialMarkers {
+		if strings.Contains(msg, marker) {
+			return true
+		}
+	}
+	return false
+}
+
+// requestCluster makes a cheap authenticated request through config's
+// context, so the credential plugin mints and caches a token.
+func requestCluster(ctx context.Context, config GKEConfig) error {
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	args := []string{"--context", contextName(config), "get", "--raw", "/version"}
+	if path := kubeconfigPath(config); path != "" {
+		args = append([]string{"--kubeconfig", path}, args...)
+	}
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "kubectl", args...)
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("failed to reach the cluster: %s", msg)
+		}
+		return fmt.Errorf("failed to reach the cluster: %v", err)
+	}
+	return nil
+}
+
+// refreshToken requests the cluster through config's context. A token the
+// cluster rejects, typically one cached for the account used before a
+// switch, is refreshed and the request retried once. Only credentials the
+// cluster still rejects are an error: an endpoint this host can't reach,
+// such as a private one behind a bastion, is merely warned about.
+func refreshToken(ctx context.Context, config GKEConfig) error {
+	err := requestCluster(ctx, config)
+	if credentialError(err) {
+		slog.Info("cluster rejected the cached credentials, refreshing them", "cluster", contextName(config), "err", err)
+		if rerr := resetTokens(ctx); rerr != nil {
+			return fmt.Errorf("%v (refreshing the token failed: %v)", err, rerr)
+		}
+		err = requestCluster(ctx, config)
+		if credentialError(err) {
+			return err
+		}
+	}
+	if err != nil {
+		slog.Warn("could not check the new credentials", "cluster", contextName(config), "err", err)
+	}
+	return nil
+}
+
+// resetTokens drops the credential plugin's token cache and, with gcloud,
+// refreshes the active account's access token so the plugin gets a new one.
+func resetTokens(ctx context.Context) error {
+	if home, err := os.UserHomeDir(); err == nil {
+		cache := filepath.Join(home, ".kube", "gke_gcloud_auth_plugin_cache")
+		if err := os.Remove(cache); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if !usesGcloudIdentity() {
+		return nil
+	}
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+	var stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "gcloud", "auth", "print-access-token")
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return fmt.Errorf("gcloud auth print-access-token: %s", strings.TrimSpace(stderr.String()))
+	}
+	return nil
+}
@@ -0,0 +1,116 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+)
+
+func newDaemonCmd() *cobra.Command {
+	var once bool
+	var debugAddr string
+	cmd := &cobra.Command{
+		Use:   "daemon",
+		Short: "Pre-sync pinned clusters on the configured schedule",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runDaemon(cmd.Context(), once, debugAddr)
+		}),
+	}
+	cmd.Flags().BoolVar(&once, "once", false, "sync pinned clusters immediately and exit")
+	addDebugFlag(cmd, &debugAddr)
+	return cmd
+}
+
+// runDaemon keeps running in the background and pre-syncs the pinned
+// clusters at the times listed in the config schedule, so the first kubectl
+// of the day doesn't hit a stale authorized network entry or token.
+func runDaemon(ctx context.Context, once bool, debugAddr string) error {
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Pinned) == 0 {
+		return fmt.Errorf("no pinned clusters in the config file")
+	}
+	if once {
+		return syncPinned(ctx, cfg.Pinned)
+	}
+	if len(cfg.Schedule) == 0 {
+		return fmt.Errorf("no schedule in the config file")
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := startDebugServer(ctx, debugAddr); err != nil {
+		return err
+	}
+
+	for {
+		next, err := nextScheduledRun(cfg.Schedule, time.Now())
+		if err != nil {
+			return err
+		}
+		slog.Info("next sync scheduled", "clusters", len(cfg.Pinned), "at", next.Format("Mon Jan 2 15:04"))
+
+		timer := time.NewTimer(time.Until(next))
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			slog.Info("stopping")
+			return nil
+		case <-timer.C:
+		}
+
+		if err := syncPinned(ctx, cfg.Pinned); err != nil {
+			slog.Warn("sync finished with errors", "err", err)
+		}
+	}
+}
+
+// syncPinned puts the current public IP on every pinned cluster, rewrites
+// its credentials and fetches a fresh token through the new context.
+func syncPinned(ctx context.Context, pinned []clusterRef) error {
+	username, err := getUsername(ctx)
+	if err != nil {
+		return err
+	}
+
+	failed := 0
+	for _, ref := range pinned {
+		debugReconciliations.Add(1)
+		config, cluster, err := resolveCluster(ctx, ref.Project, ref.Location, ref.Cluster)
+		if err == nil {
+			config.Username = username
+			if gke.HasAuthorizedNetworks(cluster) {
+				err = updateAuthorizedNetworks(ctx, config, cluster, nil)
+			}
+		}
+		if err == nil {
+			err = writeCredentials(config, cluster)
+		}
+		if err == nil {
+			err = refreshToken(ctx, config)
+		}
+
+		if err != nil {
+			failed++
+			slog.Error("sync failed", "cluster", ref, "err", err)
+			continue
+		}
+		slog.Info("synced", "cluster", ref)
+	}
+
+	if failed > 0 {
+		return fmt.Errorf("%d of %s failed", failed, pluralize(len(pinned), "pinned cluster"))
+	}
+	return nil
+}
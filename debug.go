@@ -0,0 +1,151 @@
+package main
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slog"
+)
+
+const defaultDebugAddr = "127.0.0.1:6060"
+
+// Counters exported on /debug/vars by long-running modes.
+var (
+	debugStarted         = expvar.NewString("started")
+	debugReconciliations = expvar.NewInt("reconciliations")
+	debugRPCRequests     = expvar.NewInt("rpcRequests")
+)
+
+// addDebugFlag registers --debug-listen on a long-running command.
+func addDebugFlag(cmd *cobra.Command, addr *string) {
+	cmd.Flags().StringVar(addr, "debug-listen", "", "serve pprof and expvar on this loopback address, e.g. "+defaultDebugAddr)
+}
+
+// startDebugServer serves pprof and expvar on addr, or on the config's
+// debugListen when addr is empty. Nothing is started when neither is set.
+func startDebugServer(ctx context.Context, addr string) error {
+	if addr == "" {
+		cfg, err := loadUserConfig()
+		if err != nil {
+			return err
+		}
+		addr = cfg.DebugListen
+	}
+	if addr == "" {
+		return nil
+	}
+	if err := requireLoopback(addr); err != nil {
+		return err
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.Handle("/debug/vars", expvar.Handler())
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+	go func() {
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			slog.Warn("debug server stopped", "err", err)
+		}
+	}()
+
+	debugStarted.Set(time.Now().Format(time.RFC3339))
+	slog.Info("debug endpoints listening", "pprof", "http://"+addr+"/debug/pprof/", "vars", "http://"+addr+"/debug/vars")
+	return nil
+}
+
+func newDebugCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "debug",
+		Short: "Diagnose long-running watch, daemon and serve processes",
+	}
+
+	var addr, output string
+	dump := &cobra.Command{
+		Use:   "dump",
+		Short: "Save goroutine and heap snapshots of a running process",
+		Long: `Fetches goroutine stacks, a heap profile and the exported counters from
+the debug endpoint of a running watch, daemon or serve process (started
+with --debug-listen or debugListen in the config) and saves them to files.
+Inspect the heap profile with "go tool pprof".`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDebugDump(addr, output)
+		},
+	}
+	dump.Flags().StringVar(&addr, "addr", "", "debug address of the running process (default: debugListen from the config, or "+defaultDebugAddr+")")
+	dump.Flags().StringVar(&output, "output", ".", "directory to write the snapshots to")
+	cmd.AddCommand(dump)
+	return cmd
+}
+
+func runDebugDump(addr, output string) error {
+	if addr == "" {
+		cfg, err := loadUserConfig()
+		if err != nil {
+			return err
+		}
+		addr = cfg.DebugListen
+	}
+	if addr == "" {
+		addr = defaultDebugAddr
+	}
+	if err := os.MkdirAll(output, 0o700); err != nil {
+		return err
+	}
+
+	stamp := time.Now().Format("20060102T150405")
+	snapshots := []struct{ path, file string }{
+		{"/debug/pprof/goroutine?debug=2", "goroutines-" + stamp + ".txt"},
+		{"/debug/pprof/heap", "heap-" + stamp + ".pprof"},
+		{"/debug/vars", "vars-" + stamp + ".json"},
+	}
+
+	client := &http.Client{Timeout: 30 * time.Second}
+	for _, snap := range snapshots {
+		file := filepath.Join(output, snap.file)
+		if err := fetchSnapshot(client, "http://"+addr+snap.path, file); err != nil {
+			return err
+		}
+		fmt.Printf("📦 %s\n", file)
+	}
+	return nil
+}
+
+func fetchSnapshot(client *http.Client, url, file string) error {
+	resp, err := client.Get(url)
+	if err != nil {
+		return fmt.Errorf("failed to reach the debug endpoint: %v", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s: %s", url, resp.Status)
+	}
+
+	f, err := os.Create(file)
+	if err != nil {
+		return err
+	}
+	if _, err := io.Copy(f, resp.Body); err != nil {
+		f.Close()
+		return fmt.Errorf("failed to write %s: %v", file, err)
+	}
+	return f.Close()
+}
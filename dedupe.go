@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"golang.org/x/term"
+)
+
+func newManDedupeCmd() *cobra.Command {
+	var target targetOptions
+	var apply applyOptions
+	cmd := &cobra.Command{
+		Use:   "dedupe",
+		Short: "Merge authorized networks that list the same CIDR under several names",
+		Long: `Finds CIDRs listed more than once under different display names and merges
+each into a single entry, freeing room under the entry limit. For every
+duplicate you pick the name to keep; with --yes, or without a terminal, the
+first one is kept. The dropped names are recorded as aliases in the audit
+log.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runManDedupe(cmd.Context(), target, apply)
+		}),
+	}
+	target.addFlags(cmd)
+	apply.addFlags(cmd, "")
+	return cmd
+}
+
+func runManDedupe(ctx context.Context, target targetOptions, apply applyOptions) error {
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+
+	current := gke.AuthorizedEntries(cluster)
+	duplicates := man.Duplicates(current)
+	if len(duplicates) == 0 {
+		fmt.Printf("✅ No CIDR is listed more than once on %s\n", config.Cluster)
+		return nil
+	}
+
+	interactive := !apply.yes && !apply.dryRun && term.IsTerminal(int(os.Stdin.Fd()))
+	keep := make(map[string]string)
+	for _, d := range duplicates {
+		keep[d.CIDR] = d.Names[0]
+		if interactive {
+			keep[d.CIDR] = chooseName(d)
+		}
+	}
+
+	merged := man.Merge(current, keep)
+	fmt.Printf("Merging duplicate entries of %s:\n\n", config.Cluster)
+	for _, d := range duplicates {
+		fmt.Printf("  = %-30s %s\n", keep[d.CIDR], d.CIDR)
+		for _, name := range d.Names {
+			if name != keep[d.CIDR] {
+				fmt.Printf("  - %-30s %s\n", name, d.CIDR)
+			}
+		}
+	}
+	fmt.Printf("\n  %d -> %d of %d entries\n\n", len(current), len(merged), man.DefaultMaxEntries)
+
+	if apply.dryRun {
+		return nil
+	}
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}.String()
+	var changes []string
+	for _, d := range duplicates {
+		changes = append(changes, fmt.Sprintf("= %s %s", keep[d.CIDR], d.CIDR))
+	}
+	if err := approve(ctx, apply.yes, newApproval("man dedupe", "Apply these changes?", []string{ref}, changes)); err != nil {
+		return err
+	}
+
+	fmt.Printf("📡 Updating authorized networks...\n")
+	if err := applyAuthorizedNetworks(ctx, config, cluster, merged, nil); err != nil {
+		return err
+	}
+
+	user, _ := getUsername(ctx)
+	var records []auditRecord
+	for _, d := range duplicates {
+		record := auditRecord{
+			Time:    time.Now(),
+			User:    user,
+			Action:  "merge",
+			Cluster: config.target().Name(),
+			CIDR:    d.CIDR,
+			Kept:    keep[d.CIDR],
+		}
+		for _, name := range d.Names {
+			if name != record.Kept {
+				record.Aliases = append(record.Aliases, name)
+			}
+		}
+		records = append(records, record)
+	}
+	if err := appendAudit(records...); err != nil {
+		fmt.Printf("⚠️  %v\n", err)
+	}
+	fmt.Printf("✨ Merged %s on %s\n", pluralize(len(duplicates), "duplicate CIDR"), config.Cluster)
+	return nil
+}
+
+// chooseName asks which display name a duplicate CIDR keeps, defaulting to
+// the first.
+func chooseName(d man.Duplicate) string {
+	fmt.Printf("🔁 %s is listed as:\n", d.CIDR)
+	for i, name := range d.Names {
+		fmt.Printf("  %d) %s\n", i+1, name)
+	}
+	for {
+		fmt.Printf("Keep which name? [1]: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil || answer == "" {
+			return d.Names[0]
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(d.Names) {
+			return d.Names[n-1]
+		}
+		fmt.Printf("Enter a number from 1 to %d\n", len(d.Names))
+	}
+}
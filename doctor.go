@@ -0,0 +1,93 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/kubeconfig"
+)
+
+// doctorCheck is one environment check run by `gke doctor`. Optional checks
+// only warn when they fail.
+type doctorCheck struct {
+	name     string
+	optional bool
+	run      func(ctx context.Context) (string, error)
+}
+
+func newDoctorCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:         "doctor",
+		Short:       "Check tools, credentials, network and data files",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDoctor(cmd.Context())
+		},
+	}
+}
+
+var doctorChecks = []doctorCheck{
+	{name: "kubectl", run: func(context.Context) (string, error) {
+		return exec.LookPath("kubectl")
+	}},
+	{name: kubeconfig.AuthPlugin, run: func(context.Context) (string, error) {
+		return exec.LookPath(kubeconfig.AuthPlugin)
+	}},
+	{name: "gcloud", optional: true, run: func(context.Context) (string, error) {
+		path, err := exec.LookPath("gcloud")
+		if err != nil {
+			return "", fmt.Errorf("not found, credentials will be written natively")
+		}
+		return path, nil
+	}},
+	{name: "data files", run: func(context.Context) (string, error) {
+		if len(safeMode) > 0 {
+			return "", fmt.Errorf("running in safe mode, some files are unreadable")
+		}
+		return "config, state and history readable", nil
+	}},
+	{name: "credentials", run: func(ctx context.Context) (string, error) {
+		if err := checkCredentials(ctx); err != nil {
+			return "", err
+		}
+		return getUsername(ctx)
+	}},
+	{name: "public IP", run: func(context.Context) (string, error) {
+		return getCurrentPublicIP()
+	}},
+	{name: "Resource Manager API", run: func(ctx context.Context) (string, error) {
+		projects, err := getProjects(ctx)
+		if err != nil {
+			return "", err
+		}
+		return pluralize(len(projects), "project") + " visible", nil
+	}},
+}
+
+// runDoctor runs every check and fails if a required one fails.
+func runDoctor(ctx context.Context) error {
+	failed := 0
+	for _, check := range doctorChecks {
+		detail, err := check.run(ctx)
+		switch {
+		case err == nil:
+			fmt.Printf("✅ %-22s %s\n", check.name, detail)
+		case check.optional:
+			fmt.Printf("⚠️  %-22s %v\n", check.name, err)
+		default:
+			failed++
+			fmt.Printf("❌ %-22s %s\n", check.name, strings.TrimSpace(err.Error()))
+		}
+	}
+
+	fmt.Println()
+	if failed > 0 {
+		return fmt.Errorf("%s failed", pluralize(failed, "check"))
+	}
+	fmt.Printf("✨ Everything looks good\n")
+	return nil
+}
@@ -0,0 +1,172 @@
+//go:build e2e
+
+// The e2e suite runs against a real GKE project and is opt-in:
+//
+//	GKE_E2E_PROJECT=my-test-project GKE_E2E_CLUSTER=e2e \
+//	    go test -tags e2e -run E2E -v .
+//
+// GKE_E2E_LOCATION is optional. The cluster must have authorized networks
+// enabled and is modified during the run; every test restores what it
+// changed.
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+// e2eEntry uses a documentation range so it never grants real access.
+var e2eEntry = man.Entry{DisplayName: "my-gke-e2e", CIDR: "192.0.2.1/32"}
+
+type e2eTarget struct {
+	project, location, cluster string
+}
+
+func e2eSetup(t *testing.T) (context.Context, e2eTarget) {
+	t.Helper()
+	target := e2eTarget{
+		project:  os.Getenv("GKE_E2E_PROJECT"),
+		location: os.Getenv("GKE_E2E_LOCATION"),
+		cluster:  os.Getenv("GKE_E2E_CLUSTER"),
+	}
+	if target.project == "" || target.cluster == "" {
+		t.Skip("GKE_E2E_PROJECT and GKE_E2E_CLUSTER must be set")
+	}
+
+	// Keep config, state and kubeconfig writes away from the real ones. gcloud
+	// keeps its own credentials under HOME, which is left alone.
+	dir := t.TempDir()
+	t.Setenv("XDG_CONFIG_HOME", dir)
+	t.Setenv("KUBECONFIG", filepath.Join(dir, "kubeconfig"))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 15*time.Minute)
+	t.Cleanup(cancel)
+	return ctx, target
+}
+
+// e2eCluster fetches the test cluster and restores its authorized networks
+// when the test ends.
+func e2eCluster(t *testing.T, ctx context.Context, target e2eTarget) (GKEConfig, *container.Cluster) {
+	t.Helper()
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		t.Fatalf("resolveCluster: %v", err)
+	}
+	if !gke.HasAuthorizedNetworks(cluster) {
+		t.Fatalf("%s does not have authorized networks enabled", config.Cluster)
+	}
+
+	original := gke.AuthorizedEntries(cluster)
+	t.Cleanup(func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
+		defer cancel()
+		_, current, err := resolveCluster(ctx, config.ProjectID, config.Region, config.Cluster)
+		if err != nil {
+			t.Errorf("cleanup: %v", err)
+			return
+		}
+		if sameEntries(gke.AuthorizedEntries(current), original) {
+			return
+		}
+		if err := applyAuthorizedNetworks(ctx, config, current, original, nil); err != nil {
+			t.Errorf("cleanup: failed to restore authorized networks: %v", err)
+		}
+	})
+	return config, cluster
+}
+
+func hasEntry(cluster *container.Cluster, entry man.Entry) bool {
+	for _, e := range gke.AuthorizedEntries(cluster) {
+		if e == entry {
+			return true
+		}
+	}
+	return false
+}
+
+func TestE2EListing(t *testing.T) {
+	ctx, target := e2eSetup(t)
+
+	projects, err := getProjects(ctx)
+	if err != nil {
+		t.Fatalf("getProjects: %v", err)
+	}
+	found := false
+	for _, p := range projects {
+		found = found || p == target.project
+	}
+	if !found {
+		t.Errorf("project %s not in %d listed projects", target.project, len(projects))
+	}
+
+	clusters, err := getClusters(ctx, target.project)
+	if err != nil {
+		t.Fatalf("getClusters: %v", err)
+	}
+	if len(filterClusters(clusters, target.location, target.cluster)) != 1 {
+		t.Errorf("cluster %s not listed in %s", target.cluster, target.project)
+	}
+}
+
+func TestE2EUpdateAndRollback(t *testing.T) {
+	ctx, target := e2eSetup(t)
+	config, cluster := e2eCluster(t, ctx, target)
+
+	set := changeSet{
+		Clusters: []clusterRef{{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}},
+		Entries:  []man.Entry{e2eEntry},
+	}
+	changes, err := planChangeSet(ctx, set)
+	if err != nil {
+		t.Fatalf("planChangeSet: %v", err)
+	}
+	change := changes[0]
+	if change.plan.Empty() {
+		t.Fatalf("%s already has %s, remove it first", config.Cluster, e2eEntry.DisplayName)
+	}
+
+	if err := applyAuthorizedNetworks(ctx, config, cluster, change.plan.Result, nil); err != nil {
+		t.Fatalf("apply: %v", err)
+	}
+	_, updated, err := resolveCluster(ctx, config.ProjectID, config.Region, config.Cluster)
+	if err != nil {
+		t.Fatalf("resolveCluster: %v", err)
+	}
+	if !hasEntry(updated, e2eEntry) {
+		t.Fatalf("%s missing after update", e2eEntry.CIDR)
+	}
+
+	if err := rollbackChangeSet(ctx, changes, set.Clusters[0], context.Canceled); err == nil {
+		t.Fatalf("rollbackChangeSet returned nil, want the cause")
+	}
+	_, restored, err := resolveCluster(ctx, config.ProjectID, config.Region, config.Cluster)
+	if err != nil {
+		t.Fatalf("resolveCluster: %v", err)
+	}
+	if !sameEntries(gke.AuthorizedEntries(restored), change.plan.Current) {
+		t.Errorf("authorized networks not restored by rollback")
+	}
+}
+
+func TestE2EKubeconfig(t *testing.T) {
+	_, target := e2eSetup(t)
+	ctx := context.Background()
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		t.Fatalf("resolveCluster: %v", err)
+	}
+
+	if err := kube.WriteCluster(contextName(config), cluster); err != nil {
+		t.Fatalf("WriteCluster: %v", err)
+	}
+	if !kube.ContextExists(contextName(config)) {
+		t.Errorf("context %s not written to %s", contextName(config), os.Getenv("KUBECONFIG"))
+	}
+}
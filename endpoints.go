@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+)
+
+// apiVIPs are the hostnames of the Private Google Access virtual IPs. In
+// VPC Service Controls perimeters API traffic has to reach Google through
+// one of them while still presenting the usual *.googleapis.com host.
+var apiVIPs = map[string]string{
+	"private":    "private.googleapis.com",
+	"restricted": "restricted.googleapis.com",
+}
+
+// apiVIP is the selected entry of apiVIPs, from --api-vip.
+var apiVIP string
+
+// apiEndpoint returns the endpoint override for api, following gcloud's
+// CLOUDSDK_API_ENDPOINT_OVERRIDES_<API> convention so that gcloud and this
+// tool agree on where each API lives.
+func apiEndpoint(api string) string {
+	return os.Getenv("CLOUDSDK_API_ENDPOINT_OVERRIDES_" + strings.ToUpper(api))
+}
+
+// setAPIEndpoint overrides the endpoint for api in this process and for the
+// gcloud commands it runs.
+func setAPIEndpoint(api, endpoint string) {
+	if endpoint == "" {
+		return
+	}
+	if !strings.HasSuffix(endpoint, "/") {
+		endpoint += "/"
+	}
+	os.Setenv("CLOUDSDK_API_ENDPOINT_OVERRIDES_"+strings.ToUpper(api), endpoint)
+}
+
+func validateAPIVIP(name string) error {
+	if _, ok := apiVIPs[name]; name != "" && !ok {
+		return fmt.Errorf("unknown --api-vip %q; use private or restricted", name)
+	}
+	return nil
+}
+
+// apiBaseTransport returns a transport that connects googleapis.com hosts
+// through the selected VIP, or nil when no VIP is configured.
+func apiBaseTransport() http.RoundTripper {
+	vip := apiVIPs[apiVIP]
+	if vip == "" {
+		return nil
+	}
+
+	dialer := &net.Dialer{}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, network, addr string) (net.Conn, error) {
+		host, port, err := net.SplitHostPort(addr)
+		if err == nil && strings.HasSuffix(host, ".googleapis.com") {
+			addr = net.JoinHostPort(vip, port)
+		}
+		return dialer.DialContext(ctx, network, addr)
+	}
+	return transport
+}
@@ -0,0 +1,117 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"syscall"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+)
+
+func newEphemeralCmd() *cobra.Command {
+	var target targetOptions
+	cmd := &cobra.Command{
+		Use:   "ephemeral --cluster NAME -- COMMAND [ARGS...]",
+		Short: "Run one command with credentials in a temporary kubeconfig",
+		Long: `Adds your IP to the cluster's authorized networks, writes its credentials to
+a temporary kubeconfig, runs the command with KUBECONFIG pointing at it and
+shreds the file when the command exits. Your own kubeconfig is not touched.`,
+		Example:               `  gke ephemeral --project my-project --cluster prod -- kubectl get nodes`,
+		Args:                  cobra.MinimumNArgs(1),
+		DisableFlagsInUseLine: true,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runEphemeral(cmd.Context(), target, args)
+		}),
+	}
+	target.addFlags(cmd)
+	// Everything after the first argument belongs to the command.
+	cmd.Flags().SetInterspersed(false)
+	return cmd
+}
+
+func runEphemeral(ctx context.Context, target targetOptions, args []string) error {
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	if config.Username, err = getUsername(ctx); err != nil {
+		return err
+	}
+
+	dir, err := os.MkdirTemp("", "gke-ephemeral-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer shredDir(dir)
+
+	// gcloud and kubectl, including the ones writeCredentials runs, all
+	// follow KUBECONFIG.
+	kubeconfigFlag = filepath.Join(dir, "kubeconfig")
+	os.Setenv("KUBECONFIG", kubeconfigFlag)
+
+	if gke.HasAuthorizedNetworks(cluster) {
+		if err := updateAuthorizedNetworks(ctx, config, cluster, nil); err != nil {
+			return fmt.Errorf("failed to update authorized networks: %w", err)
+		}
+	}
+	if err := writeCredentials(config, cluster); err != nil {
+		return err
+	}
+
+	child := exec.Command(args[0], args[1:]...)
+	child.Stdin, child.Stdout, child.Stderr = os.Stdin, os.Stdout, os.Stderr
+
+	// Interrupts go to the child; this process stays around to shred the
+	// kubeconfig once the child is gone.
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	if err := child.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", args[0], err)
+	}
+	done := make(chan error, 1)
+	go func() { done <- child.Wait() }()
+	for {
+		select {
+		case sig := <-signals:
+			child.Process.Signal(sig)
+		case err := <-done:
+			if exitErr, ok := err.(*exec.ExitError); ok {
+				return childError{exitErr}
+			}
+			return err
+		}
+	}
+}
+
+// shredDir overwrites every file in dir with random bytes before removing
+// the directory, so the credentials don't linger in free disk blocks.
+func shredDir(dir string) {
+	filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err == nil && info.Mode().IsRegular() {
+			shredFile(path, info.Size())
+		}
+		return nil
+	})
+	if err := os.RemoveAll(dir); err != nil {
+		fmt.Fprintf(os.Stderr, "⚠️  Failed to remove %s: %v\n", dir, err)
+	}
+}
+
+func shredFile(path string, size int64) {
+	f, err := os.OpenFile(path, os.O_WRONLY, 0)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	io.CopyN(f, rand.Reader, size)
+	f.Sync()
+}
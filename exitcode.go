@@ -0,0 +1,70 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os/exec"
+
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/googleapi"
+)
+
+// Exit codes let wrapper scripts tell failures apart without parsing the
+// output. They are documented in the README; keep both in sync.
+const (
+	exitFailure    = 1
+	exitUsage      = 2
+	exitAuth       = 3
+	exitPermission = 4
+	exitNotFound   = 5
+	exitTimeout    = 6
+	exitAborted    = 7
+)
+
+// errAborted is returned when the user declines a confirmation.
+var errAborted = errors.New("aborted")
+
+// usageError is a command line the commands can't make sense of.
+type usageError struct {
+	error
+}
+
+func (e usageError) Unwrap() error { return e.error }
+
+// childError is a failed command run in the foreground, such as a re-run
+// history entry. The child has already reported why, so only its exit code
+// is passed on.
+type childError struct {
+	*exec.ExitError
+}
+
+func (e childError) Unwrap() error { return e.ExitError }
+
+// exitCode maps an error returned by a command to the process exit code.
+func exitCode(err error) int {
+	var usage usageError
+	var child childError
+	var apiErr *googleapi.Error
+	switch {
+	case err == nil:
+		return 0
+	case errors.As(err, &child):
+		return child.ExitCode()
+	case errors.As(err, &usage):
+		return exitUsage
+	case errors.Is(err, errAborted), errors.Is(err, gke.ErrShrinkDeclined):
+		return exitAborted
+	case errors.Is(err, gke.ErrOperationTimeout), errors.Is(err, context.DeadlineExceeded):
+		return exitTimeout
+	case isAuthError(err):
+		return exitAuth
+	case errors.Is(err, gke.ErrNotFound):
+		return exitNotFound
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusForbidden:
+		return exitPermission
+	case errors.As(err, &apiErr) && apiErr.Code == http.StatusNotFound:
+		return exitNotFound
+	}
+	return exitFailure
+}
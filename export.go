@@ -0,0 +1,334 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+func newExportCmd() *cobra.Command {
+	var target targetOptions
+	var format string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print a cluster's configuration as YAML, JSON or Terraform",
+		Long: `Prints the configuration of a cluster. yaml and json print the whole
+Cluster object as the GKE API returns it, with sorted keys, for drift
+checks. terraform prints a google_container_cluster resource, a
+google_container_node_pool resource per node pool and import blocks for
+them: a skeleton to adopt the cluster into Terraform, to be reviewed and
+completed before applying.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runExport(cmd.Context(), os.Stdout, target, format)
+		},
+	}
+	target.addFlags(cmd)
+	cmd.Flags().StringVar(&format, "format", "yaml", "output format: yaml, json or terraform")
+	return cmd
+}
+
+func runExport(ctx context.Context, out io.Writer, target targetOptions, format string) error {
+	if format != "yaml" && format != "json" && format != "terraform" {
+		return usageError{fmt.Errorf("unsupported format %q, expected yaml, json or terraform", format)}
+	}
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	if format == "terraform" {
+		protected, err := deletionProtection(ctx, config)
+		if err != nil {
+			// Recent providers default to protecting clusters too.
+			slog.Debug("failed to read deletion protection", "cluster", config.Cluster, "err", err)
+			protected = true
+		}
+		_, err = io.WriteString(out, terraformCluster(config.ProjectID, cluster, protected))
+		return err
+	}
+	// Going through JSON keeps the API's field names and leaves out unset
+	// fields; decoding into maps sorts the keys.
+	data, err := json.Marshal(cluster)
+	if err != nil {
+		return err
+	}
+	var v map[string]any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	return writeOutput(out, format, v, nil)
+}
+
+// hcl writes Terraform configuration with two-space indentation.
+type hcl struct {
+	b      strings.Builder
+	indent int
+}
+
+func (h *hcl) line(format string, args ...any) {
+	h.b.WriteString(strings.Repeat("  ", h.indent) + fmt.Sprintf(format, args...) + "\n")
+}
+
+func (h *hcl) open(format string, args ...any) {
+	h.line(format+" {", args...)
+	h.indent++
+}
+
+func (h *hcl) close() {
+	h.indent--
+	h.line("}")
+}
+
+// str sets a string attribute, unless value is empty.
+func (h *hcl) str(name, value string) {
+	if value != "" {
+		h.line("%s = %s", name, hclString(value))
+	}
+}
+
+// boolean sets a bool attribute, unless value is false.
+func (h *hcl) boolean(name string, value bool) {
+	if value {
+		h.line("%s = true", name)
+	}
+}
+
+// number sets a number attribute, unless value is zero.
+func (h *hcl) number(name string, value int64) {
+	if value != 0 {
+		h.line("%s = %d", name, value)
+	}
+}
+
+func (h *hcl) list(name string, values []string) {
+	if len(values) == 0 {
+		return
+	}
+	var quoted []string
+	for _, v := range values {
+		quoted = append(quoted, hclString(v))
+	}
+	h.line("%s = [%s]", name, strings.Join(quoted, ", "))
+}
+
+func (h *hcl) labels(name string, labels map[string]string) {
+	if len(labels) == 0 {
+		return
+	}
+	var keys []string
+	for key := range labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	h.open("%s =", name)
+	for _, key := range keys {
+		h.line("%s = %s", hclString(key), hclString(labels[key]))
+	}
+	h.close()
+}
+
+// hclString quotes s, escaping interpolation sequences.
+func hclString(s string) string {
+	data, _ := json.Marshal(s)
+	quoted := strings.ReplaceAll(string(data), "${", "$${")
+	return strings.ReplaceAll(quoted, "%{", "%%{")
+}
+
+var nonIdentifier = regexp.MustCompile(`[^A-Za-z0-9_]`)
+
+// hclName turns a GKE name into a Terraform resource name.
+func hclName(name string) string {
+	return nonIdentifier.ReplaceAllString(name, "_")
+}
+
+// terraformCluster renders cluster as Terraform google_container_cluster
+// and google_container_node_pool resources with import blocks. Only the
+// commonly set arguments are filled in.
+func terraformCluster(projectID string, cluster *container.Cluster, protected bool) string {
+	h := &hcl{}
+	name := hclName(cluster.Name)
+	autopilot := cluster.Autopilot != nil && cluster.Autopilot.Enabled
+	h.line("# Generated by gke export from %s/%s/%s. Review before applying.", projectID, cluster.Location, cluster.Name)
+	h.open("import")
+	h.line("to = google_container_cluster.%s", name)
+	h.str("id", gke.Target{Project: projectID, Location: cluster.Location, Cluster: cluster.Name}.Name())
+	h.close()
+	h.line("")
+
+	h.open("resource \"google_container_cluster\" %s", hclString(name))
+	h.str("project", projectID)
+	h.str("name", cluster.Name)
+	h.str("location", cluster.Location)
+	h.str("description", cluster.Description)
+	if !autopilot {
+		// Terraform wants the zones of zonal clusters other than their own.
+		var zones []string
+		for _, location := range cluster.Locations {
+			if location != cluster.Location {
+				zones = append(zones, location)
+			}
+		}
+		h.list("node_locations", zones)
+	}
+	h.boolean("enable_autopilot", autopilot)
+	if !autopilot {
+		h.line("# Node pools are managed by the google_container_node_pool resources below.")
+		h.line("remove_default_node_pool = true")
+		h.line("initial_node_count = 1")
+	}
+	h.line("deletion_protection = %t", protected)
+	h.labels("resource_labels", cluster.ResourceLabels)
+	h.str("network", cluster.Network)
+	h.str("subnetwork", cluster.Subnetwork)
+	if nc := cluster.NetworkConfig; nc != nil && nc.DatapathProvider != "" && nc.DatapathProvider != "DATAPATH_PROVIDER_UNSPECIFIED" {
+		h.str("datapath_provider", nc.DatapathProvider)
+	}
+	if ip := cluster.IpAllocationPolicy; ip != nil && ip.UseIpAliases {
+		h.line("networking_mode = \"VPC_NATIVE\"")
+		h.open("ip_allocation_policy")
+		if ip.ClusterSecondaryRangeName != "" || ip.ServicesSecondaryRangeName != "" {
+			h.str("cluster_secondary_range_name", ip.ClusterSecondaryRangeName)
+			h.str("services_secondary_range_name", ip.ServicesSecondaryRangeName)
+		} else {
+			h.str("cluster_ipv4_cidr_block", ip.ClusterIpv4CidrBlock)
+			h.str("services_ipv4_cidr_block", ip.ServicesIpv4CidrBlock)
+		}
+		h.close()
+	}
+	if channel := gke.ReleaseChannel(cluster); channel != "" {
+		h.open("release_channel")
+		h.str("channel", channel)
+		h.close()
+	} else {
+		h.str("min_master_version", cluster.CurrentMasterVersion)
+	}
+	if pcc := cluster.PrivateClusterConfig; pcc != nil && (pcc.EnablePrivateNodes || pcc.EnablePrivateEndpoint) {
+		h.open("private_cluster_config")
+		h.line("enable_private_nodes = %t", pcc.EnablePrivateNodes)
+		h.line("enable_private_endpoint = %t", pcc.EnablePrivateEndpoint)
+		h.str("master_ipv4_cidr_block", pcc.MasterIpv4CidrBlock)
+		h.close()
+	}
+	if gke.HasAuthorizedNetworks(cluster) {
+		h.open("master_authorized_networks_config")
+		for _, block := range gke.AuthorizedBlocks(cluster) {
+			h.open("cidr_blocks")
+			h.str("cidr_block", block.CidrBlock)
+			h.str("display_name", block.DisplayName)
+			h.close()
+		}
+		h.close()
+	}
+	security := securityOf(cluster)
+	if security.WorkloadPool != "" && !autopilot {
+		h.open("workload_identity_config")
+		h.str("workload_pool", security.WorkloadPool)
+		h.close()
+	}
+	if ba := cluster.BinaryAuthorization; ba != nil && ba.EvaluationMode != "" && ba.EvaluationMode != "DISABLED" {
+		h.open("binary_authorization")
+		h.str("evaluation_mode", ba.EvaluationMode)
+		h.close()
+	}
+	terraformMaintenance(h, cluster)
+	h.close()
+
+	if autopilot {
+		return h.b.String()
+	}
+	for _, pool := range cluster.NodePools {
+		poolName := hclName(cluster.Name + "_" + pool.Name)
+		h.line("")
+		h.open("import")
+		h.line("to = google_container_node_pool.%s", poolName)
+		h.str("id", strings.Join([]string{projectID, cluster.Location, cluster.Name, pool.Name}, "/"))
+		h.close()
+		h.line("")
+		h.open("resource \"google_container_node_pool\" %s", hclString(poolName))
+		h.str("project", projectID)
+		h.str("name", pool.Name)
+		h.str("location", cluster.Location)
+		h.line("cluster = google_container_cluster.%s.name", name)
+		if len(cluster.Locations) != len(pool.Locations) {
+			h.list("node_locations", pool.Locations)
+		}
+		if as := pool.Autoscaling; as != nil && as.Enabled {
+			h.open("autoscaling")
+			if as.TotalMinNodeCount != 0 || as.TotalMaxNodeCount != 0 {
+				h.line("total_min_node_count = %d", as.TotalMinNodeCount)
+				h.line("total_max_node_count = %d", as.TotalMaxNodeCount)
+			} else {
+				h.line("min_node_count = %d", as.MinNodeCount)
+				h.line("max_node_count = %d", as.MaxNodeCount)
+			}
+			h.close()
+		} else {
+			h.line("node_count = %d", pool.InitialNodeCount)
+		}
+		if m := pool.Management; m != nil {
+			h.open("management")
+			h.line("auto_repair = %t", m.AutoRepair)
+			h.line("auto_upgrade = %t", m.AutoUpgrade)
+			h.close()
+		}
+		if c := pool.Config; c != nil {
+			h.open("node_config")
+			h.str("machine_type", c.MachineType)
+			h.str("image_type", c.ImageType)
+			h.str("disk_type", c.DiskType)
+			h.number("disk_size_gb", c.DiskSizeGb)
+			h.boolean("spot", c.Spot)
+			h.boolean("preemptible", c.Preemptible)
+			h.str("service_account", c.ServiceAccount)
+			h.list("oauth_scopes", c.OauthScopes)
+			h.list("tags", c.Tags)
+			h.labels("labels", c.Labels)
+			h.close()
+		}
+		h.close()
+	}
+	return h.b.String()
+}
+
+// terraformMaintenance renders cluster's maintenance window and exclusions
+// as a maintenance_policy block.
+func terraformMaintenance(h *hcl, cluster *container.Cluster) {
+	if cluster.MaintenancePolicy == nil || cluster.MaintenancePolicy.Window == nil {
+		return
+	}
+	w := cluster.MaintenancePolicy.Window
+	h.open("maintenance_policy")
+	if d := w.DailyMaintenanceWindow; d != nil && d.StartTime != "" {
+		h.open("daily_maintenance_window")
+		h.str("start_time", d.StartTime)
+		h.close()
+	}
+	if r := w.RecurringWindow; r != nil && r.Window != nil {
+		h.open("recurring_window")
+		h.str("start_time", r.Window.StartTime)
+		h.str("end_time", r.Window.EndTime)
+		h.str("recurrence", r.Recurrence)
+		h.close()
+	}
+	for _, e := range gke.MaintenanceExclusions(cluster) {
+		h.open("maintenance_exclusion")
+		h.str("exclusion_name", e.Name)
+		h.str("start_time", e.Start.UTC().Format("2006-01-02T15:04:05Z"))
+		h.str("end_time", e.End.UTC().Format("2006-01-02T15:04:05Z"))
+		h.open("exclusion_options")
+		h.str("scope", e.Scope)
+		h.close()
+		h.close()
+	}
+	h.close()
+}
@@ -0,0 +1,36 @@
+package main
+
+// isFavorite reports whether ref was starred in the picker.
+func (st *state) isFavorite(ref clusterRef) bool {
+	for _, f := range st.Favorites {
+		if f == ref {
+			return true
+		}
+	}
+	return false
+}
+
+// toggleFavorite stars ref, or unstars it if it already is, and reports
+// whether it is starred now.
+func toggleFavorite(ref clusterRef) (bool, error) {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return false, err
+	}
+
+	starred := !st.isFavorite(ref)
+	if starred {
+		st.Favorites = append(st.Favorites, ref)
+	} else {
+		var kept []clusterRef
+		for _, f := range st.Favorites {
+			if f != ref {
+				kept = append(kept, f)
+			}
+		}
+		st.Favorites = kept
+	}
+	return starred, st.save()
+}
@@ -0,0 +1,231 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/kubeconfig"
+)
+
+// memberInfo is a fleet membership as shown by gke fleet list.
+type memberInfo struct {
+	Name     string `json:"name" yaml:"name"`
+	Location string `json:"location" yaml:"location"`
+	// Kind is gke, attached, multi-cloud, on-prem, edge or appliance.
+	Kind  string `json:"kind" yaml:"kind"`
+	State string `json:"state" yaml:"state"`
+	// LastConnected is when the Connect agent of a non-GKE cluster last
+	// reached Google Cloud.
+	LastConnected *time.Time `json:"lastConnected,omitempty" yaml:"lastConnected,omitempty"`
+	Version       string     `json:"version,omitempty" yaml:"version,omitempty"`
+	// Cluster is the project/location/cluster of a GKE member.
+	Cluster string `json:"cluster,omitempty" yaml:"cluster,omitempty"`
+}
+
+func newMemberInfo(m gke.Member) memberInfo {
+	info := memberInfo{Name: m.Name, Location: m.Location, Kind: string(m.Kind), State: m.State, Version: m.Version}
+	if !m.LastConnected.IsZero() {
+		info.LastConnected = &m.LastConnected
+	}
+	if t := m.Cluster; t != nil {
+		info.Cluster = clusterRef{Project: t.Project, Location: t.Location, Cluster: t.Cluster}.String()
+	}
+	return info
+}
+
+// connection describes how recently a member's Connect agent checked in.
+func (info memberInfo) connection() string {
+	switch {
+	case info.Kind == string(gke.MemberGKE):
+		return "-"
+	case info.LastConnected == nil:
+		return "never"
+	}
+	return formatRelative(*info.LastConnected)
+}
+
+// fleetMembers lists the memberships of the fleet hosted in project, the
+// gcloud project when empty.
+func fleetMembers(ctx context.Context, project string) (string, []gke.Member, error) {
+	if project == "" {
+		project = defaultProject()
+	}
+	if project == "" {
+		return "", nil, usageError{fmt.Errorf("no fleet host project given; pass --project")}
+	}
+	project, err := resolveProject(ctx, project)
+	if err != nil {
+		return "", nil, err
+	}
+	api, err := fleetAPI(ctx)
+	if err != nil {
+		return "", nil, err
+	}
+	members, err := gke.ListMembers(ctx, api, project)
+	if err != nil {
+		return "", nil, fmt.Errorf("failed to list the fleet memberships of %s: %v", project, err)
+	}
+	return project, members, nil
+}
+
+func newFleetCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "fleet",
+		Short: "List the clusters registered to a fleet and connect to them",
+	}
+	cmd.AddCommand(newFleetListCmd(), newFleetConnectCmd())
+	return cmd
+}
+
+func newFleetListCmd() *cobra.Command {
+	var project, output string
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List the memberships of a fleet, their state and kind",
+		Long: `Lists the clusters registered to the fleet whose host project is
+--project: GKE clusters, which may live in other projects, and attached,
+multi-cloud, on-prem and edge clusters, which are reached through the
+Connect gateway. For the latter, CONNECTED tells when their Connect agent
+last reached Google Cloud.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, members, err := fleetMembers(cmd.Context(), project)
+			if err != nil {
+				return err
+			}
+			infos := []memberInfo{}
+			for _, m := range members {
+				infos = append(infos, newMemberInfo(m))
+			}
+			return writeOutput(os.Stdout, output, infos, func(w io.Writer) {
+				fmt.Fprintln(w, "NAME\tLOCATION\tKIND\tSTATE\tCONNECTED\tVERSION\tCLUSTER")
+				for _, info := range infos {
+					cluster := info.Cluster
+					if cluster == "" {
+						cluster = "-"
+					}
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", info.Name, info.Location, info.Kind, info.State, info.connection(), info.Version, cluster)
+				}
+			})
+		},
+	}
+	cmd.Flags().StringVar(&project, "project", "", "fleet host project ID or glob (defaults to the gcloud project)")
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, csv, json or yaml")
+	return cmd
+}
+
+func newFleetConnectCmd() *cobra.Command {
+	var project string
+	cmd := &cobra.Command{
+		Use:   "connect MEMBERSHIP",
+		Short: "Connect to a cluster of the fleet",
+		Long: `Connects to a fleet membership, named NAME or LOCATION/NAME when the
+name is used in several locations. GKE members are connected to like any
+other cluster, adding your IP to their authorized networks. For other
+members, such as EKS, AKS and other attached clusters, a context reaching
+them through the fleet's Connect gateway is written, named like gcloud's:
+connectgateway_PROJECT_LOCATION_NAME.`,
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFleetConnect(cmd.Context(), project, args[0])
+		},
+	}
+	cmd.Flags().StringVar(&project, "project", "", "fleet host project ID or glob (defaults to the gcloud project)")
+	addPrintCommandsFlag(cmd)
+	return cmd
+}
+
+func runFleetConnect(ctx context.Context, project, name string) error {
+	project, members, err := fleetMembers(ctx, project)
+	if err != nil {
+		return err
+	}
+	location, name, ok := strings.Cut(name, "/")
+	if !ok {
+		location, name = "", location
+	}
+	var matches []gke.Member
+	for _, m := range members {
+		if m.Name == name && (location == "" || m.Location == location) {
+			matches = append(matches, m)
+		}
+	}
+	if len(matches) == 0 {
+		return fmt.Errorf("the fleet of %s has no membership %s; see gke fleet list", project, name)
+	}
+	if len(matches) > 1 {
+		return usageError{fmt.Errorf("%s is a membership in several locations; name one as LOCATION/%s", name, name)}
+	}
+	member := matches[0]
+
+	start := time.Now()
+	if t := member.Cluster; t != nil {
+		config, err := connectAliasCluster(ctx, connectAlias{Project: t.Project, Location: t.Location, Cluster: t.Cluster})
+		recordHistory(os.Args[1:], &clusterRef{Project: t.Project, Location: t.Location, Cluster: t.Cluster}, start, err)
+		if err != nil || printCommands {
+			return err
+		}
+		runPostConnectHooks(ctx, config)
+		return nil
+	}
+	if printCommands {
+		fmt.Printf("# Connect to fleet member %s/%s through the Connect gateway\n", member.Location, member.Name)
+		fmt.Println(strings.Join([]string{"gcloud", "container", "fleet", "memberships", "get-credentials", shellQuote(member.Name),
+			"--location", member.Location, "--project", project}, " "))
+		return nil
+	}
+	err = connectGateway(ctx, project, member)
+	recordHistory(os.Args[1:], nil, start, err)
+	return err
+}
+
+// connectGateway writes a kubeconfig context for a member that isn't a GKE
+// cluster, such as an EKS or AKS cluster, reaching it through the Connect
+// gateway of the fleet's host project. Like GKE contexts, it goes to its
+// own file when the config sets a kubeconfigDir.
+func connectGateway(ctx context.Context, project string, member gke.Member) error {
+	api, err := projectAPI(ctx)
+	if err != nil {
+		return err
+	}
+	number, err := gke.ProjectNumber(ctx, api, project)
+	if err != nil {
+		return err
+	}
+	name := kubeconfig.GatewayContextName(project, member.Location, member.Name)
+	path, editor := sharedKubeconfigFile(), kube
+	if dir := kubeconfigDir(); dir != "" {
+		path = filepath.Join(dir, name+".yaml")
+		editor = kubeconfig.New(tracedRunner{kubeconfig.Kubectl{Path: path}})
+		if err := os.MkdirAll(dir, 0o700); err != nil {
+			return fmt.Errorf("failed to create kubeconfig directory: %v", err)
+		}
+	}
+	if err := backupKubeconfig(path); err != nil {
+		return err
+	}
+
+	fmt.Printf("🔑 Configuring Connect gateway credentials for %s (%s)...\n", member.Name, member.Kind)
+	if err := editor.WriteGateway(name, gke.GatewayServer(number, member), !usesGcloudIdentity()); err != nil {
+		return err
+	}
+	if namespaceFlag != "" {
+		if err := editor.SetContextNamespace(name, namespaceFlag); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("\n✨ Successfully configured credentials for fleet member: %s\n", member.Name)
+	fmt.Printf("🚀 kubectl now talks to it through the Connect gateway\n")
+	fmt.Printf("📝 Current context: %s\n\n", name)
+	if path != sharedKubeconfigFile() {
+		fmt.Printf("📄 Credentials written to %s; to use them:\n   export KUBECONFIG=%s\n", path, path)
+	}
+	return nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Human output formats durations, times and counts through these helpers so
+// the TUI, reports and history read alike. Logs and machine-readable output
+// keep Go's and RFC 3339 formats.
+
+// timeLayout is how absolute times are shown.
+const timeLayout = "Mon Jan 2 15:04"
+
+func formatTime(t time.Time) string {
+	return t.Local().Format(timeLayout)
+}
+
+// formatDuration renders d with its two most significant units, e.g.
+// "850ms", "14s", "2m 14s", "1h 5m" or "3d 4h".
+func formatDuration(d time.Duration) string {
+	if d < 0 {
+		d = -d
+	}
+	if d < time.Second {
+		return d.Round(time.Millisecond).String()
+	}
+	d = d.Round(time.Second)
+	units := []struct {
+		size time.Duration
+		name string
+	}{
+		{24 * time.Hour, "d"},
+		{time.Hour, "h"},
+		{time.Minute, "m"},
+		{time.Second, "s"},
+	}
+	for i, unit := range units {
+		if d < unit.size {
+			continue
+		}
+		s := fmt.Sprintf("%d%s", d/unit.size, unit.name)
+		if i+1 < len(units) {
+			next := units[i+1]
+			if n := d % unit.size / next.size; n > 0 {
+				s += fmt.Sprintf(" %d%s", n, next.name)
+			}
+		}
+		return s
+	}
+	return "0s"
+}
+
+// formatRelative renders t relative to now in its largest whole unit, e.g.
+// "just now", "5 minutes ago", "3 days ago" or "in 2 hours".
+func formatRelative(t time.Time) string {
+	d := time.Until(t)
+	future := d > 0
+	if !future {
+		d = -d
+	}
+	if d < time.Minute {
+		return "just now"
+	}
+
+	var amount string
+	switch {
+	case d < time.Hour:
+		amount = pluralize(int(d/time.Minute), "minute")
+	case d < 24*time.Hour:
+		amount = pluralize(int(d/time.Hour), "hour")
+	default:
+		amount = pluralize(int(d/(24*time.Hour)), "day")
+	}
+	if future {
+		return "in " + amount
+	}
+	return amount + " ago"
+}
+
+// formatRTT renders a round-trip time the way listings show it.
+func formatRTT(rtt time.Duration) string {
+	return "~" + formatDuration(rtt)
+}
+
+// pluralize renders a count of noun, e.g. "1 cluster", "3 clusters" or
+// "2 entries".
+func pluralize(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	switch {
+	case strings.HasSuffix(noun, "y") && !strings.HasSuffix(noun, "ey"):
+		noun = strings.TrimSuffix(noun, "y") + "ies"
+	case strings.HasSuffix(noun, "s"), strings.HasSuffix(noun, "x"):
+		noun += "es"
+	default:
+		noun += "s"
+	}
+	return fmt.Sprintf("%d %s", n, noun)
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+)
+
+// gcloudConfiguration is one entry of `gcloud config configurations list`.
+type gcloudConfiguration struct {
+	Name       string `json:"name"`
+	IsActive   bool   `json:"is_active"`
+	Properties struct {
+		Core struct {
+			Account string `json:"account"`
+			Project string `json:"project"`
+		} `json:"core"`
+	} `json:"properties"`
+}
+
+func listGcloudConfigurations() ([]gcloudConfiguration, error) {
+	output, err := exec.Command("gcloud", "config", "configurations", "list", "--format=json").Output()
+	if err != nil {
+		return nil, fmt.Errorf("failed to list gcloud configurations: %v", err)
+	}
+
+	var configurations []gcloudConfiguration
+	if err := json.Unmarshal(output, &configurations); err != nil {
+		return nil, fmt.Errorf("failed to parse gcloud configurations: %v", err)
+	}
+	return configurations, nil
+}
+
+// findGcloudConfiguration returns the configuration called name.
+func findGcloudConfiguration(name string) (gcloudConfiguration, error) {
+	configurations, err := listGcloudConfigurations()
+	if err != nil {
+		return gcloudConfiguration{}, err
+	}
+	for _, c := range configurations {
+		if c.Name == name {
+			return c, nil
+		}
+	}
+	return gcloudConfiguration{}, usageError{fmt.Errorf("no gcloud configuration named %q; see gcloud config configurations list", name)}
+}
+
+// useGcloudConfiguration scopes every gcloud invocation made by this process,
+// including get-credentials, to configuration c without changing the
+// globally active one. Its account becomes the session's, so that API
+// calls are made as the same identity.
+func useGcloudConfiguration(c gcloudConfiguration) {
+	os.Setenv("CLOUDSDK_ACTIVE_CONFIG_NAME", c.Name)
+	if c.Properties.Core.Account != "" {
+		useGcloudAccount(c.Properties.Core.Account)
+	}
+}
+
+func configurationLabel(c gcloudConfiguration) string {
+	label := c.Name
+	if c.Properties.Core.Account != "" {
+		label += " (" + c.Properties.Core.Account
+		if c.Properties.Core.Project != "" {
+			label += ", " + c.Properties.Core.Project
+		}
+		label += ")"
+	}
+	if c.IsActive {
+		label += " [active]"
+	}
+	return label
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+)
+
+// accessFor limits how long the authorized network entry added in this run
+// stays on the cluster. Zero means until it is replaced.
+var accessFor time.Duration
+
+// grant is a time-boxed authorized network entry that is removed once it
+// expires.
+type grant struct {
+	Cluster clusterRef `json:"cluster"`
+	Entry   man.Entry  `json:"entry"`
+	Expires time.Time  `json:"expires"`
+}
+
+// recordGrant remembers when entry should leave the cluster. Without a time
+// limit an existing grant keeps its expiry but follows the entry's new CIDR,
+// so background updates never turn time-boxed access into permanent access.
+// In safe mode a time limit can't be kept, so it is an error.
+func recordGrant(config GKEConfig, entry man.Entry) error {
+	if safeMode["state"] && accessFor > 0 {
+		return fmt.Errorf("the state file is ignored in safe mode, so the entry added to %s can't be removed after %s; remove it with gke cleanup", config.Cluster, accessFor)
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+
+	for i, g := range st.Grants {
+		if g.Cluster != ref || g.Entry.DisplayName != entry.DisplayName {
+			continue
+		}
+		if accessFor > 0 {
+			g.Expires = time.Now().Add(accessFor).Round(time.Second)
+		}
+		g.Entry = entry
+		st.Grants[i] = g
+		return st.save()
+	}
+	if accessFor == 0 {
+		return nil
+	}
+	st.Grants = append(st.Grants, grant{Cluster: ref, Entry: entry, Expires: time.Now().Add(accessFor).Round(time.Second)})
+	return st.save()
+}
+
+// forgetGrants drops the grants of a cluster whose entries were removed.
+func forgetGrants(config GKEConfig) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+
+	var kept []grant
+	for _, g := range st.Grants {
+		if g.Cluster != ref {
+			kept = append(kept, g)
+		}
+	}
+	if len(kept) == len(st.Grants) {
+		return nil
+	}
+	st.Grants = kept
+	return st.save()
+}
+
+// expireGrants removes the entries of every grant past its expiry. Grants
+// that fail to expire are kept and retried next time.
+func expireGrants(ctx context.Context, logf func(format string, args ...interface{})) error {
+	stateMu.Lock()
+	st, err := loadState()
+	stateMu.Unlock()
+	if err != nil {
+		return err
+	}
+
+	// The state lock isn't held while talking to GKE; grants recorded
+	// meanwhile are kept by reloading the state before saving.
+	now := time.Now()
+	var revoked []grant
+	failed := 0
+	for _, g := range st.Grants {
+		if now.Before(g.Expires) {
+			continue
+		}
+		if err := revokeEntry(ctx, g.Cluster, g.Entry); err != nil {
+			failed++
+			logf("❌ Failed to remove expired access to %s: %v\n", g.Cluster, err)
+			continue
+		}
+		revoked = append(revoked, g)
+		logf("⏰ Access to %s expired, removed %s (%s)\n", g.Cluster, g.Entry.DisplayName, g.Entry.CIDR)
+	}
+
+	if len(revoked) > 0 {
+		if err := dropGrants(revoked); err != nil {
+			return err
+		}
+	}
+	if failed > 0 {
+		return fmt.Errorf("%s could not be removed", pluralize(failed, "expired grant"))
+	}
+	return nil
+}
+
+// dropGrants removes grants from the state file.
+func dropGrants(grants []grant) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	var kept []grant
+	for _, g := range st.Grants {
+		dropped := false
+		for _, d := range grants {
+			if g.Cluster == d.Cluster && g.Entry == d.Entry && g.Expires.Equal(d.Expires) {
+				dropped = true
+			}
+		}
+		if !dropped {
+			kept = append(kept, g)
+		}
+	}
+	st.Grants = kept
+	return st.save()
+}
+
+// revokeEntry removes entry from the cluster's authorized networks. An entry
+// that is already gone, or whose CIDR has since changed, is left alone.
+func revokeEntry(ctx context.Context, ref clusterRef, entry man.Entry) error {
+	config, cluster, err := resolveCluster(ctx, ref.Project, ref.Location, ref.Cluster)
+	if err != nil {
+		return err
+	}
+
+	current := gke.AuthorizedEntries(cluster)
+	var remaining []man.Entry
+	for _, e := range current {
+		if e != entry {
+			remaining = append(remaining, e)
+		}
+	}
+	if len(remaining) == len(current) {
+		return nil
+	}
+	// Removing your own access is never held up by the pre-change hooks.
+	return setAuthorizedNetworks(ctx, config, cluster, remaining, nil)
+}
@@ -0,0 +1,254 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slog"
+)
+
+// hibernation is a cluster whose node pools gke hibernate scaled to zero.
+type hibernation struct {
+	Cluster clusterRef `json:"cluster"`
+	// Pools maps the node pools scaled down to their previous size per
+	// zone. Pools are removed as they are resumed.
+	Pools map[string]int64 `json:"pools"`
+	Since time.Time        `json:"since"`
+}
+
+// hibernationOf returns ref's hibernation, if it has one.
+func (st *state) hibernationOf(ref clusterRef) (hibernation, bool) {
+	for _, h := range st.Hibernations {
+		if h.Cluster == ref {
+			return h, true
+		}
+	}
+	return hibernation{}, false
+}
+
+// saveHibernation records h, replacing any earlier record for its cluster,
+// or forgets the cluster once no pools are left to resume.
+func saveHibernation(h hibernation) error {
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	var kept []hibernation
+	for _, other := range st.Hibernations {
+		if other.Cluster != h.Cluster {
+			kept = append(kept, other)
+		}
+	}
+	if len(h.Pools) > 0 {
+		kept = append(kept, h)
+	}
+	st.Hibernations = kept
+	return st.save()
+}
+
+// poolNames returns the keys of pools in order.
+func poolNames(pools map[string]int64) []string {
+	var names []string
+	for name := range pools {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func newHibernateCmd() *cobra.Command {
+	var target targetOptions
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "hibernate",
+		Short: "Scale every node pool of a Standard cluster to zero",
+		Long: `Scales every node pool of a Standard cluster to zero nodes, remembering
+their sizes so that gke resume can scale them back. The control plane
+keeps running and is still billed; the nodes are not. Autopilot clusters
+have no node pools to scale.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runHibernate(cmd.Context(), target, yes)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().BoolVar(&yes, "yes", false, "hibernate without asking for confirmation")
+	return cmd
+}
+
+func newResumeCmd() *cobra.Command {
+	var target targetOptions
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "resume",
+		Short: "Scale a hibernated cluster's node pools back to their previous sizes",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runResume(cmd.Context(), target, yes)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().BoolVar(&yes, "yes", false, "resume without asking for confirmation")
+	return cmd
+}
+
+func runHibernate(ctx context.Context, target targetOptions, yes bool) error {
+	if safeMode["state"] {
+		return fmt.Errorf("the state file is ignored in safe mode, so the node pool sizes couldn't be remembered for gke resume")
+	}
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	if cluster.Autopilot != nil && cluster.Autopilot.Enabled {
+		return fmt.Errorf("%s is an Autopilot cluster, which has no node pools to scale", config.Cluster)
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	h, ok := st.hibernationOf(ref)
+	if !ok {
+		h = hibernation{Cluster: ref, Pools: make(map[string]int64), Since: time.Now()}
+	}
+
+	sizes, err := nodePoolSizes(ctx, cluster)
+	if err != nil {
+		return err
+	}
+	var changes []string
+	for _, pool := range nodePools(cluster) {
+		size := sizes[pool.Name]
+		if size == 0 {
+			continue
+		}
+		// A pool already recorded was resized again since; keep the size
+		// it had before the first hibernation.
+		if _, recorded := h.Pools[pool.Name]; !recorded {
+			h.Pools[pool.Name] = size
+		}
+		changes = append(changes, fmt.Sprintf("%s: %d → 0 nodes per zone", pool.Name, size))
+		if pool.Autoscaling != "" {
+			fmt.Printf("⚠️  %s autoscales (%s) and may scale up again for pending pods\n", pool.Name, pool.Autoscaling)
+		}
+	}
+	if len(changes) == 0 {
+		fmt.Printf("💤 Every node pool of %s is already at zero\n", config.Cluster)
+		return nil
+	}
+	fmt.Printf("Hibernate %s, scaling its node pools to zero:\n", config.Cluster)
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+	}
+	fmt.Println()
+
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	if err := runPreChangeHooks(ctx, config, "hibernate"); err != nil {
+		return err
+	}
+	req := newApproval("hibernate", "Hibernate it?", []string{ref.String()}, changes)
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+	// The sizes are saved first so that a failure part way through can
+	// still be resumed.
+	if err := saveHibernation(h); err != nil {
+		return fmt.Errorf("failed to remember the node pool sizes: %v", err)
+	}
+
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	for _, pool := range nodePools(cluster) {
+		if sizes[pool.Name] == 0 {
+			continue
+		}
+		if err := resizeNodePool(ctx, api, config, pool.Name, 0); err != nil {
+			return fmt.Errorf("%w; gke resume restores the pools scaled down so far", err)
+		}
+	}
+	fmt.Printf("💤 Hibernated %s; run gke resume to scale it back up\n", config.Cluster)
+	return nil
+}
+
+func runResume(ctx context.Context, target targetOptions, yes bool) error {
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	h, ok := st.hibernationOf(ref)
+	if !ok {
+		return fmt.Errorf("%s was not hibernated by gke hibernate", config.Cluster)
+	}
+
+	pools, err := liveNodePools(ctx, cluster)
+	if err != nil {
+		slog.Warn("couldn't read the node pool sizes", "err", err)
+	}
+	existing := make(map[string]nodePoolInfo)
+	for _, pool := range pools {
+		existing[pool.Name] = pool
+	}
+	var changes []string
+	for _, name := range poolNames(h.Pools) {
+		pool, ok := existing[name]
+		if !ok {
+			fmt.Printf("⚠️  Node pool %s no longer exists; skipping it\n", name)
+			delete(h.Pools, name)
+			continue
+		}
+		changes = append(changes, fmt.Sprintf("%s: %s → %d nodes per zone", name, pool.nodes(), h.Pools[name]))
+	}
+	if len(changes) == 0 {
+		return saveHibernation(h)
+	}
+	fmt.Printf("Resume %s, hibernated since %s:\n", config.Cluster, formatTime(h.Since))
+	for _, change := range changes {
+		fmt.Printf("  %s\n", change)
+	}
+	fmt.Println()
+
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	if err := runPreChangeHooks(ctx, config, "resume"); err != nil {
+		return err
+	}
+	req := newApproval("resume", "Resume it?", []string{ref.String()}, changes)
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	for _, name := range poolNames(h.Pools) {
+		if err := resizeNodePool(ctx, api, config, name, h.Pools[name]); err != nil {
+			if saveErr := saveHibernation(h); saveErr != nil {
+				fmt.Printf("⚠️  %v\n", saveErr)
+			}
+			return err
+		}
+		delete(h.Pools, name)
+	}
+	if err := saveHibernation(h); err != nil {
+		return err
+	}
+	fmt.Printf("✨ Resumed %s\n", config.Cluster)
+	return nil
+}
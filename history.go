@@ -0,0 +1,238 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"github.com/spf13/pflag"
+)
+
+// maxHistory is how many runs the history file keeps.
+const maxHistory = 100
+
+// maxRecent is how many recently connected clusters the picker offers.
+const maxRecent = 3
+
+// historyEntry is one previous run, recorded with the arguments that repeat
+// it non-interactively.
+type historyEntry struct {
+	Time    time.Time `json:"time"`
+	Args    []string  `json:"args"`
+	Cluster string    `json:"cluster,omitempty"`
+	// Ref is the cluster connected to, for runs that connect to one.
+	Ref      *clusterRef   `json:"ref,omitempty"`
+	Error    string        `json:"error,omitempty"`
+	Duration time.Duration `json:"duration"`
+}
+
+// command is the shell command equivalent to the run.
+func (h historyEntry) command() string {
+	parts := []string{"gke"}
+	for _, arg := range withoutConsent(h.Args) {
+		parts = append(parts, shellQuote(arg))
+	}
+	return strings.Join(parts, " ")
+}
+
+// shellQuote quotes arg for a POSIX shell when it needs it.
+func shellQuote(arg string) string {
+	if arg == "" || strings.ContainsAny(arg, " \t'\"*?$;&|<>()`\\") {
+		return "'" + strings.ReplaceAll(arg, "'", `'\''`) + "'"
+	}
+	return arg
+}
+
+func (h historyEntry) label() string {
+	outcome := "✅"
+	if h.Error != "" {
+		outcome = "❌"
+	}
+	label := fmt.Sprintf("%s %s  %s", formatTime(h.Time), outcome, h.command())
+	if h.Cluster != "" {
+		label += "  [" + h.Cluster + "]"
+	}
+	return label + fmt.Sprintf(" (%s)", formatDuration(h.Duration))
+}
+
+// ref returns the cluster a connect went to, if the run was one. Entries
+// recorded before Ref existed are read from the batch arguments.
+func (h historyEntry) ref() (clusterRef, bool) {
+	if h.Ref != nil {
+		return *h.Ref, true
+	}
+	if h.Cluster == "" {
+		return clusterRef{}, false
+	}
+	ref := clusterRef{Project: flagValue(h.Args, "--project"), Location: flagValue(h.Args, "--location"), Cluster: h.Cluster}
+	return ref, ref.Project != ""
+}
+
+// recentClusters returns up to n clusters last connected to successfully,
+// most recent first.
+func recentClusters(history []historyEntry, n int) []clusterRef {
+	var refs []clusterRef
+	seen := make(map[clusterRef]bool)
+	for _, h := range history {
+		ref, ok := h.ref()
+		if !ok || h.Error != "" || seen[ref] {
+			continue
+		}
+		seen[ref] = true
+		if refs = append(refs, ref); len(refs) == n {
+			break
+		}
+	}
+	return refs
+}
+
+func newLastCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "last",
+		Short: "Connect again to the cluster last connected to",
+		Long: `Re-runs the most recent successful connect, made through the picker or
+with an alias, with the same flags and without any prompts.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			history, err := loadHistory()
+			if err != nil {
+				return err
+			}
+			for _, h := range history {
+				if _, ok := h.ref(); ok && h.Error == "" {
+					return rerun(h)
+				}
+			}
+			return fmt.Errorf("no connect recorded yet; run gke first")
+		},
+	}
+}
+
+func historyPath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "history.json"), nil
+}
+
+// loadHistory returns previous runs, most recent first.
+func loadHistory() ([]historyEntry, error) {
+	path, err := historyPath()
+	if err != nil || safeMode["history"] {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read history: %v", err)
+	}
+	var history []historyEntry
+	if err := json.Unmarshal(data, &history); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return history, nil
+}
+
+// consentFlags are the flags that answer confirmations in advance. They
+// aren't recorded, so repeating a run asks again instead of going ahead on
+// consent given for the original run.
+var consentFlags = map[string]bool{
+	"--yes":             false,
+	"--allow-managed":   false,
+	"--approve-file":    true,
+	"--approve-webhook": true,
+}
+
+// withoutConsent returns args without the consentFlags, which take a
+// separate value when mapped to true.
+func withoutConsent(args []string) []string {
+	var kept []string
+	for i := 0; i < len(args); i++ {
+		name, _, hasValue := strings.Cut(args[i], "=")
+		takesValue, ok := consentFlags[name]
+		if !ok {
+			kept = append(kept, args[i])
+			continue
+		}
+		if takesValue && !hasValue {
+			i++
+		}
+	}
+	return kept
+}
+
+// recordHistory adds a finished run to the history; ref is the cluster it
+// connected to, if any. Failures are ignored: history is a convenience and
+// must never break a run.
+func recordHistory(args []string, ref *clusterRef, start time.Time, runErr error) {
+	// Printing the commands of a connect isn't connecting.
+	if safeMode["history"] || printCommands {
+		return
+	}
+	history, err := loadHistory()
+	if err != nil {
+		return
+	}
+
+	entry := historyEntry{Time: start, Args: withoutConsent(args), Ref: ref, Duration: time.Since(start)}
+	if ref != nil {
+		entry.Cluster = ref.Cluster
+	}
+	if runErr != nil {
+		entry.Error = runErr.Error()
+	}
+	history = append([]historyEntry{entry}, history...)
+	if len(history) > maxHistory {
+		history = history[:maxHistory]
+	}
+
+	path, err := historyPath()
+	if err != nil {
+		return
+	}
+	data, err := json.MarshalIndent(history, "", "  ")
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+		os.WriteFile(path, data, 0o600)
+	}
+}
+
+// connectArgs returns the arguments that connect to config without the
+// TUI, keeping the global flags of this run.
+func connectArgs(config GKEConfig) []string {
+	var args []string
+	globalFlags.Visit(func(f *pflag.Flag) {
+		args = append(args, "--"+f.Name+"="+f.Value.String())
+	})
+	return append(args, "batch", "--project", config.ProjectID, "--location", config.Region, "--clusters", config.Cluster)
+}
+
+// rerun executes a previous run again with this binary. Consent flags are
+// dropped here too, for runs recorded before they were left out.
+func rerun(h historyEntry) error {
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	fmt.Printf("🔁 %s\n\n", h.command())
+	cmd := exec.Command(self, withoutConsent(h.Args)...)
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	if err := cmd.Run(); err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return childError{exitErr}
+		}
+		return err
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+// preChangeTimeout bounds each pre-change hook, which may run unattended
+// from watch or the daemon.
+const preChangeTimeout = time.Minute
+
+// hookEnv returns the environment hooks run with: this process's, plus the
+// cluster in MY_GKE_* variables.
+func hookEnv(config GKEConfig) []string {
+	env := append(os.Environ(),
+		"MY_GKE_PROJECT="+config.ProjectID,
+		"MY_GKE_LOCATION="+config.Region,
+		"MY_GKE_CLUSTER="+config.Cluster,
+		"MY_GKE_CONTEXT="+contextName(config),
+	)
+	if path := kubeconfigPath(config); path != "" {
+		env = append(env, "KUBECONFIG="+path)
+	}
+	return env
+}
+
+// runPreChangeHooks runs the config's preChange commands with sh before
+// config's authorized networks, node pools, version or maintenance policy
+// are changed. action says why: "connect" adds your entry, "apply" replaces
+// the list, "resize", "hibernate" and "resume" resize node pools, "upgrade"
+// upgrades the control plane and "maintenance" changes the maintenance
+// exclusions. The first hook that fails vetoes the change. A config file
+// that can't be read, or is ignored in safe mode, vetoes every change, as
+// its hooks can't be run.
+func runPreChangeHooks(ctx context.Context, config GKEConfig, action string) error {
+	if safeMode["config"] {
+		return fmt.Errorf("%w: the config file is ignored in safe mode, so its pre-change hooks can't vet changing %s; fix or move it aside first", errAborted, config.Cluster)
+	}
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return fmt.Errorf("%w: can't run the pre-change hooks before changing %s: %v", errAborted, config.Cluster, err)
+	}
+	if len(cfg.PreChange) == 0 {
+		return nil
+	}
+
+	env := append(hookEnv(config), "MY_GKE_ACTION="+action)
+	for _, hook := range cfg.PreChange {
+		hookCtx, cancel := context.WithTimeout(ctx, preChangeTimeout)
+		cmd := exec.CommandContext(hookCtx, "sh", "-c", hook)
+		cmd.Env = env
+		output, err := cmd.CombinedOutput()
+		cancel()
+		if err != nil {
+			reason := strings.TrimSpace(string(output))
+			if reason == "" {
+				reason = err.Error()
+			}
+			return fmt.Errorf("%w: pre-change hook %q refused changing %s: %s", errAborted, hook, config.Cluster, reason)
+		}
+	}
+	return nil
+}
+
+// runPostConnectHooks runs the config's postConnect commands with sh, in
+// order, once config's cluster is connected. A failing hook is reported
+// but doesn't undo the connect.
+func runPostConnectHooks(ctx context.Context, config GKEConfig) {
+	cfg, err := loadUserConfig()
+	if err != nil || len(cfg.PostConnect) == 0 {
+		return
+	}
+
+	env := hookEnv(config)
+	for _, hook := range cfg.PostConnect {
+		fmt.Printf("🪝 %s\n", hook)
+		cmd := exec.CommandContext(ctx, "sh", "-c", hook)
+		cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+		cmd.Env = env
+		if err := cmd.Run(); err != nil {
+			fmt.Fprintf(os.Stderr, "⚠️  Hook failed: %v\n", err)
+		}
+	}
+}
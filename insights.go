@@ -0,0 +1,97 @@
+package main
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// defaultInsightTypes are the Recommender insight types checked when the
+// config names none: GKE's diagnosis insights, which flag idle clusters
+// and over-provisioned node pools among other things.
+var defaultInsightTypes = []string{"google.container.DiagnosisInsight"}
+
+// insightStyle marks clusters with Recommender insights.
+var insightStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("105"))
+
+func insightTypes() []string {
+	if cfg, err := loadUserConfig(); err == nil && len(cfg.InsightTypes) > 0 {
+		return cfg.InsightTypes
+	}
+	return defaultInsightTypes
+}
+
+// clusterInsights summarizes the active Recommender insights about each
+// cluster of projectID, keyed by clusterKey. Insights are listed once per
+// location, concurrently; locations whose insights can't be listed, for
+// example because the API isn't enabled, are skipped.
+func clusterInsights(ctx context.Context, projectID string, clusters []*container.Cluster) map[string][]string {
+	api, err := insightAPI(ctx)
+	if err != nil {
+		slog.Debug("not checking for insights", "err", err)
+		return nil
+	}
+	types := insightTypes()
+	var locations []string
+	for _, cluster := range clusters {
+		locations = append(locations, cluster.Location)
+	}
+
+	var found []gke.Insight
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, location := range unique(locations) {
+		wg.Add(1)
+		go func(location string) {
+			defer wg.Done()
+			insights, err := gke.ListInsights(ctx, api, projectID, location, types)
+			if err != nil {
+				slog.Debug("failed to list insights", "project", projectID, "location", location, "err", err)
+				return
+			}
+			mu.Lock()
+			found = append(found, insights...)
+			mu.Unlock()
+		}(location)
+	}
+	wg.Wait()
+
+	byCluster := make(map[string][]string)
+	for _, insight := range found {
+		key := clusterKey(&container.Cluster{Name: insight.Cluster, Location: insight.Location})
+		byCluster[key] = append(byCluster[key], insight.Summary())
+	}
+	for key, summaries := range byCluster {
+		byCluster[key] = unique(summaries)
+	}
+	return byCluster
+}
+
+// insightsMsg delivers the insight summaries to the cluster list.
+type insightsMsg struct {
+	projectID string
+	insights  map[string][]string
+}
+
+// checkInsights looks for Recommender insights in the background.
+func checkInsights(projectID string, clusters []*container.Cluster) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		return insightsMsg{projectID: projectID, insights: clusterInsights(ctx, projectID, clusters)}
+	}
+}
+
+// insightLabel is the insights suffix of a cluster in the list.
+func (m *model) insightLabel(cluster *container.Cluster) string {
+	insights := m.insights[clusterKey(cluster)]
+	if len(insights) == 0 {
+		return ""
+	}
+	return " " + insightStyle.Render("💡 "+strings.Join(insights, ", "))
+}
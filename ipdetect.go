@@ -0,0 +1,129 @@
+package main
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"cloud.google.com/go/compute/metadata"
+	"golang.org/x/exp/slog"
+)
+
+const ipEchoURL = "https://api.ipify.org"
+
+// caBundle is an extra PEM file of root CAs trusted for the IP echo call,
+// for corporate proxies that re-sign TLS traffic.
+var caBundle string
+
+// ipSource selects how the public IP is detected: "auto" (metadata server
+// on GCP, HTTP echo elsewhere), "metadata", "http" or "stun". It comes from
+// --ip-source or the ipSource config setting.
+var ipSource string
+
+func getCurrentPublicIP() (string, error) {
+	ip, err := detectPublicIP()
+	slog.Debug("detected public IP", "source", ipSource, "ip", ip, "err", err)
+	return ip, err
+}
+
+func detectPublicIP() (string, error) {
+	source, stunServer := ipSource, ""
+	if cfg, err := loadUserConfig(); err == nil {
+		if source == "" {
+			source = cfg.IPSource
+		}
+		stunServer = cfg.STUNServer
+	}
+
+	switch source {
+	case "", "auto":
+		// On GCE VMs and Cloud Workstations the metadata server knows the
+		// external IP, which is faster and works when egress to third-party
+		// sites is blocked. VMs without an external IP (e.g. behind Cloud
+		// NAT) fall through to the echo service.
+		if metadata.OnGCE() {
+			if ip, err := getMetadataPublicIP(); err == nil {
+				return ip, nil
+			}
+		}
+		return getEchoPublicIP()
+	case "metadata":
+		return getMetadataPublicIP()
+	case "http":
+		return getEchoPublicIP()
+	case "stun":
+		return getSTUNPublicIP(stunServer)
+	default:
+		return "", fmt.Errorf("unknown IP source %q; use auto, metadata, http or stun", source)
+	}
+}
+
+func getMetadataPublicIP() (string, error) {
+	ip, err := metadata.ExternalIP()
+	if err != nil {
+		return "", fmt.Errorf("failed to get external IP from metadata server: %v", err)
+	}
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("this VM has no external IP")
+	}
+	return ip, nil
+}
+
+func getEchoPublicIP() (string, error) {
+	client, err := echoHTTPClient()
+	if err != nil {
+		return "", err
+	}
+
+	resp, err := client.Get(ipEchoURL)
+	if err != nil {
+		return "", fmt.Errorf("failed to get public IP: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("failed to get public IP: %s returned %s", ipEchoURL, resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 64))
+	if err != nil {
+		return "", fmt.Errorf("failed to read response: %v", err)
+	}
+
+	ip := strings.TrimSpace(string(body))
+	if net.ParseIP(ip) == nil {
+		return "", fmt.Errorf("unexpected response from %s: %q", ipEchoURL, ip)
+	}
+	return ip, nil
+}
+
+// echoHTTPClient returns a client that honors HTTP_PROXY, HTTPS_PROXY and
+// NO_PROXY, trusts caBundle in addition to the system roots, and gives up
+// after a bounded time instead of hanging the whole tool.
+func echoHTTPClient() (*http.Client, error) {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.Proxy = http.ProxyFromEnvironment
+
+	if caBundle != "" {
+		pem, err := os.ReadFile(caBundle)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read CA bundle: %v", err)
+		}
+		roots, err := x509.SystemCertPool()
+		if err != nil {
+			roots = x509.NewCertPool()
+		}
+		if !roots.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("no certificates found in %s", caBundle)
+		}
+		transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	}
+
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}, nil
+}
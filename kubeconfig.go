@@ -0,0 +1,385 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/lipgloss"
+	"gke-tool/pkg/kubeconfig"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// kube edits the user's kubeconfig.
+var kube = kubeconfig.New(tracedRunner{kubeconfig.Kubectl{}})
+
+// kubeconfigFlag is --kubeconfig. When set, it is exported as KUBECONFIG so
+// gcloud and kubectl use it, and per-cluster files are not written.
+var kubeconfigFlag string
+
+// namespaceFlag is --namespace, the namespace to set on the written context.
+var namespaceFlag string
+
+// pickNamespaceFlag is --pick-namespace.
+var pickNamespaceFlag bool
+
+// pickNamespace reports whether the picker offers the connected cluster's
+// namespaces, as asked by --pick-namespace or the config file. --namespace
+// already picks one.
+func pickNamespace() bool {
+	if namespaceFlag != "" {
+		return false
+	}
+	if pickNamespaceFlag {
+		return true
+	}
+	cfg, err := loadUserConfig()
+	return err == nil && cfg.PickNamespace
+}
+
+// useNamespace sets namespace on config's context, and on its alias when
+// that is the current context, and remembers it.
+func useNamespace(config GKEConfig, namespace string) error {
+	name, kube := contextName(config), kubeFor(config)
+	if err := kube.SetContextNamespace(name, namespace); err != nil {
+		return err
+	}
+	if current, err := kube.CurrentContext(); err == nil && current != name {
+		if err := kube.SetContextNamespace(current, namespace); err != nil {
+			return err
+		}
+	}
+
+	return saveNamespace(name, namespace)
+}
+
+// defaultNamespace returns the namespace the config file sets for config's
+// cluster, keyed by "project/location/cluster" or just the cluster name.
+func defaultNamespace(config GKEConfig) string {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return ""
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	if namespace, ok := cfg.Namespaces[ref.String()]; ok {
+		return namespace
+	}
+	return cfg.Namespaces[config.Cluster]
+}
+
+// kubeconfigPath returns the per-cluster kubeconfig file config's
+// credentials go to when the config file sets kubeconfigDir, or "" when
+// they go to the shared kubeconfig.
+func kubeconfigPath(config GKEConfig) string {
+	dir := kubeconfigDir()
+	if dir == "" {
+		return ""
+	}
+	return filepath.Join(dir, contextName(config)+".yaml")
+}
+
+// kubeconfigDir returns the config file's kubeconfigDir with "~/" expanded,
+// or "" when credentials go to the shared kubeconfig.
+func kubeconfigDir() string {
+	if kubeconfigFlag != "" {
+		return ""
+	}
+	cfg, err := loadUserConfig()
+	if err != nil || cfg.KubeconfigDir == "" {
+		return ""
+	}
+	dir := cfg.KubeconfigDir
+	if rest, ok := strings.CutPrefix(dir, "~/"); ok {
+		if home, err := os.UserHomeDir(); err == nil {
+			dir = filepath.Join(home, rest)
+		}
+	}
+	return dir
+}
+
+// kubeFor returns the editor for the kubeconfig holding config's context.
+func kubeFor(config GKEConfig) *kubeconfig.Editor {
+	if path := kubeconfigPath(config); path != "" {
+		return kubeconfig.New(tracedRunner{kubeconfig.Kubectl{Path: path}})
+	}
+	return kube
+}
+
+// tracedRunner logs every kubectl invocation at trace level.
+type tracedRunner struct {
+	kubeconfig.Runner
+}
+
+func (r tracedRunner) Run(args ...string) (string, error) {
+	start := time.Now()
+	out, err := r.Runner.Run(args...)
+	trace("kubectl", "args", args, "took", time.Since(start), "err", err)
+	return out, err
+}
+
+func contextName(config GKEConfig) string {
+	return kubeconfig.ContextName(config.ProjectID, config.Region, config.Cluster)
+}
+
+// contextPlaceholder matches the {name} placeholders of a context template.
+var contextPlaceholder = regexp.MustCompile(`\{([^{}]+)\}`)
+
+// contextAlias returns the context name the configured template gives
+// cluster, or "" when there is no template or it can't be filled in.
+func contextAlias(config GKEConfig, cluster *container.Cluster) string {
+	cfg, err := loadUserConfig()
+	if err != nil || cfg.ContextTemplate == "" {
+		return ""
+	}
+	alias, err := expandContextTemplate(cfg.ContextTemplate, config, cluster)
+	if err != nil {
+		slog.Warn("not aliasing the context", "template", cfg.ContextTemplate, "err", err)
+		return ""
+	}
+	if alias == contextName(config) {
+		return ""
+	}
+	return alias
+}
+
+// expandContextTemplate fills in {project}, {location}, {region} and
+// {cluster}. Any other placeholder is the cluster's resource label of that
+// name, so "{cluster}-{env}" gives e.g. "payments-prod".
+func expandContextTemplate(template string, config GKEConfig, cluster *container.Cluster) (string, error) {
+	var missing []string
+	alias := contextPlaceholder.ReplaceAllStringFunc(template, func(placeholder string) string {
+		key := strings.TrimSpace(placeholder[1 : len(placeholder)-1])
+		switch key {
+		case "project":
+			return config.ProjectID
+		case "location":
+			return config.Region
+		case "region":
+			return clusterRegion(config.Region)
+		case "cluster":
+			return config.Cluster
+		}
+		if value, ok := cluster.ResourceLabels[key]; ok && value != "" {
+			return value
+		}
+		missing = append(missing, key)
+		return ""
+	})
+	if len(missing) > 0 {
+		return "", fmt.Errorf("cluster %s has no label %s", config.Cluster, strings.Join(missing, ", "))
+	}
+	return alias, nil
+}
+
+// writeCredentials configures kubeconfig for the cluster with gcloud when
+// the run uses its account and natively otherwise. The namespace remembered for the
+// context survives the rewrite.
+func writeCredentials(config GKEConfig, cluster *container.Cluster) error {
+	if err := backupKubeconfig(kubeconfigFile(config)); err != nil {
+		return err
+	}
+
+	ctxName := contextName(config)
+	path, kube := kubeconfigPath(config), kubeFor(config)
+	if path != "" {
+		if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+			return fmt.Errorf("failed to create kubeconfig directory: %v", err)
+		}
+	}
+	// get-credentials rewrites the context, so note the namespace it
+	// currently points at and put it back afterwards. The alias, when there
+	// is one, is the context in use, so its namespace is the one to keep.
+	alias := contextAlias(config, cluster)
+	current := kube.ContextNamespace(ctxName)
+	if alias != "" && kube.ContextExists(alias) {
+		current = kube.ContextNamespace(alias)
+	}
+
+	var err error
+	if usesGcloudIdentity() {
+		err = runGetCredentials(config, path)
+	} else {
+		err = kube.WriteCluster(ctxName, cluster)
+	}
+	if err != nil {
+		return err
+	}
+
+	if err := applyEndpointOverride(kube, ctxName, cluster); err != nil {
+		return err
+	}
+
+	remember := current
+	if namespaceFlag != "" {
+		remember = namespaceFlag
+	}
+	namespace := remember
+	if namespace == "" {
+		if st, err := loadState(); err == nil {
+			namespace = st.namespace(ctxName)
+		}
+	}
+	if namespace == "" {
+		namespace = defaultNamespace(config)
+	}
+	if namespace != "" {
+		if err := kube.SetContextNamespace(ctxName, namespace); err != nil {
+			return err
+		}
+	}
+	if alias != "" {
+		if err := kube.AliasContext(alias, ctxName); err != nil {
+			return fmt.Errorf("failed to write context %s: %v", alias, err)
+		}
+		fmt.Printf("🏷️  Context %s is now current (alias of %s)\n", alias, ctxName)
+	}
+	if err := saveNamespace(ctxName, remember); err != nil {
+		slog.Warn("failed to remember the namespace", "context", ctxName, "err", err)
+	}
+	return nil
+}
+
+// runGetCredentials runs gcloud get-credentials, writing to path unless it
+// is empty.
+func runGetCredentials(config GKEConfig, path string) error {
+	cmd := exec.Command("gcloud", "container", "clusters", "get-credentials",
+		config.Cluster,
+		"--region", config.Region,
+		"--project", config.ProjectID)
+	if billingProject != "" {
+		cmd.Args = append(cmd.Args, "--billing-project", billingProject)
+	}
+
+	if path != "" {
+		cmd.Env = append(os.Environ(), "KUBECONFIG="+path)
+	}
+	slog.Debug("running gcloud", "args", cmd.Args[1:])
+	var stderr bytes.Buffer
+	cmd.Stdout = io.Discard
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return fmt.Errorf("get-credentials failed: %s", msg)
+		}
+		return err
+	}
+	return nil
+}
+
+// careful makes native kubeconfig writes show a preview and ask first.
+var careful bool
+
+// applyEndpointOverride points the kubeconfig cluster entry at the address
+// the network profile maps the endpoint to, keeping TLS verification
+// against the real endpoint name.
+func applyEndpointOverride(kube *kubeconfig.Editor, name string, cluster *container.Cluster) error {
+	alt := endpointAddress(cluster.Endpoint)
+	if alt == cluster.Endpoint {
+		return nil
+	}
+
+	if err := kube.SetServer(name, "https://"+alt, cluster.Endpoint); err != nil {
+		return fmt.Errorf("failed to apply endpoint override: %v", err)
+	}
+	return nil
+}
+
+// probeEndpoint checks that the cluster endpoint, after overrides, accepts
+// TCP connections.
+func probeEndpoint(cluster *container.Cluster) error {
+	if cluster.Endpoint == "" {
+		return nil
+	}
+	addr := endpointAddress(cluster.Endpoint)
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "443")
+	}
+	conn, err := net.DialTimeout("tcp", addr, 5*time.Second)
+	if err != nil {
+		return fmt.Errorf("cannot reach control plane at %s: %v", addr, err)
+	}
+	return conn.Close()
+}
+
+// nativeKubeconfigPreview renders the kubeconfig stanzas kube.WriteCluster
+// would write, with the CA certificate elided and YAML keys highlighted.
+func nativeKubeconfigPreview(config GKEConfig, cluster *container.Cluster) string {
+	name := contextName(config)
+
+	ca := "<none>"
+	if cluster.MasterAuth != nil && cluster.MasterAuth.ClusterCaCertificate != "" {
+		ca = fmt.Sprintf("<elided: %d bytes>", len(cluster.MasterAuth.ClusterCaCertificate))
+	}
+
+	action := "# new context"
+	if kubeFor(config).ContextExists(name) {
+		action = "# replaces existing context"
+	}
+
+	lines := []string{
+		action,
+		"clusters:",
+		"- name: " + name,
+		"  cluster:",
+		"    server: https://" + endpointAddress(cluster.Endpoint),
+		"    certificate-authority-data: " + ca,
+	}
+	if endpointAddress(cluster.Endpoint) != cluster.Endpoint {
+		lines = append(lines, "    tls-server-name: "+cluster.Endpoint)
+	}
+	lines = append(lines,
+		"users:",
+		"- name: "+name,
+		"  user:",
+		"    exec:",
+		"      apiVersion: "+kubeconfig.ExecAPIVersion,
+		"      command: "+kubeconfig.AuthPlugin,
+		"      args:",
+		"      - "+kubeconfig.ADCPluginArg,
+		"contexts:",
+		"- name: "+name,
+		"  context:",
+		"    cluster: "+name,
+		"    user: "+name,
+		"current-context: "+name,
+	)
+
+	var b strings.Builder
+	for _, line := range lines {
+		b.WriteString(highlightYAML(line))
+		b.WriteString("\n")
+	}
+	return b.String()
+}
+
+var (
+	yamlKeyStyle     = lipgloss.NewStyle().Foreground(lipgloss.Color("39"))
+	yamlCommentStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("243"))
+	yamlElidedStyle  = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+)
+
+func highlightYAML(line string) string {
+	if strings.HasPrefix(line, "#") {
+		return yamlCommentStyle.Render(line)
+	}
+
+	indent := line[:len(line)-len(strings.TrimLeft(line, " -"))]
+	rest := line[len(indent):]
+	key, value, found := strings.Cut(rest, ":")
+	if !found {
+		return line
+	}
+	if strings.HasPrefix(strings.TrimSpace(value), "<") {
+		value = " " + yamlElidedStyle.Render(strings.TrimSpace(value))
+	}
+	return indent + yamlKeyStyle.Render(key) + ":" + value
+}
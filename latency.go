@@ -0,0 +1,152 @@
+package main
+
+import (
+	"net"
+	"strings"
+	"sync"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// latencyTTL is how long a measured round-trip time is reused before the
+// region is probed again.
+const latencyTTL = 6 * time.Hour
+
+// latencyProbeTimeout bounds a single TCP handshake with a cluster endpoint.
+const latencyProbeTimeout = 2 * time.Second
+
+// latencyProbes is how many handshakes are made per region; the fastest
+// counts, which filters out the cost of the first connection.
+const latencyProbes = 3
+
+// latency is a cached round-trip time to a region.
+type latency struct {
+	RTT      time.Duration `json:"rtt"`
+	Measured time.Time     `json:"measured"`
+}
+
+// clusterRegion maps a cluster location to its region: zonal clusters such
+// as us-central1-a live in us-central1.
+func clusterRegion(location string) string {
+	if parts := strings.Split(location, "-"); len(parts) == 3 {
+		return parts[0] + "-" + parts[1]
+	}
+	return location
+}
+
+// regionLatencies returns the approximate round-trip time to the region of
+// each cluster, keyed by region. Fresh cached values are reused; the rest
+// are measured concurrently by timing TCP handshakes with the cluster
+// endpoints of the region. Regions whose endpoints can't be reached, such as
+// those of private clusters, are left out.
+func regionLatencies(clusters []*container.Cluster) map[string]time.Duration {
+	st, _ := loadState()
+	result := make(map[string]time.Duration)
+	endpoints := make(map[string][]string)
+	for _, cluster := range clusters {
+		region := clusterRegion(cluster.Location)
+		if cached, ok := st.Latencies[region]; ok && time.Since(cached.Measured) < latencyTTL {
+			result[region] = cached.RTT
+			continue
+		}
+		if cluster.Endpoint != "" {
+			endpoints[region] = append(endpoints[region], cluster.Endpoint)
+		}
+	}
+	if len(endpoints) == 0 {
+		return result
+	}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for region, hosts := range endpoints {
+		wg.Add(1)
+		go func(region string, hosts []string) {
+			defer wg.Done()
+			for _, host := range hosts {
+				rtt, err := probeRTT(host)
+				if err != nil {
+					slog.Debug("latency probe failed", "region", region, "endpoint", host, "err", err)
+					continue
+				}
+				mu.Lock()
+				result[region] = rtt
+				mu.Unlock()
+				return
+			}
+		}(region, hosts)
+	}
+	wg.Wait()
+
+	if st.Latencies == nil {
+		st.Latencies = make(map[string]latency)
+	}
+	for region := range endpoints {
+		if rtt, ok := result[region]; ok {
+			st.Latencies[region] = latency{RTT: rtt, Measured: time.Now()}
+		}
+	}
+	if err := st.save(); err != nil {
+		slog.Warn("failed to save state", "err", err)
+	}
+	return result
+}
+
+// probeRTT returns the fastest of a few TCP handshakes with host's HTTPS
+// port.
+func probeRTT(host string) (time.Duration, error) {
+	var best time.Duration
+	for i := 0; i < latencyProbes; i++ {
+		start := time.Now()
+		conn, err := net.DialTimeout("tcp", net.JoinHostPort(host, "443"), latencyProbeTimeout)
+		if err != nil {
+			return 0, err
+		}
+		rtt := time.Since(start)
+		conn.Close()
+		if best == 0 || rtt < best {
+			best = rtt
+		}
+	}
+	return best, nil
+}
+
+// nearestRegion returns the region with the lowest round-trip time, or ""
+// when nothing was measured.
+func nearestRegion(latencies map[string]time.Duration) string {
+	var nearest string
+	for region, rtt := range latencies {
+		if nearest == "" || rtt < latencies[nearest] || (rtt == latencies[nearest] && region < nearest) {
+			nearest = region
+		}
+	}
+	return nearest
+}
+
+// latenciesMsg delivers region round-trip times to the cluster list.
+type latenciesMsg map[string]time.Duration
+
+// measureLatencies measures the regions of clusters in the background, so
+// the list is usable while endpoints are probed.
+func measureLatencies(clusters []*container.Cluster) tea.Cmd {
+	return func() tea.Msg {
+		return latenciesMsg(regionLatencies(clusters))
+	}
+}
+
+// latencyLabel is the round-trip time suffix of a cluster in the list.
+func (m *model) latencyLabel(cluster *container.Cluster) string {
+	region := clusterRegion(cluster.Location)
+	rtt, ok := m.latencies[region]
+	if !ok {
+		return ""
+	}
+	label := " · " + formatRTT(rtt)
+	if region == nearestRegion(m.latencies) {
+		label += " (nearest)"
+	}
+	return label
+}
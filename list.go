@@ -0,0 +1,294 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// clusterInfo is a cluster as shown by `gke list clusters`.
+type clusterInfo struct {
+	Name           string `json:"name" yaml:"name"`
+	Project        string `json:"project" yaml:"project"`
+	Location       string `json:"location" yaml:"location"`
+	Version        string `json:"version" yaml:"version"`
+	ReleaseChannel string `json:"releaseChannel,omitempty" yaml:"releaseChannel,omitempty"`
+	// AvailableUpgrade is the newest control-plane version the cluster can
+	// be upgraded to, if any; only looked up for JSON and YAML output and
+	// the upgrade column.
+	AvailableUpgrade string            `json:"availableUpgrade,omitempty" yaml:"availableUpgrade,omitempty"`
+	Status           string            `json:"status" yaml:"status"`
+	Labels           map[string]string `json:"labels,omitempty" yaml:"labels,omitempty"`
+	Autopilot        bool              `json:"autopilot" yaml:"autopilot"`
+	// PrivateEndpoint tells whether the control plane is only reachable
+	// on its private endpoint.
+	PrivateEndpoint    bool `json:"privateEndpoint" yaml:"privateEndpoint"`
+	AuthorizedNetworks bool `json:"authorizedNetworks" yaml:"authorizedNetworks"`
+	Entries            int  `json:"entries" yaml:"entries"`
+	// Authorized tells whether the current IP is on the list; nil when
+	// the list is disabled or the IP couldn't be detected.
+	Authorized *bool `json:"authorized,omitempty" yaml:"authorized,omitempty"`
+	// RTTMillis is the approximate round-trip time to the cluster's
+	// region; zero when it couldn't be measured.
+	RTTMillis int64 `json:"rttMs,omitempty" yaml:"rttMs,omitempty"`
+	Nearest   bool  `json:"nearest,omitempty" yaml:"nearest,omitempty"`
+	// Security tells which security features are enabled.
+	Security clusterSecurity `json:"security" yaml:"security"`
+	// Insights sum up the active Recommender insights about the cluster,
+	// such as "idle cluster"; only looked up for JSON and YAML output and
+	// the insights column.
+	Insights []string `json:"insights,omitempty" yaml:"insights,omitempty"`
+}
+
+// listOptions are the flags of `gke list`.
+type listOptions struct {
+	output       string
+	project      string
+	columns      string
+	rtt          bool
+	allProjects  bool
+	retrySkipped bool
+}
+
+func (o *listOptions) addClusterFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.project, "project", "", "project ID or glob (defaults to the gcloud project)")
+	cmd.Flags().BoolVar(&o.allProjects, "all-projects", false, "list the clusters of every accessible project")
+	cmd.Flags().BoolVar(&o.retrySkipped, "retry-skipped", false, "with --all-projects, also try projects skipped after repeated failures")
+	cmd.Flags().BoolVar(&o.rtt, "rtt", true, "measure the round-trip time to each cluster's region")
+	cmd.Flags().StringVar(&o.columns, "columns", "", "comma-separated table columns: "+columnNames)
+}
+
+func newListCmd() *cobra.Command {
+	var opts listOptions
+	clusters := func(cmd *cobra.Command, args []string) error {
+		return runListClusters(cmd.Context(), opts)
+	}
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List projects, clusters or authorized networks",
+		Long: `Lists the clusters of a project (the default), the accessible projects, or
+a cluster's authorized networks or node pools, as a table or, with --output,
+as JSON or YAML for scripts and dashboards.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{honorsAnonymize: "true"},
+		RunE:        recorded(clusters),
+	}
+	cmd.PersistentFlags().StringVarP(&opts.output, "output", "o", "table", "output format: table, csv, json or yaml")
+	opts.addClusterFlags(cmd)
+
+	clustersCmd := &cobra.Command{
+		Use:         "clusters",
+		Short:       "List the clusters of a project and whether your IP is authorized",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{honorsAnonymize: "true"},
+		RunE:        recorded(clusters),
+	}
+	opts.addClusterFlags(clustersCmd)
+
+	projectsCmd := &cobra.Command{
+		Use:         "projects",
+		Short:       "List the active projects you can access",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{honorsAnonymize: "true"},
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runListProjects(cmd.Context(), opts.output)
+		}),
+	}
+
+	var target targetOptions
+	networksCmd := &cobra.Command{
+		Use:         "networks",
+		Short:       "List a cluster's authorized networks",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{honorsAnonymize: "true"},
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runListNetworks(cmd.Context(), target, opts.output)
+		}),
+	}
+	target.addFlags(networksCmd)
+
+	var poolTarget targetOptions
+	nodePoolsCmd := &cobra.Command{
+		Use:   "nodepools",
+		Short: "List a cluster's node pools with their machine types, sizes and versions",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runListNodePools(cmd.Context(), poolTarget, opts.output)
+		}),
+	}
+	poolTarget.addFlags(nodePoolsCmd)
+
+	cmd.AddCommand(clustersCmd, projectsCmd, networksCmd, nodePoolsCmd)
+	return cmd
+}
+
+func runListProjects(ctx context.Context, output string) error {
+	projects, err := getProjects(ctx)
+	if err != nil {
+		return err
+	}
+	if projects == nil {
+		projects = []string{}
+	}
+	an, err := loadAnonymizer()
+	if err != nil {
+		return err
+	}
+	if an != nil {
+		for i, project := range projects {
+			projects[i] = an.project(project)
+		}
+	}
+	return writeOutput(os.Stdout, output, projects, func(w io.Writer) {
+		fmt.Fprintln(w, "PROJECT")
+		for _, project := range projects {
+			fmt.Fprintln(w, project)
+		}
+	})
+}
+
+func runListClusters(ctx context.Context, opts listOptions) error {
+	columns, err := resolveColumns(opts.columns)
+	if err != nil {
+		return err
+	}
+
+	selector, err := clusterSelector()
+	if err != nil {
+		return err
+	}
+	order, err := clusterSort()
+	if err != nil {
+		return err
+	}
+
+	var scanned []projectClusters
+	if opts.allProjects {
+		if scanned, err = scanProjects(ctx, opts.retrySkipped); err != nil {
+			return err
+		}
+		if opts.columns == "" && columns[0] != "project" {
+			columns = append([]string{"project"}, columns...)
+		}
+	} else {
+		project := opts.project
+		if project == "" {
+			project = defaultProject()
+		}
+		if project == "" {
+			return fmt.Errorf("no project given; pass --project or --all-projects")
+		}
+		projectID, err := resolveProject(ctx, project)
+		if err != nil {
+			return err
+		}
+		clusters, err := getClusters(ctx, projectID)
+		if err != nil {
+			return err
+		}
+		scanned = []projectClusters{{projectID: projectID, clusters: clusters}}
+	}
+	for i, project := range scanned {
+		var matching []*container.Cluster
+		for _, cluster := range project.clusters {
+			if matchesLabels(cluster, selector) {
+				matching = append(matching, cluster)
+			}
+		}
+		sortClusters(matching, order)
+		scanned[i].clusters = matching
+	}
+
+	// Without a detectable IP the column is left as unknown rather than
+	// failing the whole listing.
+	var mine string
+	if username, err := getUsername(ctx); err == nil {
+		if entry, err := myEntry(username); err == nil {
+			mine = entry.CIDR
+		}
+	}
+
+	var all []*container.Cluster
+	for _, project := range scanned {
+		all = append(all, project.clusters...)
+	}
+	var latencies map[string]time.Duration
+	if opts.rtt {
+		latencies = regionLatencies(all)
+	}
+
+	an, err := loadAnonymizer()
+	if err != nil {
+		return err
+	}
+	lookUpUpgrades := opts.output == "json" || opts.output == "yaml"
+	lookUpInsights := lookUpUpgrades
+	for _, column := range columns {
+		lookUpUpgrades = lookUpUpgrades || column == "upgrade"
+		lookUpInsights = lookUpInsights || column == "insights"
+	}
+
+	infos := []clusterInfo{}
+	for _, project := range scanned {
+		var upgrades map[string]clusterUpgrades
+		if lookUpUpgrades {
+			upgrades = availableUpgrades(ctx, project.projectID, project.clusters)
+		}
+		var insights map[string][]string
+		if lookUpInsights {
+			insights = clusterInsights(ctx, project.projectID, project.clusters)
+		}
+		for _, cluster := range project.clusters {
+			info := newClusterInfo(project.projectID, cluster, mine, latencies)
+			info.AvailableUpgrade = upgrades[clusterKey(cluster)].Available
+			info.Insights = insights[clusterKey(cluster)]
+			if an != nil {
+				// Label values often name teams and products, so they go too.
+				info.Name, info.Project, info.Labels = an.cluster(info.Name), an.project(info.Project), nil
+				// The Workload Identity pool is named after the project.
+				if info.Security.WorkloadPool != "" {
+					info.Security.WorkloadPool = info.Project + ".svc.id.goog"
+				}
+			}
+			infos = append(infos, info)
+		}
+	}
+
+	return writeOutput(os.Stdout, opts.output, infos, func(w io.Writer) {
+		writeClusterRow(w, columns, nil)
+		for i := range infos {
+			writeClusterRow(w, columns, &infos[i])
+		}
+	})
+}
+
+func runListNetworks(ctx context.Context, target targetOptions, output string) error {
+	_, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	entries := gke.AuthorizedEntries(cluster)
+	an, err := loadAnonymizer()
+	if err != nil {
+		return err
+	}
+	if an != nil {
+		// Display names usually contain user names.
+		for i := range entries {
+			entries[i].DisplayName = an.user(entries[i].DisplayName)
+		}
+	}
+	return writeOutput(os.Stdout, output, entries, func(w io.Writer) {
+		fmt.Fprintln(w, "DISPLAY NAME\tCIDR")
+		for _, entry := range entries {
+			fmt.Fprintf(w, "%s\t%s\n", entry.DisplayName, entry.CIDR)
+		}
+	})
+}
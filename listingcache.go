@@ -0,0 +1,201 @@
+package main
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// defaultCacheTTL is how long project and cluster listings are reused by
+// the picker unless the config file sets cacheTTL.
+const defaultCacheTTL = 5 * time.Minute
+
+// cachedListing is a listing kept under the user cache directory.
+type cachedListing struct {
+	Fetched time.Time       `json:"fetched"`
+	Data    json.RawMessage `json:"data"`
+}
+
+// cacheTTL returns the configured listing cache lifetime; zero disables
+// the cache.
+func cacheTTL() time.Duration {
+	cfg, err := loadUserConfig()
+	if err != nil || cfg.CacheTTL == "" {
+		return defaultCacheTTL
+	}
+	ttl, err := time.ParseDuration(cfg.CacheTTL)
+	if err != nil {
+		slog.Warn("ignoring invalid cacheTTL", "value", cfg.CacheTTL, "err", err)
+		return defaultCacheTTL
+	}
+	return ttl
+}
+
+// listingKey names the cache file of a listing. Listings depend on who
+// asks, so the account is part of the key.
+func listingKey(kind string, parts ...string) string {
+	h := sha256.New()
+	h.Write([]byte(sessionAccount + "\x00" + os.Getenv("CLOUDSDK_CORE_ACCOUNT")))
+	for _, part := range parts {
+		h.Write([]byte("\x00" + part))
+	}
+	return kind + "-" + hex.EncodeToString(h.Sum(nil))[:16]
+}
+
+func listingPath(key string) (string, error) {
+	dir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "listings", key+".json"), nil
+}
+
+// readListing decodes the listing cached under key into v, reporting
+// whether it was there and fresh.
+func readListing(key string, v any) bool {
+	ttl := cacheTTL()
+	if ttl <= 0 {
+		return false
+	}
+	fetched, ok := readStaleListing(key, v)
+	return ok && time.Since(fetched) <= ttl
+}
+
+// readStaleListing is readListing whatever the listing's age, which it
+// returns.
+func readStaleListing(key string, v any) (time.Time, bool) {
+	path, err := listingPath(key)
+	if err != nil {
+		return time.Time{}, false
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return time.Time{}, false
+	}
+	var cached cachedListing
+	if json.Unmarshal(data, &cached) != nil || json.Unmarshal(cached.Data, v) != nil {
+		return time.Time{}, false
+	}
+	return cached.Fetched, true
+}
+
+// writeListing caches v under key. Failures are ignored: the cache only
+// saves time.
+func writeListing(key string, v any) {
+	if cacheTTL() <= 0 {
+		return
+	}
+	path, err := listingPath(key)
+	if err != nil {
+		return
+	}
+	data, err := json.Marshal(v)
+	if err != nil {
+		return
+	}
+	data, err = json.Marshal(cachedListing{Fetched: time.Now(), Data: data})
+	if err != nil {
+		return
+	}
+	if os.MkdirAll(filepath.Dir(path), 0o700) == nil {
+		os.WriteFile(path, data, 0o600)
+	}
+}
+
+func projectsKey() string {
+	filter, _ := projectFilter()
+	data, _ := json.Marshal(filter)
+	return listingKey("projects", string(data))
+}
+
+// fromCache fills v with the fresh listing cached under key, unless
+// refresh is set, or else calls fetch to list it. Offline, or when fetch
+// finds Google Cloud unreachable, the cached listing is used however old.
+// It reports whether v came from the cache.
+func fromCache(key string, v any, refresh bool, fetch func() error) (bool, error) {
+	cached, stale, err := readThrough(key, v, refresh, offline, fetch)
+	stale.apply()
+	return cached, err
+}
+
+// staleListing is a listing served from the cache however old, because
+// Google Cloud was unreachable or offline mode was on.
+type staleListing struct {
+	fetched time.Time
+	// cause is the error that showed Google Cloud unreachable, nil when
+	// already offline.
+	cause error
+}
+
+// apply goes offline if s says so and notes how old its listing is. It
+// must run on the goroutine that reads the offline state.
+func (s *staleListing) apply() {
+	if s == nil {
+		return
+	}
+	if s.cause != nil {
+		goOffline(s.cause)
+	}
+	if !s.fetched.IsZero() {
+		noteStale(s.fetched)
+	}
+}
+
+// readThrough is fromCache for use off the TUI's goroutine: rather than
+// changing the offline state, it returns the stale listing used, if any,
+// for the caller to apply.
+func readThrough(key string, v any, refresh, isOffline bool, fetch func() error) (bool, *staleListing, error) {
+	if !isOffline && !refresh && readListing(key, v) {
+		return true, nil, nil
+	}
+	var cause error
+	if !isOffline {
+		cause = fetch()
+		if !unreachable(cause) {
+			return false, nil, cause
+		}
+	}
+	fetched, ok := readStaleListing(key, v)
+	if !ok {
+		// Still offline, though there's nothing to show.
+		return false, &staleListing{cause: cause}, fmt.Errorf("%w, and nothing is cached", errOffline)
+	}
+	return true, &staleListing{fetched: fetched, cause: cause}, nil
+}
+
+func clustersKey(projectID string) string {
+	return listingKey("clusters", projectID, strings.Join(clusterLocations(), ","))
+}
+
+// listProjectsCached is getProjectDetails served from the cache when a
+// fresh listing is there, unless refresh is set. It also reports whether
+// the cache was used.
+func listProjectsCached(ctx context.Context, refresh bool) ([]gke.Project, bool, error) {
+	var projects []gke.Project
+	cached, err := fromCache(projectsKey(), &projects, refresh, func() (err error) {
+		projects, err = getProjectDetails(ctx)
+		return err
+	})
+	return projects, cached, err
+}
+
+// listClustersCached is getClusters served from the cache like
+// listProjectsCached.
+func listClustersCached(ctx context.Context, projectID string, refresh bool) ([]*container.Cluster, bool, error) {
+	var clusters []*container.Cluster
+	cached, err := fromCache(clustersKey(projectID), &clusters, refresh, func() (err error) {
+		clusters, err = getClusters(ctx, projectID)
+		return err
+	})
+	return clusters, cached, err
+}
@@ -0,0 +1,37 @@
+package main
+
+import "strings"
+
+// regionFlag is --region.
+var regionFlag string
+
+// clusterLocations returns the regions and zones cluster listings are
+// restricted to, from --region or else the config file; none means every
+// location.
+func clusterLocations() []string {
+	if regionFlag == "" {
+		cfg, _ := loadUserConfig()
+		return cfg.Locations
+	}
+	var locations []string
+	for _, location := range strings.Split(regionFlag, ",") {
+		if location = strings.TrimSpace(location); location != "" {
+			locations = append(locations, location)
+		}
+	}
+	return locations
+}
+
+// locationListed reports whether clusters in location are listed.
+func locationListed(location string) bool {
+	locations := clusterLocations()
+	if len(locations) == 0 {
+		return true
+	}
+	for _, l := range locations {
+		if l == location {
+			return true
+		}
+	}
+	return false
+}
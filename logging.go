@@ -0,0 +1,84 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/spf13/pflag"
+	"golang.org/x/exp/slog"
+)
+
+// levelTrace is below debug and shows every external command and API
+// detail. It is enabled with -vv.
+const levelTrace = slog.Level(-8)
+
+// logOptions are the persistent flags controlling diagnostic logging.
+type logOptions struct {
+	verbose int
+	format  string
+	file    string
+}
+
+func (o *logOptions) addFlags(fs *pflag.FlagSet) {
+	fs.CountVarP(&o.verbose, "verbose", "v", "log more detail; -v for debug, -vv for trace")
+	fs.StringVar(&o.format, "log-format", "text", "log format: text or json")
+	fs.StringVar(&o.file, "log-file", "", "append logs to this file instead of standard error")
+}
+
+// setupLogging installs the default logger described by o. The standard
+// library log package is routed through it as well.
+func setupLogging(o logOptions) error {
+	level := slog.LevelInfo
+	switch {
+	case o.verbose >= 2:
+		level = levelTrace
+	case o.verbose == 1:
+		level = slog.LevelDebug
+	}
+
+	var out io.Writer = os.Stderr
+	if o.file != "" {
+		f, err := os.OpenFile(o.file, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0o600)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		out = f
+	}
+
+	handlerOpts := &slog.HandlerOptions{Level: level, ReplaceAttr: levelNames}
+	var handler slog.Handler
+	switch o.format {
+	case "text":
+		handler = slog.NewTextHandler(out, handlerOpts)
+	case "json":
+		handler = slog.NewJSONHandler(out, handlerOpts)
+	default:
+		return fmt.Errorf("unknown log format %q; use text or json", o.format)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// levelNames prints levelTrace as TRACE rather than DEBUG-4.
+func levelNames(groups []string, a slog.Attr) slog.Attr {
+	if a.Key == slog.LevelKey && len(groups) == 0 {
+		if level, ok := a.Value.Any().(slog.Level); ok && level == levelTrace {
+			a.Value = slog.StringValue("TRACE")
+		}
+	}
+	return a
+}
+
+// trace logs msg at levelTrace.
+func trace(msg string, args ...interface{}) {
+	slog.Log(context.Background(), levelTrace, msg, args...)
+}
+
+// infof adapts printf-style progress callbacks, such as expireGrants', to
+// the logger.
+func infof(format string, args ...interface{}) {
+	slog.Info(strings.TrimSuffix(fmt.Sprintf(format, args...), "\n"))
+}
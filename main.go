@@ -2,75 +2,189 @@ package main
 
 import (
 	"context"
+	"errors"
 	"fmt"
-	"io"
-	"log"
-	"net/http"
+	"os"
 	"os/exec"
 	"strings"
 	"time"
 
-	tea "github.com/charmbracelet/bubbletea"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/cloudbilling/v1"
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/compute/v1"
 	"google.golang.org/api/container/v1"
-	"google.golang.org/api/cloudresourcemanager/v1"
+	"google.golang.org/api/gkehub/v1"
+	"google.golang.org/api/recommender/v1"
 )
 
 type GKEConfig struct {
-	ProjectID  string
-	Region     string
-	Cluster    string
-	Username   string
+	ProjectID string
+	Region    string
+	Cluster   string
+	Username  string
 }
 
-func getProjects(ctx context.Context) ([]string, error) {
-	cloudResourceManagerService, err := cloudresourcemanager.NewService(ctx)
+// target identifies the cluster for the gke package.
+func (c GKEConfig) target() gke.Target {
+	return gke.Target{Project: c.ProjectID, Location: c.Region, Cluster: c.Cluster}
+}
+
+func projectAPI(ctx context.Context) (gke.ProjectAPI, error) {
+	opts, err := clientOptions(ctx, "cloudresourcemanager")
+	if err != nil {
+		return nil, err
+	}
+	svc, err := resourcemanager.NewService(ctx, opts...)
 	if err != nil {
 		return nil, fmt.Errorf("failed to create cloud resource manager client: %v", err)
 	}
+	return gke.NewProjectClient(svc), nil
+}
 
-	var projectIDs []string
-	resp, err := cloudResourceManagerService.Projects.List().Do()
+func hierarchyAPI(ctx context.Context) (gke.HierarchyAPI, error) {
+	opts, err := clientOptions(ctx, "cloudresourcemanager")
+	if err != nil {
+		return nil, err
+	}
+	svc, err := resourcemanager.NewService(ctx, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list projects: %v", err)
+		return nil, fmt.Errorf("failed to create cloud resource manager client: %v", err)
 	}
+	return gke.NewHierarchyClient(svc), nil
+}
 
-	for _, project := range resp.Projects {
-		if project.LifecycleState == "ACTIVE" {
-			projectIDs = append(projectIDs, project.ProjectId)
-		}
+func clusterAPI(ctx context.Context) (gke.ClusterAPI, error) {
+	opts, err := clientOptions(ctx, "container")
+	if err != nil {
+		return nil, err
 	}
+	svc, err := container.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create container service client: %v", err)
+	}
+	return gke.NewClusterClient(svc), nil
+}
 
-	return projectIDs, nil
+func instanceGroupAPI(ctx context.Context) (gke.InstanceGroupAPI, error) {
+	opts, err := clientOptions(ctx, "compute")
+	if err != nil {
+		return nil, err
+	}
+	svc, err := compute.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Compute Engine client: %v", err)
+	}
+	return gke.NewInstanceGroupClient(svc), nil
 }
 
-func getClusters(ctx context.Context, projectID string) ([]*container.Cluster, error) {
-	containerService, err := container.NewService(ctx)
+func fleetAPI(ctx context.Context) (gke.FleetAPI, error) {
+	opts, err := clientOptions(ctx, "gkehub")
 	if err != nil {
-		return nil, fmt.Errorf("failed to create container service client: %v", err)
+		return nil, err
+	}
+	svc, err := gkehub.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GKE Hub client: %v", err)
 	}
+	return gke.NewFleetClient(svc), nil
+}
 
-	parent := fmt.Sprintf("projects/%s/locations/-", projectID)
-	resp, err := containerService.Projects.Locations.Clusters.List(parent).Do()
+func insightAPI(ctx context.Context) (gke.InsightAPI, error) {
+	opts, err := clientOptions(ctx, "recommender")
+	if err != nil {
+		return nil, err
+	}
+	svc, err := recommender.NewService(ctx, opts...)
 	if err != nil {
-		return nil, fmt.Errorf("failed to list clusters: %v", err)
+		return nil, fmt.Errorf("failed to create Recommender client: %v", err)
 	}
+	return gke.NewInsightClient(svc), nil
+}
 
-	return resp.Clusters, nil
+func pricingAPI(ctx context.Context) (gke.PricingAPI, error) {
+	opts, err := clientOptions(ctx, "cloudbilling")
+	if err != nil {
+		return nil, err
+	}
+	svc, err := cloudbilling.NewService(ctx, opts...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloud Billing client: %v", err)
+	}
+	return gke.NewPricingClient(svc), nil
+}
+
+func getProjects(ctx context.Context) ([]string, error) {
+	projects, err := getProjectDetails(ctx)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+	}
+	return ids, nil
 }
 
-func getCurrentPublicIP() (string, error) {
-	resp, err := http.Get("https://api.ipify.org")
+// browseChildren lists the organizations, folders and projects directly
+// under parent, or the organizations when parent is empty.
+func browseChildren(ctx context.Context, parent string) ([]gke.Node, error) {
+	hierarchy, err := hierarchyAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	projects, err := projectAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return gke.Children(ctx, hierarchy, projects, parent)
+}
+
+// getProjectDetails is getProjects with the projects' names and labels.
+func getProjectDetails(ctx context.Context) ([]gke.Project, error) {
+	api, err := projectAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := projectFilter()
 	if err != nil {
-		return "", fmt.Errorf("failed to get public IP: %v", err)
+		return nil, err
 	}
-	defer resp.Body.Close()
+	projects, err := gke.ListProjectDetails(ctx, api, filter)
+	if err == nil {
+		var ids []string
+		for _, project := range projects {
+			ids = append(ids, project.ID)
+		}
+		cacheProjects(ids)
+		writeListing(projectsKey(), projects)
+	}
+	return projects, err
+}
 
-	ip, err := io.ReadAll(resp.Body)
+func getClusters(ctx context.Context, projectID string) ([]*container.Cluster, error) {
+	api, err := clusterAPI(ctx)
 	if err != nil {
-		return "", fmt.Errorf("failed to read response: %v", err)
+		return nil, err
 	}
+	clusters, err := gke.ListClustersIn(ctx, api, projectID, clusterLocations())
+	recordProjectResult(projectID, err)
+	if err == nil {
+		cacheClusters(projectID, clusters)
+		writeListing(clustersKey(projectID), clusters)
+	}
+	return clusters, err
+}
 
-	return string(ip), nil
+// getCluster fetches config's cluster.
+func getCluster(ctx context.Context, config GKEConfig) (*container.Cluster, error) {
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	return api.GetCluster(ctx, config.target().Name())
 }
 
 func getGcloudUsername() (string, error) {
@@ -90,266 +204,121 @@ func getGcloudUsername() (string, error) {
 	}
 
 	if email != "" {
-		username := strings.Split(email, "@")[0]
-		username = strings.ReplaceAll(username, ".", "-")
-		return username, nil
+		return usernameFromEmail(email), nil
 	}
 
 	return "", fmt.Errorf("no valid email found in gcloud config")
 }
 
-func updateAuthorizedNetworks(ctx context.Context, config GKEConfig, cluster *container.Cluster) error {
-	containerService, err := container.NewService(ctx)
+func updateAuthorizedNetworks(ctx context.Context, config GKEConfig, cluster *container.Cluster, onProgress func(gke.Progress)) error {
+	entry, err := myEntry(config.Username)
 	if err != nil {
-		return fmt.Errorf("failed to create container service client: %v", err)
+		return err
 	}
-
-	publicIP, err := getCurrentPublicIP()
+	api, err := clusterAPI(ctx)
 	if err != nil {
 		return err
 	}
-
-	var currentNetworks []*container.CidrBlock
-	if cluster.MasterAuthorizedNetworksConfig.CidrBlocks != nil {
-		currentNetworks = cluster.MasterAuthorizedNetworksConfig.CidrBlocks
-	}
-
-	username := config.Username
-	userNetworkExists := false
-	for i, network := range currentNetworks {
-		if network.DisplayName == username {
-			currentNetworks[i].CidrBlock = publicIP + "/32"
-			userNetworkExists = true
-			break
-		}
-	}
-
-	if !userNetworkExists {
-		currentNetworks = append(currentNetworks, &container.CidrBlock{
-			DisplayName: username,
-			CidrBlock:   publicIP + "/32",
-		})
-	}
-
-	req := &container.UpdateClusterRequest{
-		Update: &container.ClusterUpdate{
-			DesiredMasterAuthorizedNetworksConfig: &container.MasterAuthorizedNetworksConfig{
-				Enabled:                     true,
-				CidrBlocks:                 currentNetworks,
-				GcpPublicCidrsAccessEnabled: cluster.MasterAuthorizedNetworksConfig.GcpPublicCidrsAccessEnabled,
-			},
+	guards := gke.Guards{
+		BeforeApply: func(live *container.Cluster) error {
+			if err := checkOwnership(ctx, config, live); err != nil {
+				return err
+			}
+			return runPreChangeHooks(ctx, config, "connect")
 		},
+		ConfirmShrink: confirmShrink,
 	}
-
-	name := fmt.Sprintf("projects/%s/locations/%s/clusters/%s",
-		config.ProjectID, config.Region, config.Cluster)
-
-	op, err := containerService.Projects.Locations.Clusters.Update(name, req).Do()
+	slog.Debug("reconciling authorized networks", "cluster", config.target().Name(), "entry", entry.DisplayName, "cidr", entry.CIDR)
+	duplicates, err := duplicatePolicy()
+	if err != nil {
+		return err
+	}
+	plan, err := gke.Reconcile(ctx, api, config.target(), cluster, entry, duplicates, guards, onProgress)
 	if err != nil {
-		return fmt.Errorf("failed to update authorized networks: %v", err)
+		slog.Debug("reconcile failed", "cluster", config.target().Name(), "err", err)
+		return err
 	}
-
-	return waitForOperation(ctx, containerService, op, config)
+	slog.Debug("reconciled authorized networks", "cluster", config.target().Name(),
+		"adds", len(plan.Adds), "updates", len(plan.Updates), "removes", len(plan.Removes))
+	trace("authorized networks", "cluster", config.target().Name(), "result", plan.Result)
+	return recordGrant(config, entry)
 }
 
-func waitForOperation(ctx context.Context, svc *container.Service, op *container.Operation, config GKEConfig) error {
-	opName := fmt.Sprintf("projects/%s/locations/%s/operations/%s",
-		config.ProjectID, config.Region, op.Name)
-
-	for {
-		result, err := svc.Projects.Locations.Operations.Get(opName).Do()
-		if err != nil {
-			return fmt.Errorf("failed to get operation status: %v", err)
-		}
-
-		if result.Status == "DONE" {
-			if result.Error != nil {
-				return fmt.Errorf("operation failed: %v", result.Error.Message)
-			}
-			return nil
-		}
+// duplicatePolicy returns what to do with further entries carrying the
+// user's display name, keeping them unless the config file says otherwise.
+func duplicatePolicy() (man.DuplicatePolicy, error) {
+	cfg, err := loadUserConfig()
+	if err != nil || cfg.DuplicateEntries == "" {
+		return man.DuplicatesKeep, nil
+	}
+	return man.ParseDuplicatePolicy(cfg.DuplicateEntries)
+}
 
-		time.Sleep(2 * time.Second)
+// applyAuthorizedNetworks replaces the cluster's authorized networks with
+// entries, once the pre-change hooks agree, and waits for the resulting
+// operation.
+func applyAuthorizedNetworks(ctx context.Context, config GKEConfig, cluster *container.Cluster, entries []man.Entry, onProgress func(gke.Progress)) error {
+	if err := runPreChangeHooks(ctx, config, "apply"); err != nil {
+		return err
 	}
+	return setAuthorizedNetworks(ctx, config, cluster, entries, onProgress)
 }
 
-func hasAuthorizedNetworks(cluster *container.Cluster) bool {
-	return cluster.MasterAuthorizedNetworksConfig != nil && 
-		cluster.MasterAuthorizedNetworksConfig.Enabled
+// setAuthorizedNetworks is applyAuthorizedNetworks without the hooks.
+func setAuthorizedNetworks(ctx context.Context, config GKEConfig, cluster *container.Cluster, entries []man.Entry, onProgress func(gke.Progress)) error {
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	return gke.SetAuthorizedNetworks(ctx, api, config.target(), cluster, entries, onProgress)
 }
 
-func setClusterCredentials(ctx context.Context, config GKEConfig, cluster *container.Cluster) error {
+func setClusterCredentials(ctx context.Context, config GKEConfig, cluster *container.Cluster, onProgress func(gke.Progress)) error {
 	fmt.Print("\n")
 
-	if hasAuthorizedNetworks(cluster) {
+	if gke.HasAuthorizedNetworks(cluster) {
 		fmt.Printf("📡 Updating authorized networks...\n")
-		if err := updateAuthorizedNetworks(ctx, config, cluster); err != nil {
-			return fmt.Errorf("failed to update authorized networks: %v", err)
+		if err := updateAuthorizedNetworks(ctx, config, cluster, onProgress); err != nil {
+			return fmt.Errorf("failed to update authorized networks: %w", err)
+		}
+		fmt.Printf("✨ Successfully updated authorized networks with your IP\n")
+		if accessFor > 0 {
+			fmt.Printf("⏰ Access expires at %s\n", formatTime(time.Now().Add(accessFor)))
 		}
-		fmt.Printf("✨ Successfully updated authorized networks with your IP\n\n")
+		fmt.Print("\n")
 	} else {
 		fmt.Printf("ℹ️  Cluster does not have authorized networks enabled, skipping IP update\n\n")
 	}
 
 	fmt.Printf("🔑 Configuring cluster credentials...\n")
-	cmd := exec.Command("gcloud", "container", "clusters", "get-credentials",
-		config.Cluster,
-		"--region", config.Region,
-		"--project", config.ProjectID)
-	
-	cmd.Stdout = io.Discard
-	cmd.Stderr = io.Discard
-	
-	if err := cmd.Run(); err != nil {
+	if err := writeCredentials(config, cluster); err != nil {
 		return err
 	}
 
 	fmt.Printf("✅ Testing cluster connection...\n")
-	testCmd := exec.Command("kubectl", "config", "current-context")
-	testCmd.Stdout = io.Discard
-	return testCmd.Run()
-}
-
-type model struct {
-	choices    []string
-	cursor     int
-	selected   string
-	step       string
-	projects   []string
-	clusters   []*container.Cluster
-	projectID  string
-	loading    bool
-	program    *tea.Program
-}
-
-func initialModel() model {
-	return model{
-		step: "project",
+	// Private and bastion-only endpoints can't be reached from every host,
+	// which doesn't make the credentials just written any less useful.
+	if err := probeEndpoint(cluster); err != nil {
+		slog.Warn("the control plane may be unreachable from this host", "cluster", config.Cluster, "err", err)
 	}
-}
-
-func (m *model) Init() tea.Cmd {
-	return nil
-}
-
-func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
-	switch msg := msg.(type) {
-	case tea.KeyMsg:
-		switch msg.String() {
-		case "ctrl+c", "q":
-			return m, tea.Quit
-		case "up", "k":
-			if m.cursor > 0 {
-				m.cursor--
-			}
-		case "down", "j":
-			if m.cursor < len(m.choices)-1 {
-				m.cursor++
-			}
-		case "enter":
-			if m.step == "project" {
-				m.projectID = m.projects[m.cursor]
-				m.step = "cluster"
-				m.cursor = 0
-				clusters, err := getClusters(context.Background(), m.projectID)
-				if err != nil {
-					log.Fatalf("Error getting clusters: %v", err)
-				}
-				m.clusters = clusters
-				var clusterNames []string
-				for _, cluster := range clusters {
-					clusterNames = append(clusterNames, cluster.Name)
-				}
-				m.choices = clusterNames
-			} else if m.step == "cluster" {
-				selectedCluster := m.clusters[m.cursor]
-				username, err := getGcloudUsername()
-				if err != nil {
-					log.Printf("Error getting gcloud username: %v", err)
-					return m, tea.Quit
-				}
-
-				config := GKEConfig{
-					ProjectID: m.projectID,
-					Region:    selectedCluster.Location,
-					Cluster:   selectedCluster.Name,
-					Username:  username,
-				}
-
-				m.loading = true
-				m.step = "configuring"
-				
-				go func() {
-					if err := setClusterCredentials(context.Background(), config, selectedCluster); err != nil {
-						log.Printf("Error setting cluster credentials: %v", err)
-						m.loading = false
-						m.program.Send(errMsg{err})
-						return
-					}
-					m.program.Send(successMsg{cluster: selectedCluster.Name})
-				}()
-
-				return m, nil
-			}
-		}
-	case errMsg:
-		return m, tea.Quit
-	case successMsg:
-		fmt.Printf("\n✨ Successfully configured credentials for cluster: %s\n", msg.cluster)
-		fmt.Printf("🚀 You can now use kubectl to interact with the cluster\n")
-		fmt.Printf("📝 Current context: %s\n\n", msg.cluster)
-		return m, tea.Quit
-	}
-	return m, nil
-}
-
-func (m *model) View() string {
-	if m.loading {
-		return "\n🔄 Configuring cluster access...\n"
+	if _, err := kubeFor(config).CurrentContext(); err != nil {
+		return err
 	}
-
-	var s strings.Builder
-	s.WriteString("Select using ↑/↓ arrows and enter to confirm\n\n")
-
-	if m.step == "project" {
-		s.WriteString("Choose a GCP project:\n\n")
-	} else {
-		s.WriteString("Choose a GKE cluster:\n\n")
+	if err := refreshToken(ctx, config); err != nil {
+		return err
 	}
-
-	for i, choice := range m.choices {
-		cursor := " "
-		if m.cursor == i {
-			cursor = ">"
-		}
-		s.WriteString(fmt.Sprintf("%s %s\n", cursor, choice))
+	if path := kubeconfigPath(config); path != "" {
+		fmt.Printf("📄 Credentials written to %s; to use them:\n   export KUBECONFIG=%s\n", path, path)
 	}
-
-	s.WriteString("\n(press q to quit)\n")
-	return s.String()
+	return nil
 }
 
-type errMsg struct{ err error }
-type successMsg struct{ cluster string }
-
 func main() {
-	ctx := context.Background()
-
-	projects, err := getProjects(ctx)
-	if err != nil {
-		log.Fatalf("Error getting projects: %v", err)
-	}
-
-	m := &model{
-		step:     "project",
-		projects: projects,
-		choices:  projects,
-	}
-
-	p := tea.NewProgram(m)
-	m.program = p
-
-	if _, err := p.Run(); err != nil {
-		log.Fatalf("Error running program: %v", err)
+	if err := newRootCmd().ExecuteContext(context.Background()); err != nil {
+		// A failed child command has reported its own error.
+		if !errors.As(err, new(childError)) {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		}
+		os.Exit(exitCode(err))
 	}
-} 
\ No newline at end of file
+}
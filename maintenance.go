@@ -0,0 +1,307 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// exclusionScopes maps the --scope values to the API's exclusion scopes.
+var exclusionScopes = map[string]string{
+	"no-upgrades":               "NO_UPGRADES",
+	"no-minor-upgrades":         "NO_MINOR_UPGRADES",
+	"no-minor-or-node-upgrades": "NO_MINOR_OR_NODE_UPGRADES",
+}
+
+// maintenanceInfo is a cluster's maintenance policy as shown by gke
+// maintenance show.
+type maintenanceInfo struct {
+	// Window is when GKE may maintain the cluster, or empty for any time.
+	Window     string          `json:"window,omitempty" yaml:"window,omitempty"`
+	Exclusions []exclusionInfo `json:"exclusions" yaml:"exclusions"`
+}
+
+type exclusionInfo struct {
+	Name  string    `json:"name" yaml:"name"`
+	Start time.Time `json:"start" yaml:"start"`
+	End   time.Time `json:"end" yaml:"end"`
+	Scope string    `json:"scope" yaml:"scope"`
+}
+
+func newMaintenanceInfo(cluster *container.Cluster) maintenanceInfo {
+	info := maintenanceInfo{Window: gke.MaintenanceWindow(cluster), Exclusions: []exclusionInfo{}}
+	for _, e := range gke.MaintenanceExclusions(cluster) {
+		info.Exclusions = append(info.Exclusions, exclusionInfo{Name: e.Name, Start: e.Start, End: e.End, Scope: scopeName(e.Scope)})
+	}
+	return info
+}
+
+// scopeName is the --scope value of an API exclusion scope.
+func scopeName(scope string) string {
+	return strings.ReplaceAll(strings.ToLower(scope), "_", "-")
+}
+
+// maintenanceWindowLabel is the maintenance window as shown to people.
+func maintenanceWindowLabel(cluster *container.Cluster) string {
+	if window := gke.MaintenanceWindow(cluster); window != "" {
+		return window
+	}
+	return "any time"
+}
+
+// exclusionLabel describes an exclusion on one line, e.g. "freeze Mon 19
+// Oct 00:00 → Mon 26 Oct 00:00 (no-upgrades)", marking the one in effect.
+func exclusionLabel(e gke.Exclusion) string {
+	label := fmt.Sprintf("%s %s → %s (%s)", e.Name, e.Start.Local().Format("Mon 2 Jan 15:04"), e.End.Local().Format("Mon 2 Jan 15:04"), scopeName(e.Scope))
+	if now := time.Now(); now.After(e.Start) && now.Before(e.End) {
+		label += ", in effect"
+	}
+	return label
+}
+
+func newMaintenanceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "maintenance",
+		Short: "Show a cluster's maintenance window and add or delete maintenance exclusions",
+	}
+	cmd.AddCommand(newMaintenanceShowCmd(), newMaintenanceExcludeCmd(), newMaintenanceDeleteCmd())
+	return cmd
+}
+
+func newMaintenanceShowCmd() *cobra.Command {
+	var target targetOptions
+	var output string
+	cmd := &cobra.Command{
+		Use:   "show",
+		Short: "Show a cluster's maintenance window and exclusions",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			_, cluster, err := resolveCluster(cmd.Context(), target.project, target.location, target.cluster)
+			if err != nil {
+				return err
+			}
+			info := newMaintenanceInfo(cluster)
+			return writeOutput(os.Stdout, output, info, func(w io.Writer) {
+				fmt.Fprintf(w, "Maintenance window: %s\n\n", maintenanceWindowLabel(cluster))
+				if len(info.Exclusions) == 0 {
+					fmt.Fprintln(w, "No maintenance exclusions")
+					return
+				}
+				fmt.Fprintln(w, "EXCLUSION\tSTART\tEND\tSCOPE")
+				for _, e := range info.Exclusions {
+					fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", e.Name, formatTime(e.Start), formatTime(e.End), e.Scope)
+				}
+			})
+		},
+	}
+	target.addFlags(cmd)
+	cmd.Flags().StringVarP(&output, "output", "o", "table", "output format: table, json or yaml")
+	return cmd
+}
+
+// exclusionOptions are the flags of gke maintenance exclude.
+type exclusionOptions struct {
+	from, until string
+	days        int
+	nextWeek    bool
+	scope       string
+	yes         bool
+}
+
+// window returns the start and end of the exclusion the flags ask for,
+// relative to now.
+func (o exclusionOptions) window(now time.Time) (time.Time, time.Time, error) {
+	if o.nextWeek {
+		if o.from != "" || o.until != "" || o.days > 0 {
+			return time.Time{}, time.Time{}, usageError{fmt.Errorf("--next-week can't be combined with --from, --until or --days")}
+		}
+		midnight := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+		daysToMonday := (8 - int(now.Weekday())) % 7
+		if daysToMonday == 0 {
+			daysToMonday = 7
+		}
+		start := midnight.AddDate(0, 0, daysToMonday)
+		return start, start.AddDate(0, 0, 7), nil
+	}
+
+	start := now
+	if o.from != "" {
+		t, err := parseWhen(o.from)
+		if err != nil {
+			return time.Time{}, time.Time{}, usageError{fmt.Errorf("invalid --from: %v", err)}
+		}
+		start = t
+	}
+	switch {
+	case o.until != "" && o.days > 0:
+		return time.Time{}, time.Time{}, usageError{fmt.Errorf("--until and --days are mutually exclusive")}
+	case o.until != "":
+		end, err := parseWhen(o.until)
+		if err != nil {
+			return time.Time{}, time.Time{}, usageError{fmt.Errorf("invalid --until: %v", err)}
+		}
+		if !end.After(start) {
+			return time.Time{}, time.Time{}, usageError{fmt.Errorf("--until must be after the start of the exclusion")}
+		}
+		return start, end, nil
+	case o.days > 0:
+		return start, start.AddDate(0, 0, o.days), nil
+	}
+	return time.Time{}, time.Time{}, usageError{fmt.Errorf("no end given; pass --until, --days or --next-week")}
+}
+
+// parseWhen parses a date ("2006-01-02", midnight local time), a local date
+// and time ("2006-01-02T15:04") or an RFC 3339 time.
+func parseWhen(s string) (time.Time, error) {
+	if t, err := time.Parse(time.RFC3339, s); err == nil {
+		return t, nil
+	}
+	for _, layout := range []string{"2006-01-02T15:04", "2006-01-02"} {
+		if t, err := time.ParseInLocation(layout, s, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date like 2006-01-02 or a time like 2006-01-02T15:04", s)
+}
+
+func newMaintenanceExcludeCmd() *cobra.Command {
+	var target targetOptions
+	var opts exclusionOptions
+	cmd := &cobra.Command{
+		Use:   "exclude [NAME]",
+		Short: "Add a maintenance exclusion, keeping GKE from upgrading the cluster for a while",
+		Long: `Adds a maintenance exclusion to the cluster's maintenance policy, during
+which GKE won't upgrade it. The exclusion starts now, or at --from, and
+ends at --until or after --days; --next-week covers next Monday to the
+Monday after. Dates are local midnight, e.g. 2024-12-20, or a local time,
+e.g. 2024-12-20T18:00. Without NAME the exclusion is called freeze-
+followed by its start date. An exclusion of the same name is replaced.
+
+--scope sets what is excluded: no-upgrades (the default, for at most 30
+days), no-minor-upgrades or no-minor-or-node-upgrades (which can last
+until the end of the version's support).`,
+		Example: `  gke maintenance exclude --next-week --project my-project --cluster prod
+  gke maintenance exclude black-friday --from 2024-11-25 --until 2024-12-02 --cluster prod`,
+		Args: cobra.MaximumNArgs(1),
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			return runMaintenanceExclude(cmd.Context(), target, name, opts)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().StringVar(&opts.from, "from", "", "start of the exclusion (default now)")
+	cmd.Flags().StringVar(&opts.until, "until", "", "end of the exclusion")
+	cmd.Flags().IntVar(&opts.days, "days", 0, "length of the exclusion in days")
+	cmd.Flags().BoolVar(&opts.nextWeek, "next-week", false, "exclude next Monday to the Monday after")
+	cmd.Flags().StringVar(&opts.scope, "scope", "no-upgrades", "what to exclude: no-upgrades, no-minor-upgrades or no-minor-or-node-upgrades")
+	cmd.Flags().BoolVar(&opts.yes, "yes", false, "add the exclusion without asking for confirmation")
+	return cmd
+}
+
+func runMaintenanceExclude(ctx context.Context, target targetOptions, name string, opts exclusionOptions) error {
+	scope, ok := exclusionScopes[opts.scope]
+	if !ok {
+		return usageError{fmt.Errorf("invalid --scope %q; want no-upgrades, no-minor-upgrades or no-minor-or-node-upgrades", opts.scope)}
+	}
+	start, end, err := opts.window(time.Now())
+	if err != nil {
+		return err
+	}
+	if name == "" {
+		name = "freeze-" + start.Format("2006-01-02")
+	}
+	exclusion := gke.Exclusion{Name: name, Start: start, End: end, Scope: scope}
+
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Add maintenance exclusion to %s: %s\n", config.Cluster, exclusionLabel(exclusion))
+	for _, e := range gke.MaintenanceExclusions(cluster) {
+		if e.Name == name {
+			fmt.Printf("⚠️  This replaces the exclusion %s\n", exclusionLabel(e))
+		}
+	}
+	fmt.Println()
+	change := "maintenance exclusion: " + exclusionLabel(exclusion)
+	return changeMaintenance(ctx, config, cluster, change, opts.yes, func(api gke.ClusterAPI, onProgress func(gke.Progress)) error {
+		return gke.AddMaintenanceExclusion(ctx, api, config.target(), cluster, exclusion, onProgress)
+	})
+}
+
+func newMaintenanceDeleteCmd() *cobra.Command {
+	var target targetOptions
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "delete NAME",
+		Short: "Delete a maintenance exclusion",
+		Args:  cobra.ExactArgs(1),
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runMaintenanceDelete(cmd.Context(), target, args[0], yes)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().BoolVar(&yes, "yes", false, "delete the exclusion without asking for confirmation")
+	return cmd
+}
+
+func runMaintenanceDelete(ctx context.Context, target targetOptions, name string, yes bool) error {
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	var exclusion *gke.Exclusion
+	for _, e := range gke.MaintenanceExclusions(cluster) {
+		if e.Name == name {
+			exclusion = &e
+			break
+		}
+	}
+	if exclusion == nil {
+		return fmt.Errorf("%s has no maintenance exclusion %s; see gke maintenance show", config.Cluster, name)
+	}
+	fmt.Printf("Delete maintenance exclusion of %s: %s\n\n", config.Cluster, exclusionLabel(*exclusion))
+	change := "delete maintenance exclusion: " + exclusionLabel(*exclusion)
+	return changeMaintenance(ctx, config, cluster, change, yes, func(api gke.ClusterAPI, onProgress func(gke.Progress)) error {
+		return gke.RemoveMaintenanceExclusion(ctx, api, config.target(), cluster, name, onProgress)
+	})
+}
+
+// changeMaintenance runs the checks of a change to cluster's maintenance
+// policy, then update, following its operation.
+func changeMaintenance(ctx context.Context, config GKEConfig, cluster *container.Cluster, change string, yes bool, update func(api gke.ClusterAPI, onProgress func(gke.Progress)) error) error {
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	if err := runPreChangeHooks(ctx, config, "maintenance"); err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	req := newApproval("maintenance", "Update the maintenance policy?", []string{ref.String()}, []string{change})
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("📡 Updating the maintenance policy of %s...\n", config.Cluster)
+	if err := followOperation(func(onProgress func(gke.Progress)) error {
+		return update(api, onProgress)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("✨ Updated the maintenance policy of %s\n", config.Cluster)
+	return nil
+}
@@ -0,0 +1,262 @@
+package main
+
+import (
+	"context"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+var csvHeader = []string{"display_name", "cidr_block"}
+
+// targetOptions select a single cluster.
+type targetOptions struct {
+	project  string
+	location string
+	cluster  string
+}
+
+func (o *targetOptions) addFlags(cmd *cobra.Command) {
+	cmd.Flags().StringVar(&o.project, "project", "", "project ID or glob (defaults to the gcloud project)")
+	cmd.Flags().StringVar(&o.location, "location", "", "cluster location (searched when omitted)")
+	cmd.Flags().StringVar(&o.cluster, "cluster", "", "cluster name")
+}
+
+// newManCmd is the `man` command family for managing a cluster's master
+// authorized networks (MAN) allow-list.
+func newManCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "man",
+		Short: "Review and change authorized networks",
+	}
+	cmd.AddCommand(newManExportCmd(), newManImportCmd(), newManChangeSetCmd(), newManDedupeCmd())
+	return cmd
+}
+
+func newManExportCmd() *cobra.Command {
+	var target targetOptions
+	var format, output string
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Export a cluster's authorized networks",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runManExport(cmd.Context(), target, format, output)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().StringVar(&format, "format", "csv", "output format: csv, json or yaml")
+	cmd.Flags().StringVar(&output, "output", "", "write to this file instead of stdout")
+	return cmd
+}
+
+func runManExport(ctx context.Context, target targetOptions, format, output string) error {
+	if format != "csv" && format != "json" && format != "yaml" {
+		return fmt.Errorf("unsupported format %q", format)
+	}
+
+	_, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if output != "" {
+		f, err := os.Create(output)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %v", output, err)
+		}
+		defer f.Close()
+		w = f
+	}
+
+	if format == "csv" {
+		return writeCidrBlocksCSV(w, gke.AuthorizedBlocks(cluster))
+	}
+	return writeOutput(w, format, gke.AuthorizedEntries(cluster), nil)
+}
+
+// applyOptions control how a planned change is confirmed.
+type applyOptions struct {
+	file   string
+	yes    bool
+	dryRun bool
+}
+
+// addFlags adds the confirmation flags and, unless fileUsage is empty, --file.
+func (o *applyOptions) addFlags(cmd *cobra.Command, fileUsage string) {
+	if fileUsage != "" {
+		cmd.Flags().StringVar(&o.file, "file", "", fileUsage)
+	}
+	cmd.Flags().BoolVar(&o.yes, "yes", false, "apply without asking for confirmation")
+	cmd.Flags().BoolVar(&o.dryRun, "dry-run", false, "print the plan without applying it")
+}
+
+// importOptions guard man import against changes a CSV makes too easily.
+type importOptions struct {
+	// enable turns authorized networks on for a cluster that has them off.
+	enable bool
+	// allowEmpty lets a CSV without entries remove every entry.
+	allowEmpty bool
+}
+
+func newManImportCmd() *cobra.Command {
+	var target targetOptions
+	var apply applyOptions
+	var opts importOptions
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Apply a reviewed CSV as a cluster's complete allow-list",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runManImport(cmd.Context(), target, apply, opts)
+		}),
+	}
+	target.addFlags(cmd)
+	apply.addFlags(cmd, "reviewed CSV to apply as the complete allow-list")
+	cmd.Flags().BoolVar(&opts.enable, "enable", false, "turn authorized networks on if the cluster has them off")
+	cmd.Flags().BoolVar(&opts.allowEmpty, "allow-empty", false, "accept a CSV without entries, removing every entry")
+	return cmd
+}
+
+func runManImport(ctx context.Context, target targetOptions, apply applyOptions, opts importOptions) error {
+	if apply.file == "" {
+		return fmt.Errorf("no CSV given; pass --file")
+	}
+
+	f, err := os.Open(apply.file)
+	if err != nil {
+		return fmt.Errorf("failed to open %s: %v", apply.file, err)
+	}
+	desired, err := readCidrBlocksCSV(f)
+	f.Close()
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", apply.file, err)
+	}
+	if len(desired) == 0 && !opts.allowEmpty {
+		return usageError{fmt.Errorf("%s has no entries, which would remove them all; pass --allow-empty if that's intended", apply.file)}
+	}
+
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	// Applying the list turns authorized networks on, opening the control
+	// plane to the listed networks only.
+	if !gke.HasAuthorizedNetworks(cluster) && !opts.enable {
+		return usageError{fmt.Errorf("%s has authorized networks turned off; pass --enable to turn them on with %s", config.Cluster, apply.file)}
+	}
+
+	plan := man.NewPlanner(man.Policy{Prune: true}).Plan(gke.AuthorizedEntries(cluster), gke.EntriesFromBlocks(desired))
+	enabling := !gke.HasAuthorizedNetworks(cluster)
+	if plan.Empty() && !enabling {
+		fmt.Printf("✅ Authorized networks of %s already match %s\n", config.Cluster, apply.file)
+		return nil
+	}
+
+	fmt.Printf("Changes to authorized networks of %s:\n\n", config.Cluster)
+	if enabling {
+		fmt.Printf("  ! turn authorized networks on\n")
+	}
+	printPlan(plan)
+
+	if plan.Limit.Exceeded {
+		return fmt.Errorf("%s has %d entries, more than the limit of %d", apply.file, plan.Limit.After, plan.Limit.Max)
+	}
+	if apply.dryRun {
+		return nil
+	}
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	changes := planChanges(plan)
+	if enabling {
+		changes = append([]string{"! turn authorized networks on"}, changes...)
+	}
+	req := newApproval("man import", "Apply these changes?", []string{clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}.String()}, changes)
+	if err := approve(ctx, apply.yes, req); err != nil {
+		return err
+	}
+
+	fmt.Printf("📡 Updating authorized networks...\n")
+	if err := applyAuthorizedNetworks(ctx, config, cluster, plan.Result, nil); err != nil {
+		return err
+	}
+	fmt.Printf("✨ Applied %s to %s\n", pluralize(len(plan.Result), "entry"), config.Cluster)
+	return nil
+}
+
+func writeCidrBlocksCSV(w io.Writer, blocks []*container.CidrBlock) error {
+	cw := csv.NewWriter(w)
+	cw.Write(csvHeader)
+	for _, block := range blocks {
+		cw.Write([]string{block.DisplayName, block.CidrBlock})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func readCidrBlocksCSV(r io.Reader) ([]*container.CidrBlock, error) {
+	records, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, err
+	}
+
+	var blocks []*container.CidrBlock
+	for i, record := range records {
+		if len(record) < 2 {
+			return nil, fmt.Errorf("line %d: expected display_name,cidr_block", i+1)
+		}
+		name, cidr := strings.TrimSpace(record[0]), strings.TrimSpace(record[1])
+		if i == 0 && name == csvHeader[0] {
+			continue
+		}
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return nil, fmt.Errorf("line %d: invalid CIDR %q", i+1, cidr)
+		}
+		blocks = append(blocks, &container.CidrBlock{DisplayName: name, CidrBlock: cidr})
+	}
+	return blocks, nil
+}
+
+// printPlan shows a plan as a diff followed by its warnings.
+// planChanges lists the changes of plan one per line, as printPlan shows
+// them, for approvals.
+func planChanges(plan *man.Plan) []string {
+	var changes []string
+	for _, entry := range plan.Removes {
+		changes = append(changes, fmt.Sprintf("- %s %s", entry.DisplayName, entry.CIDR))
+	}
+	for _, update := range plan.Updates {
+		changes = append(changes, fmt.Sprintf("~ %s %s -> %s", update.New.DisplayName, update.Old.CIDR, update.New.CIDR))
+	}
+	for _, entry := range plan.Adds {
+		changes = append(changes, fmt.Sprintf("+ %s %s", entry.DisplayName, entry.CIDR))
+	}
+	return changes
+}
+
+func printPlan(plan *man.Plan) {
+	for _, entry := range plan.Removes {
+		fmt.Printf("  - %-30s %s\n", entry.DisplayName, entry.CIDR)
+	}
+	for _, update := range plan.Updates {
+		fmt.Printf("  ~ %-30s %s -> %s\n", update.New.DisplayName, update.Old.CIDR, update.New.CIDR)
+	}
+	for _, entry := range plan.Adds {
+		fmt.Printf("  + %-30s %s\n", entry.DisplayName, entry.CIDR)
+	}
+	fmt.Printf("\n  %d -> %d of %d entries\n", plan.Limit.Before, plan.Limit.After, plan.Limit.Max)
+	for _, warning := range plan.Warnings {
+		fmt.Printf("  ⚠️  %s\n", warning)
+	}
+	fmt.Println()
+}
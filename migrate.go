@@ -0,0 +1,372 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// migrationHints is what migrate-hints learned from shell history and
+// kubeconfig.
+type migrationHints struct {
+	// fetched counts get-credentials runs per cluster.
+	fetched map[clusterRef]int
+	// contexts are the GKE clusters found in kubeconfig.
+	contexts map[clusterRef]bool
+	// cidrs counts the ranges passed to --master-authorized-networks.
+	cidrs map[string]int
+	// namespaces are the namespaces switched to right after fetching a
+	// cluster's credentials.
+	namespaces map[clusterRef]string
+	// configurations counts activated gcloud configurations.
+	configurations map[string]int
+
+	// project is the gcloud default project, looked up once it is needed.
+	project *string
+}
+
+// configSuggestion is the part of the config file migrate-hints suggests.
+type configSuggestion struct {
+	Pinned     []clusterRef              `json:"pinned,omitempty" yaml:"pinned,omitempty"`
+	Profiles   map[string]networkProfile `json:"profiles,omitempty" yaml:"profiles,omitempty"`
+	Namespaces map[string]string         `json:"namespaces,omitempty" yaml:"namespaces,omitempty"`
+}
+
+func newMigrateHintsCmd() *cobra.Command {
+	var histories []string
+	var output string
+	cmd := &cobra.Command{
+		Use:   "migrate-hints",
+		Short: "Suggest config for the gcloud and kubectl commands you run by hand",
+		Long: `Reads your shell history and kubeconfig for manual workflows, such as
+repeated gcloud container clusters get-credentials runs, and suggests the
+pinned clusters, network profiles and namespaces to add to the config file
+so gke does the same for you. Nothing is changed.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if len(histories) == 0 {
+				histories = shellHistories()
+			}
+			return runMigrateHints(histories, output)
+		},
+	}
+	cmd.Flags().StringSliceVar(&histories, "history", nil, "shell history files to read (default: bash, zsh and fish history)")
+	cmd.Flags().StringVarP(&output, "output", "o", "", "print only the suggested config as json or yaml")
+	return cmd
+}
+
+// shellHistories returns the usual history files that exist.
+func shellHistories() []string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return nil
+	}
+	candidates := []string{
+		os.Getenv("HISTFILE"),
+		filepath.Join(home, ".bash_history"),
+		filepath.Join(home, ".zsh_history"),
+		filepath.Join(home, ".local", "share", "fish", "fish_history"),
+	}
+	var paths []string
+	for _, path := range unique(candidates) {
+		if path == "" {
+			continue
+		}
+		if _, err := os.Stat(path); err == nil {
+			paths = append(paths, path)
+		}
+	}
+	return paths
+}
+
+func runMigrateHints(histories []string, output string) error {
+	hints := &migrationHints{
+		fetched:        make(map[clusterRef]int),
+		contexts:       make(map[clusterRef]bool),
+		cidrs:          make(map[string]int),
+		namespaces:     make(map[clusterRef]string),
+		configurations: make(map[string]int),
+	}
+	for _, path := range histories {
+		if err := hints.readHistory(path); err != nil {
+			return err
+		}
+	}
+	contexts, _ := kube.Contexts()
+	for _, name := range contexts {
+		if ref, ok := parseContextName(name); ok {
+			hints.contexts[ref] = true
+		}
+	}
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	suggested := hints.suggest(cfg)
+	if output != "" {
+		return writeOutput(os.Stdout, output, suggested, nil)
+	}
+	hints.print(suggested, len(histories))
+	return nil
+}
+
+// readHistory scans a bash, zsh or fish history file.
+func (h *migrationHints) readHistory(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return fmt.Errorf("failed to read history: %v", err)
+	}
+	defer f.Close()
+
+	var last clusterRef
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		for _, args := range historyCommands(scanner.Text()) {
+			switch {
+			case hasPrefixArgs(args, "gcloud", "container", "clusters", "get-credentials"):
+				if ref, ok := getCredentialsTarget(args[4:]); ok {
+					if ref.Project == "" {
+						ref.Project = h.defaultProject()
+					}
+					h.fetched[ref]++
+					last = ref
+				}
+			case hasPrefixArgs(args, "gcloud", "container", "clusters", "update"):
+				for _, cidr := range strings.Split(flagValue(args[4:], "--master-authorized-networks"), ",") {
+					if cidr != "" {
+						h.cidrs[cidr]++
+					}
+				}
+			case hasPrefixArgs(args, "gcloud", "config", "configurations", "activate") && len(args) > 4:
+				h.configurations[args[4]]++
+			case hasPrefixArgs(args, "kubectl", "config", "set-context") || hasPrefixArgs(args, "kubens"):
+				namespace := flagValue(args, "--namespace")
+				if args[0] == "kubens" && len(args) == 2 {
+					namespace = args[1]
+				}
+				if namespace != "" && last.Cluster != "" {
+					h.namespaces[last] = namespace
+				}
+			}
+		}
+	}
+	return scanner.Err()
+}
+
+// historyCommands splits a history line into its commands, dropping zsh
+// timestamps and the fish "- cmd:" prefix.
+func historyCommands(line string) [][]string {
+	if strings.HasPrefix(line, ": ") {
+		if i := strings.Index(line, ";"); i >= 0 {
+			line = line[i+1:]
+		}
+	}
+	line = strings.TrimPrefix(line, "- cmd: ")
+
+	var commands [][]string
+	for _, part := range strings.FieldsFunc(line, func(r rune) bool { return r == ';' || r == '&' || r == '|' }) {
+		args := strings.Fields(part)
+		for len(args) > 0 && (args[0] == "sudo" || strings.Contains(args[0], "=")) {
+			args = args[1:]
+		}
+		if len(args) > 0 {
+			commands = append(commands, args)
+		}
+	}
+	return commands
+}
+
+func hasPrefixArgs(args []string, prefix ...string) bool {
+	if len(args) < len(prefix) {
+		return false
+	}
+	for i, arg := range prefix {
+		if args[i] != arg {
+			return false
+		}
+	}
+	return true
+}
+
+// flagValue returns the value of --name given as "--name value" or
+// "--name=value".
+func flagValue(args []string, name string) string {
+	for i, arg := range args {
+		if value, ok := strings.CutPrefix(arg, name+"="); ok {
+			return strings.Trim(value, `"'`)
+		}
+		if arg == name && i+1 < len(args) {
+			return strings.Trim(args[i+1], `"'`)
+		}
+	}
+	return ""
+}
+
+func (h *migrationHints) defaultProject() string {
+	if h.project == nil {
+		project := defaultProject()
+		h.project = &project
+	}
+	return *h.project
+}
+
+// getCredentialsTarget reads the cluster of get-credentials arguments. The
+// project is empty when it was left to the gcloud default.
+func getCredentialsTarget(args []string) (clusterRef, bool) {
+	var ref clusterRef
+	for i := 0; i < len(args); i++ {
+		if !strings.HasPrefix(args[i], "-") {
+			ref.Cluster = args[i]
+			break
+		}
+		if !strings.Contains(args[i], "=") {
+			i++
+		}
+	}
+	ref.Project = flagValue(args, "--project")
+	for _, flag := range []string{"--location", "--region", "--zone"} {
+		if location := flagValue(args, flag); location != "" {
+			ref.Location = location
+		}
+	}
+	return ref, ref.Cluster != ""
+}
+
+// parseContextName reverses kubeconfig.ContextName. GKE project, location
+// and cluster names can't contain underscores.
+func parseContextName(name string) (clusterRef, bool) {
+	parts := strings.Split(name, "_")
+	if len(parts) != 4 || parts[0] != "gke" {
+		return clusterRef{}, false
+	}
+	return clusterRef{Project: parts[1], Location: parts[2], Cluster: parts[3]}, true
+}
+
+// suggest returns the config additions the hints call for, leaving out
+// what cfg already has.
+func (h *migrationHints) suggest(cfg *userConfig) *configSuggestion {
+	suggested := &configSuggestion{}
+
+	pinned := make(map[clusterRef]bool)
+	for _, ref := range cfg.Pinned {
+		pinned[ref] = true
+	}
+	// A cluster fetched again and again, or fetched and still in
+	// kubeconfig, is one you work with regularly.
+	for ref, n := range h.fetched {
+		if ref.Project != "" && !pinned[ref] && (n >= 2 || h.contexts[ref]) {
+			suggested.Pinned = append(suggested.Pinned, ref)
+		}
+	}
+	sort.Slice(suggested.Pinned, func(i, j int) bool {
+		return suggested.Pinned[i].String() < suggested.Pinned[j].String()
+	})
+
+	known := make(map[string]bool)
+	for _, profile := range cfg.Profiles {
+		known[profile.CIDR] = true
+	}
+	var cidrs []string
+	for cidr, n := range h.cidrs {
+		// Ranges wider than a single host keep coming back: offices, VPNs.
+		if n >= 2 && !known[cidr] && !strings.HasSuffix(cidr, "/32") {
+			cidrs = append(cidrs, cidr)
+		}
+	}
+	sort.Strings(cidrs)
+	for i, cidr := range cidrs {
+		if suggested.Profiles == nil {
+			suggested.Profiles = make(map[string]networkProfile)
+		}
+		suggested.Profiles[fmt.Sprintf("network-%d", i+1)] = networkProfile{CIDR: cidr}
+	}
+
+	for ref, namespace := range h.namespaces {
+		if _, ok := cfg.Namespaces[ref.String()]; ok {
+			continue
+		}
+		if _, ok := cfg.Namespaces[ref.Cluster]; ok {
+			continue
+		}
+		if suggested.Namespaces == nil {
+			suggested.Namespaces = make(map[string]string)
+		}
+		suggested.Namespaces[ref.String()] = namespace
+	}
+	return suggested
+}
+
+func (h *migrationHints) print(suggested *configSuggestion, histories int) {
+	fmt.Printf("🔎 Read %s and %s from kubeconfig\n\n",
+		pluralize(histories, "history file"), pluralize(len(h.contexts), "GKE context"))
+
+	var fetched []clusterRef
+	for ref := range h.fetched {
+		fetched = append(fetched, ref)
+	}
+	sort.Slice(fetched, func(i, j int) bool { return fetched[i].String() < fetched[j].String() })
+	byProject := make(map[string][]string)
+	for _, ref := range fetched {
+		fmt.Printf("  %-50s get-credentials run %s\n", ref, pluralize(h.fetched[ref], "time"))
+		if ref.Project != "" {
+			byProject[ref.Project] = append(byProject[ref.Project], ref.Cluster)
+		}
+	}
+	if len(h.fetched) > 0 {
+		fmt.Println()
+	}
+
+	var hints []string
+	if len(suggested.Pinned) > 0 {
+		hints = append(hints, fmt.Sprintf("Pin %s so `gke daemon` refreshes their access and credentials on a schedule and `gke watch` follows IP changes", pluralize(len(suggested.Pinned), "cluster")))
+	}
+	var projects []string
+	for project := range byProject {
+		projects = append(projects, project)
+	}
+	sort.Strings(projects)
+	for _, project := range projects {
+		if clusters := unique(byProject[project]); len(clusters) > 1 {
+			sort.Strings(clusters)
+			hints = append(hints, fmt.Sprintf("Fetch the clusters of %s in one go: gke batch --project %s --clusters %s", project, project, strings.Join(clusters, ",")))
+		}
+	}
+	if len(suggested.Profiles) > 0 {
+		hints = append(hints, "Save the ranges you keep authorizing as network profiles and pick one with --profile instead of editing --master-authorized-networks")
+	}
+	if len(suggested.Namespaces) > 0 {
+		hints = append(hints, "Let written contexts start in the namespace you switch to anyway")
+	}
+	var configurations []string
+	for name := range h.configurations {
+		configurations = append(configurations, name)
+	}
+	sort.Strings(configurations)
+	for _, name := range configurations {
+		hints = append(hints, fmt.Sprintf("Instead of activating the gcloud configuration %s, pass --configuration %s for one run", name, name))
+	}
+
+	if len(hints) == 0 {
+		fmt.Println("✅ No manual workflows found that gke could take over")
+		return
+	}
+	fmt.Println("💡 Suggestions:")
+	for _, hint := range hints {
+		fmt.Printf("  - %s\n", hint)
+	}
+	if len(suggested.Pinned) == 0 && len(suggested.Profiles) == 0 && len(suggested.Namespaces) == 0 {
+		return
+	}
+
+	path, _ := configPath()
+	fmt.Printf("\n📝 Add to %s:\n\n", path)
+	writeOutput(os.Stdout, "json", suggested, nil)
+}
@@ -0,0 +1,315 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+// nodePoolInfo is a node pool as shown by `gke list nodepools` and the
+// picker.
+type nodePoolInfo struct {
+	Name        string `json:"name" yaml:"name"`
+	MachineType string `json:"machineType" yaml:"machineType"`
+	// NodeCount is the pool's current size per zone, read from its
+	// instance groups, or nil when it couldn't be read. Autoscaling moves
+	// it within the range.
+	NodeCount *int64 `json:"nodeCount,omitempty" yaml:"nodeCount,omitempty"`
+	Zones     int    `json:"zones,omitempty" yaml:"zones,omitempty"`
+	// Autoscaling is the node count range, e.g. "1-5 per zone" or "3-10
+	// total", or empty when autoscaling is off.
+	Autoscaling string `json:"autoscaling,omitempty" yaml:"autoscaling,omitempty"`
+	Version     string `json:"version" yaml:"version"`
+	Spot        bool   `json:"spot" yaml:"spot"`
+	Preemptible bool   `json:"preemptible" yaml:"preemptible"`
+	Status      string `json:"status" yaml:"status"`
+}
+
+func newNodePoolInfo(pool *container.NodePool) nodePoolInfo {
+	info := nodePoolInfo{
+		Name:    pool.Name,
+		Zones:   len(pool.Locations),
+		Version: pool.Version,
+		Status:  pool.Status,
+	}
+	if pool.Config != nil {
+		info.MachineType = pool.Config.MachineType
+		info.Spot = pool.Config.Spot
+		info.Preemptible = pool.Config.Preemptible
+	}
+	if a := pool.Autoscaling; a != nil && a.Enabled {
+		if a.TotalMaxNodeCount > 0 {
+			info.Autoscaling = fmt.Sprintf("%d-%d total", a.TotalMinNodeCount, a.TotalMaxNodeCount)
+		} else {
+			info.Autoscaling = fmt.Sprintf("%d-%d per zone", a.MinNodeCount, a.MaxNodeCount)
+		}
+	}
+	return info
+}
+
+// nodes returns NodeCount for display, "?" when it is unknown.
+func (p nodePoolInfo) nodes() string {
+	if p.NodeCount == nil {
+		return "?"
+	}
+	return fmt.Sprint(*p.NodeCount)
+}
+
+// nodePools describes the node pools of cluster, without their sizes.
+func nodePools(cluster *container.Cluster) []nodePoolInfo {
+	pools := []nodePoolInfo{}
+	for _, pool := range cluster.NodePools {
+		pools = append(pools, newNodePoolInfo(pool))
+	}
+	return pools
+}
+
+// nodePoolSizes returns the current size per zone of cluster's node pools,
+// by name.
+func nodePoolSizes(ctx context.Context, cluster *container.Cluster) (map[string]int64, error) {
+	api, err := instanceGroupAPI(ctx)
+	if err != nil {
+		return nil, err
+	}
+	sizes := make(map[string]int64)
+	for _, pool := range cluster.NodePools {
+		size, err := gke.NodePoolSize(ctx, api, pool)
+		if err != nil {
+			return nil, err
+		}
+		sizes[pool.Name] = size
+	}
+	return sizes, nil
+}
+
+// liveNodePools describes the node pools of cluster with their current
+// sizes. When those can't be read, the pools come without them along with
+// the error.
+func liveNodePools(ctx context.Context, cluster *container.Cluster) ([]nodePoolInfo, error) {
+	pools := nodePools(cluster)
+	sizes, err := nodePoolSizes(ctx, cluster)
+	if err != nil {
+		return pools, err
+	}
+	for i := range pools {
+		size := sizes[pools[i].Name]
+		pools[i].NodeCount = &size
+	}
+	return pools, nil
+}
+
+// writeNodePools writes pools as tab-separated rows under a header.
+func writeNodePools(w io.Writer, pools []nodePoolInfo) {
+	fmt.Fprintln(w, "NAME\tMACHINE TYPE\tNODES\tAUTOSCALING\tVERSION\tCAPACITY\tSTATUS")
+	for _, p := range pools {
+		nodes := p.nodes()
+		if p.Zones > 1 {
+			nodes += fmt.Sprintf(" × %d zones", p.Zones)
+		}
+		autoscaling := p.Autoscaling
+		if autoscaling == "" {
+			autoscaling = "off"
+		}
+		capacity := "on-demand"
+		switch {
+		case p.Spot:
+			capacity = "spot"
+		case p.Preemptible:
+			capacity = "preemptible"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s\t%s\n", p.Name, p.MachineType, nodes, autoscaling, p.Version, capacity, p.Status)
+	}
+}
+
+func runListNodePools(ctx context.Context, target targetOptions, output string) error {
+	_, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	pools, err := liveNodePools(ctx, cluster)
+	if err != nil {
+		slog.Warn("couldn't read the node pool sizes", "err", err)
+	}
+	return writeOutput(os.Stdout, output, pools, func(w io.Writer) {
+		writeNodePools(w, pools)
+	})
+}
+
+// showNodePools lists the node pools of the highlighted cluster, and reads
+// their sizes in the background.
+func (m *model) showNodePools() tea.Cmd {
+	cluster := m.clusters[m.cursor]
+	m.push()
+	m.step = "nodepools"
+	m.poolsOf = cluster.Name
+	m.setNodePools(nodePools(cluster))
+	m.cursor = 0
+	if offline {
+		return nil
+	}
+	return loadNodePoolSizes(cluster)
+}
+
+// setNodePools shows pools as the node pool rows.
+func (m *model) setNodePools(pools []nodePoolInfo) {
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	writeNodePools(w, pools)
+	w.Flush()
+	rows := strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+	m.poolHeader = rows[0]
+	m.choices = rows[1:]
+}
+
+// nodePoolsMsg delivers a cluster's node pools with their sizes.
+type nodePoolsMsg struct {
+	cluster string
+	pools   []nodePoolInfo
+}
+
+// loadNodePoolSizes reads the sizes of cluster's node pools. The list
+// keeps showing them as unknown if that fails.
+func loadNodePoolSizes(cluster *container.Cluster) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		pools, err := liveNodePools(ctx, cluster)
+		if err != nil {
+			slog.Warn("couldn't read the node pool sizes", "cluster", cluster.Name, "err", err)
+			return nil
+		}
+		return nodePoolsMsg{cluster: cluster.Name, pools: pools}
+	}
+}
+
+func newNodePoolCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "nodepool",
+		Short: "Change a cluster's node pools",
+	}
+	cmd.AddCommand(newNodePoolResizeCmd())
+	return cmd
+}
+
+func newNodePoolResizeCmd() *cobra.Command {
+	var target targetOptions
+	var size int64
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "resize POOL --size N",
+		Short: "Set the number of nodes per zone of a node pool",
+		Long: `Resizes a node pool to N nodes in each of its zones and follows the
+operation until it is done. The same checks as for authorized network
+changes apply: externally managed clusters need --allow-managed or the
+cluster name typed, preChange hooks run with MY_GKE_ACTION=resize, and
+the resize needs confirmation (--yes, --approve-file or --approve-webhook
+in scripts).`,
+		Args: cobra.ExactArgs(1),
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			if !cmd.Flags().Changed("size") {
+				return usageError{fmt.Errorf("no size given; pass --size")}
+			}
+			return runNodePoolResize(cmd.Context(), target, args[0], size, yes)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().Int64Var(&size, "size", 0, "number of nodes per zone")
+	cmd.Flags().BoolVar(&yes, "yes", false, "resize without asking for confirmation")
+	return cmd
+}
+
+func runNodePoolResize(ctx context.Context, target targetOptions, poolName string, size int64, yes bool) error {
+	if size < 0 {
+		return usageError{fmt.Errorf("invalid size %d", size)}
+	}
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	pools, err := liveNodePools(ctx, cluster)
+	if err != nil {
+		slog.Warn("couldn't read the node pool sizes", "err", err)
+	}
+	var pool *nodePoolInfo
+	for _, p := range pools {
+		if p.Name == poolName {
+			pool = &p
+			break
+		}
+	}
+	if pool == nil {
+		return fmt.Errorf("%s has no node pool %s; see gke list nodepools", config.Cluster, poolName)
+	}
+
+	change := fmt.Sprintf("%s: %d nodes per zone", pool.Name, size)
+	if pool.Zones > 1 {
+		change += fmt.Sprintf(" (%d in total)", size*int64(pool.Zones))
+	}
+	fmt.Printf("Resize node pool %s of %s, now at %s nodes per zone, to %d\n", pool.Name, config.Cluster, pool.nodes(), size)
+	if pool.Autoscaling != "" {
+		fmt.Printf("⚠️  Autoscaling is on (%s) and may change the size again\n", pool.Autoscaling)
+	}
+	fmt.Println()
+
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	if err := runPreChangeHooks(ctx, config, "resize"); err != nil {
+		return err
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	req := newApproval("nodepool resize", "Resize it?", []string{ref.String()}, []string{change})
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	if err := resizeNodePool(ctx, api, config, pool.Name, size); err != nil {
+		return err
+	}
+	fmt.Printf("✨ Resized %s of %s to %d nodes per zone\n", pool.Name, config.Cluster, size)
+	return nil
+}
+
+// resizeNodePool resizes config's node pool called pool and follows the
+// operation.
+func resizeNodePool(ctx context.Context, api gke.ClusterAPI, config GKEConfig, pool string, size int64) error {
+	fmt.Printf("📡 Resizing %s to %d nodes per zone...\n", pool, size)
+	return followOperation(func(onProgress func(gke.Progress)) error {
+		return gke.ResizeNodePool(ctx, api, config.target(), pool, size, onProgress)
+	})
+}
+
+// followOperation runs op, showing the progress it reports on a line of
+// its own that is rewritten as it advances.
+func followOperation(op func(onProgress func(gke.Progress)) error) error {
+	shown := false
+	onProgress := func(p gke.Progress) {
+		if !p.Known {
+			return
+		}
+		line := fmt.Sprintf("   %3.0f%%", p.Percent*100)
+		if stage := humanizeStage(p.Stage); stage != "" {
+			line += "  " + stage
+		}
+		fmt.Printf("\r%-60s", line)
+		shown = true
+	}
+	err := op(onProgress)
+	if shown {
+		fmt.Println()
+	}
+	return err
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"net"
+	"time"
+
+	"golang.org/x/exp/slog"
+)
+
+// offlineFlag is --offline.
+var offlineFlag bool
+
+// offline is set by --offline or when Google Cloud turns out to be
+// unreachable. No API calls are made then: listings come from the cache
+// however old, and nothing is changed.
+var offline bool
+
+// staleSince is when the oldest listing shown offline was fetched.
+var staleSince time.Time
+
+// errOffline is returned instead of calling Google Cloud while offline.
+var errOffline = errors.New("offline: Google Cloud is unreachable or --offline is set")
+
+// offlineKey is the context key of the offline state withOffline records.
+type offlineKey struct{}
+
+// withOffline returns ctx carrying isOffline, the offline state read on the
+// TUI's goroutine, for API calls made from a tea.Cmd: goOffline and
+// tryOnline change the global there while the call runs.
+func withOffline(ctx context.Context, isOffline bool) context.Context {
+	return context.WithValue(ctx, offlineKey{}, isOffline)
+}
+
+// backgroundContext is withOffline with the current offline state. Call
+// it on the TUI's goroutine, before starting the background work.
+func backgroundContext() context.Context {
+	return withOffline(context.Background(), offline)
+}
+
+// offlineFor reports whether calls made with ctx must stay offline: the
+// state recorded by withOffline, or else the current one.
+func offlineFor(ctx context.Context) bool {
+	if isOffline, ok := ctx.Value(offlineKey{}).(bool); ok {
+		return isOffline
+	}
+	return offline
+}
+
+// unreachable reports whether err means Google Cloud couldn't be reached,
+// as opposed to answering with an error.
+func unreachable(err error) bool {
+	var netErr net.Error
+	return errors.As(err, &netErr)
+}
+
+// goOffline switches to offline mode if err means Google Cloud is
+// unreachable, and reports whether it did.
+func goOffline(err error) bool {
+	if !unreachable(err) {
+		return false
+	}
+	if !offline {
+		slog.Info("Google Cloud is unreachable, continuing offline", "err", err)
+	}
+	offline = true
+	return true
+}
+
+// tryOnline leaves offline mode unless --offline asked for it, so the next
+// API call finds out whether Google Cloud is back.
+func tryOnline() {
+	if !offlineFlag {
+		offline = false
+		staleSince = time.Time{}
+	}
+}
+
+// noteStale records that a listing fetched at fetched is shown offline.
+func noteStale(fetched time.Time) {
+	if staleSince.IsZero() || fetched.Before(staleSince) {
+		staleSince = fetched
+	}
+}
@@ -0,0 +1,47 @@
+package main
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"gopkg.in/yaml.v3"
+)
+
+// writeOutput writes v to out as JSON or YAML, or calls table for the
+// default human-readable format. table receives a tabwriter that is flushed
+// afterwards. For CSV, table's tab-separated lines become the records.
+func writeOutput(out io.Writer, format string, v interface{}, table func(w io.Writer)) error {
+	switch format {
+	case "", "table":
+		w := tabwriter.NewWriter(out, 0, 4, 2, ' ', 0)
+		table(w)
+		return w.Flush()
+	case "csv":
+		var buf bytes.Buffer
+		table(&buf)
+		cw := csv.NewWriter(out)
+		for _, line := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+			cw.Write(strings.Split(line, "\t"))
+		}
+		cw.Flush()
+		return cw.Error()
+	case "json":
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		return enc.Encode(v)
+	case "yaml":
+		enc := yaml.NewEncoder(out)
+		enc.SetIndent(2)
+		if err := enc.Encode(v); err != nil {
+			return err
+		}
+		return enc.Close()
+	default:
+		return fmt.Errorf("unsupported output format %q, expected table, csv, json or yaml", format)
+	}
+}
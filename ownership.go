@@ -0,0 +1,107 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+
+	"gke-tool/pkg/gke"
+	"golang.org/x/term"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// allowManaged skips the confirmation for externally managed clusters, for
+// scripted and background use.
+var allowManaged bool
+
+// confirmManaged asks before changing an externally managed cluster. The
+// TUI replaces it with an in-app prompt.
+var confirmManaged = promptManaged
+
+// checkOwnership shows the cluster's protections and owners before a
+// change, and for clusters managed by other tools requires the user to
+// type the cluster name (or --allow-managed). Removing the user's own
+// entry again, on expiry or at the end of a session, skips this check.
+func checkOwnership(ctx context.Context, config GKEConfig, cluster *container.Cluster) error {
+	own := gke.DetectOwnership(cluster)
+	protected, err := deletionProtection(ctx, config)
+	if err != nil {
+		fmt.Printf("⚠️  Could not read deletion protection of %s: %v\n", config.Cluster, err)
+	}
+	own.DeletionProtection = protected
+
+	if desc := own.String(); desc != "" {
+		fmt.Printf("🛡️  %s: %s\n", config.Cluster, desc)
+	}
+	if !own.External() || allowManaged {
+		return nil
+	}
+	if !confirmManaged(config.Cluster, own) {
+		return fmt.Errorf("%w: %s is externally managed (%s); pass --allow-managed to change it anyway", errAborted, config.Cluster, own)
+	}
+	return nil
+}
+
+// promptManaged asks the user to type the cluster name. Without a
+// terminal it refuses.
+func promptManaged(name string, own gke.Ownership) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Printf("\n⚠️  %s is %s. Changes made here may be reverted or cause drift.\n", name, own)
+	fmt.Printf("Type the cluster name to continue: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return err == nil && strings.TrimSpace(answer) == name
+}
+
+// deletionProtection reports whether the cluster has deletion protection
+// on. The field is newer than the API client in use, so it is read with a
+// partial-response request of its own.
+func deletionProtection(ctx context.Context, config GKEConfig) (bool, error) {
+	opts, err := clientOptions(ctx, "container")
+	if err != nil {
+		return false, err
+	}
+	// The generated client resolves the endpoint; the request itself goes
+	// through an authenticated client built from the same options.
+	svc, err := container.NewService(ctx, opts...)
+	if err != nil {
+		return false, err
+	}
+	client, _, err := htransport.NewClient(ctx, append(opts, option.WithScopes(cloudPlatformScope))...)
+	if err != nil {
+		return false, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet,
+		svc.BasePath+"v1/"+config.target().Name()+"?fields=deletionProtection", nil)
+	if err != nil {
+		return false, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer resp.Body.Close()
+	if err := googleapi.CheckResponse(resp); err != nil {
+		return false, err
+	}
+
+	var body struct {
+		DeletionProtection bool `json:"deletionProtection"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return false, err
+	}
+	return body.DeletionProtection, nil
+}
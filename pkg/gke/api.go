@@ -0,0 +1,140 @@
+// Package gke lists projects, clusters and fleet memberships and reconciles
+// a cluster's master authorized networks. The Google API clients sit behind
+// the ProjectAPI, HierarchyAPI, ClusterAPI, FleetAPI and InstanceGroupAPI
+// interfaces so callers can supply fakes; NewProjectClient,
+// NewHierarchyClient, NewClusterClient, NewFleetClient and
+// NewInstanceGroupClient adapt the generated clients.
+package gke
+
+import (
+	"context"
+	"fmt"
+
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+)
+
+// ProjectAPI is the subset of the Resource Manager v3 API used here.
+type ProjectAPI interface {
+	// SearchProjects returns every project matching query, which uses the
+	// projects.search query syntax and may be empty. Only the name, ID,
+	// display name, labels and state need to be filled in.
+	SearchProjects(ctx context.Context, query string) ([]*resourcemanager.Project, error)
+}
+
+// ClusterAPI is the subset of the GKE API used here. Names are full
+// resource names such as "projects/p/locations/l/clusters/c".
+type ClusterAPI interface {
+	// ListClusters returns the clusters under parent. When some zones
+	// didn't answer, the clusters that were listed come with a
+	// *MissingZonesError.
+	ListClusters(ctx context.Context, parent string) ([]*container.Cluster, error)
+	GetCluster(ctx context.Context, name string) (*container.Cluster, error)
+	UpdateCluster(ctx context.Context, name string, req *container.UpdateClusterRequest) (*container.Operation, error)
+	// SetNodePoolSize resizes the node pool called name, such as
+	// "projects/p/locations/l/clusters/c/nodePools/n".
+	SetNodePoolSize(ctx context.Context, name string, req *container.SetNodePoolSizeRequest) (*container.Operation, error)
+	SetMaintenancePolicy(ctx context.Context, name string, req *container.SetMaintenancePolicyRequest) (*container.Operation, error)
+	GetOperation(ctx context.Context, name string) (*container.Operation, error)
+	// ListOperations returns the operations of a location, named like
+	// "projects/p/locations/l".
+	ListOperations(ctx context.Context, parent string) ([]*container.Operation, error)
+	// GetServerConfig returns the versions offered in a location, named
+	// like "projects/p/locations/l".
+	GetServerConfig(ctx context.Context, name string) (*container.ServerConfig, error)
+}
+
+// NewProjectClient adapts a Resource Manager v3 client to ProjectAPI.
+func NewProjectClient(svc *resourcemanager.Service) ProjectAPI {
+	return projectClient{svc}
+}
+
+type projectClient struct {
+	svc *resourcemanager.Service
+}
+
+// projectFields are the only project fields fetched, which keeps large
+// listings small.
+const projectFields = "nextPageToken,projects(name,projectId,displayName,labels,state)"
+
+func (c projectClient) SearchProjects(ctx context.Context, query string) ([]*resourcemanager.Project, error) {
+	var projects []*resourcemanager.Project
+	call := c.svc.Projects.Search().Fields(googleapi.Field(projectFields))
+	if query != "" {
+		call = call.Query(query)
+	}
+	err := call.Pages(ctx, func(resp *resourcemanager.SearchProjectsResponse) error {
+		projects = append(projects, resp.Projects...)
+		return nil
+	})
+	return projects, err
+}
+
+// NewClusterClient adapts a GKE client to ClusterAPI.
+func NewClusterClient(svc *container.Service) ClusterAPI {
+	return clusterClient{svc}
+}
+
+type clusterClient struct {
+	svc *container.Service
+}
+
+func (c clusterClient) ListClusters(ctx context.Context, parent string) ([]*container.Cluster, error) {
+	resp, err := c.svc.Projects.Locations.Clusters.List(parent).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	if len(resp.MissingZones) > 0 {
+		return resp.Clusters, &MissingZonesError{Zones: resp.MissingZones}
+	}
+	return resp.Clusters, nil
+}
+
+func (c clusterClient) GetCluster(ctx context.Context, name string) (*container.Cluster, error) {
+	return c.svc.Projects.Locations.Clusters.Get(name).Context(ctx).Do()
+}
+
+func (c clusterClient) UpdateCluster(ctx context.Context, name string, req *container.UpdateClusterRequest) (*container.Operation, error) {
+	return c.svc.Projects.Locations.Clusters.Update(name, req).Context(ctx).Do()
+}
+
+func (c clusterClient) GetOperation(ctx context.Context, name string) (*container.Operation, error) {
+	return c.svc.Projects.Locations.Operations.Get(name).Context(ctx).Do()
+}
+
+// Target identifies a cluster.
+type Target struct {
+	Project  string
+	Location string
+	Cluster  string
+}
+
+// Name is the cluster's resource name.
+func (t Target) Name() string {
+	return fmt.Sprintf("projects/%s/locations/%s/clusters/%s", t.Project, t.Location, t.Cluster)
+}
+
+func (t Target) operationName(op string) string {
+	return fmt.Sprintf("projects/%s/locations/%s/operations/%s", t.Project, t.Location, op)
+}
+
+func (c clusterClient) GetServerConfig(ctx context.Context, name string) (*container.ServerConfig, error) {
+	return c.svc.Projects.Locations.GetServerConfig(name).Context(ctx).Do()
+}
+
+func (c clusterClient) ListOperations(ctx context.Context, parent string) ([]*container.Operation, error) {
+	resp, err := c.svc.Projects.Locations.Operations.List(parent).Context(ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Operations, nil
+}
+
+func (c clusterClient) SetNodePoolSize(ctx context.Context, name string, req *container.SetNodePoolSizeRequest) (*container.Operation, error) {
+	return c.svc.Projects.Locations.Clusters.NodePools.SetSize(name, req).Context(ctx).Do()
+}
+
+func (c clusterClient) SetMaintenancePolicy(ctx context.Context, name string, req *container.SetMaintenancePolicyRequest) (*container.Operation, error) {
+	return c.svc.Projects.Locations.Clusters.SetMaintenancePolicy(name, req).Context(ctx).Do()
+}
@@ -0,0 +1,117 @@
+package gke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/container/v1"
+)
+
+// ErrNotFound matches, with errors.Is, the errors returned when a cluster
+// or project doesn't exist.
+var ErrNotFound = errors.New("not found")
+
+// notFoundError keeps its own message while matching ErrNotFound.
+type notFoundError struct {
+	msg string
+}
+
+func (e notFoundError) Error() string { return e.msg }
+
+func (e notFoundError) Is(target error) bool { return target == ErrNotFound }
+
+// MissingZonesError reports zones GKE couldn't list clusters in, usually
+// because of an outage. A listing that comes with it is incomplete: the
+// clusters in those zones may still exist.
+type MissingZonesError struct {
+	Zones []string
+}
+
+func (e *MissingZonesError) Error() string {
+	return fmt.Sprintf("zones %s are unavailable, so their clusters couldn't be listed", strings.Join(e.Zones, ", "))
+}
+
+// ListClusters returns the clusters of a project in every location.
+func ListClusters(ctx context.Context, api ClusterAPI, projectID string) ([]*container.Cluster, error) {
+	return ListClustersIn(ctx, api, projectID, nil)
+}
+
+// ListClustersIn returns the clusters of a project in the given regions or
+// zones, or in every location when none are given. Each location is asked
+// for on its own, so clusters elsewhere are never listed. When zones are
+// missing from the listing, the clusters found come with a
+// *MissingZonesError naming them all.
+func ListClustersIn(ctx context.Context, api ClusterAPI, projectID string, locations []string) ([]*container.Cluster, error) {
+	if len(locations) == 0 {
+		locations = []string{"-"}
+	}
+	var all []*container.Cluster
+	var missing []string
+	seen := make(map[string]bool)
+	for _, location := range locations {
+		clusters, err := api.ListClusters(ctx, fmt.Sprintf("projects/%s/locations/%s", projectID, location))
+		var zones *MissingZonesError
+		if errors.As(err, &zones) {
+			missing = append(missing, zones.Zones...)
+		} else if err != nil {
+			return nil, fmt.Errorf("failed to list clusters: %w", err)
+		}
+		for _, cluster := range clusters {
+			if key := cluster.Location + "/" + cluster.Name; !seen[key] {
+				seen[key] = true
+				all = append(all, cluster)
+			}
+		}
+	}
+	if len(missing) > 0 {
+		return all, fmt.Errorf("failed to list clusters: %w", &MissingZonesError{Zones: missing})
+	}
+	return all, nil
+}
+
+// FindCluster picks the cluster called name out of clusters. An empty
+// location matches every location, and an error is returned if the name is
+// then ambiguous.
+func FindCluster(clusters []*container.Cluster, projectID, location, name string) (*container.Cluster, error) {
+	var matches []*container.Cluster
+	for _, cluster := range clusters {
+		if cluster.Name == name && (location == "" || cluster.Location == location) {
+			matches = append(matches, cluster)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return nil, notFoundError{fmt.Sprintf("cluster %q not found in project %s", name, projectID)}
+	case 1:
+		return matches[0], nil
+	}
+
+	var locations []string
+	for _, cluster := range matches {
+		locations = append(locations, cluster.Location)
+	}
+	return nil, fmt.Errorf("cluster %q exists in several locations (%s); pass --location",
+		name, strings.Join(locations, ", "))
+}
+
+// RunningOperations returns the operations on the target cluster or its
+// node pools that haven't finished, such as an UPGRADE_MASTER. GKE runs one
+// operation on a cluster at a time, so any update waits for them.
+func RunningOperations(ctx context.Context, api ClusterAPI, target Target) ([]*container.Operation, error) {
+	ops, err := api.ListOperations(ctx, fmt.Sprintf("projects/%s/locations/%s", target.Project, target.Location))
+	if err != nil {
+		return nil, fmt.Errorf("failed to list operations: %w", err)
+	}
+	var running []*container.Operation
+	for _, op := range ops {
+		onCluster := strings.HasSuffix(op.TargetLink, "/clusters/"+target.Cluster) ||
+			strings.Contains(op.TargetLink, "/clusters/"+target.Cluster+"/")
+		if op.Status != "DONE" && onCluster {
+			running = append(running, op)
+		}
+	}
+	return running, nil
+}
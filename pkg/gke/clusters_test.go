@@ -0,0 +1,138 @@
+package gke
+
+import (
+	"context"
+	"errors"
+	"reflect"
+	"sort"
+	"testing"
+
+	"google.golang.org/api/container/v1"
+)
+
+func names(clusters []*container.Cluster) []string {
+	var names []string
+	for _, cluster := range clusters {
+		names = append(names, cluster.Location+"/"+cluster.Name)
+	}
+	return names
+}
+
+func TestListClustersIn(t *testing.T) {
+	api := &fakeClusters{clusters: map[string][]*container.Cluster{
+		"europe-west1":   {{Name: "prod", Location: "europe-west1"}},
+		"europe-west1-b": {{Name: "dev", Location: "europe-west1-b"}},
+		"us-central1":    {{Name: "prod", Location: "us-central1"}},
+	}}
+	tests := []struct {
+		name      string
+		locations []string
+		parents   []string
+		want      []string
+	}{
+		{
+			name:    "every location",
+			parents: []string{"projects/p/locations/-"},
+			want:    []string{"europe-west1-b/dev", "europe-west1/prod", "us-central1/prod"},
+		},
+		{
+			name:      "chosen locations only",
+			locations: []string{"europe-west1", "europe-west1-b"},
+			parents:   []string{"projects/p/locations/europe-west1", "projects/p/locations/europe-west1-b"},
+			want:      []string{"europe-west1-b/dev", "europe-west1/prod"},
+		},
+		{
+			name:      "a location named twice is listed once",
+			locations: []string{"us-central1", "us-central1"},
+			parents:   []string{"projects/p/locations/us-central1", "projects/p/locations/us-central1"},
+			want:      []string{"us-central1/prod"},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api.listed = nil
+			clusters, err := ListClustersIn(context.Background(), api, "p", tt.locations)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := names(clusters)
+			sort.Strings(got)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("clusters = %v, want %v", got, tt.want)
+			}
+			if !reflect.DeepEqual(api.listed, tt.parents) {
+				t.Errorf("listed %v, want %v", api.listed, tt.parents)
+			}
+		})
+	}
+}
+
+func TestListClustersInMissingZones(t *testing.T) {
+	api := &fakeClusters{
+		clusters: map[string][]*container.Cluster{
+			"europe-west1": {{Name: "prod", Location: "europe-west1"}},
+			"us-central1":  {{Name: "prod", Location: "us-central1"}},
+		},
+		missing: map[string][]string{"europe-west1": {"europe-west1-c"}, "us-central1": {"us-central1-a"}},
+	}
+	clusters, err := ListClustersIn(context.Background(), api, "p", []string{"europe-west1", "us-central1"})
+	var missing *MissingZonesError
+	if !errors.As(err, &missing) {
+		t.Fatalf("err = %v, want a MissingZonesError", err)
+	}
+	if want := []string{"europe-west1-c", "us-central1-a"}; !reflect.DeepEqual(missing.Zones, want) {
+		t.Errorf("missing zones = %v, want %v", missing.Zones, want)
+	}
+	if got := names(clusters); len(got) != 2 {
+		t.Errorf("clusters = %v, want the two listed", got)
+	}
+}
+
+func TestListClustersInError(t *testing.T) {
+	denied := errors.New("permission denied")
+	api := &fakeClusters{listErr: denied}
+	clusters, err := ListClustersIn(context.Background(), api, "p", nil)
+	if !errors.Is(err, denied) || clusters != nil {
+		t.Errorf("ListClustersIn = %v, %v; want nil, %v", clusters, err, denied)
+	}
+}
+
+func TestFindCluster(t *testing.T) {
+	clusters := []*container.Cluster{
+		{Name: "prod", Location: "europe-west1"},
+		{Name: "prod", Location: "us-central1"},
+		{Name: "dev", Location: "europe-west1-b"},
+	}
+	tests := []struct {
+		name, location, cluster string
+		want                    string
+		err                     string
+		notFound                bool
+	}{
+		{name: "unique name", cluster: "dev", want: "europe-west1-b/dev"},
+		{name: "location picks one", location: "us-central1", cluster: "prod", want: "us-central1/prod"},
+		{name: "ambiguous", cluster: "prod", err: `cluster "prod" exists in several locations (europe-west1, us-central1); pass --location`},
+		{name: "missing", cluster: "staging", err: `cluster "staging" not found in project p`, notFound: true},
+		{name: "wrong location", location: "us-central1", cluster: "dev", err: `cluster "dev" not found in project p`, notFound: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cluster, err := FindCluster(clusters, "p", tt.location, tt.cluster)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				if errors.Is(err, ErrNotFound) != tt.notFound {
+					t.Errorf("errors.Is(err, ErrNotFound) = %v, want %v", !tt.notFound, tt.notFound)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := cluster.Location + "/" + cluster.Name; got != tt.want {
+				t.Errorf("FindCluster = %s, want %s", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,271 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"google.golang.org/api/cloudbilling/v1"
+	"google.golang.org/api/container/v1"
+)
+
+// ComputeEngineService is the Cloud Billing Catalog name of Compute Engine,
+// whose SKUs price GKE nodes.
+const ComputeEngineService = "services/6F81-5844-456A"
+
+// ClusterFee is the hourly GKE cluster management fee, in USD.
+const ClusterFee = 0.10
+
+// HoursPerMonth is the number of hours Google Cloud bills a month for.
+const HoursPerMonth = 730
+
+// PricingAPI is the subset of the Cloud Billing Catalog API used to price
+// clusters.
+type PricingAPI interface {
+	ListSkus(ctx context.Context, service string) ([]*cloudbilling.Sku, error)
+}
+
+// NewPricingClient adapts a Cloud Billing v1 client to PricingAPI. Prices
+// are in USD.
+func NewPricingClient(svc *cloudbilling.APIService) PricingAPI {
+	return pricingClient{svc}
+}
+
+type pricingClient struct {
+	svc *cloudbilling.APIService
+}
+
+func (c pricingClient) ListSkus(ctx context.Context, service string) ([]*cloudbilling.Sku, error) {
+	var skus []*cloudbilling.Sku
+	err := c.svc.Services.Skus.List(service).CurrencyCode("USD").PageSize(5000).Pages(ctx, func(resp *cloudbilling.ListSkusResponse) error {
+		skus = append(skus, resp.Skus...)
+		return nil
+	})
+	return skus, err
+}
+
+// Prices are USD unit prices by region and resource, such as
+// "us-central1/e2-core" (per vCPU hour), "us-central1/e2-ram-spot" (per GiB
+// hour) or "us-central1/pd-balanced" (per GiB month).
+type Prices map[string]float64
+
+// instanceSKU matches the descriptions of Compute Engine vCPU and memory
+// SKUs, such as "N2D AMD Instance Core running in Americas" or "Spot
+// Preemptible E2 Instance Ram running in Belgium". N1 custom SKUs have no
+// family.
+var instanceSKU = regexp.MustCompile(`^(?:Spot Preemptible |Preemptible )?(?:([A-Z0-9]+) )?(?:AMD |Intel |Arm )?(Predefined |Custom )?Instance (Core|Ram) running in`)
+
+// diskSKUs are the description prefixes of persistent disk capacity SKUs.
+var diskSKUs = map[string]string{
+	"Storage PD Capacity":    "pd-standard",
+	"Balanced PD Capacity":   "pd-balanced",
+	"SSD backed PD Capacity": "pd-ssd",
+}
+
+// ListPrices fetches the Compute Engine prices needed to estimate what a
+// cluster's nodes cost. The catalog is large; callers should cache the
+// result.
+func ListPrices(ctx context.Context, api PricingAPI) (Prices, error) {
+	skus, err := api.ListSkus(ctx, ComputeEngineService)
+	if err != nil {
+		return nil, err
+	}
+	return NewPrices(skus), nil
+}
+
+// NewPrices picks the on-demand and spot vCPU and memory prices and the
+// persistent disk prices out of Compute Engine SKUs.
+func NewPrices(skus []*cloudbilling.Sku) Prices {
+	prices := make(Prices)
+	for _, sku := range skus {
+		if sku.Category == nil || len(sku.PricingInfo) == 0 {
+			continue
+		}
+		resource := skuResource(sku)
+		if resource == "" {
+			continue
+		}
+		price, ok := unitPrice(sku.PricingInfo[0])
+		if !ok {
+			continue
+		}
+		for _, region := range sku.ServiceRegions {
+			prices[region+"/"+resource] = price
+		}
+	}
+	return prices
+}
+
+// skuResource returns the resource sku prices, or "" when it isn't one
+// clusters are estimated from.
+func skuResource(sku *cloudbilling.Sku) string {
+	usage := sku.Category.UsageType
+	if usage != "OnDemand" && usage != "Preemptible" {
+		return ""
+	}
+	if m := instanceSKU.FindStringSubmatch(sku.Description); m != nil {
+		family := strings.ToLower(m[1])
+		if family == "" {
+			family = "n1"
+		}
+		resource := family
+		if m[2] == "Custom " {
+			resource += "-custom"
+		}
+		resource += "-" + strings.ToLower(m[3])
+		if usage == "Preemptible" {
+			resource += "-spot"
+		}
+		return resource
+	}
+	if usage != "OnDemand" {
+		return ""
+	}
+	for prefix, disk := range diskSKUs {
+		if strings.HasPrefix(sku.Description, prefix) {
+			return disk
+		}
+	}
+	return ""
+}
+
+// unitPrice returns the price of the highest usage tier of info, which is
+// what sustained use of a resource costs.
+func unitPrice(info *cloudbilling.PricingInfo) (float64, bool) {
+	if info.PricingExpression == nil || len(info.PricingExpression.TieredRates) == 0 {
+		return 0, false
+	}
+	rates := info.PricingExpression.TieredRates
+	money := rates[len(rates)-1].UnitPrice
+	if money == nil {
+		return 0, false
+	}
+	return float64(money.Units) + float64(money.Nanos)/1e9, true
+}
+
+// memoryPerCPU is the memory in GiB per vCPU of predefined machine classes;
+// "*" applies to families not listed.
+var memoryPerCPU = map[string]map[string]float64{
+	"standard": {"n1": 3.75, "*": 4},
+	"highmem":  {"n1": 6.5, "*": 8},
+	"highcpu":  {"n1": 0.9, "n2": 1, "n2d": 1, "e2": 1, "*": 2},
+}
+
+// sharedCore are the vCPUs billed and memory in GiB of E2 shared-core
+// machine types.
+var sharedCore = map[string][2]float64{
+	"e2-micro":  {0.25, 1},
+	"e2-small":  {0.5, 2},
+	"e2-medium": {1, 4},
+}
+
+// MachineShape returns the machine family, vCPUs and memory in GiB of a
+// predefined or custom Compute Engine machine type such as "e2-standard-4"
+// or "n2-custom-4-16384".
+func MachineShape(machineType string) (family string, cpus, memory float64, ok bool) {
+	if shape, ok := sharedCore[machineType]; ok {
+		return "e2", shape[0], shape[1], true
+	}
+	parts := strings.Split(machineType, "-")
+	if parts[0] == "custom" {
+		// N1 custom types have no family: custom-CPUS-MEMORY.
+		parts = append([]string{"n1"}, parts...)
+	}
+	if len(parts) < 3 {
+		return "", 0, 0, false
+	}
+	family = parts[0]
+	if parts[1] == "custom" {
+		if len(parts) < 4 {
+			return "", 0, 0, false
+		}
+		n, err1 := strconv.Atoi(parts[2])
+		mb, err2 := strconv.Atoi(parts[3])
+		if err1 != nil || err2 != nil {
+			return "", 0, 0, false
+		}
+		return family + "-custom", float64(n), float64(mb) / 1024, true
+	}
+	perCPU, ok := memoryPerCPU[parts[1]]
+	if !ok {
+		return "", 0, 0, false
+	}
+	n, err := strconv.Atoi(parts[2])
+	if err != nil {
+		return "", 0, 0, false
+	}
+	ratio, ok := perCPU[family]
+	if !ok {
+		ratio = perCPU["*"]
+	}
+	return family, float64(n), float64(n) * ratio, true
+}
+
+// Estimate is the estimated monthly cost of a cluster, in USD.
+type Estimate struct {
+	Monthly float64
+	// Unpriced names the node pools left out of Monthly because their
+	// machine or disk type has no known price.
+	Unpriced []string
+}
+
+// EstimateCost estimates what cluster costs a month at list prices: the
+// management fee plus, for each node pool at its configured size, the
+// vCPUs and memory of its machines, on demand or spot, and their boot
+// disks. GPUs, local SSDs, network traffic and discounts are left out.
+// Autopilot clusters are billed by pod and can't be estimated this way.
+func EstimateCost(cluster *container.Cluster, prices Prices) (Estimate, error) {
+	if cluster.Autopilot != nil && cluster.Autopilot.Enabled {
+		return Estimate{}, fmt.Errorf("%s is an Autopilot cluster, billed by the resources its pods request", cluster.Name)
+	}
+	region := cluster.Location
+	if parts := strings.Split(region, "-"); len(parts) == 3 {
+		region = parts[0] + "-" + parts[1]
+	}
+	estimate := Estimate{Monthly: ClusterFee * HoursPerMonth}
+	for _, pool := range cluster.NodePools {
+		monthly, ok := poolCost(pool, region, prices)
+		if !ok {
+			estimate.Unpriced = append(estimate.Unpriced, pool.Name)
+			continue
+		}
+		estimate.Monthly += monthly
+	}
+	sort.Strings(estimate.Unpriced)
+	return estimate, nil
+}
+
+// poolCost returns the monthly cost of pool's nodes in region.
+func poolCost(pool *container.NodePool, region string, prices Prices) (float64, bool) {
+	config := pool.Config
+	if config == nil {
+		return 0, false
+	}
+	family, cpus, memory, ok := MachineShape(config.MachineType)
+	if !ok {
+		return 0, false
+	}
+	suffix := ""
+	if config.Spot || config.Preemptible {
+		suffix = "-spot"
+	}
+	core, ok1 := prices[region+"/"+family+"-core"+suffix]
+	ram, ok2 := prices[region+"/"+family+"-ram"+suffix]
+	diskType := config.DiskType
+	if diskType == "" {
+		diskType = "pd-standard"
+	}
+	disk, ok3 := prices[region+"/"+diskType]
+	if !ok1 || !ok2 || (!ok3 && config.DiskSizeGb > 0) {
+		return 0, false
+	}
+	zones := len(pool.Locations)
+	if zones == 0 {
+		zones = 1
+	}
+	nodes := float64(pool.InitialNodeCount) * float64(zones)
+	return nodes * ((cpus*core+memory*ram)*HoursPerMonth + float64(config.DiskSizeGb)*disk), true
+}
@@ -0,0 +1,96 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+	"google.golang.org/api/container/v1"
+)
+
+// fakeClusters is a ClusterAPI serving clusters from memory. Updates are
+// recorded and finish at once.
+type fakeClusters struct {
+	// clusters are by location; "-" lists them all.
+	clusters map[string][]*container.Cluster
+	// live is what GetCluster returns.
+	live *container.Cluster
+	// missing are the zones listings report as unavailable, by location.
+	missing map[string][]string
+	// listErr fails listings.
+	listErr error
+	// running keeps operations from finishing.
+	running bool
+
+	listed  []string
+	updates []*container.UpdateClusterRequest
+}
+
+func (f *fakeClusters) ListClusters(ctx context.Context, parent string) ([]*container.Cluster, error) {
+	f.listed = append(f.listed, parent)
+	if f.listErr != nil {
+		return nil, f.listErr
+	}
+	var location string
+	if _, err := fmt.Sscanf(parent, "projects/p/locations/%s", &location); err != nil {
+		return nil, fmt.Errorf("unexpected parent %q", parent)
+	}
+	var clusters []*container.Cluster
+	for l, cs := range f.clusters {
+		if location == "-" || l == location {
+			clusters = append(clusters, cs...)
+		}
+	}
+	if zones := f.missing[location]; len(zones) > 0 {
+		return clusters, &MissingZonesError{Zones: zones}
+	}
+	return clusters, nil
+}
+
+func (f *fakeClusters) GetCluster(ctx context.Context, name string) (*container.Cluster, error) {
+	return f.live, nil
+}
+
+func (f *fakeClusters) UpdateCluster(ctx context.Context, name string, req *container.UpdateClusterRequest) (*container.Operation, error) {
+	f.updates = append(f.updates, req)
+	return &container.Operation{Name: "op-1"}, nil
+}
+
+func (f *fakeClusters) SetNodePoolSize(ctx context.Context, name string, req *container.SetNodePoolSizeRequest) (*container.Operation, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeClusters) SetMaintenancePolicy(ctx context.Context, name string, req *container.SetMaintenancePolicyRequest) (*container.Operation, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+func (f *fakeClusters) GetOperation(ctx context.Context, name string) (*container.Operation, error) {
+	if f.running {
+		return &container.Operation{Name: name, Status: "RUNNING"}, nil
+	}
+	return &container.Operation{Name: name, Status: "DONE"}, nil
+}
+
+func (f *fakeClusters) ListOperations(ctx context.Context, parent string) ([]*container.Operation, error) {
+	return nil, nil
+}
+
+func (f *fakeClusters) GetServerConfig(ctx context.Context, name string) (*container.ServerConfig, error) {
+	return nil, fmt.Errorf("not implemented")
+}
+
+// fakeProjects is a ProjectAPI serving projects from memory, ignoring the
+// query the way a broad search would.
+type fakeProjects struct {
+	projects []string
+	queries  []string
+}
+
+func (f *fakeProjects) SearchProjects(ctx context.Context, query string) ([]*resourcemanager.Project, error) {
+	f.queries = append(f.queries, query)
+	var projects []*resourcemanager.Project
+	for i, id := range f.projects {
+		projects = append(projects, &resourcemanager.Project{Name: fmt.Sprintf("projects/%d", 100+i), ProjectId: id, State: "ACTIVE"})
+	}
+	return projects, nil
+}
@@ -0,0 +1,148 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/gkehub/v1"
+)
+
+// FleetAPI is the subset of the GKE Hub v1 API used to list a fleet's
+// memberships. Parents are names such as "projects/p/locations/-".
+type FleetAPI interface {
+	ListMemberships(ctx context.Context, parent string) ([]*gkehub.Membership, error)
+}
+
+// NewFleetClient adapts a GKE Hub v1 client to FleetAPI.
+func NewFleetClient(svc *gkehub.Service) FleetAPI {
+	return fleetClient{svc}
+}
+
+type fleetClient struct {
+	svc *gkehub.Service
+}
+
+func (c fleetClient) ListMemberships(ctx context.Context, parent string) ([]*gkehub.Membership, error) {
+	var memberships []*gkehub.Membership
+	err := c.svc.Projects.Locations.Memberships.List(parent).Pages(ctx, func(resp *gkehub.ListMembershipsResponse) error {
+		memberships = append(memberships, resp.Resources...)
+		return nil
+	})
+	return memberships, err
+}
+
+// MemberKind is the kind of cluster behind a fleet membership.
+type MemberKind string
+
+const (
+	MemberGKE        MemberKind = "gke"
+	MemberMultiCloud MemberKind = "multi-cloud"
+	MemberOnPrem     MemberKind = "on-prem"
+	MemberEdge       MemberKind = "edge"
+	MemberAppliance  MemberKind = "appliance"
+	// MemberAttached is any other cluster registered with the Connect
+	// agent, such as EKS, AKS or a self-managed cluster.
+	MemberAttached MemberKind = "attached"
+)
+
+// Member is a cluster registered to a fleet.
+type Member struct {
+	Name     string
+	Location string
+	Kind     MemberKind
+	// State is the membership's state, such as "READY".
+	State string
+	// LastConnected is when the Connect agent last reached Google Cloud;
+	// zero for GKE clusters, which don't need the agent.
+	LastConnected time.Time
+	// Version is the cluster's Kubernetes version, when reported.
+	Version string
+	// Cluster is the GKE cluster behind a gke member, nil for other kinds.
+	Cluster *Target
+}
+
+// ListMembers returns the members of the fleet whose host project is
+// projectID, in every location, sorted by name.
+func ListMembers(ctx context.Context, api FleetAPI, projectID string) ([]Member, error) {
+	memberships, err := api.ListMemberships(ctx, "projects/"+projectID+"/locations/-")
+	if err != nil {
+		return nil, err
+	}
+	var members []Member
+	for _, m := range memberships {
+		members = append(members, newMember(m))
+	}
+	sort.Slice(members, func(i, j int) bool {
+		if members[i].Name != members[j].Name {
+			return members[i].Name < members[j].Name
+		}
+		return members[i].Location < members[j].Location
+	})
+	return members, nil
+}
+
+func newMember(m *gkehub.Membership) Member {
+	member := Member{Kind: MemberAttached}
+	// Names look like projects/p/locations/l/memberships/m.
+	parts := strings.Split(m.Name, "/")
+	if len(parts) == 6 {
+		member.Location, member.Name = parts[3], parts[5]
+	}
+	if m.State != nil {
+		member.State = m.State.Code
+	}
+	if t, err := time.Parse(time.RFC3339, m.LastConnectionTime); err == nil {
+		member.LastConnected = t
+	}
+
+	e := m.Endpoint
+	if e == nil {
+		return member
+	}
+	if e.KubernetesMetadata != nil {
+		member.Version = e.KubernetesMetadata.KubernetesApiServerVersion
+	}
+	switch {
+	case e.GkeCluster != nil:
+		member.Kind = MemberGKE
+		member.Cluster = clusterFromLink(e.GkeCluster.ResourceLink)
+	case e.MultiCloudCluster != nil:
+		member.Kind = MemberMultiCloud
+	case e.OnPremCluster != nil:
+		member.Kind = MemberOnPrem
+	case e.EdgeCluster != nil:
+		member.Kind = MemberEdge
+	case e.ApplianceCluster != nil:
+		member.Kind = MemberAppliance
+	}
+	return member
+}
+
+// clusterFromLink parses a GKE cluster link such as
+// "//container.googleapis.com/projects/p/locations/l/clusters/c", which
+// older memberships spell with zones instead of locations.
+func clusterFromLink(link string) *Target {
+	_, name, ok := strings.Cut(link, "projects/")
+	if !ok {
+		return nil
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) != 5 || (parts[1] != "locations" && parts[1] != "zones") || parts[3] != "clusters" {
+		return nil
+	}
+	return &Target{Project: parts[0], Location: parts[2], Cluster: parts[4]}
+}
+
+// GatewayServer returns the Connect gateway address of a membership of the
+// fleet hosted in the project numbered projectNumber. Memberships outside
+// the global location are served by that region's gateway.
+func GatewayServer(projectNumber string, member Member) string {
+	host := "connectgateway.googleapis.com"
+	if member.Location != "global" {
+		host = member.Location + "-" + host
+	}
+	return fmt.Sprintf("https://%s/v1/projects/%s/locations/%s/gkeMemberships/%s", host, projectNumber, member.Location, member.Name)
+}
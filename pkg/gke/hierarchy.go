@@ -0,0 +1,119 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	resourcemanager "google.golang.org/api/cloudresourcemanager/v3"
+)
+
+// HierarchyAPI is the subset of the Resource Manager v3 API used to browse
+// organizations and folders. Parents are resource names such as
+// "organizations/123" or "folders/456".
+type HierarchyAPI interface {
+	SearchOrganizations(ctx context.Context) ([]*resourcemanager.Organization, error)
+	ListFolders(ctx context.Context, parent string) ([]*resourcemanager.Folder, error)
+}
+
+// NewHierarchyClient adapts a Resource Manager v3 client to HierarchyAPI.
+func NewHierarchyClient(svc *resourcemanager.Service) HierarchyAPI {
+	return hierarchyClient{svc}
+}
+
+type hierarchyClient struct {
+	svc *resourcemanager.Service
+}
+
+func (c hierarchyClient) SearchOrganizations(ctx context.Context) ([]*resourcemanager.Organization, error) {
+	var orgs []*resourcemanager.Organization
+	err := c.svc.Organizations.Search().Pages(ctx, func(resp *resourcemanager.SearchOrganizationsResponse) error {
+		orgs = append(orgs, resp.Organizations...)
+		return nil
+	})
+	return orgs, err
+}
+
+func (c hierarchyClient) ListFolders(ctx context.Context, parent string) ([]*resourcemanager.Folder, error) {
+	var folders []*resourcemanager.Folder
+	err := c.svc.Folders.List().Parent(parent).Pages(ctx, func(resp *resourcemanager.ListFoldersResponse) error {
+		folders = append(folders, resp.Folders...)
+		return nil
+	})
+	return folders, err
+}
+
+// NodeKind is what a Node of the resource hierarchy is.
+type NodeKind string
+
+const (
+	NodeOrganization NodeKind = "organization"
+	NodeFolder       NodeKind = "folder"
+	NodeProject      NodeKind = "project"
+)
+
+// Node is an organization, folder or project in the resource hierarchy.
+type Node struct {
+	Kind NodeKind
+	// Name is the resource name, e.g. "folders/456", or the project ID.
+	Name        string
+	DisplayName string
+}
+
+// Children lists what is directly under parent: the organizations the
+// caller can see when parent is empty, else parent's active folders
+// followed by its active projects, each sorted by display name.
+func Children(ctx context.Context, hierarchy HierarchyAPI, projects ProjectAPI, parent string) ([]Node, error) {
+	if parent == "" {
+		orgs, err := hierarchy.SearchOrganizations(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list organizations: %w", err)
+		}
+		var nodes []Node
+		for _, org := range orgs {
+			if org.State == "ACTIVE" {
+				nodes = append(nodes, Node{Kind: NodeOrganization, Name: org.Name, DisplayName: org.DisplayName})
+			}
+		}
+		sortNodes(nodes)
+		return nodes, nil
+	}
+
+	kind, id, ok := strings.Cut(parent, "/")
+	if !ok || (kind != "organizations" && kind != "folders") {
+		return nil, fmt.Errorf("invalid parent %q", parent)
+	}
+	folders, err := hierarchy.ListFolders(ctx, parent)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list folders of %s: %w", parent, err)
+	}
+	var nodes []Node
+	for _, folder := range folders {
+		if folder.State == "ACTIVE" {
+			nodes = append(nodes, Node{Kind: NodeFolder, Name: folder.Name, DisplayName: folder.DisplayName})
+		}
+	}
+	sortNodes(nodes)
+
+	filter := ProjectFilter{Organization: id}
+	if kind == "folders" {
+		filter = ProjectFilter{Folder: id}
+	}
+	listed, err := ListProjectDetails(ctx, projects, filter)
+	if err != nil {
+		return nil, err
+	}
+	var projectNodes []Node
+	for _, project := range listed {
+		projectNodes = append(projectNodes, Node{Kind: NodeProject, Name: project.ID, DisplayName: project.Name})
+	}
+	sortNodes(projectNodes)
+	return append(nodes, projectNodes...), nil
+}
+
+func sortNodes(nodes []Node) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return strings.ToLower(nodes[i].DisplayName) < strings.ToLower(nodes[j].DisplayName)
+	})
+}
@@ -0,0 +1,108 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"google.golang.org/api/recommender/v1"
+)
+
+// InsightAPI is the subset of the Recommender v1 API used to find idle and
+// over-provisioned clusters. Parents are names such as
+// "projects/p/locations/l/insightTypes/t".
+type InsightAPI interface {
+	ListInsights(ctx context.Context, parent string) ([]*recommender.GoogleCloudRecommenderV1Insight, error)
+}
+
+// NewInsightClient adapts a Recommender v1 client to InsightAPI. Only
+// active insights are listed.
+func NewInsightClient(svc *recommender.Service) InsightAPI {
+	return insightClient{svc}
+}
+
+type insightClient struct {
+	svc *recommender.Service
+}
+
+func (c insightClient) ListInsights(ctx context.Context, parent string) ([]*recommender.GoogleCloudRecommenderV1Insight, error) {
+	var insights []*recommender.GoogleCloudRecommenderV1Insight
+	err := c.svc.Projects.Locations.InsightTypes.Insights.List(parent).Filter("stateInfo.state = ACTIVE").Pages(ctx, func(resp *recommender.GoogleCloudRecommenderV1ListInsightsResponse) error {
+		insights = append(insights, resp.Insights...)
+		return nil
+	})
+	return insights, err
+}
+
+// Insight is a Recommender insight about a GKE cluster or one of its node
+// pools, such as an idle cluster.
+type Insight struct {
+	Cluster  string
+	Location string
+	// NodePool is the node pool the insight is about, if any.
+	NodePool string
+	// Subtype is the kind of insight, such as "IDLE_CLUSTER".
+	Subtype     string
+	Description string
+}
+
+// Summary describes the insight in a few words, e.g. "idle cluster" or
+// "overprovisioned node pool (pool-1)".
+func (i Insight) Summary() string {
+	s := strings.ToLower(strings.ReplaceAll(i.Subtype, "_", " "))
+	if i.NodePool != "" {
+		s += " (" + i.NodePool + ")"
+	}
+	return s
+}
+
+// ListInsights returns the active insights of the given types about the
+// GKE clusters of projectID in location, by cluster and node pool.
+// Insights about other resources are left out.
+func ListInsights(ctx context.Context, api InsightAPI, projectID, location string, insightTypes []string) ([]Insight, error) {
+	var insights []Insight
+	for _, insightType := range insightTypes {
+		parent := fmt.Sprintf("projects/%s/locations/%s/insightTypes/%s", projectID, location, insightType)
+		found, err := api.ListInsights(ctx, parent)
+		if err != nil {
+			return nil, err
+		}
+		for _, in := range found {
+			for _, resource := range in.TargetResources {
+				insight, ok := clusterInsight(resource)
+				if !ok {
+					continue
+				}
+				insight.Subtype, insight.Description = in.InsightSubtype, in.Description
+				insights = append(insights, insight)
+			}
+		}
+	}
+	sort.SliceStable(insights, func(i, j int) bool {
+		if insights[i].Cluster != insights[j].Cluster {
+			return insights[i].Cluster < insights[j].Cluster
+		}
+		return insights[i].NodePool < insights[j].NodePool
+	})
+	return insights, nil
+}
+
+// clusterInsight parses the cluster and node pool out of an insight target
+// such as "//container.googleapis.com/projects/p/locations/l/clusters/c/nodePools/np".
+// The project may be given by number, so it is not returned.
+func clusterInsight(resource string) (Insight, bool) {
+	name, ok := strings.CutPrefix(resource, "//container.googleapis.com/projects/")
+	if !ok {
+		return Insight{}, false
+	}
+	parts := strings.Split(name, "/")
+	if len(parts) < 5 || (parts[1] != "locations" && parts[1] != "zones") || parts[3] != "clusters" {
+		return Insight{}, false
+	}
+	insight := Insight{Location: parts[2], Cluster: parts[4]}
+	if len(parts) == 7 && parts[5] == "nodePools" {
+		insight.NodePool = parts[6]
+	}
+	return insight, true
+}
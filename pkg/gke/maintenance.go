@@ -0,0 +1,159 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"google.golang.org/api/container/v1"
+)
+
+// Exclusion is a maintenance exclusion: a period in which GKE does not
+// upgrade the cluster.
+type Exclusion struct {
+	Name  string
+	Start time.Time
+	End   time.Time
+	// Scope is what the exclusion blocks: "NO_UPGRADES" (the default),
+	// "NO_MINOR_UPGRADES" or "NO_MINOR_OR_NODE_UPGRADES".
+	Scope string
+}
+
+// MaintenanceWindow describes when cluster may be maintained, e.g. "daily
+// at 03:00 UTC for 4h" or "Sat, Sun 02:00-06:00 UTC", or "" when GKE may
+// maintain it at any time.
+func MaintenanceWindow(cluster *container.Cluster) string {
+	if cluster.MaintenancePolicy == nil || cluster.MaintenancePolicy.Window == nil {
+		return ""
+	}
+	w := cluster.MaintenancePolicy.Window
+	if d := w.DailyMaintenanceWindow; d != nil && d.StartTime != "" {
+		window := "daily at " + d.StartTime + " UTC"
+		// The duration is in ISO 8601, e.g. "PT4H0M0S".
+		if duration, err := time.ParseDuration(strings.TrimPrefix(strings.ToLower(d.Duration), "pt")); err == nil {
+			window += " for " + strings.TrimSuffix(strings.TrimSuffix(duration.String(), "0s"), "0m")
+		}
+		return window
+	}
+	r := w.RecurringWindow
+	if r == nil || r.Window == nil {
+		return ""
+	}
+	start, err1 := time.Parse(time.RFC3339, r.Window.StartTime)
+	end, err2 := time.Parse(time.RFC3339, r.Window.EndTime)
+	if err1 != nil || err2 != nil {
+		return r.Recurrence
+	}
+	hours := start.UTC().Format("15:04") + "-" + end.UTC().Format("15:04") + " UTC"
+	return recurrenceDays(r.Recurrence) + " " + hours
+}
+
+// recurrenceDays renders the days an RFC 5545 recurrence rule such as
+// "FREQ=WEEKLY;BYDAY=SA,SU" repeats on, or the rule itself when it is more
+// involved than that.
+func recurrenceDays(rule string) string {
+	days := map[string]string{"MO": "Mon", "TU": "Tue", "WE": "Wed", "TH": "Thu", "FR": "Fri", "SA": "Sat", "SU": "Sun"}
+	switch {
+	case rule == "FREQ=DAILY":
+		return "daily"
+	case strings.HasPrefix(rule, "FREQ=WEEKLY;BYDAY="):
+		var names []string
+		for _, day := range strings.Split(strings.TrimPrefix(rule, "FREQ=WEEKLY;BYDAY="), ",") {
+			name, ok := days[day]
+			if !ok {
+				return rule
+			}
+			names = append(names, name)
+		}
+		return strings.Join(names, ", ")
+	}
+	return rule
+}
+
+// MaintenanceExclusions returns cluster's maintenance exclusions by start
+// time. Exclusions whose times can't be parsed are left out.
+func MaintenanceExclusions(cluster *container.Cluster) []Exclusion {
+	if cluster.MaintenancePolicy == nil || cluster.MaintenancePolicy.Window == nil {
+		return nil
+	}
+	var exclusions []Exclusion
+	for name, w := range cluster.MaintenancePolicy.Window.MaintenanceExclusions {
+		start, err1 := time.Parse(time.RFC3339, w.StartTime)
+		end, err2 := time.Parse(time.RFC3339, w.EndTime)
+		if err1 != nil || err2 != nil {
+			continue
+		}
+		scope := "NO_UPGRADES"
+		if w.MaintenanceExclusionOptions != nil && w.MaintenanceExclusionOptions.Scope != "" {
+			scope = w.MaintenanceExclusionOptions.Scope
+		}
+		exclusions = append(exclusions, Exclusion{Name: name, Start: start, End: end, Scope: scope})
+	}
+	sort.Slice(exclusions, func(i, j int) bool {
+		if !exclusions[i].Start.Equal(exclusions[j].Start) {
+			return exclusions[i].Start.Before(exclusions[j].Start)
+		}
+		return exclusions[i].Name < exclusions[j].Name
+	})
+	return exclusions
+}
+
+// AddMaintenanceExclusion adds exclusion to cluster's maintenance policy,
+// replacing any exclusion of the same name, and waits for the operation to
+// finish. The policy's resource version makes the update fail rather than
+// undo a concurrent change.
+func AddMaintenanceExclusion(ctx context.Context, api ClusterAPI, target Target, cluster *container.Cluster, exclusion Exclusion, onProgress func(Progress)) error {
+	policy := maintenancePolicy(cluster)
+	window := &container.TimeWindow{
+		StartTime: exclusion.Start.UTC().Format(time.RFC3339),
+		EndTime:   exclusion.End.UTC().Format(time.RFC3339),
+	}
+	if exclusion.Scope != "" {
+		window.MaintenanceExclusionOptions = &container.MaintenanceExclusionOptions{Scope: exclusion.Scope}
+	}
+	policy.Window.MaintenanceExclusions[exclusion.Name] = *window
+	return setMaintenancePolicy(ctx, api, target, policy, onProgress)
+}
+
+// RemoveMaintenanceExclusion removes the exclusion called name from
+// cluster's maintenance policy and waits for the operation to finish.
+func RemoveMaintenanceExclusion(ctx context.Context, api ClusterAPI, target Target, cluster *container.Cluster, name string, onProgress func(Progress)) error {
+	policy := maintenancePolicy(cluster)
+	if _, ok := policy.Window.MaintenanceExclusions[name]; !ok {
+		return notFoundError{fmt.Sprintf("%s has no maintenance exclusion %s", target.Cluster, name)}
+	}
+	delete(policy.Window.MaintenanceExclusions, name)
+	// An empty map would be left out and leave the exclusions unchanged.
+	policy.Window.ForceSendFields = append(policy.Window.ForceSendFields, "MaintenanceExclusions")
+	return setMaintenancePolicy(ctx, api, target, policy, onProgress)
+}
+
+// maintenancePolicy copies cluster's maintenance policy so that it can be
+// changed and sent back.
+func maintenancePolicy(cluster *container.Cluster) *container.MaintenancePolicy {
+	policy := &container.MaintenancePolicy{Window: &container.MaintenanceWindow{}}
+	if current := cluster.MaintenancePolicy; current != nil {
+		policy.ResourceVersion = current.ResourceVersion
+		if current.Window != nil {
+			window := *current.Window
+			policy.Window = &window
+		}
+	}
+	exclusions := make(map[string]container.TimeWindow)
+	for name, w := range policy.Window.MaintenanceExclusions {
+		exclusions[name] = w
+	}
+	policy.Window.MaintenanceExclusions = exclusions
+	return policy
+}
+
+func setMaintenancePolicy(ctx context.Context, api ClusterAPI, target Target, policy *container.MaintenancePolicy, onProgress func(Progress)) error {
+	req := &container.SetMaintenancePolicyRequest{MaintenancePolicy: policy}
+	op, err := api.SetMaintenancePolicy(ctx, target.Name(), req)
+	if err != nil {
+		return fmt.Errorf("failed to update the maintenance policy of %s: %w", target.Cluster, err)
+	}
+	return WaitForOperation(ctx, api, target, op, onProgress)
+}
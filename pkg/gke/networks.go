@@ -0,0 +1,236 @@
+package gke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+// OperationPollInterval is how often WaitForOperation checks an operation.
+var OperationPollInterval = 2 * time.Second
+
+// OperationTimeout is how long WaitForOperation waits for an operation to
+// finish before giving up with ErrOperationTimeout. The operation itself
+// keeps running.
+var OperationTimeout = 20 * time.Minute
+
+// ErrOperationTimeout is returned by WaitForOperation when an operation is
+// still running after OperationTimeout.
+var ErrOperationTimeout = errors.New("timed out waiting for operation")
+
+// ErrOperationFailed is returned by WaitForOperation when an operation
+// finished with an error.
+var ErrOperationFailed = errors.New("operation failed")
+
+// UnfinishedError is returned by SetAuthorizedNetworks when the update was
+// accepted but waiting for it failed, for example because it timed out or
+// was cancelled. The cluster may have the new list already, or get it once
+// Operation finishes.
+type UnfinishedError struct {
+	Operation *container.Operation
+	Err       error
+}
+
+func (e *UnfinishedError) Error() string { return e.Err.Error() }
+
+func (e *UnfinishedError) Unwrap() error { return e.Err }
+
+// HasAuthorizedNetworks reports whether the cluster restricts its control
+// plane to authorized networks.
+func HasAuthorizedNetworks(cluster *container.Cluster) bool {
+	return cluster.MasterAuthorizedNetworksConfig != nil &&
+		cluster.MasterAuthorizedNetworksConfig.Enabled
+}
+
+// AuthorizedBlocks returns the cluster's authorized networks.
+func AuthorizedBlocks(cluster *container.Cluster) []*container.CidrBlock {
+	if cluster.MasterAuthorizedNetworksConfig == nil {
+		return nil
+	}
+	return cluster.MasterAuthorizedNetworksConfig.CidrBlocks
+}
+
+// AuthorizedEntries returns the cluster's authorized networks as entries.
+func AuthorizedEntries(cluster *container.Cluster) []man.Entry {
+	return EntriesFromBlocks(AuthorizedBlocks(cluster))
+}
+
+func EntriesFromBlocks(blocks []*container.CidrBlock) []man.Entry {
+	entries := make([]man.Entry, 0, len(blocks))
+	for _, block := range blocks {
+		entries = append(entries, man.Entry{DisplayName: block.DisplayName, CIDR: block.CidrBlock})
+	}
+	return entries
+}
+
+func BlocksFromEntries(entries []man.Entry) []*container.CidrBlock {
+	blocks := make([]*container.CidrBlock, 0, len(entries))
+	for _, entry := range entries {
+		blocks = append(blocks, &container.CidrBlock{DisplayName: entry.DisplayName, CidrBlock: entry.CIDR})
+	}
+	return blocks
+}
+
+// ErrShrinkDeclined is returned by Reconcile when an update would drop
+// entries from the live list and the caller did not confirm it.
+var ErrShrinkDeclined = errors.New("update would remove authorized networks and was not confirmed")
+
+// Guards are consulted by Reconcile before it changes a cluster.
+type Guards struct {
+	// BeforeApply is called with the freshly read cluster; an error aborts
+	// the update.
+	BeforeApply func(live *container.Cluster) error
+
+	// ConfirmShrink is asked with the live entries an update would drop.
+	// Nil refuses such updates.
+	ConfirmShrink func(dropped []man.Entry) bool
+}
+
+// Reconcile makes sure entry is on the cluster's authorized networks,
+// replacing an entry with the same display name, and returns the plan that
+// was applied. Further entries with that name are handled as duplicates
+// says. Nothing is changed if the entry is already there.
+//
+// The first plan is computed from cluster, which may be stale, and only
+// decides whether there is anything to do. Before applying, the live list
+// is read again and planned anew, so entries others added or removed
+// meanwhile stay that way. If the update would leave out any live entry,
+// such as a duplicate being consolidated, guards decide whether to go
+// ahead.
+func Reconcile(ctx context.Context, api ClusterAPI, target Target, cluster *container.Cluster, entry man.Entry, duplicates man.DuplicatePolicy, guards Guards, onProgress func(Progress)) (*man.Plan, error) {
+	planner := man.NewPlanner(man.Policy{Duplicates: duplicates})
+	plan := planner.Plan(AuthorizedEntries(cluster), []man.Entry{entry})
+	if err := checkLimit(plan); err != nil {
+		return plan, err
+	}
+	if plan.Empty() {
+		return plan, nil
+	}
+
+	live, err := api.GetCluster(ctx, target.Name())
+	if err != nil {
+		return plan, fmt.Errorf("failed to re-read cluster: %w", err)
+	}
+	plan = planner.Plan(AuthorizedEntries(live), []man.Entry{entry})
+	if err := checkLimit(plan); err != nil {
+		return plan, err
+	}
+	if plan.Empty() {
+		return plan, nil
+	}
+	if guards.BeforeApply != nil {
+		if err := guards.BeforeApply(live); err != nil {
+			return plan, err
+		}
+	}
+	if dropped := Dropped(AuthorizedEntries(live), plan); len(dropped) > 0 {
+		if guards.ConfirmShrink == nil || !guards.ConfirmShrink(dropped) {
+			return plan, ErrShrinkDeclined
+		}
+	}
+	return plan, SetAuthorizedNetworks(ctx, api, target, live, plan.Result, onProgress)
+}
+
+func checkLimit(plan *man.Plan) error {
+	if plan.Limit.Exceeded {
+		return fmt.Errorf("cannot add your IP: cluster already has %d of %d authorized networks",
+			plan.Limit.Before, plan.Limit.Max)
+	}
+	return nil
+}
+
+// Dropped returns the live entries missing from the plan's result, not
+// counting the old side of updates, which are replaced rather than lost.
+// Removals the plan intends, such as consolidated duplicates, are dropped
+// entries too: they shrink the list all the same.
+func Dropped(live []man.Entry, plan *man.Plan) []man.Entry {
+	remaining := make(map[man.Entry]int)
+	for _, entry := range plan.Result {
+		remaining[entry]++
+	}
+	for _, update := range plan.Updates {
+		remaining[update.Old]++
+	}
+
+	var dropped []man.Entry
+	for _, entry := range live {
+		if remaining[entry] > 0 {
+			remaining[entry]--
+			continue
+		}
+		dropped = append(dropped, entry)
+	}
+	return dropped
+}
+
+// SetAuthorizedNetworks replaces the cluster's authorized networks with
+// entries and waits for the resulting operation. If the update was
+// accepted but didn't finish, the error is an *UnfinishedError.
+func SetAuthorizedNetworks(ctx context.Context, api ClusterAPI, target Target, cluster *container.Cluster, entries []man.Entry, onProgress func(Progress)) error {
+	desired := &container.MasterAuthorizedNetworksConfig{
+		Enabled:    true,
+		CidrBlocks: BlocksFromEntries(entries),
+	}
+	if cluster.MasterAuthorizedNetworksConfig != nil {
+		desired.GcpPublicCidrsAccessEnabled = cluster.MasterAuthorizedNetworksConfig.GcpPublicCidrsAccessEnabled
+	}
+
+	req := &container.UpdateClusterRequest{
+		Update: &container.ClusterUpdate{
+			DesiredMasterAuthorizedNetworksConfig: desired,
+		},
+	}
+
+	op, err := api.UpdateCluster(ctx, target.Name(), req)
+	if err != nil {
+		return fmt.Errorf("failed to update authorized networks: %w", err)
+	}
+	if err := WaitForOperation(ctx, api, target, op, onProgress); err != nil {
+		if errors.Is(err, ErrOperationFailed) {
+			return err
+		}
+		return &UnfinishedError{Operation: op, Err: err}
+	}
+	return nil
+}
+
+// WaitForOperation polls op until it is DONE, reporting progress parsed from
+// the operation metadata to onProgress (which may be nil) on every tick.
+func WaitForOperation(ctx context.Context, api ClusterAPI, target Target, op *container.Operation, onProgress func(Progress)) error {
+	name := target.operationName(op.Name)
+
+	ticker := time.NewTicker(OperationPollInterval)
+	defer ticker.Stop()
+	timeout := time.NewTimer(OperationTimeout)
+	defer timeout.Stop()
+
+	for {
+		result, err := api.GetOperation(ctx, name)
+		if err != nil {
+			return fmt.Errorf("failed to get operation status: %w", err)
+		}
+
+		if onProgress != nil {
+			onProgress(ParseOperationProgress(result))
+		}
+
+		if result.Status == "DONE" {
+			if result.Error != nil {
+				return fmt.Errorf("%w: %v", ErrOperationFailed, result.Error.Message)
+			}
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeout.C:
+			return fmt.Errorf("%w %s after %s", ErrOperationTimeout, op.Name, OperationTimeout)
+		case <-ticker.C:
+		}
+	}
+}
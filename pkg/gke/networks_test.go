@@ -0,0 +1,225 @@
+package gke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"reflect"
+	"testing"
+	"time"
+
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+var (
+	me       = man.Entry{DisplayName: "me", CIDR: "203.0.113.1/32"}
+	meMoved  = man.Entry{DisplayName: "me", CIDR: "203.0.113.2/32"}
+	meStale  = man.Entry{DisplayName: "me", CIDR: "203.0.113.3/32"}
+	office   = man.Entry{DisplayName: "office", CIDR: "192.0.2.0/24"}
+	newcomer = man.Entry{DisplayName: "newcomer", CIDR: "198.51.100.0/24"}
+)
+
+func withNetworks(entries ...man.Entry) *container.Cluster {
+	return &container.Cluster{
+		Name: "c",
+		MasterAuthorizedNetworksConfig: &container.MasterAuthorizedNetworksConfig{
+			Enabled:    true,
+			CidrBlocks: BlocksFromEntries(entries),
+		},
+	}
+}
+
+func TestReconcile(t *testing.T) {
+	OperationPollInterval = time.Millisecond
+	target := Target{Project: "p", Location: "l", Cluster: "c"}
+	tests := []struct {
+		name       string
+		cached     *container.Cluster
+		live       *container.Cluster
+		duplicates man.DuplicatePolicy
+		confirm    bool
+		// asked are the dropped entries ConfirmShrink is asked about.
+		asked []man.Entry
+		// applied is the list written, nil when nothing is.
+		applied []man.Entry
+		err     error
+	}{
+		{
+			name:    "adds the entry",
+			cached:  withNetworks(office),
+			live:    withNetworks(office),
+			applied: []man.Entry{office, me},
+		},
+		{
+			name:    "moves the entry",
+			cached:  withNetworks(meStale, office),
+			live:    withNetworks(meStale, office),
+			applied: []man.Entry{me, office},
+		},
+		{
+			name:   "already there",
+			cached: withNetworks(office, me),
+			live:   withNetworks(office, me),
+		},
+		{
+			name:       "consolidates duplicates when confirmed",
+			cached:     withNetworks(meStale, office, meMoved),
+			live:       withNetworks(meStale, office, meMoved),
+			duplicates: man.DuplicatesConsolidate,
+			confirm:    true,
+			asked:      []man.Entry{meMoved},
+			applied:    []man.Entry{me, office},
+		},
+		{
+			name:       "declines consolidating duplicates",
+			cached:     withNetworks(meStale, office, meMoved),
+			live:       withNetworks(meStale, office, meMoved),
+			duplicates: man.DuplicatesConsolidate,
+			asked:      []man.Entry{meMoved},
+			err:        ErrShrinkDeclined,
+		},
+		{
+			name:    "keeps duplicates by default",
+			cached:  withNetworks(meStale, office, meMoved),
+			live:    withNetworks(meStale, office, meMoved),
+			applied: []man.Entry{me, office, meMoved},
+		},
+		{
+			name:    "keeps an entry added meanwhile",
+			cached:  withNetworks(office),
+			live:    withNetworks(office, newcomer),
+			applied: []man.Entry{office, newcomer, me},
+		},
+		{
+			name:    "doesn't bring back an entry removed meanwhile",
+			cached:  withNetworks(office, newcomer),
+			live:    withNetworks(office),
+			applied: []man.Entry{office, me},
+		},
+		{
+			name:   "your entry added meanwhile",
+			cached: withNetworks(office),
+			live:   withNetworks(office, me),
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			api := &fakeClusters{live: tt.live}
+			var asked []man.Entry
+			guards := Guards{ConfirmShrink: func(dropped []man.Entry) bool {
+				asked = dropped
+				return tt.confirm
+			}}
+			_, err := Reconcile(context.Background(), api, target, tt.cached, me, tt.duplicates, guards, nil)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			if !reflect.DeepEqual(asked, tt.asked) {
+				t.Errorf("asked to drop %v, want %v", asked, tt.asked)
+			}
+			if tt.applied == nil {
+				if len(api.updates) > 0 {
+					t.Errorf("cluster updated, want no update")
+				}
+				return
+			}
+			if len(api.updates) != 1 {
+				t.Fatalf("%d updates, want 1", len(api.updates))
+			}
+			got := EntriesFromBlocks(api.updates[0].Update.DesiredMasterAuthorizedNetworksConfig.CidrBlocks)
+			if !reflect.DeepEqual(got, tt.applied) {
+				t.Errorf("applied %v, want %v", got, tt.applied)
+			}
+		})
+	}
+}
+
+func TestReconcileBeforeApply(t *testing.T) {
+	refused := errors.New("change freeze")
+	api := &fakeClusters{live: withNetworks(office)}
+	guards := Guards{BeforeApply: func(live *container.Cluster) error { return refused }}
+	_, err := Reconcile(context.Background(), api, Target{Project: "p", Location: "l", Cluster: "c"}, withNetworks(office), me, "", guards, nil)
+	if !errors.Is(err, refused) {
+		t.Errorf("err = %v, want %v", err, refused)
+	}
+	if len(api.updates) > 0 {
+		t.Error("cluster updated despite BeforeApply refusing")
+	}
+}
+
+func TestReconcileLimit(t *testing.T) {
+	var full []man.Entry
+	for i := 0; i < man.DefaultMaxEntries; i++ {
+		full = append(full, man.Entry{DisplayName: fmt.Sprintf("user-%d", i), CIDR: fmt.Sprintf("10.0.%d.0/24", i)})
+	}
+	api := &fakeClusters{live: withNetworks(full...)}
+	_, err := Reconcile(context.Background(), api, Target{}, withNetworks(full...), me, "", Guards{}, nil)
+	if err == nil || len(api.updates) > 0 {
+		t.Errorf("Reconcile = %v with %d updates, want the limit refused", err, len(api.updates))
+	}
+}
+
+func TestSetAuthorizedNetworksUnfinished(t *testing.T) {
+	OperationPollInterval = time.Millisecond
+	api := &fakeClusters{running: true}
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	err := SetAuthorizedNetworks(ctx, api, Target{Project: "p", Location: "l", Cluster: "c"}, withNetworks(office), []man.Entry{office, me}, nil)
+	var unfinished *UnfinishedError
+	if !errors.As(err, &unfinished) || unfinished.Operation.Name != "op-1" {
+		t.Fatalf("err = %v, want an UnfinishedError for op-1", err)
+	}
+	if !errors.Is(err, context.Canceled) {
+		t.Errorf("err = %v, want it to match context.Canceled", err)
+	}
+}
+
+func TestDropped(t *testing.T) {
+	tests := []struct {
+		name    string
+		current []man.Entry
+		live    []man.Entry
+		policy  man.Policy
+		want    []man.Entry
+	}{
+		{
+			name:    "nothing changed meanwhile",
+			current: []man.Entry{office, meStale},
+			live:    []man.Entry{office, meStale},
+		},
+		{
+			name:    "entry added meanwhile",
+			current: []man.Entry{office},
+			live:    []man.Entry{office, newcomer},
+			want:    []man.Entry{newcomer},
+		},
+		{
+			name:    "consolidated duplicates are dropped",
+			current: []man.Entry{office, meStale, meMoved},
+			live:    []man.Entry{office, meStale, meMoved},
+			policy:  man.Policy{Duplicates: man.DuplicatesConsolidate},
+			want:    []man.Entry{meMoved},
+		},
+		{
+			name:    "entry changed meanwhile",
+			current: []man.Entry{office},
+			live:    []man.Entry{{DisplayName: "office", CIDR: "192.0.2.0/25"}},
+			want:    []man.Entry{{DisplayName: "office", CIDR: "192.0.2.0/25"}},
+		},
+		{
+			name:    "second copy added meanwhile",
+			current: []man.Entry{office},
+			live:    []man.Entry{office, office},
+			want:    []man.Entry{office},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := man.NewPlanner(tt.policy).Plan(tt.current, []man.Entry{me})
+			if got := Dropped(tt.live, plan); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Dropped = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,82 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+)
+
+// InstanceGroupAPI is the subset of the Compute Engine API used to read how
+// many nodes a node pool has. GKE itself only reports the size a pool was
+// created with.
+type InstanceGroupAPI interface {
+	// GetInstanceGroupManager returns the managed instance group called
+	// name in a project's zone.
+	GetInstanceGroupManager(ctx context.Context, project, zone, name string) (*compute.InstanceGroupManager, error)
+}
+
+// NewInstanceGroupClient adapts a Compute Engine client to
+// InstanceGroupAPI.
+func NewInstanceGroupClient(svc *compute.Service) InstanceGroupAPI {
+	return instanceGroupClient{svc}
+}
+
+type instanceGroupClient struct {
+	svc *compute.Service
+}
+
+func (c instanceGroupClient) GetInstanceGroupManager(ctx context.Context, project, zone, name string) (*compute.InstanceGroupManager, error) {
+	return c.svc.InstanceGroupManagers.Get(project, zone, name).Context(ctx).Do()
+}
+
+// NodePoolSize returns the number of nodes per zone pool is scaled to now,
+// as opposed to pool.InitialNodeCount, which resizes don't change. It is
+// the largest target size of the pool's instance groups, so scaling back
+// to it never leaves a zone short.
+func NodePoolSize(ctx context.Context, api InstanceGroupAPI, pool *container.NodePool) (int64, error) {
+	var size int64
+	for _, url := range pool.InstanceGroupUrls {
+		project, zone, name, err := parseInstanceGroupURL(url)
+		if err != nil {
+			return 0, err
+		}
+		group, err := api.GetInstanceGroupManager(ctx, project, zone, name)
+		if err != nil {
+			return 0, fmt.Errorf("failed to read the size of node pool %s: %w", pool.Name, err)
+		}
+		if group.TargetSize > size {
+			size = group.TargetSize
+		}
+	}
+	return size, nil
+}
+
+// parseInstanceGroupURL splits a URL such as
+// "https://www.googleapis.com/compute/v1/projects/p/zones/z/instanceGroupManagers/g".
+func parseInstanceGroupURL(url string) (project, zone, name string, err error) {
+	parts := strings.Split(url, "/")
+	for i := 0; i+5 < len(parts); i++ {
+		if parts[i] == "projects" && parts[i+2] == "zones" && parts[i+4] == "instanceGroupManagers" {
+			return parts[i+1], parts[i+3], parts[i+5], nil
+		}
+	}
+	return "", "", "", fmt.Errorf("unexpected instance group URL %q", url)
+}
+
+// ResizeNodePool sets the node count per zone of the target cluster's node
+// pool and waits for the operation to finish.
+func ResizeNodePool(ctx context.Context, api ClusterAPI, target Target, pool string, size int64, onProgress func(Progress)) error {
+	req := &container.SetNodePoolSizeRequest{
+		NodeCount: size,
+		// Zero is a valid size, and would otherwise be left out.
+		ForceSendFields: []string{"NodeCount"},
+	}
+	op, err := api.SetNodePoolSize(ctx, target.Name()+"/nodePools/"+pool, req)
+	if err != nil {
+		return fmt.Errorf("failed to resize node pool %s: %w", pool, err)
+	}
+	return WaitForOperation(ctx, api, target, op, onProgress)
+}
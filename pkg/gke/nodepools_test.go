@@ -0,0 +1,52 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"testing"
+
+	"google.golang.org/api/compute/v1"
+	"google.golang.org/api/container/v1"
+)
+
+// fakeInstanceGroups is an InstanceGroupAPI serving target sizes by
+// "project/zone/name".
+type fakeInstanceGroups map[string]int64
+
+func (f fakeInstanceGroups) GetInstanceGroupManager(ctx context.Context, project, zone, name string) (*compute.InstanceGroupManager, error) {
+	size, ok := f[project+"/"+zone+"/"+name]
+	if !ok {
+		return nil, fmt.Errorf("no instance group %s/%s/%s", project, zone, name)
+	}
+	return &compute.InstanceGroupManager{Name: name, TargetSize: size}, nil
+}
+
+func groupURL(zone, name string) string {
+	return "https://www.googleapis.com/compute/v1/projects/p/zones/" + zone + "/instanceGroupManagers/" + name
+}
+
+func TestNodePoolSize(t *testing.T) {
+	api := fakeInstanceGroups{"p/a/grp-a": 3, "p/b/grp-b": 5, "p/c/grp-c": 0}
+	tests := []struct {
+		name string
+		urls []string
+		want int64
+		err  bool
+	}{
+		{name: "resized since creation", urls: []string{groupURL("a", "grp-a")}, want: 3},
+		{name: "largest zone", urls: []string{groupURL("a", "grp-a"), groupURL("b", "grp-b")}, want: 5},
+		{name: "scaled to zero", urls: []string{groupURL("c", "grp-c")}, want: 0},
+		{name: "no instance groups yet"},
+		{name: "unreadable group", urls: []string{groupURL("d", "grp-d")}, err: true},
+		{name: "unexpected URL", urls: []string{"grp-a"}, err: true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			pool := &container.NodePool{Name: "pool", InitialNodeCount: 1, InstanceGroupUrls: tt.urls}
+			got, err := NodePoolSize(context.Background(), api, pool)
+			if (err != nil) != tt.err || got != tt.want {
+				t.Errorf("NodePoolSize = %d, %v; want %d, error %v", got, err, tt.want, tt.err)
+			}
+		})
+	}
+}
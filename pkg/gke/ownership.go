@@ -0,0 +1,73 @@
+package gke
+
+import (
+	"sort"
+	"strings"
+
+	"google.golang.org/api/container/v1"
+)
+
+// Ownership describes protections on a cluster and who else manages it.
+type Ownership struct {
+	DeletionProtection bool
+	// FleetMembership is the fleet membership the cluster is registered
+	// with, whose fleet-wide features may reconcile its configuration.
+	FleetMembership string
+	// ManagedBy lists the tools that provisioned or reconcile the cluster.
+	ManagedBy []string
+}
+
+// managedByLabels maps resource labels set by provisioning tools to the
+// tool's name.
+var managedByLabels = map[string]string{
+	"goog-terraform-provisioned": "Terraform",
+	"goog-pulumi-provisioned":    "Pulumi",
+	"managed-by-cnrm":            "Config Connector",
+}
+
+// DetectOwnership reads ownership from the cluster's fleet registration and
+// resource labels. DeletionProtection is not part of the cluster resource
+// the API client knows about and is left for the caller to fill in.
+func DetectOwnership(cluster *container.Cluster) Ownership {
+	var o Ownership
+	if cluster.Fleet != nil {
+		o.FleetMembership = cluster.Fleet.Membership
+	}
+
+	tools := make(map[string]bool)
+	for key, value := range cluster.ResourceLabels {
+		if tool, ok := managedByLabels[key]; ok && value != "false" {
+			tools[tool] = true
+		}
+		// The conventional "managed-by" label names the tool itself,
+		// e.g. managed-by=config-sync.
+		if key == "managed-by" || key == "managed_by" {
+			tools[value] = true
+		}
+	}
+	for tool := range tools {
+		o.ManagedBy = append(o.ManagedBy, tool)
+	}
+	sort.Strings(o.ManagedBy)
+	return o
+}
+
+// External reports whether something other than the user manages the
+// cluster, so direct changes may be reverted or cause drift.
+func (o Ownership) External() bool {
+	return len(o.ManagedBy) > 0 || o.FleetMembership != ""
+}
+
+func (o Ownership) String() string {
+	var parts []string
+	if o.DeletionProtection {
+		parts = append(parts, "deletion protection on")
+	}
+	if o.FleetMembership != "" {
+		parts = append(parts, "fleet member "+o.FleetMembership[strings.LastIndex(o.FleetMembership, "/")+1:])
+	}
+	if len(o.ManagedBy) > 0 {
+		parts = append(parts, "managed by "+strings.Join(o.ManagedBy, ", "))
+	}
+	return strings.Join(parts, ", ")
+}
@@ -0,0 +1,102 @@
+package gke
+
+import (
+	"strings"
+
+	"google.golang.org/api/container/v1"
+)
+
+// Progress is a snapshot of a long-running GKE operation as reported by
+// the operation's progress metadata.
+type Progress struct {
+	Percent float64 // 0..1, only meaningful when Known is true
+	Known   bool
+	Stage   string
+	Status  string
+}
+
+// ParseOperationProgress extracts a completion percentage and the current
+// stage name from an operation. GKE reports progress either as
+// "<x> done"/"<x> total" metric pairs or as "progress"/"progress scale",
+// optionally split into stages.
+func ParseOperationProgress(op *container.Operation) Progress {
+	p := Progress{Status: op.Status}
+	if op.Status == "DONE" {
+		p.Percent, p.Known = 1, true
+		return p
+	}
+	if op.Progress == nil {
+		return p
+	}
+
+	if pct, ok := metricsPercent(op.Progress.Metrics); ok {
+		p.Percent, p.Known = pct, true
+	}
+
+	stages := op.Progress.Stages
+	if len(stages) == 0 {
+		p.Stage = op.Progress.Name
+		return p
+	}
+
+	done := 0.0
+	for _, stage := range stages {
+		switch stage.Status {
+		case "DONE":
+			done++
+		case "RUNNING", "ABORTING":
+			if p.Stage == "" {
+				p.Stage = stage.Name
+			}
+			if pct, ok := metricsPercent(stage.Metrics); ok {
+				done += pct
+			}
+		}
+	}
+	if !p.Known {
+		p.Percent, p.Known = done/float64(len(stages)), true
+	}
+	if p.Stage == "" {
+		p.Stage = op.Progress.Name
+	}
+	return p
+}
+
+func metricsPercent(metrics []*container.Metric) (float64, bool) {
+	values := make(map[string]float64, len(metrics))
+	for _, m := range metrics {
+		v := m.DoubleValue
+		if m.IntValue != 0 {
+			v = float64(m.IntValue)
+		}
+		values[strings.ToLower(m.Name)] = v
+	}
+
+	if v, ok := values["progress"]; ok {
+		scale := values["progress scale"]
+		if scale == 0 {
+			scale = 1
+		}
+		return clampPercent(v / scale), true
+	}
+
+	for name, total := range values {
+		if !strings.HasSuffix(name, " total") || total == 0 {
+			continue
+		}
+		if done, ok := values[strings.TrimSuffix(name, " total")+" done"]; ok {
+			return clampPercent(done / total), true
+		}
+	}
+	return 0, false
+}
+
+func clampPercent(v float64) float64 {
+	if v < 0 {
+		return 0
+	}
+	if v > 1 {
+		return 1
+	}
+	return v
+}
@@ -0,0 +1,192 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// ProjectFilter narrows a project listing. The zero value lists every
+// project.
+type ProjectFilter struct {
+	// Labels are required project labels; an empty value only requires
+	// the label to be set.
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// Folder or Organization is the numeric ID of the projects' direct
+	// parent; Folder wins when both are set.
+	Folder       string `json:"folder,omitempty"`
+	Organization string `json:"organization,omitempty"`
+
+	// Include and Exclude are patterns matched against project IDs after
+	// listing: globs such as "payments-*", or regular expressions between
+	// slashes such as "/^tf-.*-sandbox$/". With Include set, only matching
+	// projects are listed; projects matching Exclude never are.
+	Include []string `json:"include,omitempty"`
+	Exclude []string `json:"exclude,omitempty"`
+}
+
+// allows reports whether the project id passes Include and Exclude.
+func (f ProjectFilter) allows(id string) (bool, error) {
+	if len(f.Include) > 0 {
+		included, err := matchAny(f.Include, id)
+		if err != nil || !included {
+			return false, err
+		}
+	}
+	excluded, err := matchAny(f.Exclude, id)
+	return !excluded, err
+}
+
+func matchAny(patterns []string, id string) (bool, error) {
+	for _, pattern := range patterns {
+		var matched bool
+		var err error
+		if len(pattern) > 1 && strings.HasPrefix(pattern, "/") && strings.HasSuffix(pattern, "/") {
+			var re *regexp.Regexp
+			if re, err = regexp.Compile(pattern[1 : len(pattern)-1]); err == nil {
+				matched = re.MatchString(id)
+			}
+		} else {
+			matched, err = path.Match(pattern, id)
+		}
+		if err != nil {
+			return false, fmt.Errorf("invalid project pattern %q: %v", pattern, err)
+		}
+		if matched {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// String renders the filter as a Resource Manager v3 search query for
+// active projects.
+func (f ProjectFilter) String() string {
+	terms := []string{"state:ACTIVE"}
+	var keys []string
+	for key := range f.Labels {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		value := f.Labels[key]
+		if value == "" {
+			value = "*"
+		}
+		terms = append(terms, "labels."+key+":"+value)
+	}
+
+	switch {
+	case f.Folder != "":
+		terms = append(terms, "parent.type:folder", "parent.id:"+f.Folder)
+	case f.Organization != "":
+		terms = append(terms, "parent.type:organization", "parent.id:"+f.Organization)
+	}
+	return strings.Join(terms, " ")
+}
+
+// Project is an active project as listed.
+type Project struct {
+	ID string
+	// Name is the human-readable display name, which may equal ID.
+	Name   string
+	Labels map[string]string
+}
+
+// ListProjects returns the IDs of the active projects matching filter.
+func ListProjects(ctx context.Context, api ProjectAPI, filter ProjectFilter) ([]string, error) {
+	projects, err := ListProjectDetails(ctx, api, filter)
+	if err != nil {
+		return nil, err
+	}
+	var ids []string
+	for _, project := range projects {
+		ids = append(ids, project.ID)
+	}
+	return ids, nil
+}
+
+// ListProjectDetails is ListProjects with the projects' names and labels.
+func ListProjectDetails(ctx context.Context, api ProjectAPI, filter ProjectFilter) ([]Project, error) {
+	projects, err := api.SearchProjects(ctx, filter.String())
+	if err != nil {
+		return nil, fmt.Errorf("failed to list projects: %w", err)
+	}
+
+	var listed []Project
+	for _, project := range projects {
+		if project.State != "ACTIVE" {
+			continue
+		}
+		ok, err := filter.allows(project.ProjectId)
+		if err != nil {
+			return nil, err
+		}
+		if ok {
+			listed = append(listed, Project{ID: project.ProjectId, Name: project.DisplayName, Labels: project.Labels})
+		}
+	}
+	return listed, nil
+}
+
+// ResolveProject expands a glob such as "payments-*" into exactly one
+// active project ID. Patterns without wildcards are returned unchanged.
+func ResolveProject(ctx context.Context, api ProjectAPI, pattern string) (string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern, nil
+	}
+	if _, err := path.Match(pattern, ""); err != nil {
+		return "", fmt.Errorf("invalid project pattern %q: %v", pattern, err)
+	}
+
+	// The search query narrows the listing; path.Match keeps the
+	// semantics exact for patterns the query syntax can't express.
+	projects, err := api.SearchProjects(ctx, "id:"+pattern+" state:ACTIVE")
+	if err != nil {
+		return "", fmt.Errorf("failed to search projects: %w", err)
+	}
+	var matches []string
+	for _, project := range projects {
+		if ok, _ := path.Match(pattern, project.ProjectId); ok {
+			matches = append(matches, project.ProjectId)
+		}
+	}
+
+	switch len(matches) {
+	case 0:
+		return "", notFoundError{fmt.Sprintf("no active project matches %q", pattern)}
+	case 1:
+		return matches[0], nil
+	}
+
+	const shown = 10
+	candidates := matches
+	if len(candidates) > shown {
+		candidates = candidates[:shown]
+	}
+	msg := fmt.Sprintf("project pattern %q is ambiguous, %d projects match: %s",
+		pattern, len(matches), strings.Join(candidates, ", "))
+	if len(matches) > shown {
+		msg += ", ..."
+	}
+	return "", fmt.Errorf("%s; narrow the pattern", msg)
+}
+
+// ProjectNumber returns the number of the project called projectID, which
+// some APIs, such as the Connect gateway, take instead of the ID.
+func ProjectNumber(ctx context.Context, api ProjectAPI, projectID string) (string, error) {
+	projects, err := api.SearchProjects(ctx, "id:"+projectID)
+	if err != nil {
+		return "", fmt.Errorf("failed to look up project %s: %w", projectID, err)
+	}
+	for _, project := range projects {
+		if project.ProjectId == projectID {
+			return strings.TrimPrefix(project.Name, "projects/"), nil
+		}
+	}
+	return "", notFoundError{fmt.Sprintf("project %s not found", projectID)}
+}
@@ -0,0 +1,60 @@
+package gke
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"testing"
+)
+
+func TestResolveProject(t *testing.T) {
+	api := &fakeProjects{projects: []string{"payments-prod", "payments-dev", "search-prod"}}
+	tests := []struct {
+		pattern  string
+		want     string
+		err      string
+		notFound bool
+		searched bool
+	}{
+		{pattern: "anything-goes", want: "anything-goes"},
+		{pattern: "search-*", want: "search-prod", searched: true},
+		{pattern: "payments-?rod", want: "payments-prod", searched: true},
+		{pattern: "payments-*", err: `project pattern "payments-*" is ambiguous, 2 projects match: payments-prod, payments-dev; narrow the pattern`, searched: true},
+		{pattern: "billing-*", err: `no active project matches "billing-*"`, notFound: true, searched: true},
+		{pattern: "payments-[", err: `invalid project pattern "payments-[": syntax error in pattern`},
+	}
+	for _, tt := range tests {
+		t.Run(tt.pattern, func(t *testing.T) {
+			api.queries = nil
+			got, err := ResolveProject(context.Background(), api, tt.pattern)
+			if tt.err != "" {
+				if err == nil || err.Error() != tt.err {
+					t.Fatalf("err = %v, want %q", err, tt.err)
+				}
+				if errors.Is(err, ErrNotFound) != tt.notFound {
+					t.Errorf("errors.Is(err, ErrNotFound) = %v, want %v", !tt.notFound, tt.notFound)
+				}
+			} else if err != nil || got != tt.want {
+				t.Errorf("ResolveProject = %q, %v; want %q", got, err, tt.want)
+			}
+			if searched := len(api.queries) > 0; searched != tt.searched {
+				t.Errorf("searched = %v, want %v", searched, tt.searched)
+			}
+			if tt.searched && api.queries[0] != "id:"+tt.pattern+" state:ACTIVE" {
+				t.Errorf("query = %q", api.queries[0])
+			}
+		})
+	}
+}
+
+func TestResolveProjectManyMatches(t *testing.T) {
+	api := &fakeProjects{}
+	for i := 0; i < 12; i++ {
+		api.projects = append(api.projects, fmt.Sprintf("team-%02d", i))
+	}
+	_, err := ResolveProject(context.Background(), api, "team-*")
+	if err == nil || !strings.Contains(err.Error(), "12 projects match") || !strings.Contains(err.Error(), "team-09, ...") {
+		t.Errorf("err = %v, want the first ten of 12 matches", err)
+	}
+}
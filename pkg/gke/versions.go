@@ -0,0 +1,161 @@
+package gke
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"google.golang.org/api/container/v1"
+)
+
+// CompareVersions compares GKE versions such as "1.27.3-gke.1700" number by
+// number, returning -1, 0 or 1.
+func CompareVersions(a, b string) int {
+	split := func(v string) []string {
+		return strings.FieldsFunc(v, func(r rune) bool { return r < '0' || r > '9' })
+	}
+	as, bs := split(a), split(b)
+	for i := 0; i < len(as) && i < len(bs); i++ {
+		x, _ := strconv.Atoi(as[i])
+		y, _ := strconv.Atoi(bs[i])
+		switch {
+		case x < y:
+			return -1
+		case x > y:
+			return 1
+		}
+	}
+	switch {
+	case len(as) < len(bs):
+		return -1
+	case len(as) > len(bs):
+		return 1
+	}
+	return 0
+}
+
+// AvailableUpgrade returns the newest control-plane version cluster can be
+// upgraded to according to config, the server config of its location, or
+// "" when it runs the newest already.
+func AvailableUpgrade(cluster *container.Cluster, config *container.ServerConfig) string {
+	versions := UpgradeVersions(cluster, config)
+	if len(versions) == 0 {
+		return ""
+	}
+	return versions[0]
+}
+
+// UpgradeVersions returns the control-plane versions newer than cluster's
+// that config, the server config of its location, offers, newest first.
+// Clusters enrolled in a release channel are offered that channel's
+// versions only.
+func UpgradeVersions(cluster *container.Cluster, config *container.ServerConfig) []string {
+	if config == nil {
+		return nil
+	}
+	versions := config.ValidMasterVersions
+	if channel := ReleaseChannel(cluster); channel != "" {
+		versions = nil
+		for _, c := range config.Channels {
+			if c.Channel == channel {
+				versions = c.ValidVersions
+			}
+		}
+	}
+
+	var newer []string
+	for _, v := range versions {
+		if CompareVersions(v, cluster.CurrentMasterVersion) > 0 {
+			newer = append(newer, v)
+		}
+	}
+	sort.SliceStable(newer, func(i, j int) bool { return CompareVersions(newer[i], newer[j]) > 0 })
+	return newer
+}
+
+// AutoUpgradeTarget returns the version GKE will automatically upgrade
+// cluster's control plane to in a coming maintenance window, the default
+// version of its release channel in config, or "" when it runs that
+// version already or isn't enrolled in a channel.
+func AutoUpgradeTarget(cluster *container.Cluster, config *container.ServerConfig) string {
+	channel := ReleaseChannel(cluster)
+	if config == nil || channel == "" {
+		return ""
+	}
+	for _, c := range config.Channels {
+		if c.Channel == channel && CompareVersions(c.DefaultVersion, cluster.CurrentMasterVersion) > 0 {
+			return c.DefaultVersion
+		}
+	}
+	return ""
+}
+
+// ScheduledUpgrade is a node pool auto-upgrade GKE has announced.
+type ScheduledUpgrade struct {
+	NodePool    string
+	Start       time.Time
+	Description string
+}
+
+// ScheduledUpgrades returns the node pool auto-upgrades about to start on
+// cluster, soonest first.
+func ScheduledUpgrades(cluster *container.Cluster) []ScheduledUpgrade {
+	var upgrades []ScheduledUpgrade
+	for _, pool := range cluster.NodePools {
+		if pool.Management == nil || pool.Management.UpgradeOptions == nil {
+			continue
+		}
+		options := pool.Management.UpgradeOptions
+		start, err := time.Parse(time.RFC3339, options.AutoUpgradeStartTime)
+		if err != nil {
+			continue
+		}
+		upgrades = append(upgrades, ScheduledUpgrade{NodePool: pool.Name, Start: start, Description: options.Description})
+	}
+	sort.Slice(upgrades, func(i, j int) bool { return upgrades[i].Start.Before(upgrades[j].Start) })
+	return upgrades
+}
+
+// Notifications returns the Pub/Sub topic cluster sends upgrade and
+// security bulletin notifications to and the event types sent, none
+// meaning all; the topic is "" when notifications are off.
+func Notifications(cluster *container.Cluster) (string, []string) {
+	nc := cluster.NotificationConfig
+	if nc == nil || nc.Pubsub == nil || !nc.Pubsub.Enabled {
+		return "", nil
+	}
+	var events []string
+	if nc.Pubsub.Filter != nil {
+		for _, event := range nc.Pubsub.Filter.EventType {
+			if event != "EVENT_TYPE_UNSPECIFIED" {
+				events = append(events, event)
+			}
+		}
+	}
+	return nc.Pubsub.Topic, events
+}
+
+// UpgradeMaster starts the upgrade of the target cluster's control plane to
+// version and waits for the operation to finish.
+func UpgradeMaster(ctx context.Context, api ClusterAPI, target Target, version string, onProgress func(Progress)) error {
+	req := &container.UpdateClusterRequest{
+		Update: &container.ClusterUpdate{DesiredMasterVersion: version},
+	}
+	op, err := api.UpdateCluster(ctx, target.Name(), req)
+	if err != nil {
+		return fmt.Errorf("failed to upgrade %s to %s: %w", target.Cluster, version, err)
+	}
+	return WaitForOperation(ctx, api, target, op, onProgress)
+}
+
+// ReleaseChannel returns the release channel cluster is enrolled in, such as
+// "REGULAR", or "" when it isn't enrolled in any.
+func ReleaseChannel(cluster *container.Cluster) string {
+	if cluster.ReleaseChannel == nil || cluster.ReleaseChannel.Channel == "UNSPECIFIED" {
+		return ""
+	}
+	return cluster.ReleaseChannel.Channel
+}
@@ -0,0 +1,265 @@
+// Package kubeconfig writes GKE cluster and Connect gateway entries into the
+// user's kubeconfig.
+// All edits go through kubectl, behind the Runner interface, so the file
+// format and merge rules stay kubectl's and callers can substitute a fake.
+package kubeconfig
+
+import (
+	"bytes"
+	"fmt"
+	"os/exec"
+	"strings"
+
+	"google.golang.org/api/container/v1"
+)
+
+const (
+	// AuthPlugin is the credential plugin kubectl runs for GKE.
+	AuthPlugin = "gke-gcloud-auth-plugin"
+
+	ExecAPIVersion = "client.authentication.k8s.io/v1beta1"
+
+	// ADCPluginArg makes the plugin use Application Default Credentials
+	// instead of gcloud.
+	ADCPluginArg = "--use_application_default_credentials"
+)
+
+// Runner runs kubectl with args and returns its standard output.
+type Runner interface {
+	Run(args ...string) (string, error)
+}
+
+// Kubectl is the Runner that executes the kubectl binary on PATH.
+type Kubectl struct {
+	// Path is the kubeconfig file to edit. Empty means kubectl's default,
+	// KUBECONFIG or ~/.kube/config.
+	Path string
+}
+
+func (k Kubectl) Run(args ...string) (string, error) {
+	if k.Path != "" {
+		args = append([]string{"--kubeconfig=" + k.Path}, args...)
+	}
+	var stdout bytes.Buffer
+	cmd := exec.Command("kubectl", args...)
+	cmd.Stdout = &stdout
+	err := cmd.Run()
+	return stdout.String(), err
+}
+
+// Editor edits kubeconfig through a Runner.
+type Editor struct {
+	run Runner
+}
+
+// New returns an Editor using runner.
+func New(runner Runner) *Editor {
+	return &Editor{run: runner}
+}
+
+// ContextName mirrors the context naming used by gcloud get-credentials.
+func ContextName(project, location, cluster string) string {
+	return fmt.Sprintf("gke_%s_%s_%s", project, location, cluster)
+}
+
+// GatewayContextName mirrors the context naming used by gcloud for fleet
+// memberships reached through the Connect gateway.
+func GatewayContextName(project, location, membership string) string {
+	return fmt.Sprintf("connectgateway_%s_%s_%s", project, location, membership)
+}
+
+// WriteGateway writes cluster, user and context entries called name for a
+// fleet membership served by the Connect gateway at server, and makes the
+// context current. The gateway has a publicly trusted certificate, so no
+// CA is written. With adc the user entry runs AuthPlugin against
+// Application Default Credentials, otherwise against gcloud's.
+func (e *Editor) WriteGateway(name, server string, adc bool) error {
+	credentials := []string{"config", "set-credentials", name,
+		"--exec-command=" + AuthPlugin,
+		"--exec-api-version=" + ExecAPIVersion}
+	if adc {
+		credentials = append(credentials, "--exec-arg="+ADCPluginArg)
+	}
+	steps := [][]string{
+		{"config", "set-cluster", name, "--server=" + server},
+		credentials,
+		{"config", "set-context", name, "--cluster=" + name, "--user=" + name},
+		{"config", "use-context", name},
+	}
+
+	for _, args := range steps {
+		if _, err := e.run.Run(args...); err != nil {
+			return fmt.Errorf("kubectl %s %s failed: %v", args[0], args[1], err)
+		}
+	}
+	return nil
+}
+
+// WriteCluster writes cluster, user and context entries called name for the
+// cluster and makes the context current. The user entry runs AuthPlugin
+// against Application Default Credentials, so gcloud is not needed.
+func (e *Editor) WriteCluster(name string, cluster *container.Cluster) error {
+	if cluster.Endpoint == "" || cluster.MasterAuth == nil {
+		return fmt.Errorf("cluster %s has no reachable endpoint", cluster.Name)
+	}
+
+	steps := [][]string{
+		{"config", "set-cluster", name, "--server=https://" + cluster.Endpoint},
+		{"config", "set", "clusters." + name + ".certificate-authority-data", cluster.MasterAuth.ClusterCaCertificate},
+		{"config", "set-credentials", name,
+			"--exec-command=" + AuthPlugin,
+			"--exec-api-version=" + ExecAPIVersion,
+			"--exec-arg=" + ADCPluginArg},
+		{"config", "set-context", name, "--cluster=" + name, "--user=" + name},
+		{"config", "use-context", name},
+	}
+
+	for _, args := range steps {
+		if _, err := e.run.Run(args...); err != nil {
+			return fmt.Errorf("kubectl %s %s failed: %v", args[0], args[1], err)
+		}
+	}
+	return nil
+}
+
+// SetServer points the cluster entry called name at server while still
+// verifying TLS against tlsServerName.
+func (e *Editor) SetServer(name, server, tlsServerName string) error {
+	for _, args := range [][]string{
+		{"config", "set", "clusters." + name + ".server", server},
+		{"config", "set", "clusters." + name + ".tls-server-name", tlsServerName},
+	} {
+		if _, err := e.run.Run(args...); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// AliasContext writes a context called alias that uses the cluster, user and
+// namespace of the context called name, and makes it current.
+func (e *Editor) AliasContext(alias, name string) error {
+	out, err := e.run.Run("config", "view",
+		"-o", fmt.Sprintf(`jsonpath={.contexts[?(@.name=="%s")].context.cluster} {.contexts[?(@.name=="%s")].context.user}`, name, name))
+	fields := strings.Fields(out)
+	if err != nil || len(fields) != 2 {
+		return fmt.Errorf("context %s not found", name)
+	}
+
+	args := []string{"config", "set-context", alias, "--cluster=" + fields[0], "--user=" + fields[1]}
+	if namespace := e.ContextNamespace(name); namespace != "" {
+		args = append(args, "--namespace="+namespace)
+	}
+	for _, args := range [][]string{args, {"config", "use-context", alias}} {
+		if _, err := e.run.Run(args...); err != nil {
+			return fmt.Errorf("kubectl %s %s failed: %v", args[0], args[1], err)
+		}
+	}
+	return nil
+}
+
+// Namespaces lists the namespaces of the cluster behind context, asking the
+// cluster itself.
+func (e *Editor) Namespaces(context string) ([]string, error) {
+	out, err := e.run.Run("--context", context, "--request-timeout=15s", "get", "namespaces", "-o", "name")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list namespaces: %v", err)
+	}
+	var namespaces []string
+	for _, name := range strings.Fields(out) {
+		namespaces = append(namespaces, strings.TrimPrefix(name, "namespace/"))
+	}
+	return namespaces, nil
+}
+
+// Context is a context entry of kubeconfig.
+type Context struct {
+	Name      string
+	Cluster   string
+	User      string
+	Namespace string
+}
+
+// ContextEntries returns all contexts with the cluster, user and namespace
+// they use.
+func (e *Editor) ContextEntries() ([]Context, error) {
+	out, err := e.run.Run("config", "view", "-o",
+		`jsonpath={range .contexts[*]}{.name}{"\t"}{.context.cluster}{"\t"}{.context.user}{"\t"}{.context.namespace}{"\n"}{end}`)
+	if err != nil {
+		return nil, err
+	}
+	var contexts []Context
+	for _, line := range strings.Split(out, "\n") {
+		fields := strings.Split(line, "\t")
+		if len(fields) != 4 || fields[0] == "" {
+			continue
+		}
+		contexts = append(contexts, Context{Name: fields[0], Cluster: fields[1], User: fields[2], Namespace: fields[3]})
+	}
+	return contexts, nil
+}
+
+// UseContext makes the context called name current.
+func (e *Editor) UseContext(name string) error {
+	if _, err := e.run.Run("config", "use-context", name); err != nil {
+		return fmt.Errorf("failed to switch to %s: %v", name, err)
+	}
+	return nil
+}
+
+// Delete removes the contexts and then the cluster and user entries they
+// used.
+func (e *Editor) Delete(contexts []Context) error {
+	var steps [][]string
+	clusters, users := make(map[string]bool), make(map[string]bool)
+	for _, c := range contexts {
+		steps = append(steps, []string{"config", "delete-context", c.Name})
+		if c.Cluster != "" && !clusters[c.Cluster] {
+			clusters[c.Cluster] = true
+			steps = append(steps, []string{"config", "delete-cluster", c.Cluster})
+		}
+		if c.User != "" && !users[c.User] {
+			users[c.User] = true
+			steps = append(steps, []string{"config", "delete-user", c.User})
+		}
+	}
+	for _, args := range steps {
+		if _, err := e.run.Run(args...); err != nil {
+			return fmt.Errorf("kubectl %s %s %s failed: %v", args[0], args[1], args[2], err)
+		}
+	}
+	return nil
+}
+
+// Contexts returns the names of all contexts.
+func (e *Editor) Contexts() ([]string, error) {
+	out, err := e.run.Run("config", "get-contexts", "-o", "name")
+	return strings.Fields(out), err
+}
+
+func (e *Editor) ContextExists(name string) bool {
+	_, err := e.run.Run("config", "get-contexts", name)
+	return err == nil
+}
+
+func (e *Editor) CurrentContext() (string, error) {
+	out, err := e.run.Run("config", "current-context")
+	return strings.TrimSpace(out), err
+}
+
+// ContextNamespace returns the namespace set on a context, if any.
+func (e *Editor) ContextNamespace(name string) string {
+	out, err := e.run.Run("config", "view",
+		"-o", fmt.Sprintf(`jsonpath={.contexts[?(@.name=="%s")].context.namespace}`, name))
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(out)
+}
+
+func (e *Editor) SetContextNamespace(name, namespace string) error {
+	if _, err := e.run.Run("config", "set-context", name, "--namespace="+namespace); err != nil {
+		return fmt.Errorf("failed to set namespace %s on %s: %v", namespace, name, err)
+	}
+	return nil
+}
@@ -0,0 +1,47 @@
+package man
+
+// Duplicate is a CIDR listed under more than one display name.
+type Duplicate struct {
+	CIDR string `json:"cidrBlock"`
+	// Names are the display names sharing the CIDR, in list order.
+	Names []string `json:"names"`
+}
+
+// Duplicates returns the CIDRs that appear more than once in entries, in
+// order of their first appearance. CIDRs are compared as written.
+func Duplicates(entries []Entry) []Duplicate {
+	var order []string
+	names := make(map[string][]string)
+	for _, entry := range entries {
+		if _, ok := names[entry.CIDR]; !ok {
+			order = append(order, entry.CIDR)
+		}
+		names[entry.CIDR] = append(names[entry.CIDR], entry.DisplayName)
+	}
+
+	var duplicates []Duplicate
+	for _, cidr := range order {
+		if len(names[cidr]) > 1 {
+			duplicates = append(duplicates, Duplicate{CIDR: cidr, Names: names[cidr]})
+		}
+	}
+	return duplicates
+}
+
+// Merge collapses every CIDR in keep to a single entry named keep[cidr],
+// which must be one of its current names. The kept entry stays where it
+// was; the others are dropped. Entries whose CIDR is not in keep are
+// returned unchanged.
+func Merge(entries []Entry, keep map[string]string) []Entry {
+	merged := make([]Entry, 0, len(entries))
+	done := make(map[string]bool)
+	for _, entry := range entries {
+		name, ok := keep[entry.CIDR]
+		if ok && (entry.DisplayName != name || done[entry.CIDR]) {
+			continue
+		}
+		done[entry.CIDR] = ok
+		merged = append(merged, entry)
+	}
+	return merged
+}
@@ -0,0 +1,76 @@
+package man
+
+import "testing"
+
+func TestDuplicates(t *testing.T) {
+	vpn := Entry{DisplayName: "vpn", CIDR: office.CIDR}
+	tests := []struct {
+		name    string
+		entries []Entry
+		want    []Duplicate
+	}{
+		{name: "none", entries: []Entry{alice, bob, office}},
+		{
+			name:    "in order of first appearance",
+			entries: []Entry{bob, office, alice, vpn, {DisplayName: "carol", CIDR: bob.CIDR}, {DisplayName: "ci", CIDR: office.CIDR}},
+			want: []Duplicate{
+				{CIDR: bob.CIDR, Names: []string{"bob", "carol"}},
+				{CIDR: office.CIDR, Names: []string{"office", "vpn", "ci"}},
+			},
+		},
+		{
+			name:    "CIDRs compared as written",
+			entries: []Entry{office, {DisplayName: "host", CIDR: "192.0.2.7/24"}},
+		},
+		{
+			name:    "same name twice",
+			entries: []Entry{alice, alice},
+			want:    []Duplicate{{CIDR: alice.CIDR, Names: []string{"alice", "alice"}}},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, "duplicates", Duplicates(tt.entries), tt.want)
+		})
+	}
+}
+
+func TestMerge(t *testing.T) {
+	vpn := Entry{DisplayName: "vpn", CIDR: office.CIDR}
+	ci := Entry{DisplayName: "ci", CIDR: office.CIDR}
+	tests := []struct {
+		name    string
+		entries []Entry
+		keep    map[string]string
+		want    []Entry
+	}{
+		{
+			name:    "nothing to merge",
+			entries: []Entry{alice, office, vpn},
+			want:    []Entry{alice, office, vpn},
+		},
+		{
+			name:    "keeps the named entry where it was",
+			entries: []Entry{office, alice, vpn, ci},
+			keep:    map[string]string{office.CIDR: "vpn"},
+			want:    []Entry{alice, vpn},
+		},
+		{
+			name:    "keeps one of several entries with the name",
+			entries: []Entry{alice, alice, bob},
+			keep:    map[string]string{alice.CIDR: "alice"},
+			want:    []Entry{alice, bob},
+		},
+		{
+			name:    "other CIDRs are untouched",
+			entries: []Entry{bob, office, bob, vpn},
+			keep:    map[string]string{office.CIDR: "office"},
+			want:    []Entry{bob, office, bob},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			check(t, "merged", Merge(tt.entries, tt.keep), tt.want)
+		})
+	}
+}
@@ -0,0 +1,275 @@
+// Package man plans changes to a GKE cluster's master authorized networks
+// (MAN) allow-list. A Planner compares the current entries with the desired
+// ones under a Policy and returns a Plan describing adds, updates, removals,
+// warnings and how the result fits the per-cluster entry limit. Plans are
+// pure data: callers decide whether to print, confirm or apply them.
+package man
+
+import (
+	"fmt"
+	"net"
+)
+
+// DefaultMaxEntries is the number of authorized networks GKE accepts on a
+// cluster with a public endpoint.
+const DefaultMaxEntries = 50
+
+// Entry is a single authorized network.
+type Entry struct {
+	DisplayName string `json:"displayName" yaml:"displayName"`
+	CIDR        string `json:"cidrBlock" yaml:"cidrBlock"`
+}
+
+// Policy controls how desired entries are merged into the current list.
+type Policy struct {
+	// Prune removes current entries whose display name does not appear in
+	// the desired list, making the desired list authoritative. Without it
+	// desired entries are upserted and everything else is kept.
+	Prune bool
+
+	// MaxEntries is the limit the resulting list is checked against.
+	// Zero means DefaultMaxEntries.
+	MaxEntries int
+
+	// Duplicates decides what happens to current entries left over when
+	// there are more of them with a display name than desired ones.
+	Duplicates DuplicatePolicy
+}
+
+// DuplicatePolicy handles current entries sharing a display name, such as
+// several entries for one user left behind by old tool versions.
+type DuplicatePolicy string
+
+const (
+	// DuplicatesKeep leaves the extra entries as they are. It is the
+	// default.
+	DuplicatesKeep DuplicatePolicy = ""
+	// DuplicatesConsolidate removes the extra entries.
+	DuplicatesConsolidate DuplicatePolicy = "consolidate"
+	// DuplicatesUpdateAll gives the extra entries the CIDR of the last
+	// desired entry with their name.
+	DuplicatesUpdateAll DuplicatePolicy = "update-all"
+)
+
+// ParseDuplicatePolicy reads a policy name: keep, consolidate or update-all.
+func ParseDuplicatePolicy(name string) (DuplicatePolicy, error) {
+	switch DuplicatePolicy(name) {
+	case "keep", DuplicatesKeep:
+		return DuplicatesKeep, nil
+	case DuplicatesConsolidate, DuplicatesUpdateAll:
+		return DuplicatePolicy(name), nil
+	}
+	return "", fmt.Errorf("unknown duplicate entry policy %q; choose keep, consolidate or update-all", name)
+}
+
+// Update is an entry whose CIDR changes while its display name stays.
+type Update struct {
+	Old Entry `json:"old"`
+	New Entry `json:"new"`
+}
+
+// LimitAnalysis describes the list size before and after the plan.
+type LimitAnalysis struct {
+	Max      int  `json:"max"`
+	Before   int  `json:"before"`
+	After    int  `json:"after"`
+	Exceeded bool `json:"exceeded"`
+}
+
+// Plan is the outcome of planning a change to an allow-list.
+type Plan struct {
+	Current  []Entry       `json:"current"`
+	Result   []Entry       `json:"result"`
+	Adds     []Entry       `json:"adds,omitempty"`
+	Updates  []Update      `json:"updates,omitempty"`
+	Removes  []Entry       `json:"removes,omitempty"`
+	Warnings []string      `json:"warnings,omitempty"`
+	Limit    LimitAnalysis `json:"limit"`
+}
+
+// Empty reports whether applying the plan would change nothing.
+func (p *Plan) Empty() bool {
+	return len(p.Adds) == 0 && len(p.Updates) == 0 && len(p.Removes) == 0
+}
+
+// Planner computes Plans under a fixed Policy.
+type Planner struct {
+	Policy Policy
+}
+
+// NewPlanner returns a Planner using policy.
+func NewPlanner(policy Policy) *Planner {
+	return &Planner{Policy: policy}
+}
+
+// Plan merges desired into current. Entries are matched by display name in
+// order, so the first desired entry named "alice" pairs with the first
+// current entry named "alice", and so on. Current entries left over are
+// handled by the Duplicates policy.
+func (pl *Planner) Plan(current, desired []Entry) *Plan {
+	plan := &Plan{Current: current}
+
+	byName := make(map[string][]int)
+	for i, entry := range current {
+		byName[entry.DisplayName] = append(byName[entry.DisplayName], i)
+	}
+	warned := make(map[string]bool)
+	for _, entry := range current {
+		if n := len(byName[entry.DisplayName]); n > 1 && !warned[entry.DisplayName] {
+			warned[entry.DisplayName] = true
+			plan.Warnings = append(plan.Warnings,
+				fmt.Sprintf("%d current entries share the display name %q", n, entry.DisplayName))
+		}
+	}
+
+	result := make([]Entry, len(current))
+	copy(result, current)
+	matched := make([]bool, len(current))
+	dropped := make([]bool, len(current))
+
+	wanted := make(map[string]int)
+	for _, entry := range desired {
+		wanted[entry.DisplayName]++
+	}
+
+	for _, entry := range desired {
+		if warning := checkCIDR(entry); warning != "" {
+			plan.Warnings = append(plan.Warnings, warning)
+		}
+
+		indexes := byName[entry.DisplayName]
+		if len(indexes) == 0 {
+			plan.Adds = append(plan.Adds, entry)
+			result = append(result, entry)
+			continue
+		}
+
+		i := indexes[0]
+		byName[entry.DisplayName] = indexes[1:]
+		matched[i] = true
+		if current[i].CIDR != entry.CIDR {
+			plan.Updates = append(plan.Updates, Update{Old: current[i], New: entry})
+			result[i] = entry
+		}
+
+		wanted[entry.DisplayName]--
+		if wanted[entry.DisplayName] > 0 {
+			continue
+		}
+		for _, j := range byName[entry.DisplayName] {
+			switch pl.Policy.Duplicates {
+			case DuplicatesConsolidate:
+				matched[j], dropped[j] = true, true
+				plan.Removes = append(plan.Removes, current[j])
+			case DuplicatesUpdateAll:
+				matched[j] = true
+				if current[j].CIDR != entry.CIDR {
+					plan.Updates = append(plan.Updates, Update{Old: current[j], New: entry})
+					result[j] = entry
+				}
+			}
+		}
+	}
+
+	kept := result[:0]
+	for i, entry := range result {
+		if i < len(current) && dropped[i] {
+			continue
+		}
+		if i < len(current) && !matched[i] && pl.Policy.Prune {
+			plan.Removes = append(plan.Removes, entry)
+			continue
+		}
+		kept = append(kept, entry)
+	}
+	result = kept
+	plan.Result = result
+
+	seen := make(map[string]string)
+	for _, entry := range result {
+		if other, ok := seen[entry.CIDR]; ok {
+			plan.Warnings = append(plan.Warnings,
+				fmt.Sprintf("%s is listed under both %q and %q", entry.CIDR, other, entry.DisplayName))
+			continue
+		}
+		seen[entry.CIDR] = entry.DisplayName
+	}
+
+	limit := pl.Policy.MaxEntries
+	if limit == 0 {
+		limit = DefaultMaxEntries
+	}
+	plan.Limit = LimitAnalysis{
+		Max:      limit,
+		Before:   len(current),
+		After:    len(result),
+		Exceeded: len(result) > limit,
+	}
+	if plan.Limit.Exceeded {
+		plan.Warnings = append(plan.Warnings,
+			fmt.Sprintf("result has %d entries, more than the limit of %d", len(result), limit))
+	}
+
+	return plan
+}
+
+// Revert undoes the plan on live, the list as it is now, leaving alone
+// whatever else changed since the plan was applied. Added entries are
+// taken out, updated ones get their old CIDR back and removed ones are put
+// back, each only where live still shows the plan's doing.
+func (p *Plan) Revert(live []Entry) []Entry {
+	added := make(map[Entry]int)
+	for _, entry := range p.Adds {
+		added[entry]++
+	}
+	updated := make(map[Entry][]Entry)
+	for _, update := range p.Updates {
+		updated[update.New] = append(updated[update.New], update.Old)
+	}
+
+	reverted := make([]Entry, 0, len(live)+len(p.Removes))
+	for _, entry := range live {
+		if added[entry] > 0 {
+			added[entry]--
+			continue
+		}
+		if olds := updated[entry]; len(olds) > 0 {
+			reverted = append(reverted, olds[0])
+			updated[entry] = olds[1:]
+			continue
+		}
+		reverted = append(reverted, entry)
+	}
+
+	// A removed entry that live has more copies of than the plan left was
+	// put back by someone else already.
+	extra := make(map[Entry]int)
+	for _, entry := range live {
+		extra[entry]++
+	}
+	for _, entry := range p.Result {
+		extra[entry]--
+	}
+	for _, entry := range p.Removes {
+		if extra[entry] > 0 {
+			extra[entry]--
+			continue
+		}
+		reverted = append(reverted, entry)
+	}
+	return reverted
+}
+
+func checkCIDR(entry Entry) string {
+	ip, network, err := net.ParseCIDR(entry.CIDR)
+	if err != nil {
+		return fmt.Sprintf("%q has an invalid CIDR %q", entry.DisplayName, entry.CIDR)
+	}
+	if ones, _ := network.Mask.Size(); ones == 0 {
+		return fmt.Sprintf("%q opens the control plane to the whole internet (%s)", entry.DisplayName, entry.CIDR)
+	}
+	if !ip.Equal(network.IP) {
+		return fmt.Sprintf("%q has host bits set in %s; GKE will store %s", entry.DisplayName, entry.CIDR, network)
+	}
+	return ""
+}
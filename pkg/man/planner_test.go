@@ -0,0 +1,290 @@
+package man
+
+import (
+	"fmt"
+	"reflect"
+	"testing"
+)
+
+var (
+	alice     = Entry{DisplayName: "alice", CIDR: "203.0.113.1/32"}
+	aliceNew  = Entry{DisplayName: "alice", CIDR: "203.0.113.9/32"}
+	aliceOld  = Entry{DisplayName: "alice", CIDR: "203.0.113.5/32"}
+	bob       = Entry{DisplayName: "bob", CIDR: "198.51.100.0/24"}
+	office    = Entry{DisplayName: "office", CIDR: "192.0.2.0/24"}
+	bobMoved  = Entry{DisplayName: "bob", CIDR: "198.51.100.7/32"}
+	everybody = Entry{DisplayName: "everybody", CIDR: "0.0.0.0/0"}
+)
+
+func TestPlan(t *testing.T) {
+	tests := []struct {
+		name     string
+		policy   Policy
+		current  []Entry
+		desired  []Entry
+		adds     []Entry
+		updates  []Update
+		removes  []Entry
+		result   []Entry
+		warnings []string
+	}{
+		{
+			name:    "unchanged",
+			current: []Entry{alice, bob},
+			desired: []Entry{alice},
+			result:  []Entry{alice, bob},
+		},
+		{
+			name:    "upsert adds a new name",
+			current: []Entry{alice},
+			desired: []Entry{bob},
+			adds:    []Entry{bob},
+			result:  []Entry{alice, bob},
+		},
+		{
+			name:    "upsert updates the CIDR in place",
+			current: []Entry{alice, office},
+			desired: []Entry{aliceNew},
+			updates: []Update{{Old: alice, New: aliceNew}},
+			result:  []Entry{aliceNew, office},
+		},
+		{
+			name:    "prune removes names not desired",
+			policy:  Policy{Prune: true},
+			current: []Entry{alice, bob, office},
+			desired: []Entry{office, bobMoved},
+			updates: []Update{{Old: bob, New: bobMoved}},
+			removes: []Entry{alice},
+			result:  []Entry{bobMoved, office},
+		},
+		{
+			name:    "prune to an empty list",
+			policy:  Policy{Prune: true},
+			current: []Entry{alice, bob},
+			removes: []Entry{alice, bob},
+			result:  []Entry{},
+		},
+		{
+			name:     "duplicates are kept by default",
+			current:  []Entry{alice, aliceOld},
+			desired:  []Entry{aliceNew},
+			updates:  []Update{{Old: alice, New: aliceNew}},
+			result:   []Entry{aliceNew, aliceOld},
+			warnings: []string{`2 current entries share the display name "alice"`},
+		},
+		{
+			name:     "kept duplicates are pruned",
+			policy:   Policy{Prune: true},
+			current:  []Entry{alice, aliceOld},
+			desired:  []Entry{aliceNew},
+			updates:  []Update{{Old: alice, New: aliceNew}},
+			removes:  []Entry{aliceOld},
+			result:   []Entry{aliceNew},
+			warnings: []string{`2 current entries share the display name "alice"`},
+		},
+		{
+			name:     "consolidate removes the extra duplicates",
+			policy:   Policy{Duplicates: DuplicatesConsolidate},
+			current:  []Entry{alice, bob, aliceOld},
+			desired:  []Entry{aliceNew},
+			updates:  []Update{{Old: alice, New: aliceNew}},
+			removes:  []Entry{aliceOld},
+			result:   []Entry{aliceNew, bob},
+			warnings: []string{`2 current entries share the display name "alice"`},
+		},
+		{
+			name:     "consolidate keeps as many as desired",
+			policy:   Policy{Duplicates: DuplicatesConsolidate},
+			current:  []Entry{alice, aliceOld},
+			desired:  []Entry{alice, aliceOld},
+			result:   []Entry{alice, aliceOld},
+			warnings: []string{`2 current entries share the display name "alice"`},
+		},
+		{
+			name:    "update-all moves every duplicate",
+			policy:  Policy{Duplicates: DuplicatesUpdateAll},
+			current: []Entry{alice, bob, aliceOld},
+			desired: []Entry{aliceNew},
+			updates: []Update{{Old: alice, New: aliceNew}, {Old: aliceOld, New: aliceNew}},
+			result:  []Entry{aliceNew, bob, aliceNew},
+			warnings: []string{
+				`2 current entries share the display name "alice"`,
+				`203.0.113.9/32 is listed under both "alice" and "alice"`,
+			},
+		},
+		{
+			name:     "CIDR listed under two names",
+			current:  []Entry{office},
+			desired:  []Entry{{DisplayName: "vpn", CIDR: office.CIDR}},
+			adds:     []Entry{{DisplayName: "vpn", CIDR: office.CIDR}},
+			result:   []Entry{office, {DisplayName: "vpn", CIDR: office.CIDR}},
+			warnings: []string{`192.0.2.0/24 is listed under both "office" and "vpn"`},
+		},
+		{
+			name:    "CIDR warnings",
+			desired: []Entry{everybody, {DisplayName: "typo", CIDR: "192.0.2.300/32"}, {DisplayName: "host", CIDR: "192.0.2.7/24"}},
+			adds:    []Entry{everybody, {DisplayName: "typo", CIDR: "192.0.2.300/32"}, {DisplayName: "host", CIDR: "192.0.2.7/24"}},
+			result:  []Entry{everybody, {DisplayName: "typo", CIDR: "192.0.2.300/32"}, {DisplayName: "host", CIDR: "192.0.2.7/24"}},
+			warnings: []string{
+				`"everybody" opens the control plane to the whole internet (0.0.0.0/0)`,
+				`"typo" has an invalid CIDR "192.0.2.300/32"`,
+				`"host" has host bits set in 192.0.2.7/24; GKE will store 192.0.2.0/24`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := NewPlanner(tt.policy).Plan(tt.current, tt.desired)
+			check(t, "adds", plan.Adds, tt.adds)
+			check(t, "updates", plan.Updates, tt.updates)
+			check(t, "removes", plan.Removes, tt.removes)
+			check(t, "result", plan.Result, tt.result)
+			check(t, "warnings", plan.Warnings, tt.warnings)
+			if empty := len(tt.adds)+len(tt.updates)+len(tt.removes) == 0; plan.Empty() != empty {
+				t.Errorf("Empty() = %v, want %v", plan.Empty(), empty)
+			}
+		})
+	}
+}
+
+func TestPlanLimit(t *testing.T) {
+	tests := []struct {
+		name    string
+		max     int
+		current int
+		desired int
+		want    LimitAnalysis
+		warning string
+	}{
+		{name: "default limit", current: 49, desired: 1, want: LimitAnalysis{Max: 50, Before: 49, After: 50}},
+		{name: "over the default limit", current: 50, desired: 1, want: LimitAnalysis{Max: 50, Before: 50, After: 51, Exceeded: true},
+			warning: "result has 51 entries, more than the limit of 50"},
+		{name: "custom limit", max: 2, current: 2, desired: 1, want: LimitAnalysis{Max: 2, Before: 2, After: 3, Exceeded: true},
+			warning: "result has 3 entries, more than the limit of 2"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := NewPlanner(Policy{MaxEntries: tt.max}).Plan(numbered("current", tt.current), numbered("desired", tt.desired))
+			if plan.Limit != tt.want {
+				t.Errorf("Limit = %+v, want %+v", plan.Limit, tt.want)
+			}
+			var warnings []string
+			if tt.warning != "" {
+				warnings = []string{tt.warning}
+			}
+			check(t, "warnings", plan.Warnings, warnings)
+		})
+	}
+}
+
+func TestRevert(t *testing.T) {
+	carol := Entry{DisplayName: "carol", CIDR: "198.51.100.9/32"}
+	tests := []struct {
+		name    string
+		policy  Policy
+		current []Entry
+		desired []Entry
+		// live is the list when reverting, nil meaning the plan's result.
+		live []Entry
+		want []Entry
+	}{
+		{
+			name:    "undoes an add",
+			current: []Entry{alice},
+			desired: []Entry{bob},
+			want:    []Entry{alice},
+		},
+		{
+			name:    "undoes an update",
+			current: []Entry{alice, bob},
+			desired: []Entry{aliceNew},
+			want:    []Entry{alice, bob},
+		},
+		{
+			name:    "puts back removals",
+			policy:  Policy{Prune: true},
+			current: []Entry{alice, bob, office},
+			desired: []Entry{alice},
+			want:    []Entry{alice, bob, office},
+		},
+		{
+			name:    "keeps what changed meanwhile",
+			current: []Entry{alice, bob},
+			desired: []Entry{aliceNew, office},
+			live:    []Entry{aliceNew, office, carol},
+			want:    []Entry{alice, carol},
+		},
+		{
+			name:    "leaves an entry removed meanwhile out",
+			current: []Entry{alice},
+			desired: []Entry{bob},
+			live:    []Entry{bob},
+		},
+		{
+			name:    "doesn't put back a removal someone undid",
+			policy:  Policy{Prune: true},
+			current: []Entry{alice, bob},
+			desired: []Entry{alice},
+			live:    []Entry{alice, bob},
+			want:    []Entry{alice, bob},
+		},
+		{
+			name:    "nothing applied",
+			current: []Entry{alice},
+			desired: []Entry{bob},
+			live:    []Entry{alice},
+			want:    []Entry{alice},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plan := NewPlanner(tt.policy).Plan(tt.current, tt.desired)
+			live := tt.live
+			if live == nil {
+				live = plan.Result
+			}
+			check(t, "reverted", plan.Revert(live), tt.want)
+		})
+	}
+}
+
+func TestParseDuplicatePolicy(t *testing.T) {
+	for name, want := range map[string]DuplicatePolicy{
+		"":            DuplicatesKeep,
+		"keep":        DuplicatesKeep,
+		"consolidate": DuplicatesConsolidate,
+		"update-all":  DuplicatesUpdateAll,
+	} {
+		got, err := ParseDuplicatePolicy(name)
+		if err != nil || got != want {
+			t.Errorf("ParseDuplicatePolicy(%q) = %q, %v; want %q", name, got, err, want)
+		}
+	}
+	if _, err := ParseDuplicatePolicy("merge"); err == nil {
+		t.Error("ParseDuplicatePolicy(\"merge\") succeeded, want an error")
+	}
+}
+
+// numbered returns n entries with distinct names and CIDRs.
+func numbered(prefix string, n int) []Entry {
+	second := 1
+	if prefix == "desired" {
+		second = 2
+	}
+	var entries []Entry
+	for i := 0; i < n; i++ {
+		entries = append(entries, Entry{DisplayName: fmt.Sprintf("%s-%d", prefix, i), CIDR: fmt.Sprintf("10.%d.%d.0/24", second, i)})
+	}
+	return entries
+}
+
+// check compares got and want, treating nil and empty slices alike.
+func check(t *testing.T, what string, got, want any) {
+	t.Helper()
+	if reflect.ValueOf(got).Len() == 0 && reflect.ValueOf(want).Len() == 0 {
+		return
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("%s = %+v, want %+v", what, got, want)
+	}
+}
@@ -0,0 +1,239 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+	"google.golang.org/api/googleapi"
+	"google.golang.org/api/option"
+	htransport "google.golang.org/api/transport/http"
+)
+
+// postureEndpoint is the Container Security API, which serves the findings
+// of GKE Security Posture. There is no generated client for it in use, so
+// it is called directly.
+const postureEndpoint = "https://containersecurity.googleapis.com/"
+
+// finding is a Security Posture finding. Only the fields used here are
+// decoded; Raw keeps the finding as the API returned it.
+type finding struct {
+	ResourceName string `json:"resourceName"`
+	// Type is VULNERABILITY or MISCONFIGURATION.
+	Type     string `json:"type"`
+	Severity string `json:"severity"`
+	State    string `json:"state"`
+	Raw      json.RawMessage
+}
+
+func (f *finding) UnmarshalJSON(data []byte) error {
+	type fields finding
+	if err := json.Unmarshal(data, (*fields)(f)); err != nil {
+		return err
+	}
+	f.Raw = append(json.RawMessage(nil), data...)
+	return nil
+}
+
+// cluster returns the name of the cluster f was found in, taken from its
+// resource name, e.g. "//container.googleapis.com/projects/p/locations/l/clusters/c/k8s/...".
+func (f finding) cluster() string {
+	_, rest, ok := strings.Cut(f.ResourceName, "/clusters/")
+	if !ok {
+		return ""
+	}
+	name, _, _ := strings.Cut(rest, "/")
+	return name
+}
+
+// active reports whether f still needs fixing.
+func (f finding) active() bool {
+	return f.State == "" || f.State == "ACTIVE"
+}
+
+// postureCounts are a cluster's active findings by type and severity.
+type postureCounts struct {
+	Vulnerabilities   int
+	Misconfigurations int
+	// Critical and High count findings of either type.
+	Critical, High int
+}
+
+func (c postureCounts) String() string {
+	s := fmt.Sprintf("%s, %s", pluralize(c.Vulnerabilities, "vulnerability"), pluralize(c.Misconfigurations, "misconfiguration"))
+	if c.Critical > 0 || c.High > 0 {
+		s += fmt.Sprintf(" (%d critical, %d high)", c.Critical, c.High)
+	}
+	return s
+}
+
+// listFindings returns the Security Posture findings of projectID in
+// location, following pages.
+func listFindings(ctx context.Context, projectID, location string) ([]finding, error) {
+	opts, err := clientOptions(ctx, "containersecurity")
+	if err != nil {
+		return nil, err
+	}
+	client, _, err := htransport.NewClient(ctx, append(opts, option.WithScopes(cloudPlatformScope))...)
+	if err != nil {
+		return nil, err
+	}
+	base := postureEndpoint
+	if endpoint := apiEndpoint("containersecurity"); endpoint != "" {
+		base = endpoint
+	}
+
+	var findings []finding
+	pageToken := ""
+	for {
+		u := fmt.Sprintf("%sv1beta1/projects/%s/locations/%s/findings?pageToken=%s", base, projectID, location, url.QueryEscape(pageToken))
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return nil, err
+		}
+		var page struct {
+			Findings      []finding `json:"findings"`
+			NextPageToken string    `json:"nextPageToken"`
+		}
+		err = googleapi.CheckResponse(resp)
+		if err == nil {
+			err = json.NewDecoder(resp.Body).Decode(&page)
+		}
+		resp.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		findings = append(findings, page.Findings...)
+		if page.NextPageToken == "" {
+			return findings, nil
+		}
+		pageToken = page.NextPageToken
+	}
+}
+
+// postureSummary counts the active findings of each cluster of projectID,
+// keyed by clusterKey. Findings are listed once per location, concurrently;
+// locations whose findings can't be listed, for example because the API
+// isn't enabled, are left out.
+func postureSummary(ctx context.Context, projectID string, clusters []*container.Cluster) map[string]postureCounts {
+	byLocation := make(map[string][]finding)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	var locations []string
+	for _, cluster := range clusters {
+		locations = append(locations, cluster.Location)
+	}
+	for _, location := range unique(locations) {
+		wg.Add(1)
+		go func(location string) {
+			defer wg.Done()
+			findings, err := listFindings(ctx, projectID, location)
+			if err != nil {
+				slog.Debug("failed to list security posture findings", "project", projectID, "location", location, "err", err)
+				return
+			}
+			mu.Lock()
+			byLocation[location] = findings
+			mu.Unlock()
+		}(location)
+	}
+	wg.Wait()
+
+	counts := make(map[string]postureCounts)
+	for _, cluster := range clusters {
+		findings, ok := byLocation[cluster.Location]
+		if !ok {
+			continue
+		}
+		var c postureCounts
+		for _, f := range findings {
+			if f.cluster() != cluster.Name || !f.active() {
+				continue
+			}
+			switch f.Type {
+			case "VULNERABILITY":
+				c.Vulnerabilities++
+			case "MISCONFIGURATION":
+				c.Misconfigurations++
+			}
+			switch f.Severity {
+			case "CRITICAL":
+				c.Critical++
+			case "HIGH":
+				c.High++
+			}
+		}
+		counts[clusterKey(cluster)] = c
+	}
+	return counts
+}
+
+// postureMsg delivers the findings counts to the cluster list.
+type postureMsg struct {
+	projectID string
+	counts    map[string]postureCounts
+}
+
+// checkPosture counts the clusters' findings in the background.
+func checkPosture(projectID string, clusters []*container.Cluster) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		return postureMsg{projectID: projectID, counts: postureSummary(ctx, projectID, clusters)}
+	}
+}
+
+func newFindingsCmd() *cobra.Command {
+	var target targetOptions
+	var all bool
+	cmd := &cobra.Command{
+		Use:   "findings",
+		Short: "Print a cluster's Security Posture findings as JSON",
+		Long: `Prints the vulnerabilities and misconfigurations GKE Security Posture
+found in a cluster's workloads as a JSON array, as the Container Security
+API returns them. Remediated findings are left out unless --all is given.`,
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runFindings(cmd.Context(), os.Stdout, target, all)
+		},
+	}
+	target.addFlags(cmd)
+	cmd.Flags().BoolVar(&all, "all", false, "include remediated findings")
+	return cmd
+}
+
+func runFindings(ctx context.Context, out io.Writer, target targetOptions, all bool) error {
+	config, _, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	findings, err := listFindings(ctx, config.ProjectID, config.Region)
+	if err != nil {
+		return fmt.Errorf("failed to list security posture findings: %v", err)
+	}
+	raw := []json.RawMessage{}
+	for _, f := range findings {
+		if f.cluster() == config.Cluster && (all || f.active()) {
+			raw = append(raw, f.Raw)
+		}
+	}
+	data, err := json.MarshalIndent(raw, "", "  ")
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(out, "%s\n", data)
+	return err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"google.golang.org/api/container/v1"
+)
+
+// printCommands makes connecting print the equivalent gcloud and kubectl
+// commands instead of changing anything.
+var printCommands bool
+
+// addPrintCommandsFlag adds --print-commands to a command that connects and
+// honors it. Other commands don't take it, so it can't be mistaken for a
+// dry run of theirs.
+func addPrintCommandsFlag(cmd *cobra.Command) {
+	cmd.Flags().BoolVar(&printCommands, "print-commands", false, "print the gcloud and kubectl commands connecting would run instead of running them")
+}
+
+// connectCommands returns the gcloud and kubectl commands that do what
+// connecting to cluster would: put the user's IP on its authorized
+// networks, write its credentials and set the namespace. Comments list the
+// authorized network changes, since gcloud takes the whole list.
+func connectCommands(config GKEConfig, cluster *container.Cluster) ([]string, error) {
+	var commands []string
+	if gke.HasAuthorizedNetworks(cluster) {
+		entry, err := myEntry(config.Username)
+		if err != nil {
+			return nil, err
+		}
+		duplicates, err := duplicatePolicy()
+		if err != nil {
+			return nil, err
+		}
+		plan := man.NewPlanner(man.Policy{Duplicates: duplicates}).Plan(gke.AuthorizedEntries(cluster), []man.Entry{entry})
+		if plan.Limit.Exceeded {
+			return nil, fmt.Errorf("cannot add your IP: cluster already has %d of %d authorized networks", plan.Limit.Before, plan.Limit.Max)
+		}
+		if !plan.Empty() {
+			for _, e := range plan.Adds {
+				commands = append(commands, fmt.Sprintf("# add %s %s", e.CIDR, e.DisplayName))
+			}
+			for _, u := range plan.Updates {
+				commands = append(commands, fmt.Sprintf("# update %s %s -> %s", u.Old.DisplayName, u.Old.CIDR, u.New.CIDR))
+			}
+			for _, e := range plan.Removes {
+				commands = append(commands, fmt.Sprintf("# remove %s %s", e.CIDR, e.DisplayName))
+			}
+			commands = append(commands, "# gcloud drops the entries' display names, which gke keeps")
+			var cidrs []string
+			for _, e := range plan.Result {
+				cidrs = append(cidrs, e.CIDR)
+			}
+			commands = append(commands, gcloudCommand(config, "update",
+				"--enable-master-authorized-networks", "--master-authorized-networks", strings.Join(cidrs, ",")))
+		}
+	}
+
+	getCredentials := gcloudCommand(config, "get-credentials")
+	if path := kubeconfigPath(config); path != "" {
+		getCredentials = "KUBECONFIG=" + shellQuote(path) + " " + getCredentials
+	}
+	commands = append(commands, getCredentials)
+	if namespaceFlag != "" {
+		commands = append(commands, "kubectl config set-context --current --namespace "+shellQuote(namespaceFlag))
+	}
+	return commands, nil
+}
+
+// gcloudCommand renders a gcloud container clusters command on config's
+// cluster, with the same location and billing flags as runGetCredentials.
+func gcloudCommand(config GKEConfig, verb string, args ...string) string {
+	parts := []string{"gcloud", "container", "clusters", verb, config.Cluster,
+		"--region", config.Region, "--project", config.ProjectID}
+	if billingProject != "" {
+		parts = append(parts, "--billing-project", billingProject)
+	}
+	parts = append(parts, args...)
+	for i, part := range parts {
+		parts[i] = shellQuote(part)
+	}
+	return strings.Join(parts, " ")
+}
+
+// connectScript renders the commands connecting to cluster amounts to as
+// a script for change tickets.
+func connectScript(config GKEConfig, cluster *container.Cluster) (string, error) {
+	commands, err := connectCommands(config, cluster)
+	if err != nil {
+		return "", err
+	}
+	header := fmt.Sprintf("# Connect to %s/%s/%s\n", config.ProjectID, config.Region, config.Cluster)
+	return header + strings.Join(commands, "\n") + "\n", nil
+}
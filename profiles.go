@@ -0,0 +1,127 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"strings"
+
+	"gke-tool/pkg/man"
+)
+
+// networkProfile describes where the user is connecting from.
+type networkProfile struct {
+	// CIDR is either a fixed range such as "198.51.100.0/24" or
+	// "auto/<bits>", meaning the detected public IP masked to that size.
+	CIDR string `json:"cidr,omitempty" yaml:"cidr,omitempty"`
+
+	// EndpointOverrides maps a cluster endpoint (IP or hostname) to an
+	// alternate address, such as an internal load balancer or a tunnel,
+	// used in the written kubeconfig and in connectivity probes.
+	EndpointOverrides map[string]string `json:"endpointOverrides,omitempty" yaml:"endpointOverrides,omitempty"`
+}
+
+// activeProfile is the profile picked with --profile, if any.
+var activeProfile string
+
+// currentProfile returns the network profile selected with --profile or
+// defaultProfile. An empty name means no profile is in use.
+func currentProfile() (string, networkProfile, error) {
+	cfg, err := loadUserConfig()
+	if err != nil {
+		if activeProfile != "" {
+			return "", networkProfile{}, err
+		}
+		return "", networkProfile{}, nil
+	}
+
+	name := activeProfile
+	if name == "" {
+		name = cfg.DefaultProfile
+	}
+	if name == "" {
+		return "", networkProfile{}, nil
+	}
+	profile, ok := cfg.Profiles[name]
+	if !ok {
+		return "", networkProfile{}, fmt.Errorf("unknown network profile %q", name)
+	}
+	return name, profile, nil
+}
+
+// myEntry returns the authorized network entry for the current user. With
+// a network profile it carries that profile's CIDR and a profile-tagged
+// display name; otherwise it is the detected public IP as a /32.
+func myEntry(username string) (man.Entry, error) {
+	name, profile, err := currentProfile()
+	if err != nil {
+		return man.Entry{}, err
+	}
+
+	if name == "" || profile.CIDR == "" {
+		ip, err := getCurrentPublicIP()
+		if err != nil {
+			return man.Entry{}, err
+		}
+		return man.Entry{DisplayName: username, CIDR: ip + "/32"}, nil
+	}
+
+	cidr, err := profile.resolve()
+	if err != nil {
+		return man.Entry{}, fmt.Errorf("network profile %s: %v", name, err)
+	}
+	return man.Entry{DisplayName: username + "-" + name, CIDR: cidr}, nil
+}
+
+// isMyEntry reports whether an authorized network entry belongs to username,
+// either untagged or tagged with one of the configured network profiles.
+// Other suffixes are left alone: "bob-smith" is someone else's entry, not
+// bob's.
+func isMyEntry(entry man.Entry, username string, profiles map[string]networkProfile) bool {
+	if entry.DisplayName == username {
+		return true
+	}
+	profile, tagged := strings.CutPrefix(entry.DisplayName, username+"-")
+	_, known := profiles[profile]
+	return tagged && known
+}
+
+// endpointAddress returns the address to reach a cluster endpoint at, after
+// applying the connect alias's endpoint or the current profile's endpoint
+// overrides.
+func endpointAddress(endpoint string) string {
+	if endpointOverride != "" {
+		return endpointOverride
+	}
+	_, profile, err := currentProfile()
+	if err != nil {
+		return endpoint
+	}
+	if alt, ok := profile.EndpointOverrides[endpoint]; ok && alt != "" {
+		return alt
+	}
+	return endpoint
+}
+
+func (p networkProfile) resolve() (string, error) {
+	if !strings.HasPrefix(p.CIDR, "auto") {
+		_, network, err := net.ParseCIDR(p.CIDR)
+		if err != nil {
+			return "", fmt.Errorf("invalid CIDR %q", p.CIDR)
+		}
+		return network.String(), nil
+	}
+
+	bits := strings.TrimPrefix(strings.TrimPrefix(p.CIDR, "auto"), "/")
+	if bits == "" {
+		bits = "32"
+	}
+	ip, err := getCurrentPublicIP()
+	if err != nil {
+		return "", err
+	}
+	_, network, err := net.ParseCIDR(ip + "/" + bits)
+	if err != nil {
+		return "", fmt.Errorf("invalid CIDR %q", p.CIDR)
+	}
+	return network.String(), nil
+}
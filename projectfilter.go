@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gke-tool/pkg/gke"
+)
+
+// projectLabels, projectFolder and projectOrganization are --project-labels,
+// --folder and --organization.
+var projectLabels, projectFolder, projectOrganization string
+
+// projectFilter returns the filter for project listings: the config
+// file's projectFilter with the flags given taking precedence.
+func projectFilter() (gke.ProjectFilter, error) {
+	var filter gke.ProjectFilter
+	if cfg, err := loadUserConfig(); err == nil {
+		filter = cfg.ProjectFilter
+	}
+
+	if projectLabels != "" {
+		labels, err := parseLabelSelector(projectLabels)
+		if err != nil {
+			return filter, err
+		}
+		filter.Labels = labels
+	}
+	if projectFolder != "" && projectOrganization != "" {
+		return filter, usageError{fmt.Errorf("--folder and --organization are mutually exclusive")}
+	}
+	if projectFolder != "" {
+		filter.Folder, filter.Organization = projectFolder, ""
+	}
+	if projectOrganization != "" {
+		filter.Folder, filter.Organization = "", projectOrganization
+	}
+	return filter, nil
+}
+
+// parseLabelSelector parses comma-separated key=value or key terms into the
+// labels they require; a key alone only requires the label to be set.
+func parseLabelSelector(s string) (map[string]string, error) {
+	if strings.TrimSpace(s) == "" {
+		return nil, nil
+	}
+	labels := make(map[string]string)
+	for _, selector := range strings.Split(s, ",") {
+		key, value, _ := strings.Cut(strings.TrimSpace(selector), "=")
+		if key == "" {
+			return nil, usageError{fmt.Errorf("invalid label selector %q; want key=value or key", selector)}
+		}
+		labels[strings.TrimSpace(key)] = strings.TrimSpace(value)
+	}
+	return labels, nil
+}
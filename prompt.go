@@ -0,0 +1,48 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+
+	"gke-tool/pkg/man"
+	"golang.org/x/term"
+)
+
+// confirm asks a yes/no question on the terminal and defaults to no.
+func confirm(question string) bool {
+	fmt.Printf("%s [y/N]: ", question)
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	if err != nil {
+		return false
+	}
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
+
+// confirmShrink decides whether an update of the user's own entry may drop
+// other entries from a cluster's live list, including duplicates of it
+// being consolidated. The TUI replaces it with an
+// in-app prompt.
+var confirmShrink = promptShrink
+
+var promptMu sync.Mutex
+
+// promptShrink lists the entries that would disappear and asks on the
+// terminal. Without a terminal, as in daemon, watch and serve, it refuses.
+func promptShrink(dropped []man.Entry) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Printf("\n⚠️  Updating your entry would remove these entries, added since the list was read or left over as duplicates of yours:\n\n")
+	for _, entry := range dropped {
+		fmt.Printf("  - %-30s %s\n", entry.DisplayName, entry.CIDR)
+	}
+	fmt.Println()
+	return confirm("Remove these entries anyway?")
+}
@@ -0,0 +1,157 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/kubeconfig"
+)
+
+func newKubeconfigPruneCmd() *cobra.Command {
+	var yes, dryRun bool
+	cmd := &cobra.Command{
+		Use:   "prune",
+		Short: "Remove the contexts of clusters that no longer exist",
+		Long: `Checks every GKE context in kubeconfig, aliases included, against the
+clusters of its project and removes the contexts, cluster and user entries
+of clusters that were deleted. With kubeconfigDir set, the files of
+deleted clusters are removed instead. Projects that can't be listed are
+left alone.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runKubeconfigPrune(cmd.Context(), yes, dryRun)
+		}),
+	}
+	cmd.Flags().BoolVar(&yes, "yes", false, "remove without asking for confirmation")
+	cmd.Flags().BoolVar(&dryRun, "dry-run", false, "only list what would be removed")
+	return cmd
+}
+
+// staleContext is a kubeconfig context, or a per-cluster kubeconfig file,
+// of a deleted cluster.
+type staleContext struct {
+	ref     clusterRef
+	context kubeconfig.Context
+	path    string
+}
+
+func runKubeconfigPrune(ctx context.Context, yes, dryRun bool) error {
+	dir := kubeconfigDir()
+
+	var candidates []staleContext
+	if dir != "" {
+		targets, err := switchTargets()
+		if err != nil {
+			return err
+		}
+		for _, t := range targets {
+			candidates = append(candidates, staleContext{ref: t.ref, context: kubeconfig.Context{Name: t.context}, path: t.path})
+		}
+	} else {
+		contexts, err := kube.ContextEntries()
+		if err != nil {
+			return fmt.Errorf("failed to read kubeconfig: %v", err)
+		}
+		for _, c := range contexts {
+			if ref, ok := parseContextName(c.Cluster); ok {
+				candidates = append(candidates, staleContext{ref: ref, context: c})
+			}
+		}
+	}
+	if len(candidates) == 0 {
+		fmt.Println("✅ No GKE contexts in kubeconfig")
+		return nil
+	}
+
+	stale, err := findStaleContexts(ctx, candidates)
+	if err != nil {
+		return err
+	}
+	if len(stale) == 0 {
+		fmt.Printf("✅ All %s belong to existing clusters\n", pluralize(len(candidates), "GKE context"))
+		return nil
+	}
+
+	fmt.Printf("Contexts of deleted clusters:\n\n")
+	var names []string
+	for _, s := range stale {
+		label := s.context.Name
+		if s.path != "" {
+			label = s.path
+		}
+		fmt.Printf("  - %-50s %s\n", label, s.ref)
+		names = append(names, s.ref.String())
+	}
+	fmt.Println()
+	if dryRun {
+		return nil
+	}
+	req := newApproval("kubeconfig prune", fmt.Sprintf("Remove %s?", pluralize(len(stale), "context")), unique(names), nil)
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	if dir != "" {
+		for _, s := range stale {
+			if err := backupKubeconfig(s.path); err != nil {
+				return err
+			}
+			if err := os.Remove(s.path); err != nil {
+				return fmt.Errorf("failed to remove %s: %v", s.path, err)
+			}
+		}
+	} else {
+		if err := backupKubeconfig(sharedKubeconfigFile()); err != nil {
+			return err
+		}
+		var contexts []kubeconfig.Context
+		for _, s := range stale {
+			contexts = append(contexts, s.context)
+		}
+		if err := kube.Delete(contexts); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("✨ Removed %s; gke kubeconfig restore puts them back\n", pluralize(len(stale), "context"))
+	return nil
+}
+
+// findStaleContexts lists the clusters of each project the candidates
+// refer to and returns the candidates whose cluster is gone. Candidates in
+// locations left out by --region are kept.
+func findStaleContexts(ctx context.Context, candidates []staleContext) ([]staleContext, error) {
+	var projects []string
+	for _, c := range candidates {
+		projects = append(projects, c.ref.Project)
+	}
+	projects = unique(projects)
+	sort.Strings(projects)
+
+	existing := make(map[clusterRef]bool)
+	listed := make(map[string]bool)
+	for _, project := range projects {
+		// A listing with zones missing fails too: the clusters in those
+		// zones may well exist.
+		clusters, err := getClusters(ctx, project)
+		if err != nil {
+			fmt.Printf("⚠️  Leaving the contexts of %s alone: %v\n", project, err)
+			continue
+		}
+		listed[project] = true
+		for _, cluster := range clusters {
+			existing[clusterRef{Project: project, Location: cluster.Location, Cluster: cluster.Name}] = true
+		}
+	}
+
+	var stale []staleContext
+	for _, c := range candidates {
+		if listed[c.ref.Project] && locationListed(c.ref.Location) && !existing[c.ref] {
+			stale = append(stale, c)
+		}
+	}
+	sort.Slice(stale, func(i, j int) bool { return stale[i].context.Name < stale[j].context.Name })
+	return stale, nil
+}
@@ -0,0 +1,171 @@
+package main
+
+import (
+	"context"
+	"time"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"google.golang.org/api/container/v1"
+)
+
+// clusterRefreshInterval is how often the visible cluster list is re-fetched
+// in the background.
+const clusterRefreshInterval = 30 * time.Second
+
+// Change markers for clusters that differ from what was first shown.
+const (
+	clusterAdded   = "added"
+	clusterRemoved = "removed"
+	clusterChanged = "changed"
+)
+
+var (
+	addedStyle   = lipgloss.NewStyle().Foreground(lipgloss.Color("42"))
+	changedStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+	removedStyle = lipgloss.NewStyle().Faint(true).Strikethrough(true)
+)
+
+// Refresh messages carry the generation they were scheduled in, so that a
+// refresh loop started for an earlier visit of the cluster list stops.
+type clusterRefreshTickMsg struct{ gen int }
+
+// clustersLoadedMsg delivers the clusters of a project just opened.
+type clustersLoadedMsg struct {
+	gen      int
+	clusters []*container.Cluster
+	cached   bool
+	// stale is set when the cache was used because of being offline.
+	stale *staleListing
+	err   error
+}
+
+type clustersRefreshedMsg struct {
+	gen      int
+	clusters []*container.Cluster
+	err      error
+}
+
+// startClusterRefresh begins a new background refresh loop for the visible
+// cluster list, superseding any earlier one.
+func (m *model) startClusterRefresh() tea.Cmd {
+	m.refreshGen++
+	return scheduleClusterRefresh(m.refreshGen)
+}
+
+func scheduleClusterRefresh(gen int) tea.Cmd {
+	return tea.Tick(clusterRefreshInterval, func(time.Time) tea.Msg {
+		return clusterRefreshTickMsg{gen: gen}
+	})
+}
+
+func fetchClusters(gen int, projectID string) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		clusters, err := getClusters(ctx, projectID)
+		return clustersRefreshedMsg{gen: gen, clusters: clusters, err: err}
+	}
+}
+
+func clusterKey(cluster *container.Cluster) string {
+	return cluster.Location + "/" + cluster.Name
+}
+
+// mergeClusters folds a fresh listing into the visible one without
+// reordering it: new clusters are appended, vanished ones stay in place
+// marked as removed, and status changes are flagged. The cursor stays on
+// the cluster it was on.
+func (m *model) mergeClusters(fresh []*container.Cluster) {
+	var selected string
+	if m.cursor < len(m.clusters) {
+		selected = clusterKey(m.clusters[m.cursor])
+	}
+	if m.clusterChanges == nil {
+		m.clusterChanges = make(map[string]string)
+	}
+
+	byKey := make(map[string]*container.Cluster, len(fresh))
+	for _, cluster := range fresh {
+		byKey[clusterKey(cluster)] = cluster
+	}
+
+	seen := make(map[string]bool, len(m.clusters))
+	for i, old := range m.clusters {
+		key := clusterKey(old)
+		seen[key] = true
+		cluster, ok := byKey[key]
+		switch {
+		case !ok:
+			m.clusterChanges[key] = clusterRemoved
+		case cluster.Status != old.Status:
+			m.clusterChanges[key] = clusterChanged
+			m.clusters[i] = cluster
+		default:
+			m.clusters[i] = cluster
+			if m.clusterChanges[key] == clusterRemoved {
+				delete(m.clusterChanges, key)
+			}
+		}
+	}
+	for _, cluster := range fresh {
+		key := clusterKey(cluster)
+		switch {
+		case seen[key]:
+			continue
+		case !m.shows(cluster):
+			m.hiddenClusters[key] = true
+			continue
+		case m.hiddenClusters[key]:
+			delete(m.hiddenClusters, key)
+			m.clusterChanges[key] = clusterChanged
+		default:
+			m.clusterChanges[key] = clusterAdded
+		}
+		m.clusters = append(m.clusters, cluster)
+	}
+
+	m.choices = m.clusterLabels()
+	for i, cluster := range m.clusters {
+		if clusterKey(cluster) == selected {
+			m.cursor = i
+		}
+	}
+}
+
+// decorateCluster highlights a cluster label according to how it changed
+// since the list was first shown, and dims clusters that aren't running.
+func (m *model) decorateCluster(cluster *container.Cluster, label string) string {
+	switch m.clusterChanges[clusterKey(cluster)] {
+	case clusterAdded:
+		return addedStyle.Render(label + " (new)")
+	case clusterChanged:
+		return changedStyle.Render(label + " (" + cluster.Status + ")")
+	case clusterRemoved:
+		return removedStyle.Render(label) + " (deleted)"
+	}
+	if !clusterRunning(cluster) {
+		return inactiveStyle.Render(label)
+	}
+	return label
+}
+
+func (m *model) clusterRemoved(i int) bool {
+	return m.clusterChanges[clusterKey(m.clusters[i])] == clusterRemoved
+}
+
+// loadClustersCmd lists the clusters of projectID like listClustersCached,
+// in the background. Whether it went offline is applied when the
+// clustersLoadedMsg arrives.
+func loadClustersCmd(gen int, projectID string) tea.Cmd {
+	// The key and offline state are read here, on the TUI's goroutine.
+	key, isOffline := clustersKey(projectID), offline
+	ctx := withOffline(context.Background(), isOffline)
+	return func() tea.Msg {
+		var clusters []*container.Cluster
+		cached, stale, err := readThrough(key, &clusters, false, isOffline, func() (err error) {
+			clusters, err = getClusters(ctx, projectID)
+			return err
+		})
+		return clustersLoadedMsg{gen: gen, clusters: clusters, cached: cached, stale: stale, err: err}
+	}
+}
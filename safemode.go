@@ -0,0 +1,62 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"time"
+)
+
+// safeMode records data files that were found corrupted and left in place.
+// The tool keeps working with defaults for them but never writes them back.
+var safeMode = make(map[string]bool)
+
+// dataFile is a file the tool reads at startup and can regenerate.
+type dataFile struct {
+	name string
+	path func() (string, error)
+}
+
+var dataFiles = []dataFile{
+	{name: "config", path: configPath},
+	{name: "state", path: statePath},
+	{name: "history", path: historyPath},
+}
+
+// checkDataFiles looks for data files that exist but can't be parsed and
+// offers to quarantine them. Declining switches to safe mode instead of
+// failing later with a parse error.
+func checkDataFiles() {
+	for _, df := range dataFiles {
+		path, err := df.path()
+		if err != nil {
+			continue
+		}
+		data, err := os.ReadFile(path)
+		if err != nil || json.Valid(data) {
+			continue
+		}
+
+		fmt.Printf("⚠️  The %s file %s is corrupted.\n", df.name, path)
+		if confirm("Move it aside and start with a fresh one?") {
+			backup, err := quarantine(path)
+			if err == nil {
+				fmt.Printf("📦 Kept a copy at %s\n\n", backup)
+				continue
+			}
+			fmt.Printf("❌ %v\n", err)
+		}
+
+		fmt.Printf("🛟 Continuing in safe mode: %s will be ignored and not written.\n\n", df.name)
+		safeMode[df.name] = true
+	}
+}
+
+// quarantine renames a corrupted file to a timestamped backup next to it.
+func quarantine(path string) (string, error) {
+	backup := fmt.Sprintf("%s.corrupt-%s", path, time.Now().Format("20060102T150405"))
+	if err := os.Rename(path, backup); err != nil {
+		return "", fmt.Errorf("failed to quarantine %s: %v", path, err)
+	}
+	return backup, nil
+}
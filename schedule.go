@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// scheduleEntry is a recurring local time on a set of weekdays.
+type scheduleEntry struct {
+	Days string `json:"days"` // "Mon-Fri", "Sat,Sun", "daily"
+	At   string `json:"at"`   // "08:30"
+}
+
+var weekdayNames = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// parseDays expands a day specification into the set of weekdays it covers.
+func parseDays(spec string) (map[time.Weekday]bool, error) {
+	days := make(map[time.Weekday]bool)
+	spec = strings.ToLower(strings.TrimSpace(spec))
+	if spec == "" || spec == "daily" {
+		for d := time.Sunday; d <= time.Saturday; d++ {
+			days[d] = true
+		}
+		return days, nil
+	}
+
+	for _, part := range strings.Split(spec, ",") {
+		from, to, isRange := strings.Cut(strings.TrimSpace(part), "-")
+		start, ok := weekdayNames[from]
+		if !ok {
+			return nil, fmt.Errorf("unknown weekday %q", from)
+		}
+		end := start
+		if isRange {
+			if end, ok = weekdayNames[to]; !ok {
+				return nil, fmt.Errorf("unknown weekday %q", to)
+			}
+		}
+		for d := start; ; d = (d + 1) % 7 {
+			days[d] = true
+			if d == end {
+				break
+			}
+		}
+	}
+	return days, nil
+}
+
+// next returns the first time after now matching the entry, in now's
+// location.
+func (e scheduleEntry) next(now time.Time) (time.Time, error) {
+	at, err := time.Parse("15:04", e.At)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid time %q, expected HH:MM", e.At)
+	}
+	days, err := parseDays(e.Days)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	for i := 0; i <= 7; i++ {
+		day := now.AddDate(0, 0, i)
+		candidate := time.Date(day.Year(), day.Month(), day.Day(), at.Hour(), at.Minute(), 0, 0, now.Location())
+		if candidate.After(now) && days[candidate.Weekday()] {
+			return candidate, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("schedule %s %s never matches", e.Days, e.At)
+}
+
+// nextScheduledRun returns the earliest upcoming run across entries.
+func nextScheduledRun(entries []scheduleEntry, now time.Time) (time.Time, error) {
+	var earliest time.Time
+	for _, e := range entries {
+		t, err := e.next(now)
+		if err != nil {
+			return time.Time{}, err
+		}
+		if earliest.IsZero() || t.Before(earliest) {
+			earliest = t
+		}
+	}
+	return earliest, nil
+}
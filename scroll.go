@@ -0,0 +1,95 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// minListRows is the fewest choices shown at once, however short the
+// terminal.
+const minListRows = 3
+
+// listWindow returns the range of choices to show in rows lines, scrolled
+// just enough to keep the cursor in view, and remembers how many are shown
+// as the page size for pgup and pgdown. All choices are shown when they
+// fit or the terminal's height isn't known; otherwise two of the lines
+// are left for the scroll indicators.
+func (m *model) listWindow(rows int) (start, end int) {
+	if m.height == 0 || len(m.choices) <= rows {
+		m.pageSize, m.offset = 0, 0
+		return 0, len(m.choices)
+	}
+	rows -= 2
+	if rows < minListRows {
+		rows = minListRows
+	}
+	if rows > len(m.choices) {
+		rows = len(m.choices)
+	}
+	m.pageSize = rows
+	if m.cursor < m.offset {
+		m.offset = m.cursor
+	}
+	if m.cursor >= m.offset+rows {
+		m.offset = m.cursor - rows + 1
+	}
+	if m.offset > len(m.choices)-rows {
+		m.offset = len(m.choices) - rows
+	}
+	return m.offset, m.offset + rows
+}
+
+// renderList renders the choices that fit in rows lines, with the cursor,
+// and scroll indicators with a counter when some don't fit.
+func (m *model) renderList(rows int) string {
+	start, end := m.listWindow(rows)
+	var list strings.Builder
+	scrolling := start > 0 || end < len(m.choices)
+	if scrolling {
+		if start > 0 {
+			list.WriteString(fmt.Sprintf("  ↑ %d more\n", start))
+		} else {
+			list.WriteString("\n")
+		}
+	}
+	for i := start; i < end; i++ {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		// The first nine choices are numbered for picking them by digit.
+		number := " "
+		if i < 9 {
+			number = fmt.Sprint(i + 1)
+		}
+		list.WriteString(fmt.Sprintf("%s %s %s\n", cursor, number, m.choices[i]))
+	}
+	if scrolling {
+		more := ""
+		if end < len(m.choices) {
+			more = fmt.Sprintf("↓ %d more, ", len(m.choices)-end)
+		}
+		list.WriteString(fmt.Sprintf("  %s%d of %d (pgup/pgdown to page)\n", more, m.cursor+1, len(m.choices)))
+	}
+	return strings.TrimSuffix(list.String(), "\n")
+}
+
+// page moves the cursor a page up or down, or to the first or last choice
+// when the whole list is shown.
+func (m *model) page(down bool) {
+	size := m.pageSize
+	if size == 0 {
+		size = len(m.choices)
+	}
+	if down {
+		m.cursor += size
+	} else {
+		m.cursor -= size
+	}
+	if m.cursor > len(m.choices)-1 {
+		m.cursor = len(m.choices) - 1
+	}
+	if m.cursor < 0 {
+		m.cursor = 0
+	}
+}
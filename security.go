@@ -0,0 +1,64 @@
+package main
+
+import (
+	"strings"
+
+	"google.golang.org/api/container/v1"
+)
+
+// clusterSecurity is the state of the security features worth checking in
+// a review.
+type clusterSecurity struct {
+	// WorkloadPool is the Workload Identity pool, such as
+	// "my-project.svc.id.goog", or empty when Workload Identity is off.
+	WorkloadPool        string `json:"workloadPool,omitempty" yaml:"workloadPool,omitempty"`
+	ShieldedNodes       bool   `json:"shieldedNodes" yaml:"shieldedNodes"`
+	BinaryAuthorization bool   `json:"binaryAuthorization" yaml:"binaryAuthorization"`
+}
+
+func securityOf(cluster *container.Cluster) clusterSecurity {
+	var s clusterSecurity
+	if wi := cluster.WorkloadIdentityConfig; wi != nil {
+		s.WorkloadPool = wi.WorkloadPool
+	}
+	s.ShieldedNodes = cluster.ShieldedNodes != nil && cluster.ShieldedNodes.Enabled
+	if ba := cluster.BinaryAuthorization; ba != nil {
+		// Enabled is the older field, superseded by EvaluationMode.
+		s.BinaryAuthorization = ba.Enabled || (ba.EvaluationMode != "" && ba.EvaluationMode != "DISABLED" && ba.EvaluationMode != "EVALUATION_MODE_UNSPECIFIED")
+	}
+	return s
+}
+
+// summary lists the features in a few letters, e.g. "WI SN -BA" when
+// Binary Authorization is off.
+func (s clusterSecurity) summary() string {
+	var features []string
+	feature := func(name string, on bool) {
+		if !on {
+			name = "-" + name
+		}
+		features = append(features, name)
+	}
+	feature("WI", s.WorkloadPool != "")
+	feature("SN", s.ShieldedNodes)
+	feature("BA", s.BinaryAuthorization)
+	return strings.Join(features, " ")
+}
+
+// securityDetails adds the security features to the detail pane.
+func securityDetails(cluster *container.Cluster, row func(label, value string)) {
+	onOff := func(on bool) string {
+		if on {
+			return "on"
+		}
+		return "off"
+	}
+	s := securityOf(cluster)
+	workloadIdentity := "off"
+	if s.WorkloadPool != "" {
+		workloadIdentity = "on (" + s.WorkloadPool + ")"
+	}
+	row("Workload Identity", workloadIdentity)
+	row("Shielded nodes", onOff(s.ShieldedNodes))
+	row("Binary Auth", onOff(s.BinaryAuthorization))
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/subtle"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sort"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+)
+
+const defaultServeAddr = "127.0.0.1:7878"
+
+// serveConfig configures `gke serve`.
+type serveConfig struct {
+	Listen string `json:"listen,omitempty"`
+
+	// Allow lists the JSON-RPC methods clients may call. When empty only
+	// read-only methods are allowed; mutating methods must always be listed
+	// explicitly.
+	Allow []string `json:"allow,omitempty"`
+}
+
+// rpcMethod is a method exposed over JSON-RPC.
+type rpcMethod struct {
+	mutating bool
+	call     func(ctx context.Context, params json.RawMessage) (interface{}, error)
+}
+
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params"`
+	ID      json.RawMessage `json:"id"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcResponse struct {
+	JSONRPC string          `json:"jsonrpc"`
+	Result  interface{}     `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+	ID      json.RawMessage `json:"id"`
+}
+
+// JSON-RPC 2.0 error codes, plus one for rejected methods.
+const (
+	rpcParseError     = -32700
+	rpcMethodNotFound = -32601
+	rpcInvalidParams  = -32602
+	rpcInternalError  = -32603
+	rpcForbidden      = -32001
+)
+
+type clusterParams struct {
+	Project  string `json:"project"`
+	Location string `json:"location"`
+	Cluster  string `json:"cluster"`
+}
+
+var rpcMethods = map[string]rpcMethod{
+	"listProjects": {call: func(ctx context.Context, _ json.RawMessage) (interface{}, error) {
+		return getProjects(ctx)
+	}},
+	"listClusters": {call: func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p clusterParams
+		if err := json.Unmarshal(params, &p); err != nil || p.Project == "" {
+			return nil, errInvalidParams
+		}
+		clusters, err := getClusters(ctx, p.Project)
+		if err != nil {
+			return nil, err
+		}
+		var refs []clusterRef
+		for _, cluster := range clusters {
+			refs = append(refs, clusterRef{Project: p.Project, Location: cluster.Location, Cluster: cluster.Name})
+		}
+		return refs, nil
+	}},
+	"getAuthorizedNetworks": {call: func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p clusterParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, errInvalidParams
+		}
+		_, cluster, err := resolveCluster(ctx, p.Project, p.Location, p.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		return gke.AuthorizedEntries(cluster), nil
+	}},
+	"updateMyIP": {mutating: true, call: func(ctx context.Context, params json.RawMessage) (interface{}, error) {
+		var p clusterParams
+		if err := json.Unmarshal(params, &p); err != nil {
+			return nil, errInvalidParams
+		}
+		config, cluster, err := resolveCluster(ctx, p.Project, p.Location, p.Cluster)
+		if err != nil {
+			return nil, err
+		}
+		if !gke.HasAuthorizedNetworks(cluster) {
+			return nil, fmt.Errorf("authorized networks are not enabled on %s", config.Cluster)
+		}
+		if config.Username, err = getUsername(ctx); err != nil {
+			return nil, err
+		}
+		return true, updateAuthorizedNetworks(ctx, config, cluster, nil)
+	}},
+}
+
+var errInvalidParams = errors.New("invalid params")
+
+func newServeCmd() *cobra.Command {
+	var listen string
+	var rotate bool
+	var debugAddr string
+	cmd := &cobra.Command{
+		Use:   "serve",
+		Short: "Serve a local JSON-RPC API for IDE integrations",
+		Args:  cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runServe(cmd.Context(), listen, rotate, debugAddr)
+		}),
+	}
+	cmd.Flags().StringVar(&listen, "listen", "", "address to listen on (default "+defaultServeAddr+")")
+	cmd.Flags().BoolVar(&rotate, "rotate-token", false, "generate a new auth token before starting")
+	addDebugFlag(cmd, &debugAddr)
+	return cmd
+}
+
+// runServe exposes a JSON-RPC 2.0 API on localhost for IDE integrations.
+// Every request must carry the locally generated token, and only the
+// methods allowed by the config can be called.
+func runServe(ctx context.Context, listen string, rotate bool, debugAddr string) error {
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	addr := listen
+	if addr == "" {
+		addr = cfg.Serve.Listen
+	}
+	if addr == "" {
+		addr = defaultServeAddr
+	}
+	if err := requireLoopback(addr); err != nil {
+		return err
+	}
+
+	token, tokenFile, err := serveToken(rotate)
+	if err != nil {
+		return err
+	}
+	allowed := allowedMethods(cfg.Serve.Allow)
+
+	mux := http.NewServeMux()
+	mux.Handle("/rpc", rpcHandler(token, allowed))
+	server := &http.Server{Addr: addr, Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := startDebugServer(ctx, debugAddr); err != nil {
+		return err
+	}
+	go func() {
+		<-ctx.Done()
+		server.Shutdown(context.Background())
+	}()
+
+	var names []string
+	for name := range allowed {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	slog.Info("serving JSON-RPC", "url", "http://"+addr+"/rpc", "token", tokenFile, "allowed", strings.Join(names, ","))
+
+	if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		return err
+	}
+	return nil
+}
+
+// allowedMethods resolves the configured allow-list. Unknown names are
+// ignored; an empty list means every read-only method.
+func allowedMethods(allow []string) map[string]bool {
+	allowed := make(map[string]bool)
+	if len(allow) == 0 {
+		for name, method := range rpcMethods {
+			if !method.mutating {
+				allowed[name] = true
+			}
+		}
+		return allowed
+	}
+	for _, name := range allow {
+		if _, ok := rpcMethods[name]; ok {
+			allowed[name] = true
+		}
+	}
+	return allowed
+}
+
+func rpcHandler(token string, allowed map[string]bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		presented := strings.TrimPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(presented), []byte(token)) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+
+		debugRPCRequests.Add(1)
+		var req rpcRequest
+		resp := rpcResponse{JSONRPC: "2.0"}
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1<<20)).Decode(&req); err != nil {
+			resp.Error = &rpcError{Code: rpcParseError, Message: "parse error"}
+			writeRPC(w, resp)
+			return
+		}
+		resp.ID = req.ID
+
+		method, ok := rpcMethods[req.Method]
+		switch {
+		case !ok:
+			resp.Error = &rpcError{Code: rpcMethodNotFound, Message: "method not found"}
+		case !allowed[req.Method]:
+			resp.Error = &rpcError{Code: rpcForbidden, Message: "method not allowed by serve.allow"}
+		default:
+			result, err := method.call(r.Context(), req.Params)
+			switch {
+			case err == errInvalidParams:
+				resp.Error = &rpcError{Code: rpcInvalidParams, Message: err.Error()}
+			case err != nil:
+				resp.Error = &rpcError{Code: rpcInternalError, Message: err.Error()}
+			default:
+				resp.Result = result
+			}
+		}
+		slog.Info("rpc", "method", req.Method, "outcome", rpcOutcome(resp))
+		writeRPC(w, resp)
+	})
+}
+
+func rpcOutcome(resp rpcResponse) string {
+	if resp.Error != nil {
+		return fmt.Sprintf("error %d: %s", resp.Error.Code, resp.Error.Message)
+	}
+	return "ok"
+}
+
+func writeRPC(w http.ResponseWriter, resp rpcResponse) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(resp)
+}
+
+// requireLoopback refuses to expose the API beyond this machine.
+func requireLoopback(addr string) error {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return fmt.Errorf("invalid listen address %q: %v", addr, err)
+	}
+	if host == "localhost" {
+		return nil
+	}
+	if ip := net.ParseIP(host); ip == nil || !ip.IsLoopback() {
+		return fmt.Errorf("refusing to listen on %s: only loopback addresses are allowed", addr)
+	}
+	return nil
+}
+
+// serveToken returns the local auth token, generating it on first use or
+// when rotate is set. The file is readable by the current user only.
+func serveToken(rotate bool) (token, path string, err error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", "", err
+	}
+	path = filepath.Join(dir, "my-gke", "serve-token")
+
+	if !rotate {
+		if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+			return strings.TrimSpace(string(data)), path, nil
+		}
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return "", "", fmt.Errorf("failed to generate token: %v", err)
+	}
+	token = hex.EncodeToString(buf)
+
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return "", "", err
+	}
+	if err := os.WriteFile(path, []byte(token+"\n"), 0o600); err != nil {
+		return "", "", fmt.Errorf("failed to write token: %v", err)
+	}
+	return token, path, nil
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"os/signal"
+	"syscall"
+
+	"gke-tool/pkg/man"
+)
+
+// runSession starts the user's shell, or the then command when given, with
+// config's context selected. With revoke, the user's authorized network
+// entry is removed once it exits or this process is interrupted or
+// terminated. A failing then command is a childError.
+func runSession(ctx context.Context, config GKEConfig, revoke bool, then string) error {
+	var entry man.Entry
+	if revoke {
+		var err error
+		if entry, err = myEntry(config.Username); err != nil {
+			return err
+		}
+	}
+
+	shell := os.Getenv("SHELL")
+	if shell == "" {
+		shell = "/bin/sh"
+	}
+	cmd := exec.Command(shell)
+	if then != "" {
+		cmd = exec.Command("sh", "-c", then)
+	}
+	cmd.Stdin, cmd.Stdout, cmd.Stderr = os.Stdin, os.Stdout, os.Stderr
+	cmd.Env = append(os.Environ(), "MY_GKE_SESSION="+contextName(config), "MY_GKE_CLUSTER="+config.Cluster)
+	if path := kubeconfigPath(config); path != "" {
+		cmd.Env = append(cmd.Env, "KUBECONFIG="+path)
+	}
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(signals)
+
+	switch {
+	case then != "" && revoke:
+		fmt.Printf("🚀 Running %s, your access is revoked when it exits\n\n", then)
+	case then != "":
+		fmt.Printf("🚀 Running %s\n\n", then)
+	case revoke:
+		fmt.Printf("🐚 Starting a session shell for %s, exit it to revoke your access\n\n", config.Cluster)
+	case kubeconfigFlag != "":
+		fmt.Printf("🐚 Starting a shell for %s with a private kubeconfig, exit it to discard the credentials\n\n", config.Cluster)
+	default:
+		fmt.Printf("🐚 Starting a shell for %s\n\n", config.Cluster)
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("failed to start %s: %v", cmd.Args[0], err)
+	}
+
+	done := make(chan error, 1)
+	go func() { done <- cmd.Wait() }()
+	var err error
+	select {
+	case err = <-done:
+	case sig := <-signals:
+		fmt.Printf("\n⚠️  Received %v, ending the session\n", sig)
+		if then != "" {
+			cmd.Process.Signal(sig)
+		} else {
+			cmd.Process.Signal(syscall.SIGHUP)
+		}
+		err = <-done
+	}
+	// The shell's exit status is that of whatever ran last in it, which
+	// isn't ours to report.
+	if then == "" {
+		err = nil
+	}
+	if exitErr, ok := err.(*exec.ExitError); ok {
+		err = childError{exitErr}
+	}
+	if !revoke {
+		return err
+	}
+
+	fmt.Printf("\n🔒 Removing %s (%s) from %s...\n", entry.DisplayName, entry.CIDR, config.Cluster)
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	if err := revokeEntry(ctx, ref, entry); err != nil {
+		return fmt.Errorf("failed to revoke access, remove %s manually: %w", entry.CIDR, err)
+	}
+	fmt.Printf("✨ Access revoked\n")
+	return err
+}
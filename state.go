@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// state is the small amount of data remembered between runs.
+type state struct {
+	// Namespaces maps kubeconfig context names to the namespace last used
+	// with them.
+	Namespaces map[string]string `json:"namespaces,omitempty"`
+
+	// Grants are time-boxed authorized network entries awaiting removal.
+	Grants []grant `json:"grants,omitempty"`
+
+	// Latencies caches the measured round-trip time to each region.
+	Latencies map[string]latency `json:"latencies,omitempty"`
+
+	// Projects and Clusters are the last listings seen, for shell
+	// completion.
+	Projects []string     `json:"projects,omitempty"`
+	Clusters []clusterRef `json:"clusters,omitempty"`
+
+	// ProjectFailures are the projects whose listing keeps failing.
+	ProjectFailures map[string]projectFailure `json:"projectFailures,omitempty"`
+
+	// Favorites are the clusters starred in the picker, listed above the
+	// projects.
+	Favorites []clusterRef `json:"favorites,omitempty"`
+
+	// KubeconfigBackups are the kubeconfig backups kept, oldest first.
+	KubeconfigBackups []kubeconfigBackup `json:"kubeconfigBackups,omitempty"`
+
+	// Hibernations are the clusters scaled to zero by gke hibernate, with
+	// the sizes to resume them to.
+	Hibernations []hibernation `json:"hibernations,omitempty"`
+}
+
+// stateMu serializes read-modify-write cycles of the state file within the
+// process, for updates made from concurrent listings.
+var stateMu sync.Mutex
+
+func statePath() (string, error) {
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(dir, "my-gke", "state.json"), nil
+}
+
+// loadState reads the state file. A missing file yields empty state.
+func loadState() (*state, error) {
+	st := &state{}
+	path, err := statePath()
+	if err != nil {
+		return st, err
+	}
+
+	if safeMode["state"] {
+		return st, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return st, fmt.Errorf("failed to read state: %v", err)
+	}
+	if err := json.Unmarshal(data, st); err != nil {
+		return &state{}, fmt.Errorf("failed to parse %s: %v", path, err)
+	}
+	return st, nil
+}
+
+func (st *state) save() error {
+	if safeMode["state"] {
+		return nil
+	}
+	path, err := statePath()
+	if err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0o700); err != nil {
+		return fmt.Errorf("failed to create state directory: %v", err)
+	}
+
+	data, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Writing a temporary file and renaming it over the state means a
+	// crash or another process never leaves a half-written file behind.
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".state-")
+	if err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+	_, err = tmp.Write(data)
+	if cerr := tmp.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	if err := os.Rename(tmp.Name(), path); err != nil {
+		return fmt.Errorf("failed to write state: %v", err)
+	}
+	return nil
+}
+
+func (st *state) namespace(context string) string {
+	return st.Namespaces[context]
+}
+
+func (st *state) rememberNamespace(context, namespace string) {
+	if namespace == "" {
+		return
+	}
+	if st.Namespaces == nil {
+		st.Namespaces = make(map[string]string)
+	}
+	st.Namespaces[context] = namespace
+}
+
+// saveNamespace remembers namespace for context in the state file, keeping
+// whatever else other commands or workers saved meanwhile. A state file
+// that can't be read is left as it is.
+func saveNamespace(context, namespace string) error {
+	if namespace == "" {
+		return nil
+	}
+	stateMu.Lock()
+	defer stateMu.Unlock()
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	st.rememberNamespace(context, namespace)
+	return st.save()
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+func newStatusCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "status",
+		Short: "Show the current identity, network, context and time-boxed grants",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runStatus(cmd.Context())
+		},
+	}
+}
+
+func runStatus(ctx context.Context) error {
+	username, err := getUsername(ctx)
+	if err != nil {
+		username = "unknown (" + err.Error() + ")"
+	}
+	fmt.Printf("👤 User:      %s\n", username)
+
+	profile, _, err := currentProfile()
+	switch {
+	case err != nil:
+		fmt.Printf("🧭 Profile:   %v\n", err)
+	case profile != "":
+		fmt.Printf("🧭 Profile:   %s\n", profile)
+	}
+
+	if entry, err := myEntry(username); err != nil {
+		fmt.Printf("🌐 Network:   unknown (%v)\n", err)
+	} else {
+		fmt.Printf("🌐 Network:   %s as %s\n", entry.CIDR, entry.DisplayName)
+	}
+
+	if current, err := kube.CurrentContext(); err != nil {
+		fmt.Printf("📝 Context:   none\n")
+	} else {
+		fmt.Printf("📝 Context:   %s\n", current)
+	}
+
+	if len(safeMode) > 0 {
+		var names []string
+		for name := range safeMode {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		fmt.Printf("🛟 Safe mode: ignoring %s\n", strings.Join(names, ", "))
+	}
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Pinned) > 0 {
+		fmt.Printf("\n📌 Pinned clusters:\n")
+		for _, ref := range cfg.Pinned {
+			fmt.Printf("  %s\n", ref)
+		}
+	}
+
+	st, err := loadState()
+	if err != nil {
+		return err
+	}
+	if len(st.Grants) > 0 {
+		fmt.Printf("\n⏰ Time-boxed access:\n")
+		for _, g := range st.Grants {
+			fmt.Printf("  %s  %s (%s) expires %s, %s\n",
+				g.Cluster, g.Entry.DisplayName, g.Entry.CIDR, formatTime(g.Expires), formatRelative(g.Expires))
+		}
+	}
+	return nil
+}
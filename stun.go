@@ -0,0 +1,137 @@
+package main
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"time"
+)
+
+const defaultSTUNServer = "stun.l.google.com:19302"
+
+// STUN (RFC 5389) message constants used for a binding request.
+const (
+	stunBindingRequest  = 0x0001
+	stunBindingSuccess  = 0x0101
+	stunMagicCookie     = 0x2112A442
+	stunMappedAddress   = 0x0001
+	stunXorMappedAddr   = 0x0020
+	stunHeaderLength    = 20
+	stunAttempts        = 3
+	stunAttemptDeadline = 2 * time.Second
+)
+
+// getSTUNPublicIP discovers the public IP with a STUN binding request, for
+// networks that filter HTTP egress but allow UDP to STUN servers.
+func getSTUNPublicIP(server string) (string, error) {
+	if server == "" {
+		server = defaultSTUNServer
+	}
+
+	// Authorized networks take IPv4 CIDRs only, so the mapping must be too.
+	conn, err := net.Dial("udp4", server)
+	if err != nil {
+		return "", fmt.Errorf("failed to reach STUN server %s: %v", server, err)
+	}
+	defer conn.Close()
+
+	request := make([]byte, stunHeaderLength)
+	binary.BigEndian.PutUint16(request[0:], stunBindingRequest)
+	binary.BigEndian.PutUint32(request[4:], stunMagicCookie)
+	if _, err := rand.Read(request[8:20]); err != nil {
+		return "", err
+	}
+
+	response := make([]byte, 1500)
+	for attempt := 0; attempt < stunAttempts; attempt++ {
+		if _, err := conn.Write(request); err != nil {
+			return "", fmt.Errorf("failed to send STUN request: %v", err)
+		}
+		conn.SetReadDeadline(time.Now().Add(stunAttemptDeadline))
+		n, err := conn.Read(response)
+		if err != nil {
+			continue // UDP: lost packets are retried
+		}
+		if ip, err := parseSTUNResponse(response[:n], request[8:20]); err == nil {
+			if ip.To4() == nil {
+				return "", fmt.Errorf("STUN server %s mapped an IPv6 address, %s", server, ip)
+			}
+			return ip.To4().String(), nil
+		} else if attempt == stunAttempts-1 {
+			return "", err
+		}
+	}
+	return "", fmt.Errorf("no response from STUN server %s", server)
+}
+
+func parseSTUNResponse(msg, transactionID []byte) (net.IP, error) {
+	if len(msg) < stunHeaderLength ||
+		binary.BigEndian.Uint16(msg[0:]) != stunBindingSuccess ||
+		binary.BigEndian.Uint32(msg[4:]) != stunMagicCookie ||
+		!bytes.Equal(msg[8:20], transactionID) {
+		return nil, fmt.Errorf("unexpected STUN response")
+	}
+
+	var mapped net.IP
+	attrs := msg[stunHeaderLength:]
+	for len(attrs) >= 4 {
+		attrType := binary.BigEndian.Uint16(attrs[0:])
+		attrLen := int(binary.BigEndian.Uint16(attrs[2:]))
+		if len(attrs) < 4+attrLen {
+			break
+		}
+		value := attrs[4 : 4+attrLen]
+
+		switch attrType {
+		case stunXorMappedAddr:
+			if ip := stunAddress(value, msg[4:20]); ip != nil {
+				return ip, nil
+			}
+		case stunMappedAddress:
+			mapped = stunAddress(value, nil)
+		}
+
+		// Attributes are padded to a multiple of four bytes.
+		next := 4 + (attrLen+3)&^3
+		if next > len(attrs) {
+			break
+		}
+		attrs = attrs[next:]
+	}
+
+	if mapped == nil {
+		return nil, fmt.Errorf("STUN response carries no mapped address")
+	}
+	return mapped, nil
+}
+
+// stunAddress decodes a (XOR-)MAPPED-ADDRESS value. xorKey is the magic
+// cookie followed by the transaction ID, or nil for the plain variant.
+func stunAddress(value, xorKey []byte) net.IP {
+	if len(value) < 8 {
+		return nil
+	}
+	var size int
+	switch value[1] {
+	case 0x01:
+		size = net.IPv4len
+	case 0x02:
+		size = net.IPv6len
+	default:
+		return nil
+	}
+	if len(value) < 4+size {
+		return nil
+	}
+
+	ip := make(net.IP, size)
+	copy(ip, value[4:4+size])
+	if xorKey != nil {
+		for i := range ip {
+			ip[i] ^= xorKey[i]
+		}
+	}
+	return ip
+}
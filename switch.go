@@ -0,0 +1,242 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/spf13/cobra"
+	"golang.org/x/term"
+)
+
+// switchTarget is a context written by gke that `gke switch` can make
+// current.
+type switchTarget struct {
+	context   string
+	ref       clusterRef
+	namespace string
+	// path is the cluster's own kubeconfig file when kubeconfigDir is set.
+	path    string
+	current bool
+}
+
+func (t switchTarget) label() string {
+	label := t.context
+	if t.context != contextName(GKEConfig{ProjectID: t.ref.Project, Region: t.ref.Location, Cluster: t.ref.Cluster}) {
+		label += " (" + t.ref.String() + ")"
+	}
+	if t.namespace != "" {
+		label += " (ns: " + t.namespace + ")"
+	}
+	if t.current {
+		label += " [current]"
+	}
+	return label
+}
+
+func newSwitchCmd() *cobra.Command {
+	var list bool
+	cmd := &cobra.Command{
+		Use:   "switch [CONTEXT]",
+		Short: "Switch between clusters already configured, without any API calls",
+		Long: `Lists the kubeconfig contexts gke wrote, including their aliases, and makes
+the picked one current. CONTEXT may also be a cluster name. Nothing is
+fetched from Google Cloud, so switching is instant; use connect when your
+IP or credentials need refreshing.`,
+		Args:        cobra.MaximumNArgs(1),
+		Annotations: map[string]string{skipAuth: "true"},
+		ValidArgsFunction: func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+			targets, _ := switchTargets()
+			var names []string
+			for _, t := range targets {
+				names = append(names, t.context)
+			}
+			return names, cobra.ShellCompDirectiveNoFileComp
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			name := ""
+			if len(args) == 1 {
+				name = args[0]
+			}
+			return runSwitch(name, list)
+		},
+	}
+	cmd.Flags().BoolVarP(&list, "list", "l", false, "only list the contexts")
+	return cmd
+}
+
+// switchTargets returns the contexts gke wrote: from the cluster files in
+// kubeconfigDir when it is set, else the contexts of the shared kubeconfig
+// that use a GKE cluster entry.
+func switchTargets() ([]switchTarget, error) {
+	var targets []switchTarget
+	if dir := kubeconfigDir(); dir != "" {
+		paths, err := filepath.Glob(filepath.Join(dir, "gke_*.yaml"))
+		if err != nil {
+			return nil, err
+		}
+		for _, path := range paths {
+			name := strings.TrimSuffix(filepath.Base(path), ".yaml")
+			if ref, ok := parseContextName(name); ok {
+				targets = append(targets, switchTarget{context: name, ref: ref, path: path, current: os.Getenv("KUBECONFIG") == path})
+			}
+		}
+		return targets, nil
+	}
+
+	contexts, err := kube.ContextEntries()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read kubeconfig: %v", err)
+	}
+	current, _ := kube.CurrentContext()
+	for _, c := range contexts {
+		if ref, ok := parseContextName(c.Cluster); ok {
+			targets = append(targets, switchTarget{context: c.Name, ref: ref, namespace: c.Namespace, current: c.Name == current})
+		}
+	}
+	sort.Slice(targets, func(i, j int) bool { return targets[i].context < targets[j].context })
+	return targets, nil
+}
+
+// findSwitchTarget picks the target called name, or else the only one of
+// the cluster called name.
+func findSwitchTarget(targets []switchTarget, name string) (switchTarget, error) {
+	var matches []switchTarget
+	for _, t := range targets {
+		if t.context == name {
+			return t, nil
+		}
+		if t.ref.Cluster == name {
+			matches = append(matches, t)
+		}
+	}
+	switch len(matches) {
+	case 0:
+		return switchTarget{}, fmt.Errorf("no context %s written by gke; see gke switch --list", name)
+	case 1:
+		return matches[0], nil
+	}
+	var names []string
+	for _, t := range matches {
+		names = append(names, t.context)
+	}
+	return switchTarget{}, usageError{fmt.Errorf("%s is ambiguous: %s", name, strings.Join(names, ", "))}
+}
+
+func runSwitch(name string, list bool) error {
+	targets, err := switchTargets()
+	if err != nil {
+		return err
+	}
+	if len(targets) == 0 {
+		fmt.Println("No contexts written by gke yet; run gke connect first")
+		return nil
+	}
+
+	if list {
+		for _, t := range targets {
+			fmt.Println(t.label())
+		}
+		return nil
+	}
+
+	var target switchTarget
+	switch {
+	case name != "":
+		if target, err = findSwitchTarget(targets, name); err != nil {
+			return err
+		}
+	case term.IsTerminal(int(os.Stdin.Fd())):
+		picked, err := pickSwitchTarget(targets)
+		if err != nil || picked == nil {
+			return err
+		}
+		target = *picked
+	default:
+		return usageError{fmt.Errorf("no context given")}
+	}
+	return switchTo(target)
+}
+
+func switchTo(target switchTarget) error {
+	if target.path != "" {
+		fmt.Printf("📄 %s has its own kubeconfig; to use it:\n   export KUBECONFIG=%s\n", target.context, target.path)
+		return nil
+	}
+	if err := kube.UseContext(target.context); err != nil {
+		return err
+	}
+	if target.namespace != "" {
+		fmt.Printf("🔀 Switched to %s (ns: %s)\n", target.context, target.namespace)
+	} else {
+		fmt.Printf("🔀 Switched to %s\n", target.context)
+	}
+	return nil
+}
+
+// switchModel is the context picker of `gke switch`.
+type switchModel struct {
+	targets []switchTarget
+	cursor  int
+	picked  *switchTarget
+}
+
+func pickSwitchTarget(targets []switchTarget) (*switchTarget, error) {
+	m := &switchModel{targets: targets}
+	for i, t := range targets {
+		if t.current {
+			m.cursor = i
+		}
+	}
+	if _, err := tea.NewProgram(m).Run(); err != nil {
+		return nil, fmt.Errorf("running program: %v", err)
+	}
+	return m.picked, nil
+}
+
+func (m *switchModel) Init() tea.Cmd {
+	return nil
+}
+
+func (m *switchModel) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	key, ok := msg.(tea.KeyMsg)
+	if !ok {
+		return m, nil
+	}
+	switch key.String() {
+	case "ctrl+c", "q", "esc":
+		return m, tea.Quit
+	case "up", "k":
+		if m.cursor > 0 {
+			m.cursor--
+		}
+	case "down", "j":
+		if m.cursor < len(m.targets)-1 {
+			m.cursor++
+		}
+	case "enter":
+		m.picked = &m.targets[m.cursor]
+		return m, tea.Quit
+	}
+	return m, nil
+}
+
+func (m *switchModel) View() string {
+	if m.picked != nil {
+		return ""
+	}
+	var s strings.Builder
+	s.WriteString("Select using ↑/↓ arrows and enter to confirm\n\nSwitch to context:\n\n")
+	for i, t := range m.targets {
+		cursor := " "
+		if m.cursor == i {
+			cursor = ">"
+		}
+		s.WriteString(fmt.Sprintf("%s %s\n", cursor, t.label()))
+	}
+	s.WriteString("\n(press q to quit)\n")
+	return s.String()
+}
@@ -0,0 +1,79 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"gke-tool/pkg/gke"
+	"google.golang.org/api/container/v1"
+)
+
+// defaultProject returns the project configured in gcloud or the
+// environment, for commands invoked without --project.
+func defaultProject() string {
+	for _, env := range []string{"CLOUDSDK_CORE_PROJECT", "GOOGLE_CLOUD_PROJECT"} {
+		if project := os.Getenv(env); project != "" {
+			return project
+		}
+	}
+	if !hasGcloud() {
+		return ""
+	}
+	output, err := exec.Command("gcloud", "config", "get-value", "project").Output()
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(output))
+}
+
+// resolveCluster finds a cluster by name for non-interactive commands. When
+// location is empty every location in the project is searched, and an error
+// is returned if the name is ambiguous.
+func resolveCluster(ctx context.Context, projectID, location, name string) (GKEConfig, *container.Cluster, error) {
+	if projectID == "" {
+		projectID = defaultProject()
+	}
+	if projectID == "" {
+		return GKEConfig{}, nil, fmt.Errorf("no project given; pass --project")
+	}
+	projectID, err := resolveProject(ctx, projectID)
+	if err != nil {
+		return GKEConfig{}, nil, err
+	}
+	if name == "" {
+		return GKEConfig{}, nil, fmt.Errorf("no cluster given; pass --cluster")
+	}
+
+	clusters, err := getClusters(ctx, projectID)
+	if err != nil {
+		return GKEConfig{}, nil, err
+	}
+
+	cluster, err := gke.FindCluster(clusters, projectID, location, name)
+	if err != nil {
+		return GKEConfig{}, nil, err
+	}
+
+	config := GKEConfig{
+		ProjectID: projectID,
+		Region:    cluster.Location,
+		Cluster:   cluster.Name,
+	}
+	return config, cluster, nil
+}
+
+// resolveProject expands a glob such as "payments-*" into exactly one
+// active project ID. Patterns without wildcards are returned unchanged.
+func resolveProject(ctx context.Context, pattern string) (string, error) {
+	if !strings.ContainsAny(pattern, "*?[") {
+		return pattern, nil
+	}
+	api, err := projectAPI(ctx)
+	if err != nil {
+		return "", err
+	}
+	return gke.ResolveProject(ctx, api, pattern)
+}
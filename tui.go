@@ -0,0 +1,1342 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+	"github.com/charmbracelet/bubbles/spinner"
+	tea "github.com/charmbracelet/bubbletea"
+	"gke-tool/pkg/gke"
+	"gke-tool/pkg/man"
+	"golang.org/x/exp/slog"
+	"google.golang.org/api/container/v1"
+)
+
+type model struct {
+	choices     []string
+	cursor      int
+	selected    string
+	step        string
+	projects    []string
+	projectRows []string
+	favorites   []clusterRef
+	recent      []clusterRef
+	// clustersCached is set while the clusters shown come from the cache.
+	clustersCached   bool
+	configurations   []gcloudConfiguration
+	accounts         []gcloudAccount
+	preferredProject string
+	pendingConfig    GKEConfig
+	pendingCluster   *container.Cluster
+	// queued is set when the picked cluster's update waits for an
+	// operation already running on it.
+	queued         bool
+	preview        string
+	clusters       []*container.Cluster
+	clusterChanges map[string]string
+	// hiddenClusters are the clusters left out for not running, when
+	// hideNotRunning is set, or for not matching labelFilter. allClusters
+	// is the latest listing, hidden clusters included.
+	hideNotRunning bool
+	hiddenClusters map[string]bool
+	allClusters    []*container.Cluster
+	// labelFilter are the resource labels clusters must have, edited as
+	// labelInput after pressing l.
+	labelFilter map[string]string
+	// poolsOf is the cluster whose node pools are listed, under
+	// poolHeader.
+	poolsOf    string
+	poolHeader string
+	// sortKey is the cluster order, cycled with s.
+	sortKey       string
+	labelInput    string
+	editingLabels bool
+	labelErr      error
+	latencies     map[string]time.Duration
+	// upgrades are the available control-plane upgrades, by clusterKey.
+	upgrades map[string]clusterUpgrades
+	// posture are the Security Posture findings counts, by clusterKey.
+	posture map[string]postureCounts
+	// insights are the Recommender insight summaries, by clusterKey.
+	insights map[string][]string
+	// script holds the commands connecting would run, with
+	// --print-commands.
+	script string
+	// costs are the estimated monthly costs, by clusterKey.
+	costs      map[string]string
+	refreshGen int
+	projectID  string
+	loading    bool
+	progress   gke.Progress
+	bar        progress.Model
+	spinner    spinner.Model
+	// width and height are the terminal's, for laying out the cluster
+	// detail pane and scrolling long lists. offset is the first choice
+	// shown and pageSize how many are, 0 when they all fit.
+	width    int
+	height   int
+	offset   int
+	pageSize int
+	// pendingG is set after a g, so a second one jumps to the top.
+	pendingG bool
+	program  *tea.Program
+
+	// connected is the cluster configured successfully, if any.
+	connected *GKEConfig
+	// namespaces are offered after connecting when --pick-namespace is set.
+	namespaces []string
+	// err is why the picker ended without connecting, if it did.
+	err error
+	// failure is the error shown in place of the picker, if any.
+	failure *tuiFailure
+
+	// ask is a pending question from the background connect, answer what
+	// has been typed so far when it expects a typed answer.
+	ask    *askMsg
+	answer string
+
+	history []historyEntry
+	// rerun is the history entry picked for re-execution, if any.
+	rerun *historyEntry
+	// switchTo is the existing context picked offline, if any.
+	switchTo *switchTarget
+
+	// trail holds the pickers left for a later one, so esc can return to
+	// them with the cursor where it was.
+	trail []tuiFrame
+
+	// browsePath are the organization and folders drilled into in the
+	// browse step, nodes what they contain, and nodeCache the contents
+	// listed so far, by parent.
+	browsePath []gke.Node
+	nodes      []gke.Node
+	nodeCache  map[string][]gke.Node
+	browseErr  error
+}
+
+// tuiFailure is an error shown in place of the picker, with ways to retry
+// what failed and to go back.
+type tuiFailure struct {
+	what  string
+	err   error
+	retry func() tea.Cmd
+	// back leaves the error screen; nil when there is nowhere to go back
+	// to.
+	back func() tea.Cmd
+}
+
+// declinedMsg reports that the user declined a confirmation while
+// connecting; the picker goes back instead of showing an error.
+type declinedMsg struct {
+	back func() tea.Cmd
+}
+
+// fail shows err in place of the picker.
+func (m *model) fail(what string, err error, retry, back func() tea.Cmd) {
+	slog.Debug(what, "err", err)
+	m.loading = false
+	m.failure = &tuiFailure{what: what, err: err, retry: retry, back: back}
+}
+
+// handleFailure handles the keys of the error screen.
+func (m *model) handleFailure(msg tea.KeyMsg) tea.Cmd {
+	f := m.failure
+	switch msg.String() {
+	case "r", "enter":
+		m.failure = nil
+		return f.retry()
+	case "esc", "backspace":
+		if f.back != nil {
+			m.failure = nil
+			return f.back()
+		}
+	case "ctrl+c", "q":
+		m.err = f.err
+		return tea.Quit
+	}
+	return nil
+}
+
+// failureView renders the error screen.
+func (m *model) failureView() string {
+	f := m.failure
+	keys := []string{"r to retry"}
+	if f.back != nil {
+		keys = append(keys, "esc to go back")
+	}
+	keys = append(keys, "q to quit")
+	return fmt.Sprintf("\n❌ %s:\n\n   %v\n\n(press %s)\n", f.what, f.err, strings.Join(keys, ", "))
+}
+
+type tuiFrame struct {
+	step   string
+	cursor int
+	// choice is the label under the cursor, which is looked up again in
+	// case entries were added or removed meanwhile.
+	choice string
+	// browsePath is where the browse step was.
+	browsePath []gke.Node
+}
+
+func initialModel() model {
+	return model{
+		step: "project",
+	}
+}
+
+// pickableAccounts returns the credentialed gcloud accounts when there is
+// more than one to choose from and none was picked for this session.
+func pickableAccounts() []gcloudAccount {
+	if sessionAccount != "" || os.Getenv("CLOUDSDK_CORE_ACCOUNT") != "" || !hasGcloud() {
+		return nil
+	}
+	accounts, err := listGcloudAccounts()
+	if err != nil || len(accounts) < 2 {
+		return nil
+	}
+	return accounts
+}
+
+func (m *model) showConfigurations(configurations []gcloudConfiguration) {
+	m.step = "configuration"
+	m.configurations = configurations
+	m.choices = nil
+	m.cursor = 0
+	for i, c := range configurations {
+		m.choices = append(m.choices, configurationLabel(c))
+		if c.IsActive {
+			m.cursor = i
+		}
+	}
+}
+
+func (m *model) showAccounts(accounts []gcloudAccount, preferredAccount, preferredProject string) {
+	m.step = "account"
+	m.accounts = accounts
+	m.preferredProject = preferredProject
+	m.choices = nil
+	m.cursor = 0
+	for i, a := range accounts {
+		label := a.Account
+		if a.Status == "ACTIVE" {
+			label += " [active]"
+		}
+		m.choices = append(m.choices, label)
+		if a.Account == preferredAccount || (preferredAccount == "" && a.Status == "ACTIVE") {
+			m.cursor = i
+		}
+	}
+}
+
+// showProjects switches to the project picker, listing projects on first
+// use so that the identity chosen in earlier steps is the one listing them.
+func (m *model) showProjects(preferred string) {
+	if m.projects == nil {
+		projects, _, err := listProjectsCached(context.Background(), false)
+		if err != nil {
+			var back func() tea.Cmd
+			if len(m.trail) > 0 {
+				back = m.back
+			}
+			m.fail("Failed to list projects", err, func() tea.Cmd {
+				m.showProjects(preferred)
+				return nil
+			}, back)
+			return
+		}
+		m.projects = nil
+		for _, project := range projects {
+			m.projects = append(m.projects, project.ID)
+		}
+		m.projectRows = projectRows(projects)
+	}
+
+	st, _ := loadState()
+	m.favorites = st.Favorites
+	history, _ := loadHistory()
+	m.recent = nil
+	for _, ref := range recentClusters(history, maxRecent+len(m.favorites)) {
+		if !st.isFavorite(ref) && len(m.recent) < maxRecent {
+			m.recent = append(m.recent, ref)
+		}
+	}
+
+	m.step = "project"
+	m.choices = nil
+	for _, f := range m.favorites {
+		m.choices = append(m.choices, "★ "+f.String())
+	}
+	for _, r := range m.recent {
+		m.choices = append(m.choices, "↺ "+r.String())
+	}
+	if len(m.projectRows) == len(m.projects) {
+		m.choices = append(m.choices, m.projectRows...)
+	} else {
+		m.choices = append(m.choices, m.projects...)
+	}
+	m.cursor = 0
+	for i, project := range m.projects {
+		if project == preferred {
+			m.cursor = m.shortcuts() + i
+			break
+		}
+	}
+}
+
+// projectRows renders the projects as aligned rows of ID, display name when
+// it differs, and labels when the config file asks for them.
+func projectRows(projects []gke.Project) []string {
+	cfg, _ := loadUserConfig()
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, project := range projects {
+		name := project.Name
+		if name == project.ID {
+			name = ""
+		}
+		var labels []string
+		if cfg.ShowProjectLabels {
+			for key, value := range project.Labels {
+				labels = append(labels, key+"="+value)
+			}
+			sort.Strings(labels)
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\n", project.ID, name, strings.Join(labels, ","))
+	}
+	w.Flush()
+
+	var rows []string
+	for _, row := range strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n") {
+		rows = append(rows, strings.TrimRight(row, " "))
+	}
+	return rows
+}
+
+// showBrowse switches to the browse step, listing what the last node of
+// browsePath contains, or the organizations at the top.
+func (m *model) showBrowse() {
+	parent := ""
+	if len(m.browsePath) > 0 {
+		parent = m.browsePath[len(m.browsePath)-1].Name
+	}
+	if m.nodeCache == nil {
+		m.nodeCache = make(map[string][]gke.Node)
+	}
+	nodes, ok := m.nodeCache[parent]
+	m.browseErr = nil
+	if !ok {
+		var err error
+		if nodes, err = browseChildren(context.Background(), parent); err != nil {
+			m.browseErr = err
+		} else {
+			m.nodeCache[parent] = nodes
+		}
+	}
+
+	m.step = "browse"
+	m.nodes = nodes
+	m.choices = nil
+	m.cursor = 0
+	for _, node := range nodes {
+		var label string
+		switch node.Kind {
+		case gke.NodeOrganization:
+			label = "🏢 " + node.DisplayName
+		case gke.NodeFolder:
+			label = "📁 " + node.DisplayName
+		default:
+			label = "📦 " + node.Name
+			if node.DisplayName != "" && node.DisplayName != node.Name {
+				label += "  " + node.DisplayName
+			}
+		}
+		m.choices = append(m.choices, label)
+	}
+}
+
+// openProject lists the clusters of project in the background and switches
+// to them once they are in, showing a spinner meanwhile.
+func (m *model) openProject(project string) tea.Cmd {
+	m.projectID = project
+	m.push()
+	return m.loadClusters()
+}
+
+// loadClusters starts listing the clusters of m.projectID, served from the
+// cache when fresh.
+func (m *model) loadClusters() tea.Cmd {
+	m.step = "loading clusters"
+	m.choices = nil
+	m.cursor = 0
+	m.refreshGen++
+	gen, projectID := m.refreshGen, m.projectID
+	return tea.Batch(m.spinner.Tick, loadClustersCmd(gen, projectID))
+}
+
+// clustersLoaded shows the clusters listed by loadClusters. Cached clusters
+// are refreshed right away unless offline.
+func (m *model) clustersLoaded(msg clustersLoadedMsg) tea.Cmd {
+	msg.stale.apply()
+	if msg.err != nil {
+		m.fail("Failed to list the clusters of "+m.projectID, msg.err, m.loadClusters, m.back)
+		return nil
+	}
+	clusters := msg.clusters
+	m.showClusters(clusters)
+	m.clustersCached = msg.cached
+	if offline {
+		return nil
+	}
+	refresh := m.startClusterRefresh()
+	if msg.cached {
+		refresh = fetchClusters(m.refreshGen, m.projectID)
+	}
+	return tea.Batch(refresh, measureLatencies(clusters), checkUpgrades(m.projectID, clusters), checkPosture(m.projectID, clusters), checkCosts(m.projectID, clusters), checkInsights(m.projectID, clusters))
+}
+
+// refresh lists the projects or clusters shown again, bypassing the
+// cache. Offline, it first checks whether Google Cloud is back.
+func (m *model) refresh() tea.Cmd {
+	tryOnline()
+	switch m.step {
+	case "project":
+		projects, _, err := listProjectsCached(context.Background(), true)
+		if err != nil {
+			slog.Warn("failed to refresh projects", "err", err)
+			return nil
+		}
+		m.projects = nil
+		for _, project := range projects {
+			m.projects = append(m.projects, project.ID)
+		}
+		m.projectRows = projectRows(projects)
+		cursor := m.cursor
+		m.showProjects("")
+		if cursor < len(m.choices) {
+			m.cursor = cursor
+		}
+	case "cluster":
+		m.refreshGen++
+		return fetchClusters(m.refreshGen, m.projectID)
+	}
+	return nil
+}
+
+// shortcuts is the number of favorite and recent clusters listed above
+// the projects.
+func (m *model) shortcuts() int {
+	return len(m.favorites) + len(m.recent)
+}
+
+// shortcut returns the favorite or recent cluster at i in the project list.
+func (m *model) shortcut(i int) clusterRef {
+	if i < len(m.favorites) {
+		return m.favorites[i]
+	}
+	return m.recent[i-len(m.favorites)]
+}
+
+// toggleFavorite stars or unstars the cluster under the cursor, which may
+// be a favorite or recent one in the project list.
+func (m *model) toggleFavorite() {
+	var ref clusterRef
+	switch {
+	case m.step == "project" && m.cursor < m.shortcuts():
+		ref = m.shortcut(m.cursor)
+	case m.step == "cluster" && len(m.clusters) > 0:
+		cluster := m.clusters[m.cursor]
+		ref = clusterRef{Project: m.projectID, Location: cluster.Location, Cluster: cluster.Name}
+	default:
+		return
+	}
+	if _, err := toggleFavorite(ref); err != nil {
+		slog.Warn("failed to save favorites", "err", err)
+		return
+	}
+	if m.step == "cluster" {
+		m.choices = m.clusterLabels()
+		return
+	}
+	cursor := m.cursor
+	m.showProjects("")
+	if cursor >= len(m.choices) {
+		cursor = len(m.choices) - 1
+	}
+	m.cursor = cursor
+}
+
+// showHistory switches to the list of previous runs.
+func (m *model) showHistory() {
+	history, err := loadHistory()
+	if err != nil {
+		slog.Warn("failed to load history", "err", err)
+	}
+	m.step = "history"
+	m.history = history
+	m.choices = nil
+	m.cursor = 0
+	for _, h := range history {
+		m.choices = append(m.choices, h.label())
+	}
+}
+
+func (m *model) showClusters(clusters []*container.Cluster) {
+	m.step = "cluster"
+	cfg, _ := loadUserConfig()
+	m.hideNotRunning = cfg.HideNotRunning
+	m.hiddenClusters = make(map[string]bool)
+	m.allClusters = clusters
+	m.clusters = nil
+	for _, cluster := range clusters {
+		if !m.shows(cluster) {
+			m.hiddenClusters[clusterKey(cluster)] = true
+			continue
+		}
+		m.clusters = append(m.clusters, cluster)
+	}
+	sortClusters(m.clusters, m.sortKey)
+	m.clusterChanges = nil
+	m.choices = m.clusterLabels()
+	m.cursor = 0
+}
+
+// cycleSort sorts the clusters by the next key, keeping the cursor on the
+// cluster it was on.
+func (m *model) cycleSort() {
+	m.sortKey = nextSort(m.sortKey)
+	var selected *container.Cluster
+	if m.cursor < len(m.clusters) {
+		selected = m.clusters[m.cursor]
+	}
+	if m.sortKey == "" {
+		// Back to the listing's order, changes since kept in place.
+		order := make(map[string]int)
+		for i, cluster := range m.allClusters {
+			order[clusterKey(cluster)] = i
+		}
+		sort.SliceStable(m.clusters, func(i, j int) bool {
+			a, aok := order[clusterKey(m.clusters[i])]
+			b, bok := order[clusterKey(m.clusters[j])]
+			return aok && (!bok || a < b)
+		})
+	}
+	sortClusters(m.clusters, m.sortKey)
+	m.choices = m.clusterLabels()
+	for i, cluster := range m.clusters {
+		if cluster == selected {
+			m.cursor = i
+		}
+	}
+}
+
+// shows reports whether cluster passes the picker's filters.
+func (m *model) shows(cluster *container.Cluster) bool {
+	return (!m.hideNotRunning || clusterRunning(cluster)) && matchesLabels(cluster, m.labelFilter)
+}
+
+// push remembers the current picker before moving on to the next one.
+func (m *model) push() {
+	frame := tuiFrame{step: m.step, cursor: m.cursor, browsePath: m.browsePath}
+	if m.cursor < len(m.choices) {
+		frame.choice = m.choices[m.cursor]
+	}
+	m.trail = append(m.trail, frame)
+}
+
+// back returns to the previous picker, if any, with its cursor restored.
+// Going back past the project list forgets the projects, as another
+// configuration or account may see different ones.
+func (m *model) back() tea.Cmd {
+	if len(m.trail) == 0 {
+		return nil
+	}
+	frame := m.trail[len(m.trail)-1]
+	m.trail = m.trail[:len(m.trail)-1]
+	m.refreshGen++
+
+	var cmd tea.Cmd
+	switch frame.step {
+	case "configuration":
+		m.projects = nil
+		m.showConfigurations(m.configurations)
+	case "account":
+		m.projects = nil
+		m.showAccounts(m.accounts, "", m.preferredProject)
+	case "project":
+		m.showProjects("")
+	case "browse":
+		m.browsePath = frame.browsePath
+		m.showBrowse()
+	case "cluster":
+		m.step = "cluster"
+		m.choices = m.clusterLabels()
+		cmd = m.startClusterRefresh()
+	}
+	if frame.cursor < len(m.choices) {
+		m.cursor = frame.cursor
+	}
+	for i, choice := range m.choices {
+		if choice == frame.choice {
+			m.cursor = i
+			break
+		}
+	}
+	return cmd
+}
+
+func (m *model) clusterLabels() []string {
+	st, _ := loadState()
+	rows, columns := m.clusterRows()
+	var labels []string
+	for i, cluster := range m.clusters {
+		label := rows[i]
+		if !columns["type"] {
+			label += " " + badgeStyle.Render("["+newClusterInfo(m.projectID, cluster, "", nil).badge()+"]")
+		}
+		config := GKEConfig{ProjectID: m.projectID, Region: cluster.Location, Cluster: cluster.Name}
+		if st.isFavorite(clusterRef{Project: m.projectID, Location: cluster.Location, Cluster: cluster.Name}) {
+			label = "★ " + label
+		}
+		if namespace := st.namespace(contextName(config)); namespace != "" {
+			label += " (ns: " + namespace + ")"
+		}
+		if !columns["status"] && !clusterRunning(cluster) && m.clusterChanges[clusterKey(cluster)] != clusterChanged {
+			label += " [" + cluster.Status + "]"
+		}
+		if !columns["upgrade"] {
+			label += m.upgradeLabel(cluster)
+		}
+		if !columns["insights"] {
+			label += m.insightLabel(cluster)
+		}
+		if !columns["rtt"] {
+			label += m.latencyLabel(cluster)
+		}
+		labels = append(labels, m.decorateCluster(cluster, label))
+	}
+	return labels
+}
+
+// clusterRows renders the clusters as aligned rows of the columns picked in
+// the config, or as bare names when none are. It also returns the columns
+// shown.
+func (m *model) clusterRows() ([]string, map[string]bool) {
+	var rows []string
+	cfg, err := loadUserConfig()
+	if err != nil || len(cfg.Columns) == 0 {
+		// Padded so the type badges line up.
+		width := 0
+		for _, cluster := range m.clusters {
+			if len(cluster.Name) > width {
+				width = len(cluster.Name)
+			}
+		}
+		for _, cluster := range m.clusters {
+			rows = append(rows, fmt.Sprintf("%-*s", width, cluster.Name))
+		}
+		return rows, nil
+	}
+	columns, err := resolveColumns("")
+	if err != nil {
+		columns = defaultClusterColumns
+	}
+
+	var buf bytes.Buffer
+	w := tabwriter.NewWriter(&buf, 0, 4, 2, ' ', 0)
+	for _, cluster := range m.clusters {
+		info := newClusterInfo(m.projectID, cluster, "", m.latencies)
+		writeClusterRow(w, columns, &info)
+	}
+	w.Flush()
+	rows = strings.Split(strings.TrimSuffix(buf.String(), "\n"), "\n")
+
+	shown := make(map[string]bool)
+	for _, column := range columns {
+		shown[column] = true
+	}
+	return rows, shown
+}
+
+func (m *model) Init() tea.Cmd {
+	return nil
+}
+
+func (m *model) Update(msg tea.Msg) (tea.Model, tea.Cmd) {
+	switch msg := msg.(type) {
+	case tea.KeyMsg:
+		if m.ask != nil {
+			m.handleAnswer(msg)
+			return m, nil
+		}
+		if m.editingLabels {
+			m.editLabels(msg)
+			return m, nil
+		}
+		if m.failure != nil {
+			return m, m.handleFailure(msg)
+		}
+		// Nothing but quitting is possible while connecting, which goes on
+		// in the background.
+		if m.loading || m.step == "listing namespaces" {
+			if s := msg.String(); s == "ctrl+c" || s == "q" {
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.step == "status" {
+			switch msg.String() {
+			case "y":
+				m.preview = ""
+				return m, m.configure(m.pendingConfig, m.pendingCluster)
+			case "n", "enter", "esc", "backspace":
+				m.preview = ""
+				return m, m.back()
+			case "ctrl+c", "q":
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		if m.step == "preview" {
+			switch msg.String() {
+			case "y", "enter":
+				return m, m.connect(m.pendingConfig, m.pendingCluster)
+			case "n", "esc", "backspace":
+				m.preview = ""
+				return m, m.back()
+			case "ctrl+c", "q":
+				return m, tea.Quit
+			}
+			return m, nil
+		}
+		// gg jumps to the top, like in vim.
+		pendingG := m.pendingG
+		m.pendingG = false
+		switch msg.String() {
+		case "ctrl+c", "q":
+			return m, tea.Quit
+		case "*":
+			m.toggleFavorite()
+		case "r":
+			return m, m.refresh()
+		case "l":
+			if m.step == "cluster" {
+				m.editingLabels = true
+			}
+		case "s":
+			if m.step == "cluster" {
+				m.cycleSort()
+			}
+		case "n":
+			if m.step == "cluster" && m.cursor < len(m.clusters) && !m.clusterRemoved(m.cursor) {
+				m.refreshGen++
+				return m, m.showNodePools()
+			}
+		case "b":
+			if m.step == "project" {
+				m.push()
+				m.browsePath = nil
+				m.showBrowse()
+			}
+		case "h":
+			if m.step == "project" || m.step == "cluster" {
+				m.refreshGen++
+				m.push()
+				m.showHistory()
+			}
+		case "esc", "backspace":
+			if m.step == "namespace" {
+				printConnected(*m.connected)
+				return m, tea.Quit
+			}
+			return m, m.back()
+		case "up", "k":
+			if m.cursor > 0 {
+				m.cursor--
+			}
+		case "down", "j":
+			if m.cursor < len(m.choices)-1 {
+				m.cursor++
+			}
+		case "pgup":
+			m.page(false)
+		case "pgdown":
+			m.page(true)
+		case "home":
+			m.cursor = 0
+		case "g":
+			if pendingG {
+				m.cursor = 0
+			} else {
+				m.pendingG = true
+			}
+		case "end", "G":
+			if len(m.choices) > 0 {
+				m.cursor = len(m.choices) - 1
+			}
+		case "1", "2", "3", "4", "5", "6", "7", "8", "9":
+			// Digits pick one of the first nine choices outright.
+			i := int(msg.Runes[0] - '1')
+			if i >= len(m.choices) {
+				return m, nil
+			}
+			m.cursor = i
+			fallthrough
+		case "enter":
+			if m.step == "namespace" {
+				if err := useNamespace(*m.connected, m.namespaces[m.cursor]); err != nil {
+					slog.Warn("failed to set namespace", "err", err)
+				}
+				printConnected(*m.connected)
+				return m, tea.Quit
+			} else if m.step == "history" {
+				if len(m.history) > 0 {
+					m.rerun = &m.history[m.cursor]
+					return m, tea.Quit
+				}
+			} else if m.step == "configuration" {
+				selected := m.configurations[m.cursor]
+				useGcloudConfiguration(selected)
+				m.push()
+				if accounts := pickableAccounts(); accounts != nil {
+					m.showAccounts(accounts, selected.Properties.Core.Account, selected.Properties.Core.Project)
+				} else {
+					m.showProjects(selected.Properties.Core.Project)
+				}
+			} else if m.step == "account" {
+				useGcloudAccount(m.accounts[m.cursor].Account)
+				m.push()
+				m.showProjects(m.preferredProject)
+			} else if m.step == "project" && m.cursor < m.shortcuts() {
+				return m, m.openShortcut(m.shortcut(m.cursor))
+			} else if m.step == "project" {
+				return m, m.openProject(m.projects[m.cursor-m.shortcuts()])
+			} else if m.step == "browse" && len(m.nodes) > 0 {
+				node := m.nodes[m.cursor]
+				if node.Kind == gke.NodeProject {
+					return m, m.openProject(node.Name)
+				}
+				m.push()
+				m.browsePath = append(m.browsePath[:len(m.browsePath):len(m.browsePath)], node)
+				m.showBrowse()
+			} else if m.step == "cluster" {
+				if m.clusterRemoved(m.cursor) {
+					return m, nil
+				}
+				return m, m.pickCluster(m.clusters[m.cursor])
+			}
+		}
+	case tea.WindowSizeMsg:
+		m.width, m.height = msg.Width, msg.Height
+	case clustersLoadedMsg:
+		if m.step == "loading clusters" && msg.gen == m.refreshGen {
+			return m, m.clustersLoaded(msg)
+		}
+	case spinner.TickMsg:
+		if m.step == "loading clusters" {
+			var cmd tea.Cmd
+			m.spinner, cmd = m.spinner.Update(msg)
+			return m, cmd
+		}
+	case clusterRefreshTickMsg:
+		if m.step == "cluster" && msg.gen == m.refreshGen {
+			return m, fetchClusters(msg.gen, m.projectID)
+		}
+	case clustersRefreshedMsg:
+		if m.step != "cluster" || msg.gen != m.refreshGen {
+			return m, nil
+		}
+		if msg.err == nil {
+			m.allClusters = msg.clusters
+			m.mergeClusters(msg.clusters)
+			m.clustersCached = false
+		}
+		if goOffline(msg.err) || offline {
+			return m, nil
+		}
+		return m, scheduleClusterRefresh(msg.gen)
+	case upgradesMsg:
+		if msg.projectID == m.projectID {
+			m.upgrades = msg.upgrades
+			if m.step == "cluster" {
+				m.choices = m.clusterLabels()
+			}
+		}
+	case postureMsg:
+		if msg.projectID == m.projectID {
+			m.posture = msg.counts
+		}
+	case insightsMsg:
+		if msg.projectID == m.projectID {
+			m.insights = msg.insights
+			if m.step == "cluster" {
+				m.choices = m.clusterLabels()
+			}
+		}
+	case costsMsg:
+		if msg.projectID == m.projectID {
+			m.costs = msg.costs
+		}
+	case latenciesMsg:
+		m.latencies = msg
+		if m.step == "cluster" {
+			m.choices = m.clusterLabels()
+		}
+	case askMsg:
+		m.ask = &msg
+		m.answer = ""
+	case progressMsg:
+		m.progress = gke.Progress(msg)
+	case tuiFailure:
+		m.loading = false
+		m.failure = &msg
+	case declinedMsg:
+		m.loading = false
+		return m, msg.back()
+	case successMsg:
+		m.connected = &msg.config
+		if pickNamespace() {
+			m.loading = false
+			m.step = "listing namespaces"
+			m.choices = nil
+			return m, listNamespaces(msg.config)
+		}
+		printConnected(msg.config)
+		return m, tea.Quit
+	case nodePoolsMsg:
+		if m.step == "nodepools" && m.poolsOf == msg.cluster {
+			m.setNodePools(msg.pools)
+		}
+	case namespacesMsg:
+		if msg.err != nil || len(msg.namespaces) == 0 {
+			if msg.err != nil {
+				slog.Warn("not picking a namespace", "err", msg.err)
+			}
+			printConnected(*m.connected)
+			return m, tea.Quit
+		}
+		m.showNamespaces(msg.namespaces)
+	}
+	return m, nil
+}
+
+// openShortcut connects to a favorite or recent cluster.
+func (m *model) openShortcut(ref clusterRef) tea.Cmd {
+	if offline {
+		return m.switchOffline(ref)
+	}
+	config, cluster, err := resolveCluster(context.Background(), ref.Project, ref.Location, ref.Cluster)
+	if err != nil {
+		m.fail("Failed to find "+ref.String(), err, func() tea.Cmd {
+			return m.openShortcut(ref)
+		}, func() tea.Cmd { return nil })
+		return nil
+	}
+	m.projectID = config.ProjectID
+	return m.pickCluster(cluster)
+}
+
+// pickCluster connects to cluster of m.projectID, asking first when the
+// cluster isn't running.
+func (m *model) pickCluster(cluster *container.Cluster) tea.Cmd {
+	if offline {
+		return m.switchOffline(clusterRef{Project: m.projectID, Location: cluster.Location, Cluster: cluster.Name})
+	}
+	if m.clustersCached {
+		config := GKEConfig{ProjectID: m.projectID, Region: cluster.Location, Cluster: cluster.Name}
+		if fresh, err := getCluster(context.Background(), config); err == nil {
+			cluster = fresh
+		}
+	}
+
+	username, err := getUsername(context.Background())
+	if err != nil {
+		m.fail("Failed to get your username", err, func() tea.Cmd {
+			return m.pickCluster(cluster)
+		}, func() tea.Cmd { return nil })
+		return nil
+	}
+
+	config := GKEConfig{
+		ProjectID: m.projectID,
+		Region:    cluster.Location,
+		Cluster:   cluster.Name,
+		Username:  username,
+	}
+
+	if printCommands {
+		m.script, m.err = connectScript(config, cluster)
+		return tea.Quit
+	}
+
+	warning, queued := busyWarning(context.Background(), config, cluster)
+	m.queued = queued
+	if warning != "" {
+		m.confirm("status", warning, config, cluster)
+		return nil
+	}
+	return m.configure(config, cluster)
+}
+
+// confirm asks whether to go on connecting to cluster, text being what
+// the step shows. Declining goes back to the picker the cluster was picked
+// from, so only the first of several confirmations remembers it.
+func (m *model) confirm(step, text string, config GKEConfig, cluster *container.Cluster) {
+	if m.step != "status" && m.step != "preview" {
+		m.push()
+	}
+	m.step = step
+	m.pendingConfig = config
+	m.pendingCluster = cluster
+	m.preview = text
+}
+
+// configure connects to cluster, previewing the kubeconfig first in
+// careful mode.
+func (m *model) configure(config GKEConfig, cluster *container.Cluster) tea.Cmd {
+	if careful && !usesGcloudIdentity() {
+		m.confirm("preview", nativeKubeconfigPreview(config, cluster), config, cluster)
+		return nil
+	}
+	return m.connect(config, cluster)
+}
+
+// switchOffline picks the existing context of ref to switch to, since
+// connecting needs Google Cloud. The canonical context wins over aliases.
+func (m *model) switchOffline(ref clusterRef) tea.Cmd {
+	targets, err := switchTargets()
+	if err != nil {
+		m.err = err
+		return tea.Quit
+	}
+	canonical := contextName(GKEConfig{ProjectID: ref.Project, Region: ref.Location, Cluster: ref.Cluster})
+	for i, t := range targets {
+		if t.ref != ref {
+			continue
+		}
+		if m.switchTo == nil || t.context == canonical {
+			m.switchTo = &targets[i]
+		}
+	}
+	if m.switchTo == nil {
+		m.err = fmt.Errorf("%w: there is no context for %s to switch to", errOffline, ref)
+	}
+	return tea.Quit
+}
+
+// connect starts configuring access to cluster in the background, reporting
+// progress and the outcome back to the program as messages.
+func (m *model) connect(config GKEConfig, cluster *container.Cluster) tea.Cmd {
+	from := m.step
+	m.loading = true
+	m.step = "configuring"
+	// Going back after a failure returns to the picker connect came from.
+	back := func() tea.Cmd {
+		switch from {
+		case "status", "preview":
+			return m.back()
+		case "cluster":
+			m.step = "cluster"
+			m.choices = m.clusterLabels()
+			return m.startClusterRefresh()
+		}
+		m.step = from
+		return nil
+	}
+
+	// declined is set when the user answers no to one of the questions
+	// below, which aborts connecting.
+	var declined bool
+	confirmShrink = func(dropped []man.Entry) bool {
+		var s strings.Builder
+		s.WriteString("⚠️  Updating your entry would remove these entries, added since the list was read or left over as duplicates of yours:\n\n")
+		for _, entry := range dropped {
+			s.WriteString(fmt.Sprintf("  - %-30s %s\n", entry.DisplayName, entry.CIDR))
+		}
+		s.WriteString("\nRemove these entries anyway? (y/N)")
+		ok := m.askUser(s.String(), "")
+		declined = declined || !ok
+		return ok
+	}
+	confirmManaged = func(name string, own gke.Ownership) bool {
+		ok := m.askUser(fmt.Sprintf("⚠️  %s is %s. Changes made here may be reverted or cause drift.\n\n"+
+			"Type the cluster name to continue, esc to cancel:", name, own), name)
+		declined = declined || !ok
+		return ok
+	}
+
+	ctx := backgroundContext()
+	go func() {
+		start := time.Now()
+		onProgress := func(p gke.Progress) { m.program.Send(progressMsg(p)) }
+		err := setClusterCredentials(ctx, config, cluster, onProgress)
+		recordHistory(connectArgs(config), &clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}, start, err)
+		if err != nil && declined {
+			m.program.Send(declinedMsg{back: back})
+			return
+		}
+		if err != nil {
+			m.program.Send(tuiFailure{what: "Failed to connect to " + config.Cluster, err: err, retry: func() tea.Cmd {
+				m.step = from
+				return m.connect(config, cluster)
+			}, back: back})
+			return
+		}
+		m.program.Send(successMsg{cluster: cluster.Name, config: config})
+	}()
+
+	return nil
+}
+
+func (m *model) View() string {
+	if m.ask != nil {
+		view := "\n" + m.ask.text + "\n"
+		if m.ask.expect != "" {
+			view += "\n> " + m.answer + "\n"
+		}
+		return view
+	}
+	if m.failure != nil {
+		return m.failureView()
+	}
+	if m.loading {
+		if !m.progress.Known {
+			return "\n🔄 Configuring cluster access...\n"
+		}
+		view := "\n🔄 Configuring cluster access...\n\n" + m.bar.ViewAs(m.progress.Percent) + "\n"
+		if stage := humanizeStage(m.progress.Stage); stage != "" {
+			view += "   " + stage + "\n"
+		}
+		if m.queued {
+			view += "   ⏳ Queued behind the operation already running on the cluster\n"
+		}
+		return view
+	}
+
+	if m.step == "status" {
+		return "\n⚠️  " + m.preview + ".\n\nConnect anyway? (y/N)\n"
+	}
+	if m.step == "preview" {
+		return "\nThe following will be written to your kubeconfig:\n\n" + m.preview +
+			"\nWrite it? (y/n)\n"
+	}
+
+	var s strings.Builder
+	if len(safeMode) > 0 {
+		s.WriteString("🛟 Safe mode: some saved data was unreadable and is being ignored\n\n")
+	}
+	if offline {
+		s.WriteString("📴 Offline: ")
+		if !staleSince.IsZero() {
+			s.WriteString("listings are cached, the oldest from " + formatRelative(staleSince) + "; ")
+		}
+		s.WriteString("picking a cluster switches to its existing context without changing anything\n\n")
+	}
+	s.WriteString("Select using ↑/↓ arrows and enter to confirm\n\n")
+
+	if m.step == "configuration" {
+		s.WriteString("Choose a gcloud configuration:\n\n")
+	} else if m.step == "account" {
+		s.WriteString("Choose a gcloud account:\n\n")
+	} else if m.step == "project" && m.shortcuts() > 0 {
+		s.WriteString("Choose a favorite (★) or recent (↺) cluster, or a GCP project:\n\n")
+	} else if m.step == "project" {
+		s.WriteString("Choose a GCP project:\n\n")
+	} else if m.step == "browse" {
+		var path []string
+		for _, node := range m.browsePath {
+			path = append(path, node.DisplayName)
+		}
+		if len(path) == 0 {
+			s.WriteString("Choose an organization:\n\n")
+		} else {
+			s.WriteString(strings.Join(path, " › ") + ":\n\n")
+		}
+		if m.browseErr != nil {
+			s.WriteString(fmt.Sprintf("  ⚠️  %v\n", m.browseErr))
+		} else if len(m.choices) == 0 {
+			s.WriteString("  Nothing here\n")
+		}
+	} else if m.step == "loading clusters" {
+		return "\n" + m.spinner.View() + "Listing the clusters of " + m.projectID + "...\n\n(press esc to go back, q to quit)\n"
+	} else if m.step == "listing namespaces" {
+		return "\n🔄 Listing namespaces...\n"
+	} else if m.step == "namespace" {
+		s.WriteString("Choose a namespace for the context (esc to keep the current one):\n\n")
+	} else if m.step == "nodepools" {
+		s.WriteString("Node pools of " + m.poolsOf + ":\n\n    " + m.poolHeader + "\n")
+		if len(m.choices) == 0 {
+			s.WriteString("  No node pools\n")
+		}
+	} else if m.step == "history" {
+		s.WriteString("Previous runs (enter to re-run, esc to go back):\n\n")
+		if len(m.choices) == 0 {
+			s.WriteString("  No runs recorded yet\n")
+		}
+	} else {
+		var notes []string
+		if len(m.labelFilter) > 0 {
+			notes = append(notes, "labels "+formatLabelSelector(m.labelFilter))
+		}
+		if m.sortKey != "" {
+			notes = append(notes, "by "+m.sortKey)
+		}
+		if len(notes) > 0 {
+			s.WriteString("Choose a GKE cluster (" + strings.Join(notes, ", ") + "):\n\n")
+		} else {
+			s.WriteString("Choose a GKE cluster:\n\n")
+		}
+	}
+
+	// The footer is laid out first so the list gets the lines left over.
+	var footer strings.Builder
+	if m.step == "cluster" && len(m.hiddenClusters) > 0 {
+		footer.WriteString(fmt.Sprintf("\n  (%s hidden by the filters)\n", pluralize(len(m.hiddenClusters), "cluster")))
+	}
+	if m.editingLabels {
+		footer.WriteString("\nFilter by labels, e.g. env=prod,team (enter to apply, esc to cancel):\n> " + m.labelInput + "\n")
+		if m.labelErr != nil {
+			footer.WriteString(fmt.Sprintf("  ⚠️  %v\n", m.labelErr))
+		}
+	} else {
+		m.writeKeys(&footer)
+	}
+
+	if len(m.choices) > 0 {
+		rows := m.height - strings.Count(s.String(), "\n") - strings.Count(footer.String(), "\n") - 1
+		s.WriteString(m.withDetails(m.renderList(rows)) + "\n")
+	}
+	s.WriteString(footer.String())
+	return s.String()
+}
+
+// writeKeys writes the keys the current picker takes.
+func (m *model) writeKeys(s *strings.Builder) {
+
+	var keys []string
+	if len(m.trail) > 0 {
+		keys = append(keys, "esc to go back")
+	}
+	if m.step == "cluster" {
+		keys = append(keys, "* to star", "n for node pools", "l to filter by labels", "s to sort")
+	} else if m.step == "project" && m.shortcuts() > 0 {
+		keys = append(keys, "* to star or unstar")
+	}
+	if m.step == "project" || m.step == "cluster" {
+		keys = append(keys, "r to refresh")
+	}
+	if m.step == "project" {
+		keys = append(keys, "b to browse folders")
+	}
+	if m.step == "project" || m.step == "cluster" {
+		keys = append(keys, "h for history")
+	}
+	keys = append(keys, "q to quit")
+	s.WriteString("\n(press " + strings.Join(keys, ", ") + ")\n")
+}
+
+type progressMsg gke.Progress
+
+// askMsg puts a question from the background connect to the user; the
+// answer goes back on reply.
+type askMsg struct {
+	text string
+	// expect is the answer the user must type; empty means a y/N question.
+	expect string
+	reply  chan bool
+}
+
+// askUser shows a question and blocks until it is answered. It must not be
+// called from the program's own goroutine.
+func (m *model) askUser(text, expect string) bool {
+	reply := make(chan bool)
+	m.program.Send(askMsg{text: text, expect: expect, reply: reply})
+	return <-reply
+}
+
+func (m *model) handleAnswer(msg tea.KeyMsg) {
+	answer := func(ok bool) {
+		m.ask.reply <- ok
+		m.ask, m.answer = nil, ""
+	}
+
+	if m.ask.expect == "" {
+		switch msg.String() {
+		case "y":
+			answer(true)
+		case "n", "esc", "enter", "q", "ctrl+c":
+			answer(false)
+		}
+		return
+	}
+
+	switch msg.Type {
+	case tea.KeyEnter:
+		answer(m.answer == m.ask.expect)
+	case tea.KeyEsc, tea.KeyCtrlC:
+		answer(false)
+	case tea.KeyBackspace:
+		if len(m.answer) > 0 {
+			m.answer = m.answer[:len(m.answer)-1]
+		}
+	case tea.KeyRunes:
+		m.answer += string(msg.Runes)
+	}
+}
+
+// printConnected reports the connected cluster once the picker is done.
+func printConnected(config GKEConfig) {
+	fmt.Printf("\n✨ Successfully configured credentials for cluster: %s\n", config.Cluster)
+	fmt.Printf("🚀 You can now use kubectl to interact with the cluster\n")
+	fmt.Printf("📝 Current context: %s\n\n", config.Cluster)
+}
+
+type successMsg struct {
+	cluster string
+	config  GKEConfig
+}
+
+// humanizeStage turns API stage identifiers like "UPDATING_MASTER" into
+// something readable.
+func humanizeStage(stage string) string {
+	stage = strings.ReplaceAll(strings.ToLower(stage), "_", " ")
+	if stage == "" {
+		return ""
+	}
+	return strings.ToUpper(stage[:1]) + stage[1:]
+}
+
+type namespacesMsg struct {
+	namespaces []string
+	err        error
+}
+
+// listNamespaces asks the cluster just connected to for its namespaces.
+func listNamespaces(config GKEConfig) tea.Cmd {
+	return func() tea.Msg {
+		namespaces, err := kubeFor(config).Namespaces(contextName(config))
+		return namespacesMsg{namespaces: namespaces, err: err}
+	}
+}
+
+func (m *model) showNamespaces(namespaces []string) {
+	m.step = "namespace"
+	m.namespaces = namespaces
+	m.choices = namespaces
+	m.cursor = 0
+	current := kubeFor(*m.connected).ContextNamespace(contextName(*m.connected))
+	if current == "" {
+		current = "default"
+	}
+	for i, namespace := range namespaces {
+		if namespace == current {
+			m.cursor = i
+		}
+	}
+}
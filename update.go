@@ -0,0 +1,226 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+)
+
+// releasesURL is the GitHub API endpoint of the latest release.
+const releasesURL = "https://api.github.com/repos/bobphul/my-gke/releases/latest"
+
+// checksumsAsset is the release asset listing the SHA-256 of every binary
+// in `sha256sum` format.
+const checksumsAsset = "checksums.txt"
+
+type release struct {
+	TagName string         `json:"tag_name"`
+	Assets  []releaseAsset `json:"assets"`
+}
+
+type releaseAsset struct {
+	Name string `json:"name"`
+	URL  string `json:"browser_download_url"`
+}
+
+func (r release) asset(name string) (releaseAsset, bool) {
+	for _, asset := range r.Assets {
+		if asset.Name == name {
+			return asset, true
+		}
+	}
+	return releaseAsset{}, false
+}
+
+// binaryAsset is the release asset name of the binary for this platform,
+// e.g. gke_linux_amd64 or gke_windows_amd64.exe.
+func binaryAsset() string {
+	name := "gke_" + runtime.GOOS + "_" + runtime.GOARCH
+	if runtime.GOOS == "windows" {
+		name += ".exe"
+	}
+	return name
+}
+
+func newUpdateCmd() *cobra.Command {
+	var check, yes, force bool
+	cmd := &cobra.Command{
+		Use:   "update",
+		Short: "Replace this binary with the latest release",
+		Long: `Checks the latest GitHub release and, if it is newer, downloads the binary
+for this platform, verifies it against the release's checksums and replaces
+the running executable with it.`,
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runUpdate(cmd.Context(), check, yes, force)
+		}),
+	}
+	cmd.Flags().BoolVar(&check, "check", false, "only report whether a newer release exists")
+	cmd.Flags().BoolVar(&yes, "yes", false, "update without asking for confirmation")
+	cmd.Flags().BoolVar(&force, "force", false, "install the latest release even if it isn't newer, or this is a development build")
+	return cmd
+}
+
+func runUpdate(ctx context.Context, check, yes, force bool) error {
+	client := &http.Client{Timeout: 5 * time.Minute}
+	current := currentBuild().Version
+
+	var latest release
+	if err := getJSON(ctx, client, releasesURL, &latest); err != nil {
+		return fmt.Errorf("failed to check for releases: %v", err)
+	}
+	switch {
+	case current == "dev" && !force:
+		fmt.Printf("ℹ️  This is a development build; the latest release is %s. Pass --force to install it.\n", latest.TagName)
+		return nil
+	case !newerVersion(latest.TagName, current) && !force:
+		fmt.Printf("✅ gke %s is the latest release\n", current)
+		return nil
+	}
+	fmt.Printf("⬆️  gke %s is available (you have %s)\n", latest.TagName, current)
+	if check {
+		return nil
+	}
+
+	binary, ok := latest.asset(binaryAsset())
+	if !ok {
+		return fmt.Errorf("release %s has no binary for %s/%s", latest.TagName, runtime.GOOS, runtime.GOARCH)
+	}
+	sums, ok := latest.asset(checksumsAsset)
+	if !ok {
+		return fmt.Errorf("release %s has no %s; refusing to install an unverified binary", latest.TagName, checksumsAsset)
+	}
+
+	self, err := os.Executable()
+	if err != nil {
+		return err
+	}
+	if self, err = filepath.EvalSymlinks(self); err != nil {
+		return err
+	}
+	req := newApproval("update", fmt.Sprintf("Replace %s with %s?", self, latest.TagName), nil, []string{latest.TagName})
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	want, err := releaseChecksum(ctx, client, sums.URL, binary.Name)
+	if err != nil {
+		return err
+	}
+
+	// Download next to the executable so the final rename stays on one
+	// file system.
+	tmp, err := os.CreateTemp(filepath.Dir(self), ".gke-update-")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %v", err)
+	}
+	defer os.Remove(tmp.Name())
+
+	fmt.Printf("📥 Downloading %s...\n", binary.Name)
+	got, err := download(ctx, client, binary.URL, tmp)
+	tmp.Close()
+	if err != nil {
+		return fmt.Errorf("failed to download %s: %v", binary.Name, err)
+	}
+	if got != want {
+		return fmt.Errorf("checksum mismatch for %s: expected %s, got %s", binary.Name, want, got)
+	}
+	if err := os.Chmod(tmp.Name(), 0o755); err != nil {
+		return err
+	}
+
+	// Windows can't replace a running executable, but it can rename it.
+	old := self + ".old"
+	os.Remove(old)
+	if err := os.Rename(self, old); err != nil {
+		return fmt.Errorf("failed to replace %s: %v", self, err)
+	}
+	if err := os.Rename(tmp.Name(), self); err != nil {
+		os.Rename(old, self)
+		return fmt.Errorf("failed to replace %s: %v", self, err)
+	}
+	os.Remove(old)
+
+	fmt.Printf("✨ Updated to %s (sha256 %s verified)\n", latest.TagName, got[:12])
+	return nil
+}
+
+func getJSON(ctx context.Context, client *http.Client, url string, v interface{}) error {
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	return json.NewDecoder(resp.Body).Decode(v)
+}
+
+// get fetches url, authenticating to GitHub with GITHUB_TOKEN when set to
+// avoid the anonymous rate limit.
+func get(ctx context.Context, client *http.Client, url string) (*http.Response, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, err
+	}
+	if token := os.Getenv("GITHUB_TOKEN"); token != "" && strings.HasPrefix(url, "https://api.github.com/") {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("GET %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// releaseChecksum returns the SHA-256 listed for name in the checksums file
+// at url.
+func releaseChecksum(ctx context.Context, client *http.Client, url, name string) (string, error) {
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return "", fmt.Errorf("failed to download %s: %v", checksumsAsset, err)
+	}
+	defer resp.Body.Close()
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) == 2 && strings.TrimPrefix(fields[1], "*") == name {
+			return strings.ToLower(fields[0]), nil
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return "", fmt.Errorf("failed to read %s: %v", checksumsAsset, err)
+	}
+	return "", fmt.Errorf("%s has no checksum for %s", checksumsAsset, name)
+}
+
+// download writes url to w and returns the hex SHA-256 of what it wrote.
+func download(ctx context.Context, client *http.Client, url string, w io.Writer) (string, error) {
+	resp, err := get(ctx, client, url)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(io.MultiWriter(w, h), resp.Body); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
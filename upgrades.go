@@ -0,0 +1,270 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+
+	tea "github.com/charmbracelet/bubbletea"
+	"github.com/charmbracelet/lipgloss"
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+	"golang.org/x/term"
+	"google.golang.org/api/container/v1"
+)
+
+// upgradeStyle marks clusters with a control-plane upgrade available.
+var upgradeStyle = lipgloss.NewStyle().Foreground(lipgloss.Color("214"))
+
+// clusterUpgrades are the control-plane upgrades of a cluster.
+type clusterUpgrades struct {
+	// Available is the newest version the cluster can be upgraded to.
+	Available string
+	// Auto is the version GKE will upgrade it to on its own, if any.
+	Auto string
+}
+
+// availableUpgrades returns the newest control-plane version each cluster
+// of projectID can be upgraded to and the version it will be auto-upgraded
+// to, keyed by clusterKey. Clusters that are current are left out. The
+// server config of each location is fetched once, concurrently; locations
+// whose config can't be fetched are skipped.
+func availableUpgrades(ctx context.Context, projectID string, clusters []*container.Cluster) map[string]clusterUpgrades {
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		slog.Debug("not checking for upgrades", "err", err)
+		return nil
+	}
+	var locations []string
+	for _, cluster := range clusters {
+		locations = append(locations, cluster.Location)
+	}
+
+	configs := make(map[string]*container.ServerConfig)
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for _, location := range unique(locations) {
+		wg.Add(1)
+		go func(location string) {
+			defer wg.Done()
+			config, err := api.GetServerConfig(ctx, fmt.Sprintf("projects/%s/locations/%s", projectID, location))
+			if err != nil {
+				slog.Debug("failed to get server config", "project", projectID, "location", location, "err", err)
+				return
+			}
+			mu.Lock()
+			configs[location] = config
+			mu.Unlock()
+		}(location)
+	}
+	wg.Wait()
+
+	upgrades := make(map[string]clusterUpgrades)
+	for _, cluster := range clusters {
+		config := configs[cluster.Location]
+		u := clusterUpgrades{Available: gke.AvailableUpgrade(cluster, config), Auto: gke.AutoUpgradeTarget(cluster, config)}
+		if u.Available != "" {
+			upgrades[clusterKey(cluster)] = u
+		}
+	}
+	return upgrades
+}
+
+// upgradesMsg delivers the available upgrades to the cluster list.
+type upgradesMsg struct {
+	projectID string
+	upgrades  map[string]clusterUpgrades
+}
+
+// checkUpgrades looks for available upgrades in the background.
+func checkUpgrades(projectID string, clusters []*container.Cluster) tea.Cmd {
+	ctx := backgroundContext()
+	return func() tea.Msg {
+		return upgradesMsg{projectID: projectID, upgrades: availableUpgrades(ctx, projectID, clusters)}
+	}
+}
+
+// upgradeLabel is the upgrade suffix of a cluster in the list: the newest
+// version available and, to warn of nodes recycling, any upgrade GKE will
+// run on its own.
+func (m *model) upgradeLabel(cluster *container.Cluster) string {
+	u := m.upgrades[clusterKey(cluster)]
+	label := ""
+	if u.Available != "" {
+		label += " " + upgradeStyle.Render("⬆ "+u.Available)
+	}
+	if u.Auto != "" {
+		label += " " + upgradeStyle.Render("⏰ auto "+u.Auto)
+	} else if scheduled := gke.ScheduledUpgrades(cluster); len(scheduled) > 0 {
+		label += " " + upgradeStyle.Render("⏰ nodes "+scheduled[0].Start.Local().Format("Mon 15:04"))
+	}
+	return label
+}
+
+// defaultProduction are the label selectors marking production clusters
+// when the config has none.
+var defaultProduction = []string{"env=prod", "env=production", "environment=prod", "environment=production"}
+
+// isProduction reports whether cluster carries the labels of a production
+// cluster, and which.
+func isProduction(cluster *container.Cluster) (string, bool, error) {
+	selectors := defaultProduction
+	if cfg, err := loadUserConfig(); err == nil && len(cfg.Production) > 0 {
+		selectors = cfg.Production
+	}
+	for _, s := range selectors {
+		selector, err := parseLabelSelector(s)
+		if err != nil {
+			return "", false, fmt.Errorf("invalid production selector in config: %w", err)
+		}
+		if len(selector) > 0 && matchesLabels(cluster, selector) {
+			return s, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+func newUpgradeCmd() *cobra.Command {
+	var target targetOptions
+	var version, confirmName string
+	var yes bool
+	cmd := &cobra.Command{
+		Use:   "upgrade",
+		Short: "Upgrade a cluster's control plane to an available version",
+		Long: `Upgrades the control plane of a cluster to one of the versions GKE offers
+for it, newer than the current one and, for clusters on a release channel,
+from that channel. Without --version the versions are listed to pick from.
+Node pools are not upgraded.
+
+The checks of other changes apply: externally managed clusters need
+--allow-managed or the cluster name typed, preChange hooks run with
+MY_GKE_ACTION=upgrade, and the upgrade needs confirmation. Production
+clusters, those matching the config's production label selectors, also
+need their name typed, or passed with --confirm in scripts.`,
+		Example: `  gke upgrade --project my-project --cluster dev
+  gke upgrade --project my-project --cluster prod --version 1.28.5-gke.1217000 --confirm prod --yes`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runUpgrade(cmd.Context(), target, version, confirmName, yes)
+		}),
+	}
+	target.addFlags(cmd)
+	cmd.Flags().StringVar(&version, "version", "", "control-plane version to upgrade to")
+	cmd.Flags().StringVar(&confirmName, "confirm", "", "the cluster's name, to upgrade a production cluster without typing it")
+	cmd.Flags().BoolVar(&yes, "yes", false, "upgrade without asking for confirmation")
+	return cmd
+}
+
+func runUpgrade(ctx context.Context, target targetOptions, version, confirmName string, yes bool) error {
+	config, cluster, err := resolveCluster(ctx, target.project, target.location, target.cluster)
+	if err != nil {
+		return err
+	}
+	api, err := clusterAPI(ctx)
+	if err != nil {
+		return err
+	}
+	serverConfig, err := api.GetServerConfig(ctx, fmt.Sprintf("projects/%s/locations/%s", config.ProjectID, config.Region))
+	if err != nil {
+		return fmt.Errorf("failed to get the versions offered in %s: %v", config.Region, err)
+	}
+	versions := gke.UpgradeVersions(cluster, serverConfig)
+	if len(versions) == 0 {
+		fmt.Printf("✅ %s runs %s, the newest version available to it\n", config.Cluster, cluster.CurrentMasterVersion)
+		return nil
+	}
+	if version == "" {
+		if !term.IsTerminal(int(os.Stdin.Fd())) {
+			return usageError{fmt.Errorf("no version given; pass --version with one of %s", strings.Join(versions, ", "))}
+		}
+		version = chooseVersion(cluster, versions)
+	}
+	offered := false
+	for _, v := range versions {
+		offered = offered || v == version
+	}
+	if !offered {
+		return fmt.Errorf("%s can't be upgraded to %s; available: %s", config.Cluster, version, strings.Join(versions, ", "))
+	}
+
+	fmt.Printf("Upgrade the control plane of %s from %s to %s\n", config.Cluster, cluster.CurrentMasterVersion, version)
+	if channel := gke.ReleaseChannel(cluster); channel != "" {
+		fmt.Printf("ℹ️  %s is on the %s channel, which will upgrade its node pools automatically\n", config.Cluster, channelName(channel))
+	} else {
+		fmt.Printf("ℹ️  Node pools stay on their versions until upgraded separately\n")
+	}
+	if warning := statusWarning(cluster); warning != "" {
+		fmt.Printf("⚠️  %s\n", warning)
+	}
+	fmt.Println()
+
+	if err := checkOwnership(ctx, config, cluster); err != nil {
+		return err
+	}
+	if err := runPreChangeHooks(ctx, config, "upgrade"); err != nil {
+		return err
+	}
+	selector, production, err := isProduction(cluster)
+	if err != nil {
+		return err
+	}
+	if production && confirmName != config.Cluster && !promptProduction(config.Cluster, selector) {
+		return fmt.Errorf("%w: %s is a production cluster (%s); type its name or pass --confirm %s", errAborted, config.Cluster, selector, config.Cluster)
+	}
+	ref := clusterRef{Project: config.ProjectID, Location: config.Region, Cluster: config.Cluster}
+	change := fmt.Sprintf("control plane: %s → %s", cluster.CurrentMasterVersion, version)
+	req := newApproval("upgrade", "Upgrade it?", []string{ref.String()}, []string{change})
+	if err := approve(ctx, yes, req); err != nil {
+		return err
+	}
+
+	fmt.Printf("📡 Upgrading %s to %s; this usually takes 10 to 30 minutes...\n", config.Cluster, version)
+	if err := followOperation(func(onProgress func(gke.Progress)) error {
+		return gke.UpgradeMaster(ctx, api, config.target(), version, onProgress)
+	}); err != nil {
+		return err
+	}
+	fmt.Printf("✨ Upgraded the control plane of %s to %s\n", config.Cluster, version)
+	return nil
+}
+
+// chooseVersion asks which of versions to upgrade cluster to, defaulting to
+// the newest.
+func chooseVersion(cluster *container.Cluster, versions []string) string {
+	fmt.Printf("⬆  %s runs %s and can be upgraded to:\n", cluster.Name, cluster.CurrentMasterVersion)
+	for i, v := range versions {
+		fmt.Printf("  %d) %s\n", i+1, v)
+	}
+	for {
+		fmt.Printf("Upgrade to which version? [1]: ")
+		answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+		answer = strings.TrimSpace(answer)
+		if err != nil || answer == "" {
+			return versions[0]
+		}
+		if n, err := strconv.Atoi(answer); err == nil && n >= 1 && n <= len(versions) {
+			return versions[n-1]
+		}
+		fmt.Printf("Enter a number from 1 to %d\n", len(versions))
+	}
+}
+
+// promptProduction asks the user to type the name of a production cluster.
+// Without a terminal it refuses.
+func promptProduction(name, selector string) bool {
+	if !term.IsTerminal(int(os.Stdin.Fd())) {
+		return false
+	}
+	promptMu.Lock()
+	defer promptMu.Unlock()
+
+	fmt.Printf("\n🚨 %s is a production cluster (%s). A control-plane upgrade can't be undone.\n", name, selector)
+	fmt.Printf("Type the cluster name to continue: ")
+	answer, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	return err == nil && strings.TrimSpace(answer) == name
+}
@@ -0,0 +1,125 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/cobra"
+)
+
+// Release builds set these with
+//
+//	-ldflags "-X main.version=v1.2.3 -X main.commit=$(git rev-parse HEAD) -X main.date=$(date -u +%FT%TZ)"
+//
+// Other builds fall back to what the Go toolchain recorded.
+var (
+	version = "dev"
+	commit  = ""
+	date    = ""
+)
+
+// pseudoVersion matches the timestamp and commit part of Go module
+// pseudo-versions such as v0.0.0-20240102150405-abcdef123456.
+var pseudoVersion = regexp.MustCompile(`\d{14}-[0-9a-f]{12}`)
+
+// buildInfo is what `gke version` reports.
+type buildInfo struct {
+	Version   string `json:"version" yaml:"version"`
+	Commit    string `json:"commit,omitempty" yaml:"commit,omitempty"`
+	Date      string `json:"date,omitempty" yaml:"date,omitempty"`
+	GoVersion string `json:"goVersion" yaml:"goVersion"`
+	Platform  string `json:"platform" yaml:"platform"`
+}
+
+func currentBuild() buildInfo {
+	info := buildInfo{
+		Version:   version,
+		Commit:    commit,
+		Date:      date,
+		GoVersion: runtime.Version(),
+		Platform:  runtime.GOOS + "/" + runtime.GOARCH,
+	}
+	bi, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+	// `go install ...@v1.2.3` records the module version, and builds from
+	// a checkout record the commit. Pseudo-versions of untagged commits
+	// stay "dev".
+	if info.Version == "dev" && bi.Main.Version != "" && bi.Main.Version != "(devel)" && !pseudoVersion.MatchString(bi.Main.Version) {
+		info.Version = bi.Main.Version
+	}
+	for _, setting := range bi.Settings {
+		switch {
+		case setting.Key == "vcs.revision" && info.Commit == "":
+			info.Commit = setting.Value
+		case setting.Key == "vcs.time" && info.Date == "":
+			info.Date = setting.Value
+		}
+	}
+	return info
+}
+
+func newVersionCmd() *cobra.Command {
+	var output string
+	cmd := &cobra.Command{
+		Use:         "version",
+		Short:       "Print the version, commit and build date",
+		Args:        cobra.NoArgs,
+		Annotations: map[string]string{skipAuth: "true"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info := currentBuild()
+			if output == "" || output == "table" {
+				line := "gke " + info.Version
+				if info.Commit != "" {
+					line += " (commit " + shortCommit(info.Commit)
+					if info.Date != "" {
+						line += ", built " + info.Date
+					}
+					line += ")"
+				}
+				fmt.Printf("%s\n%s %s\n", line, info.GoVersion, info.Platform)
+				return nil
+			}
+			return writeOutput(os.Stdout, output, info, nil)
+		},
+	}
+	cmd.Flags().StringVarP(&output, "output", "o", "", "output format: json or yaml")
+	return cmd
+}
+
+func shortCommit(commit string) string {
+	if len(commit) > 12 {
+		return commit[:12]
+	}
+	return commit
+}
+
+// newerVersion reports whether semantic version a is newer than b. Leading
+// "v"s and pre-release or build suffixes are ignored.
+func newerVersion(a, b string) bool {
+	pa, pb := versionParts(a), versionParts(b)
+	for i := range pa {
+		if pa[i] != pb[i] {
+			return pa[i] > pb[i]
+		}
+	}
+	return false
+}
+
+func versionParts(v string) [3]int {
+	v = strings.TrimPrefix(v, "v")
+	if i := strings.IndexAny(v, "-+"); i >= 0 {
+		v = v[:i]
+	}
+	var parts [3]int
+	for i, field := range strings.SplitN(v, ".", 3) {
+		parts[i], _ = strconv.Atoi(field)
+	}
+	return parts
+}
@@ -0,0 +1,118 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/spf13/cobra"
+	"gke-tool/pkg/gke"
+	"golang.org/x/exp/slog"
+)
+
+func newWatchCmd() *cobra.Command {
+	var interval time.Duration
+	var debugAddr string
+	cmd := &cobra.Command{
+		Use:   "watch",
+		Short: "Follow public IP changes on the pinned clusters",
+		Long: `Re-detects your public IP periodically and, whenever it changes, updates
+your authorized network entry on every pinned cluster. Expired time-boxed
+access is removed on every tick.`,
+		Args: cobra.NoArgs,
+		RunE: recorded(func(cmd *cobra.Command, args []string) error {
+			return runWatch(cmd.Context(), interval, debugAddr)
+		}),
+	}
+	cmd.Flags().DurationVar(&interval, "interval", time.Minute, "how often to re-detect the public IP")
+	addDebugFlag(cmd, &debugAddr)
+	return cmd
+}
+
+// runWatch re-detects the public IP periodically and, whenever it changes,
+// updates the user's authorized network entry on every pinned cluster.
+func runWatch(ctx context.Context, interval time.Duration, debugAddr string) error {
+
+	cfg, err := loadUserConfig()
+	if err != nil {
+		return err
+	}
+	if len(cfg.Pinned) == 0 {
+		return fmt.Errorf("no pinned clusters in the config file")
+	}
+
+	username, err := getUsername(ctx)
+	if err != nil {
+		return err
+	}
+
+	ctx, stop := signal.NotifyContext(ctx, os.Interrupt, syscall.SIGTERM)
+	defer stop()
+	if err := startDebugServer(ctx, debugAddr); err != nil {
+		return err
+	}
+
+	slog.Info("watching public IP", "interval", interval, "clusters", len(cfg.Pinned))
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	var last string
+	for {
+		if err := expireGrants(ctx, infof); err != nil {
+			slog.Warn("failed to expire grants", "err", err)
+		}
+
+		entry, err := myEntry(username)
+		switch {
+		case err != nil:
+			slog.Warn("failed to detect public IP", "err", err)
+		case entry.CIDR != last:
+			if last == "" {
+				slog.Info("current network", "cidr", entry.CIDR)
+			} else {
+				slog.Info("network changed", "from", last, "to", entry.CIDR)
+			}
+			if reconcilePinned(ctx, cfg.Pinned, username) {
+				last = entry.CIDR
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			slog.Info("stopping")
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// reconcilePinned updates the authorized network entry on every pinned
+// cluster and reports whether all of them succeeded. Failed clusters are
+// retried on the next tick.
+func reconcilePinned(ctx context.Context, pinned []clusterRef, username string) bool {
+	ok := true
+	for _, ref := range pinned {
+		debugReconciliations.Add(1)
+		start := time.Now()
+		config, cluster, err := resolveCluster(ctx, ref.Project, ref.Location, ref.Cluster)
+		if err == nil && !gke.HasAuthorizedNetworks(cluster) {
+			slog.Info("authorized networks not enabled, skipping", "cluster", ref)
+			continue
+		}
+		if err == nil {
+			config.Username = username
+			err = updateAuthorizedNetworks(ctx, config, cluster, nil)
+		}
+		if err != nil {
+			ok = false
+			slog.Error("reconcile failed", "cluster", ref, "err", err)
+			continue
+		}
+		slog.Info("reconciled", "cluster", ref, "took", time.Since(start).Round(time.Second))
+	}
+	return ok
+}